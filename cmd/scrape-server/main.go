@@ -17,14 +17,18 @@ import (
 	"time"
 
 	"github.com/efixler/envflags"
+	"github.com/efixler/scrape/database"
 	"github.com/efixler/scrape/fetch"
 	"github.com/efixler/scrape/fetch/trafilatura"
 	"github.com/efixler/scrape/internal"
 	"github.com/efixler/scrape/internal/auth"
+	"github.com/efixler/scrape/internal/cache"
 	"github.com/efixler/scrape/internal/cmd"
 	"github.com/efixler/scrape/internal/headless"
 	"github.com/efixler/scrape/internal/server"
 	"github.com/efixler/scrape/internal/server/api"
+	"github.com/efixler/scrape/internal/server/healthchecks"
+	"github.com/efixler/scrape/internal/settings"
 	"github.com/efixler/scrape/internal/storage"
 	"github.com/efixler/scrape/resource"
 	"github.com/efixler/scrape/ua"
@@ -42,10 +46,27 @@ var (
 	signingKey      *envflags.Value[*auth.HMACBase64Key]
 	ttl             *envflags.Value[time.Duration]
 	userAgent       *envflags.Value[*ua.UserAgent]
+	maxRedirects    *envflags.Value[int]
+	redirectPolicy  *envflags.Value[*fetch.RedirectPolicy]
+	resolveVariants *envflags.Value[bool]
 	dbFlags         *cmd.DatabaseFlags
+	urlFlags        *cmd.URLFlags
 	headlessEnabled *envflags.Value[bool]
+	headlessBlock   *envflags.Value[*fetch.BlockList]
+	headlessTabs    *envflags.Value[int]
+	pageTimeout     *envflags.Value[time.Duration]
+	maxPages        *envflags.Value[int]
+	maxMemoryMB     *envflags.Value[int]
 	profile         *envflags.Value[bool]
 	publicHome      *envflags.Value[bool]
+	historyVersions *envflags.Value[int]
+	historyTTL      *envflags.Value[time.Duration]
+	archiveTTL      *envflags.Value[time.Duration]
+	renderTTL       *envflags.Value[time.Duration]
+	renderMaxMB     *envflags.Value[int]
+	maxBodyMB       *envflags.Value[int]
+	cacheMB         *envflags.Value[int]
+	cacheMaxAge     *envflags.Value[time.Duration]
 	logWriter       io.Writer
 )
 
@@ -59,25 +80,128 @@ func main() {
 		slog.Error("scrape-server error opening database", "database", dbh, "error", err)
 		os.Exit(1)
 	}
+	// Urls are normalized with the global rules and the rules for their domains. The
+	// api saves settings through the same store, so that the cached rules are cleared.
+	domainSettings := settings.NewDomainSettingsStorage(dbh)
+	normalizer := urlFlags.Normalizer(domainSettings.URLRules)
 
-	directClient := fetch.MustClient(fetch.WithUserAgent(userAgent.Get().String()))
-	var headlessFetcher fetch.URLFetcher = nil
-	if headlessEnabled.Get() {
-		headlessClient := headless.MustChromeClient(ctx, userAgent.Get().String(), 6)
-		headlessFetcher = trafilatura.MustNew(headlessClient)
+	var (
+		fetcherOptions = []trafilatura.Option{trafilatura.WithMaxBodySize(int64(maxBodyMB.Get()) * 1024 * 1024)}
+		archive        *storage.ArchiveStore
+	)
+	if archiveTTL.Get() > 0 {
+		archive = storage.NewArchiveStore(dbh, archiveTTL.Get())
+		fetcherOptions = append(fetcherOptions, trafilatura.WithArchiver(archive))
+		dbh.Maintenance(time.Hour, pruneArchive(archive))
+		slog.Info("scrape-server raw response archiving is enabled", "ttl", archiveTTL.Get())
+	}
+	if resolveVariants.Get() {
+		fetcherOptions = append(fetcherOptions, trafilatura.WithVariantResolution(normalizer))
 	}
 
-	sbf := internal.NewStorageBackedFetcher(
-		trafilatura.MustNew(directClient),
-		storage.NewURLDataStore(dbh),
+	// Both clients send and save cookies from the per-domain cookie store
+	cookies := settings.NewCookieStorage(dbh)
+	directClient := fetch.MustClient(
+		fetch.WithUserAgent(userAgent.Get().String()),
+		fetch.WithCookieJar(cookies),
+		fetch.WithMaxRedirects(maxRedirects.Get()),
+		fetch.WithRedirectPolicy(*redirectPolicy.Get()),
 	)
+	directFetcher := trafilatura.MustNew(directClient, fetcherOptions...)
+
+	urlStore := storage.NewURLDataStore(
+		dbh,
+		storage.WithHistory(historyVersions.Get(), historyTTL.Get()),
+	)
+	var (
+		fetchStore internal.URLStore = urlStore
+		observers                    = map[string]healthchecks.Observer{"storage": storage.Stats}
+		pageCache  *cache.Store
+	)
+	if cacheMB.Get() > 0 {
+		pageCache = cache.MustNew(urlStore, int64(cacheMB.Get())*1024*1024, cache.WithMaxAge(cacheMaxAge.Get()))
+		fetchStore = pageCache
+		observers["cache"] = pageCache.Stats
+		slog.Info("scrape-server page cache is enabled", "size_mb", cacheMB.Get())
+	}
+	sbf := internal.NewStorageBackedFetcher(directFetcher, fetchStore)
+	sbf.Normalizer = normalizer
+	// Headless and auto fetches use the same storage as direct fetches
+	var (
+		headlessFetcher, autoFetcher fetch.URLFetcher
+		renderer                     *internal.StorageBackedRenderer
+	)
+	if headlessEnabled.Get() {
+		headlessClient, err := headless.NewChromeClient(
+			ctx,
+			userAgent.Get().String(),
+			headlessTabs.Get(),
+			headless.WithDomainOptions(domainSettings.HeadlessOptions),
+			headless.WithBlockList(*headlessBlock.Get()),
+			headless.WithCookieJar(cookies),
+			headless.WithPageTimeout(pageTimeout.Get()),
+			headless.WithMaxPages(maxPages.Get()),
+			headless.WithMaxMemory(int64(maxMemoryMB.Get())*1024*1024),
+		)
+		if err != nil {
+			slog.Error("scrape-server error initializing the headless browser", "error", err)
+			os.Exit(1)
+		}
+		observers["headless"] = headlessClient.Stats
+		headlessTF := trafilatura.MustNew(headlessClient, fetcherOptions...)
+		headlessFetcher = mustAlternateFetcher(ctx, sbf, headlessTF)
+		// auto fetches look for javascript-rendered pages in the direct response
+		autoDirectTF := trafilatura.MustNew(
+			directClient,
+			append(fetcherOptions, trafilatura.WithJavaScriptDetection(fetch.DefaultMinContentLength))...,
+		)
+		autoFetcher = mustAlternateFetcher(
+			ctx,
+			sbf,
+			internal.NewFallbackFetcher(autoDirectTF, headlessTF, internal.WithHeadlessPreferences(domainSettings)),
+		)
+		renders := storage.NewRenderStore(dbh, renderTTL.Get())
+		renderer = internal.NewStorageBackedRenderer(headlessClient, renders, renderMaxMB.Get()*1024*1024)
+		renderer.Normalizer = normalizer
+		if renderTTL.Get() > 0 {
+			dbh.Maintenance(time.Hour, pruneRenders(renders))
+		}
+	}
+	var searcher storage.Searcher
+	if urlStore.SearchEnabled() {
+		searcher = urlStore
+	} else {
+		slog.Warn("scrape-server full text search is not available for this database", "database", dbh)
+	}
+	var reextractor *internal.Reextractor
+	if archive != nil {
+		reextractor = internal.NewReextractor(archive, urlStore).WithURLNormalizer(normalizer)
+		if pageCache != nil {
+			reextractor = reextractor.WithSaver(pageCache)
+		}
+	}
+	var versions storage.VersionStore
+	if urlStore.HistoryEnabled() {
+		versions = urlStore
+		slog.Info("scrape-server content history is enabled", "versions", historyVersions.Get(), "ttl", historyTTL.Get())
+		if historyTTL.Get() > 0 {
+			dbh.Maintenance(time.Hour, pruneHistory(urlStore))
+		}
+	}
 
 	ss := api.MustAPIServer(
 		ctx,
 		api.WithURLFetcher(sbf),
 		api.WithHeadlessIf(headlessFetcher),
+		api.WithAutoFetcherIf(autoFetcher),
 		api.WithAuthorizationIf(*signingKey.Get()),
-		api.WithSettingsFrom(dbh),
+		api.WithSettings(domainSettings, cookies),
+		api.WithURLNormalizer(normalizer),
+		api.WithSearchIf(searcher),
+		api.WithListerIf(urlStore),
+		api.WithHistoryIf(versions),
+		api.WithReextractorIf(reextractor),
+		api.WithRendererIf(renderer),
 	)
 
 	if ss.AuthEnabled() {
@@ -89,6 +213,7 @@ func main() {
 	mux, err := server.InitMux(
 		ss,
 		dbh,
+		observers,
 		publicHome.Get(),
 		profile.Get(),
 	)
@@ -118,6 +243,48 @@ func main() {
 	}
 }
 
+func mustAlternateFetcher(ctx context.Context, sbf *internal.StorageBackedFetcher, uf fetch.URLFetcher) *internal.StorageBackedFetcher {
+	alt, err := sbf.WithAlternateURLFetcher(ctx, uf)
+	if err != nil {
+		slog.Error("scrape-server error initializing fetcher", "error", err)
+		os.Exit(1)
+	}
+	return alt
+}
+
+func pruneHistory(store *storage.URLDataStore) database.MaintenanceFunction {
+	return func(dbh *database.DBHandle) error {
+		pruned, err := store.PruneHistory()
+		if err != nil {
+			return err
+		}
+		slog.Debug("scrape-server pruned content history", "versions", pruned)
+		return nil
+	}
+}
+
+func pruneArchive(archive *storage.ArchiveStore) database.MaintenanceFunction {
+	return func(dbh *database.DBHandle) error {
+		pruned, err := archive.Prune()
+		if err != nil {
+			return err
+		}
+		slog.Debug("scrape-server pruned archived responses", "responses", pruned)
+		return nil
+	}
+}
+
+func pruneRenders(renders *storage.RenderStore) database.MaintenanceFunction {
+	return func(dbh *database.DBHandle) error {
+		pruned, err := renders.Prune()
+		if err != nil {
+			return err
+		}
+		slog.Debug("scrape-server pruned renders", "renders", pruned)
+		return nil
+	}
+}
+
 func init() {
 	logWriter = os.Stderr
 	envflags.EnvPrefix = "SCRAPE_"
@@ -127,6 +294,21 @@ func init() {
 
 	headlessEnabled = envflags.NewBool("ENABLE_HEADLESS", false)
 	headlessEnabled.AddTo(&flags, "enable-headless", "Enable headless browser extraction functionality")
+	defaultBlock := fetch.DefaultBlockList
+	headlessBlock = envflags.NewText("HEADLESS_BLOCK", &defaultBlock)
+	headlessBlock.AddTo(
+		&flags,
+		"headless-block",
+		"What the headless browser doesn't load: a comma separated list of resource types (image, media, font, stylesheet), domains, and 'trackers' for the built-in tracker list, or 'none'",
+	)
+	headlessTabs = envflags.NewInt("HEADLESS_TABS", 6)
+	headlessTabs.AddTo(&flags, "headless-tabs", "Load up to this many pages at once in the headless browser")
+	pageTimeout = envflags.NewDuration("HEADLESS_PAGE_TIMEOUT", headless.DefaultPageTimeout)
+	pageTimeout.AddTo(&flags, "headless-page-timeout", "How long the headless browser gets to load each page")
+	maxPages = envflags.NewInt("HEADLESS_MAX_PAGES", headless.DefaultMaxPages)
+	maxPages.AddTo(&flags, "headless-max-pages", "Restart the headless browser after it loads this many pages. Never restart it for the number of pages if 0")
+	maxMemoryMB = envflags.NewInt("HEADLESS_MAX_MEMORY_MB", 0)
+	maxMemoryMB.AddTo(&flags, "headless-max-memory-mb", "Restart the headless browser when it uses more than this many MB of memory (Linux only). No limit if 0")
 
 	host = envflags.NewString("HOST", "")
 	host.AddTo(&flags, "host", "TCP address to listen on (empty for all interfaces)")
@@ -144,9 +326,42 @@ func init() {
 	ttl = envflags.NewDuration("TTL", resource.DefaultTTL)
 	ttl.AddTo(&flags, "ttl", "TTL for fetched resources")
 
+	historyVersions = envflags.NewInt("HISTORY_VERSIONS", 0)
+	historyVersions.AddTo(&flags, "history-versions", "Keep up to this many versions of each page's content. History is disabled if this and -history-ttl are both 0")
+	historyTTL = envflags.NewDuration("HISTORY_TTL", 0)
+	historyTTL.AddTo(&flags, "history-ttl", "Keep versions of page content for this long")
+
+	archiveTTL = envflags.NewDuration("ARCHIVE_TTL", 0)
+	archiveTTL.AddTo(&flags, "archive-ttl", "Archive the raw response for each fetched page, keeping it for this long. Archiving is disabled if 0")
+
+	renderTTL = envflags.NewDuration("RENDER_TTL", 24*time.Hour)
+	renderTTL.AddTo(&flags, "render-ttl", "Keep screenshots and PDFs of pages for this long (with -enable-headless). They don't expire if 0")
+	renderMaxMB = envflags.NewInt("RENDER_MAX_MB", 10)
+	renderMaxMB.AddTo(&flags, "render-max-mb", "Don't return or store screenshots and PDFs larger than this many MB")
+
+	maxBodyMB = envflags.NewInt("MAX_BODY_MB", 10)
+	maxBodyMB.AddTo(&flags, "max-body-mb", "Don't extract pages whose responses are larger than this many MB")
+
+	cacheMB = envflags.NewInt("CACHE_MB", 0)
+	cacheMB.AddTo(&flags, "cache-mb", "Cache up to this many MB of stored pages in memory. Caching is disabled if 0")
+	cacheMaxAge = envflags.NewDuration("CACHE_MAX_AGE", cache.DefaultMaxAge)
+	cacheMaxAge.AddTo(&flags, "cache-max-age", "Read cached pages from the database again after this long, to pick up changes made outside the server")
+
 	defaultUA := ua.UserAgent(fetch.DefaultUserAgent)
 	userAgent = envflags.NewText("USER_AGENT", &defaultUA)
 	userAgent.AddTo(&flags, "user-agent", "User agent for fetching")
+	maxRedirects = envflags.NewInt("MAX_REDIRECTS", fetch.DefaultMaxRedirects)
+	maxRedirects.AddTo(&flags, "max-redirects", "Follow up to this many redirects for each page (without the headless browser)")
+	defaultRedirects := fetch.FollowRedirects
+	redirectPolicy = envflags.NewText("REDIRECT_POLICY", &defaultRedirects)
+	redirectPolicy.AddTo(
+		&flags,
+		"redirect-policy",
+		"Which redirects to follow (without the headless browser): 'follow' for all of them, or 'same-domain' for those within the requested url's domain",
+	)
+	resolveVariants = envflags.NewBool("RESOLVE_VARIANTS", false)
+	resolveVariants.AddTo(&flags, "resolve-variants", "Fetch the desktop page for AMP and mobile urls, and map the AMP or mobile url to it")
+	urlFlags = cmd.AddURLFlags(&flags)
 
 	profile = envflags.NewBool("PROFILE", false)
 	profile.AddTo(&flags, "profile", "Enable profiling at /debug/pprof")
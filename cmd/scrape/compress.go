@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/internal/storage"
+)
+
+// Compress the content text of pages that were stored before content was
+// compressed, and write a summary to stdout as JSON. args are the command
+// line arguments following the `compress` subcommand.
+func compressDatabase(dbh *database.DBHandle, args []string) {
+	var compressFlags flag.FlagSet
+	compressFlags.Init("compress", flag.ExitOnError)
+	compressFlags.Usage = func() {
+		fmt.Println(`Usage:
+	scrape [flags] compress
+
+Compresses the stored content text of pages that were saved before content
+was compressed. New pages are always compressed when they're saved. Run
+'scrape -migrate up' before compressing an existing database. This can be
+interrupted and run again; pages that are already compressed are skipped.`)
+	}
+	compressFlags.Parse(args)
+
+	summary, err := storage.NewURLDataStore(dbh).CompressContent()
+	if err != nil {
+		slog.Error("Error compressing stored content", "database", dbh, "compressed", summary.Compressed, "err", err)
+		os.Exit(1)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		slog.Error("Error encoding compression summary", "err", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/internal/transfer"
+)
+
+// Export stored pages to a file, or to stdout. args are the command line
+// arguments following the `export` subcommand.
+func exportDatabase(dbh *database.DBHandle, args []string) {
+	var (
+		exportFlags flag.FlagSet
+		query       storage.ListQuery
+		format      string
+		output      string
+		compress    bool
+		since       string
+		until       string
+	)
+	exportFlags.Init("export", flag.ExitOnError)
+	exportFlags.Usage = func() {
+		fmt.Println(`Usage:
+	scrape [flags] export [export flags]
+
+JSON lines exports write one record per page, with the page's expiry and
+the urls that map to it, and can be imported without losing anything.
+WARC exports include the raw response for each page that has one archived,
+followed by a metadata record with the page's JSON.
+
+Export flags:`)
+		exportFlags.PrintDefaults()
+	}
+	exportFlags.StringVar(&format, "format", "jsonl", "Export format [jsonl|warc]")
+	exportFlags.StringVar(&query.Hostname, "hostname", "", "Only export pages from this hostname (and its subdomains)")
+	exportFlags.StringVar(&since, "since", "", "Only export pages fetched on or after this date (YYYY-MM-DD or RFC3339)")
+	exportFlags.StringVar(&until, "until", "", "Only export pages fetched before this date (YYYY-MM-DD or RFC3339)")
+	exportFlags.StringVar(&output, "o", "", "File to write to (default stdout)")
+	exportFlags.BoolVar(&compress, "gzip", false, "Compress the output (always set when the -o file ends with .gz)")
+	exportFlags.Parse(args)
+	query.Limit = storage.MaxListLimit
+	var err error
+	if query.FetchedSince, err = storage.ParseDate(since); err != nil {
+		slog.Error("Invalid -since value", "since", since, "err", err)
+		os.Exit(1)
+	}
+	if query.FetchedUntil, err = storage.ParseDate(until); err != nil {
+		slog.Error("Invalid -until value", "until", until, "err", err)
+		os.Exit(1)
+	}
+	compress = compress || strings.HasSuffix(output, ".gz")
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			slog.Error("Error creating export file", "file", output, "err", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	exporter := transfer.NewExporter(storage.NewURLDataStore(dbh), storage.NewArchiveStore(dbh, 0))
+	var count int
+	switch format {
+	case "jsonl":
+		count, err = exporter.JSONL(w, query)
+	case "warc":
+		count, err = exporter.WARC(w, query, compress)
+	default:
+		slog.Error("Unsupported export format", "format", format)
+		exportFlags.Usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		slog.Error("Error exporting pages", "database", dbh, "err", err)
+		os.Exit(1)
+	}
+	slog.Warn("Export complete", "database", dbh, "format", format, "pages", count)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/internal/transfer"
+	"github.com/efixler/scrape/resource"
+)
+
+// Import pages from files, or from stdin, into the store. args are the
+// command line arguments following the `import` subcommand.
+func importDatabase(dbh *database.DBHandle, normalizer *resource.URLNormalizer, args []string) {
+	var (
+		importFlags flag.FlagSet
+		format      string
+		ttl         time.Duration
+		filter      storage.ListQuery
+		since       string
+		until       string
+	)
+	importFlags.Init("import", flag.ExitOnError)
+	importFlags.Usage = func() {
+		fmt.Println(`Usage:
+	scrape [flags] import [import flags] [:file ...files]
+
+Reads from stdin when no files are passed. Pages imported from JSON lines
+keep their original expiry; pages that have already expired are skipped.
+WARC files can be compressed, and their response records are run through
+the extractor; nothing is fetched.
+
+Import flags:`)
+		importFlags.PrintDefaults()
+	}
+	importFlags.StringVar(&format, "format", "jsonl", "Import format [jsonl|warc]")
+	importFlags.DurationVar(&ttl, "ttl", resource.DefaultTTL, "Pages imported from WARC files expire this long after they're imported")
+	importFlags.StringVar(&filter.Hostname, "hostname", "", "Only import pages from this hostname (and its subdomains)")
+	importFlags.StringVar(&since, "since", "", "Only import pages fetched on or after this date (YYYY-MM-DD or RFC3339)")
+	importFlags.StringVar(&until, "until", "", "Only import pages fetched before this date (YYYY-MM-DD or RFC3339)")
+	importFlags.Parse(args)
+	var err error
+	if filter.FetchedSince, err = storage.ParseDate(since); err != nil {
+		slog.Error("Invalid -since value", "since", since, "err", err)
+		os.Exit(1)
+	}
+	if filter.FetchedUntil, err = storage.ParseDate(until); err != nil {
+		slog.Error("Invalid -until value", "until", until, "err", err)
+		os.Exit(1)
+	}
+
+	importer := transfer.NewImporter(
+		storage.NewURLDataStore(dbh),
+		ttl,
+		transfer.WithFilter(filter),
+		transfer.WithURLNormalizer(normalizer),
+	)
+	var importFunc func(io.Reader) (*transfer.ImportSummary, error)
+	switch format {
+	case "jsonl":
+		importFunc = importer.JSONL
+	case "warc":
+		importFunc = importer.WARC
+	default:
+		slog.Error("Unsupported import format", "format", format)
+		importFlags.Usage()
+		os.Exit(1)
+	}
+	files := importFlags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, file := range files {
+		var r io.Reader = os.Stdin
+		if file != "-" {
+			f, err := os.Open(file)
+			if err != nil {
+				slog.Error("Error opening import file", "file", file, "err", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			r = f
+		}
+		summary, err := importFunc(r)
+		if err != nil {
+			slog.Error("Error importing pages", "file", file, "err", err)
+			os.Exit(1)
+		}
+		slog.Warn(
+			"Import complete",
+			"file", file,
+			"imported", summary.Imported,
+			"skipped", summary.Skipped,
+			"failed", summary.Failed,
+		)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+// List stored pages and write them to stdout as JSON, along with the cursor
+// for the next set of results. args are the command line arguments following
+// the `list` subcommand.
+func listDatabase(dbh *database.DBHandle, args []string) {
+	var (
+		listFlags      flag.FlagSet
+		query          storage.ListQuery
+		fetchedSince   string
+		fetchedUntil   string
+		publishedSince string
+		publishedUntil string
+	)
+	listFlags.Init("list", flag.ExitOnError)
+	listFlags.Usage = func() {
+		fmt.Println(`Usage:
+	scrape [flags] list [list flags]
+
+Pass the next_cursor from the output to -cursor to get the next set of results.
+
+List flags:`)
+		listFlags.PrintDefaults()
+	}
+	listFlags.StringVar(&query.Hostname, "hostname", "", "Only list pages from this hostname (and its subdomains)")
+	listFlags.StringVar(&fetchedSince, "since", "", "Only list pages fetched on or after this date (YYYY-MM-DD or RFC3339)")
+	listFlags.StringVar(&fetchedUntil, "until", "", "Only list pages fetched before this date (YYYY-MM-DD or RFC3339)")
+	listFlags.StringVar(&publishedSince, "published-since", "", "Only list pages published on or after this date (YYYY-MM-DD or RFC3339)")
+	listFlags.StringVar(&publishedUntil, "published-until", "", "Only list pages published before this date (YYYY-MM-DD or RFC3339)")
+	listFlags.TextVar(&query.FetchMethod, "method", resource.Unspecified, "Only list pages fetched with this method [direct|chromium-headless|chromium-headless-fallback]")
+	listFlags.IntVar(&query.StatusCode, "status", 0, "Only list pages with this HTTP status code")
+	listFlags.StringVar(&query.Cursor, "cursor", "", "Cursor from a previous list, to continue from")
+	listFlags.IntVar(&query.Limit, "limit", storage.DefaultListLimit, "Maximum number of pages to list")
+	listFlags.Parse(args)
+
+	var err error
+	for _, d := range []struct {
+		name  string
+		value string
+		dest  *time.Time
+	}{
+		{"since", fetchedSince, &query.FetchedSince},
+		{"until", fetchedUntil, &query.FetchedUntil},
+		{"published-since", publishedSince, &query.PublishedSince},
+		{"published-until", publishedUntil, &query.PublishedUntil},
+	} {
+		if *d.dest, err = storage.ParseDate(d.value); err != nil {
+			slog.Error("Invalid date flag value", "flag", d.name, "value", d.value, "err", err)
+			os.Exit(1)
+		}
+	}
+
+	store := storage.NewURLDataStore(dbh)
+	result, err := store.List(query)
+	if err != nil {
+		slog.Error("Error listing pages", "database", dbh, "err", err)
+		os.Exit(1)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(result); err != nil {
+		slog.Error("Error encoding list results", "err", err)
+		os.Exit(1)
+	}
+}
@@ -6,6 +6,39 @@
 //
 // > scrape https://example.com/path
 //
+// Stored content can be searched with the `search` subcommand,
+// and browsed with the `list` subcommand:
+//
+// > scrape search -hostname example.com some search terms
+//
+// > scrape list -hostname example.com -since 2024-05-01
+//
+// Stored pages can be re-extracted from archived responses, without
+// fetching them again, with the `reextract` subcommand:
+//
+// > scrape reextract -hostname example.com
+//
+// Stored pages can be exported to, and imported from, JSON lines or WARC files:
+//
+// > scrape export -hostname example.com -o pages.jsonl
+//
+// > scrape import pages.jsonl
+//
+// > scrape export -format warc -o pages.warc.gz
+//
+// > scrape import -format warc pages.warc.gz
+//
+// Content text is compressed when it's stored. Content stored by earlier
+// versions can be compressed in place with the `compress` subcommand:
+//
+// > scrape compress
+//
+// Urls are normalized before they're fetched and stored. When the normalization
+// rules change, stored pages can be moved to their normalized urls with the
+// `rekey` subcommand:
+//
+// > scrape -strip-params 'utm_*,fbclid,ref' rekey
+//
 // Run `scrape -h` for complete help and command line options.
 package main
 
@@ -25,6 +58,7 @@ import (
 	"github.com/efixler/scrape/internal"
 	"github.com/efixler/scrape/internal/cmd"
 	"github.com/efixler/scrape/internal/headless"
+	"github.com/efixler/scrape/internal/settings"
 	"github.com/efixler/scrape/internal/storage"
 	"github.com/efixler/scrape/resource"
 	"github.com/efixler/scrape/ua"
@@ -35,10 +69,13 @@ var (
 	flags           flag.FlagSet
 	noContent       *envflags.Value[bool]
 	dbFlags         *cmd.DatabaseFlags
+	urlFlags        *cmd.URLFlags
+	resolveVariants *envflags.Value[bool]
 	userAgent       *envflags.Value[*ua.UserAgent]
 	csvPath         *envflags.Value[string]
 	csvUrlIndex     *envflags.Value[int]
 	headlessEnabled bool
+	refresh         bool
 	// clear           bool
 	maintain bool
 	ping     bool
@@ -53,6 +90,8 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	openDatabase(dbh, ctx)
+	// Urls are normalized with the global rules and the rules for their domains
+	normalizer := urlFlags.Normalizer(settings.NewDomainSettingsStorage(dbh).URLRules)
 
 	if dbFlags.IsMigration() {
 		migrateDatabase(dbh, dbFlags.MigrationCommand)
@@ -64,7 +103,30 @@ func main() {
 		pingDatabase(dbh)
 		return
 	}
-	fetcher, err := initFetcher(dbh)
+	switch flags.Arg(0) {
+	case "search":
+		searchDatabase(dbh, flags.Args()[1:])
+		return
+	case "list":
+		listDatabase(dbh, flags.Args()[1:])
+		return
+	case "reextract":
+		reextractDatabase(dbh, normalizer, flags.Args()[1:])
+		return
+	case "export":
+		exportDatabase(dbh, flags.Args()[1:])
+		return
+	case "import":
+		importDatabase(dbh, normalizer, flags.Args()[1:])
+		return
+	case "compress":
+		compressDatabase(dbh, flags.Args()[1:])
+		return
+	case "rekey":
+		rekeyDatabase(dbh, normalizer, flags.Args()[1:])
+		return
+	}
+	fetcher, err := initFetcher(dbh, normalizer)
 	if err != nil {
 		slog.Error("Error initializing fetcher", "err", err)
 		os.Exit(1)
@@ -78,7 +140,7 @@ func main() {
 	encoder := jsonarray.NewEncoder[*resource.WebPage](os.Stdout, false)
 
 	encoder.SetIndent("", "  ")
-	rchan := fetcher.Batch(args, fetch.BatchOptions{})
+	rchan := fetcher.Batch(args, fetch.BatchOptions{Refresh: refresh})
 	for page := range rchan {
 		// TODO: Make it so we don't have to run a conditional on every iteration
 		if noContent.Get() {
@@ -132,7 +194,24 @@ func maintainDatabase(dbh *database.DBHandle) {
 		slog.Error("Error maintaining database", "database", dbh, "err", err)
 		os.Exit(1)
 	}
-	slog.Warn("Database maintenance complete", "database", dbh)
+	scan, err := storage.NewURLDataStore(dbh).ScanKeys()
+	if err != nil {
+		slog.Error("Error scanning for key collisions", "database", dbh, "err", err)
+		os.Exit(1)
+	}
+	for _, kc := range scan.Collisions {
+		slog.Warn("Key collision", "url", kc.URL, "key", kc.ID, "other_url", kc.OtherURL, "other_key", kc.OtherID)
+	}
+	for _, kc := range scan.Mismatches {
+		slog.Warn("Page stored under a key that isn't its url's", "url", kc.URL, "key", kc.ID)
+	}
+	slog.Warn(
+		"Database maintenance complete",
+		"database", dbh,
+		"pages", scan.Examined,
+		"key_collisions", len(scan.Collisions),
+		"key_mismatches", len(scan.Mismatches),
+	)
 }
 
 func migrateDatabase(dbh *database.DBHandle, migrationCommand cmd.MigrationCommand) {
@@ -170,11 +249,17 @@ func openDatabase(dbh *database.DBHandle, ctx context.Context) {
 	}
 }
 
-func initFetcher(dbh *database.DBHandle) (*internal.StorageBackedFetcher, error) {
+func initFetcher(dbh *database.DBHandle, normalizer *resource.URLNormalizer) (*internal.StorageBackedFetcher, error) {
 	var err error
 	var client fetch.Client
 	if headlessEnabled {
-		client, err = headless.NewChromeClient(dbh.Ctx, userAgent.Get().String(), 1)
+		client, err = headless.NewChromeClient(
+			dbh.Ctx,
+			userAgent.Get().String(),
+			1,
+			headless.WithDomainOptions(settings.NewDomainSettingsStorage(dbh).HeadlessOptions),
+			headless.WithCookieJar(settings.NewCookieStorage(dbh)),
+		)
 		if err != nil {
 			return nil, fmt.Errorf("error creating headless client: %s", err)
 		}
@@ -182,12 +267,18 @@ func initFetcher(dbh *database.DBHandle) (*internal.StorageBackedFetcher, error)
 		client = fetch.MustClient(
 			fetch.WithFiles("./"),
 			fetch.WithUserAgent(userAgent.Get().String()),
+			fetch.WithCookieJar(settings.NewCookieStorage(dbh)),
 		)
 	}
+	var options []trafilatura.Option
+	if resolveVariants.Get() {
+		options = append(options, trafilatura.WithVariantResolution(normalizer))
+	}
 	fetcher := internal.NewStorageBackedFetcher(
-		trafilatura.MustNew(client),
+		trafilatura.MustNew(client, options...),
 		storage.NewURLDataStore(dbh),
 	)
+	fetcher.Normalizer = normalizer
 	return fetcher, nil
 }
 
@@ -198,8 +289,12 @@ func init() {
 	noContent = envflags.NewBool("NOTEXT", false)
 	noContent.AddTo(&flags, "notext", "Skip text content")
 	dbFlags = cmd.AddDatabaseFlags("DB", &flags, true)
+	urlFlags = cmd.AddURLFlags(&flags)
 
 	flags.BoolVar(&headlessEnabled, "headless", false, "Use headless browser for extraction")
+	flags.BoolVar(&refresh, "refresh", false, "Fetch urls again, even if they're stored")
+	resolveVariants = envflags.NewBool("RESOLVE_VARIANTS", false)
+	resolveVariants.AddTo(&flags, "resolve-variants", "Fetch the desktop page for AMP and mobile urls")
 
 	dua := ua.UserAgent(fetch.DefaultUserAgent)
 	userAgent = envflags.NewText("USER_AGENT", &dua)
@@ -234,9 +329,18 @@ func init() {
 func usage() {
 	fmt.Println(`Usage: 
 	scrape [flags] :url [...urls]
+	scrape [flags] search [search flags] :term [...terms]
+	scrape [flags] list [list flags]
+	scrape [flags] reextract [reextract flags] [:url ...urls]
+	scrape [flags] export [export flags]
+	scrape [flags] import [import flags] [:file ...files]
+	scrape [flags] compress
+	scrape [flags] rekey
 
 In addition to http[s] URLs, file:/// urls are supported, using the current working directory as the base path.
 
+Run 'scrape <subcommand> -h' for subcommand flags.
+
 Flags:
  
   -h	
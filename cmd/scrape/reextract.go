@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	nurl "net/url"
+	"os"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/internal"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+// Re-extract stored pages from their archived raw responses, and write
+// the results to stdout as JSON. args are the command line
+// arguments following the `reextract` subcommand.
+func reextractDatabase(dbh *database.DBHandle, normalizer *resource.URLNormalizer, args []string) {
+	var (
+		reextractFlags flag.FlagSet
+		query          internal.ReextractQuery
+	)
+	reextractFlags.Init("reextract", flag.ExitOnError)
+	reextractFlags.Usage = func() {
+		fmt.Println(`Usage:
+	scrape [flags] reextract [reextract flags] [:url ...urls]
+
+Runs the extractor over the archived responses for stored pages and updates
+the stored content. No urls are fetched. Only pages that were archived by
+scrape-server (see its -archive-ttl flag) can be re-extracted.
+
+Reextract flags:`)
+		reextractFlags.PrintDefaults()
+	}
+	reextractFlags.StringVar(&query.Hostname, "hostname", "", "Re-extract pages from this hostname (and its subdomains)")
+	reextractFlags.BoolVar(&query.All, "all", false, "Re-extract all stored pages")
+	reextractFlags.Parse(args)
+	for _, u := range reextractFlags.Args() {
+		url, err := nurl.Parse(u)
+		if err != nil || !url.IsAbs() {
+			slog.Error("Invalid url", "url", u, "err", err)
+			os.Exit(1)
+		}
+		query.URLs = append(query.URLs, url)
+	}
+
+	r := internal.NewReextractor(storage.NewArchiveStore(dbh, 0), storage.NewURLDataStore(dbh)).
+		WithURLNormalizer(normalizer)
+	report := internal.NewReextractReport()
+	if err := r.Reextract(query, report.Add); err != nil {
+		slog.Error("Error re-extracting pages", "database", dbh, "err", err)
+		os.Exit(1)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		slog.Error("Error encoding re-extraction results", "err", err)
+		os.Exit(1)
+	}
+}
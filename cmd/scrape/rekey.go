@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+// Store pages under the keys of their urls as they're normalized with the
+// current url rules, and write a summary to stdout as JSON. args are the
+// command line arguments following the `rekey` subcommand.
+func rekeyDatabase(dbh *database.DBHandle, normalizer *resource.URLNormalizer, args []string) {
+	var rekeyFlags flag.FlagSet
+	rekeyFlags.Init("rekey", flag.ExitOnError)
+	rekeyFlags.Usage = func() {
+		fmt.Println(`Usage:
+	scrape [flags] rekey
+
+Normalizes the urls of stored pages with the current url rules, set with the
+-strip-params and -normalize-paths flags and the url_rules domain setting, and
+stores the pages under their normalized urls. Run this after the rules change,
+so that stored pages are found by their normalized urls. Pages whose normalized
+url is already stored are merged with the stored page, keeping the newer one.
+The urls that were mapped to a page still find it. This can be interrupted and
+run again.`)
+	}
+	rekeyFlags.Parse(args)
+
+	summary, err := storage.NewURLDataStore(dbh).Rekey(normalizer.Normalize)
+	if err != nil {
+		slog.Error("Error re-keying stored pages", "database", dbh, "examined", summary.Examined, "err", err)
+		os.Exit(1)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		slog.Error("Error encoding re-key summary", "err", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/internal/storage"
+)
+
+// Run a full text search against the stored content and write the results
+// to stdout as JSON. args are the command line arguments following the
+// `search` subcommand.
+func searchDatabase(dbh *database.DBHandle, args []string) {
+	var (
+		searchFlags flag.FlagSet
+		query       storage.SearchQuery
+		since       string
+		until       string
+	)
+	searchFlags.Init("search", flag.ExitOnError)
+	searchFlags.Usage = func() {
+		fmt.Println(`Usage:
+	scrape [flags] search [search flags] :term [...terms]
+
+Search flags:`)
+		searchFlags.PrintDefaults()
+	}
+	searchFlags.StringVar(&query.Hostname, "hostname", "", "Only return results from this hostname")
+	searchFlags.StringVar(&since, "since", "", "Only return results fetched on or after this date (YYYY-MM-DD or RFC3339)")
+	searchFlags.StringVar(&until, "until", "", "Only return results fetched before this date (YYYY-MM-DD or RFC3339)")
+	searchFlags.IntVar(&query.Offset, "offset", 0, "Number of results to skip")
+	searchFlags.IntVar(&query.Limit, "limit", storage.DefaultSearchLimit, "Maximum number of results to return")
+	searchFlags.Parse(args)
+
+	query.Query = strings.Join(searchFlags.Args(), " ")
+	var err error
+	if query.Since, err = storage.ParseDate(since); err != nil {
+		slog.Error("Invalid -since value", "since", since, "err", err)
+		os.Exit(1)
+	}
+	if query.Until, err = storage.ParseDate(until); err != nil {
+		slog.Error("Invalid -until value", "until", until, "err", err)
+		os.Exit(1)
+	}
+
+	store := storage.NewURLDataStore(dbh)
+	results, err := store.Search(query)
+	if err != nil {
+		slog.Error("Error searching database", "database", dbh, "query", query.Query, "err", err)
+		if err == storage.ErrEmptySearchQuery {
+			searchFlags.Usage()
+		}
+		os.Exit(1)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(results); err != nil {
+		slog.Error("Error encoding search results", "err", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+const (
+	// Compressed text starts with this byte, which can't start a UTF-8
+	// string, so that uncompressed values stored before compression was
+	// added can still be read.
+	CompressedTextMarker byte = 0xFF
+	// Shorter text isn't worth compressing.
+	MinCompressedTextSize = 256
+)
+
+// Encode text for storage in a binary column, gzip compressing it if it's
+// long enough for that to be worthwhile.
+func CompressText(text string) ([]byte, error) {
+	if len(text) < MinCompressedTextSize {
+		return []byte(text), nil
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(CompressedTextMarker)
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = zw.Write([]byte(text)); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode text encoded by CompressText. Values that aren't compressed are
+// returned as-is.
+func DecompressText(data []byte) (string, error) {
+	if !IsCompressedText(data) {
+		return string(data), nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data[1:]))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+// Reports whether data was compressed by CompressText.
+func IsCompressedText(data []byte) bool {
+	return len(data) > 0 && data[0] == CompressedTextMarker
+}
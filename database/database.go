@@ -16,6 +16,7 @@ type DriverName string
 const (
 	SQLite                DriverName = "sqlite3"
 	MySQL                 DriverName = "mysql"
+	Postgres              DriverName = "postgres"
 	closeListenerCapacity            = 8
 )
 
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"strings"
+)
+
+// Dialect supplies the SQL that differs between database engines, so that
+// application statements can be written once and run on any engine.
+// Statements use ? placeholders on all engines.
+type Dialect interface {
+	// An INSERT statement for the columns of a table that replaces any
+	// existing row with the same key. keys are the columns of the table's
+	// primary key, and must also be included in columns.
+	Upsert(table string, keys []string, columns ...string) string
+	// The operator for a case-insensitive LIKE comparison.
+	ILike() string
+	// An expression for the text value of a top-level field of an object
+	// stored in a JSON column. The expression is NULL if the field isn't set.
+	JSONField(column string, field string) string
+	// The SQL for the engine's full text index of stored content, or nil if
+	// the engine doesn't have one.
+	FullText() FullTextDialect
+}
+
+// FullTextDialect supplies the SQL for the full text index of stored content,
+// the urls_fts table, which has an entry for each page in the urls table with
+// its title, description and content text. Search terms are passed as
+// letters and digits only, so they can't carry query syntax.
+type FullTextDialect interface {
+	// The statement that adds or replaces the index entry for a page, with the
+	// page's key, title, description and content text as params.
+	Index() string
+	// The column of the index that holds the page's key.
+	Key() string
+	// The condition that matches the index, as f, to pages with all of
+	// the terms, and its params.
+	Match(terms []string) (string, []any)
+	// An ORDER BY expression that puts the best matches first, and its params.
+	Rank(terms []string) (string, []any)
+}
+
+// BaseDialect is the default Dialect for BaseEngine, and the base of the
+// SQLite and MySQL dialects. Its upserts use REPLACE INTO, which both of
+// them support. It doesn't have a full text index.
+type BaseDialect struct{}
+
+func (d BaseDialect) Upsert(table string, keys []string, columns ...string) string {
+	return "REPLACE INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + Placeholders(len(columns)) + ")"
+}
+
+// LIKE is case-insensitive for ASCII characters in SQLite, and with the
+// default collations in MySQL.
+func (d BaseDialect) ILike() string {
+	return "LIKE"
+}
+
+func (d BaseDialect) JSONField(column string, field string) string {
+	return "json_extract(" + column + ", '$." + field + "')"
+}
+
+func (d BaseDialect) FullText() FullTextDialect {
+	return nil
+}
+
+// Returns n comma-separated ? placeholders.
+func Placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
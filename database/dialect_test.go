@@ -0,0 +1,52 @@
+package database
+
+import "testing"
+
+func TestBaseDialect(t *testing.T) {
+	d := BaseDialect{}
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{
+			name:     "upsert",
+			got:      d.Upsert("kv", []string{"k"}, "k", "v"),
+			expected: "REPLACE INTO kv (k, v) VALUES (?, ?)",
+		},
+		{
+			name:     "ilike",
+			got:      d.ILike(),
+			expected: "LIKE",
+		},
+		{
+			name:     "json field",
+			got:      d.JSONField("metadata", "title"),
+			expected: "json_extract(metadata, '$.title')",
+		},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("[%s] expected %q, got %q", test.name, test.expected, test.got)
+		}
+	}
+	if d.FullText() != nil {
+		t.Error("Expected no full text index for the base dialect")
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		n        int
+		expected string
+	}{
+		{0, ""},
+		{1, "?"},
+		{3, "?, ?, ?"},
+	}
+	for _, test := range tests {
+		if got := Placeholders(test.n); got != test.expected {
+			t.Errorf("Placeholders(%d): expected %q, got %q", test.n, test.expected, got)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package database
 import (
 	"io/fs"
 	"time"
+
+	"github.com/pressly/goose/v3"
 )
 
 // Interface for specific database implementations using DBHandle. SQL database
@@ -18,7 +20,8 @@ import (
 // This interface (along with the supplemental/options interfaces below), provide
 // the hooks to implement platform-specific behaviors in these 3 area. Application
 // runtime  operations using DBHandle should utilize common SQL syntax, which is
-// generally straightforward.
+// generally straightforward, and get anything that differs between engines
+// from the engine's Dialect.
 type Engine interface {
 	// Provides DSN and basic configuration info
 	DSNSource() DataSource
@@ -26,6 +29,8 @@ type Engine interface {
 	Driver() string
 	// Migrations directory, or nil if unsupported
 	MigrationFS() fs.FS
+	// Engine-specific SQL
+	Dialect() Dialect
 }
 
 // This interface is to expose a method to supply
@@ -43,6 +48,13 @@ type Maintainable interface {
 	Maintain(dbh *DBHandle) error
 }
 
+// Engines that maintain a full text index of stored content implement this
+// interface. FullTextSearch reports whether the index is available, which
+// can depend on how the underlying driver was built.
+type FullTextSearchable interface {
+	FullTextSearch() bool
+}
+
 // Provided for Engine implementations that want to do something
 // right after the connection is opened.
 type AfterOpenHook interface {
@@ -63,6 +75,13 @@ type MigrationEnvSetter interface {
 	MigrationEnv() []string
 }
 
+// Engines with migrations that can't be written in SQL provide them with this
+// hook. They're run in version order along with the migrations in MigrationFS,
+// and must be constructed with goose.NewGoMigration.
+type GoMigrationProvider interface {
+	GoMigrations() []*goose.Migration
+}
+
 type BaseEngine struct {
 	driver      string
 	dsnSource   DataSource
@@ -91,6 +110,10 @@ func (e BaseEngine) MigrationFS() fs.FS {
 	return e.migrationFS
 }
 
+func (e BaseEngine) Dialect() Dialect {
+	return BaseDialect{}
+}
+
 // BaseDataSource provides a basic DataSource implementation that's wrapped
 // around a dsn string. The configiration-ey options (QueryTimeout, MaxConnections,
 // ConnMaxLifetime) are all set to set to defaults that are generally useful for
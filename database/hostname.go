@@ -0,0 +1,30 @@
+package database
+
+import "strings"
+
+// The key that stored pages are matched on by hostname: the hostname,
+// lowercased and reversed, with a trailing dot. A hostname's key is a prefix
+// of the keys of its subdomains, so that a hostname and its subdomains can be
+// matched with an indexed range instead of a suffix match. Returns an empty
+// string for an empty hostname.
+func HostnameKey(hostname string) string {
+	if hostname == "" {
+		return ""
+	}
+	runes := []rune(strings.ToLower(hostname))
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes) + "."
+}
+
+// The bounds of the range of keys that match hostname and its subdomains,
+// for a `key >= lower AND key < upper` condition.
+func HostnameKeyRange(hostname string) (lower, upper string) {
+	lower = HostnameKey(hostname)
+	if lower == "" {
+		return "", ""
+	}
+	// '/' follows '.', so upper is the first key that doesn't start with lower
+	return lower, strings.TrimSuffix(lower, ".") + "/"
+}
@@ -70,6 +70,9 @@ func (d DBHandle) prepareForMigration(env ...string) (func(), error) {
 	}
 
 	goose.SetBaseFS(migFS)
+	if err := d.registerGoMigrations(); err != nil {
+		return nil, err
+	}
 
 	envRestore := make(map[string]string, len(env)/2)
 	clearF := func() {
@@ -101,12 +104,25 @@ func (d DBHandle) PrintMigrationStatus() error {
 		return err
 	}
 	goose.SetBaseFS(migFS)
+	if err := d.registerGoMigrations(); err != nil {
+		return err
+	}
 	if err := goose.Status(d.DB, "."); err != nil {
 		return err
 	}
 	return nil
 }
 
+// Goose keeps Go migrations in a global registry, so it's replaced with the
+// engine's migrations before each run.
+func (d DBHandle) registerGoMigrations() error {
+	goose.ResetGlobalMigrations()
+	if gmp, ok := d.Engine.(GoMigrationProvider); ok {
+		return goose.SetGlobalMigrations(gmp.GoMigrations()...)
+	}
+	return nil
+}
+
 // Get the subdirectory of the migration directory that actually contains the migration files.
 func extractMigrationFS(migrationFS fs.FS) (fs.FS, string, error) {
 	if migrationFS == nil {
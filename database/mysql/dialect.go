@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"strings"
+
+	"github.com/efixler/scrape/database"
+)
+
+// MySQL supports REPLACE INTO and case-insensitive LIKE comparisons like
+// SQLite, and differs in its JSON syntax and its full text index.
+type Dialect struct {
+	database.BaseDialect
+}
+
+// ->> unquotes the extracted value, which json_extract doesn't on MySQL.
+func (d Dialect) JSONField(column string, field string) string {
+	return column + "->>'$." + field + "'"
+}
+
+func (d Dialect) FullText() database.FullTextDialect {
+	return fullText{}
+}
+
+// The index is a FULLTEXT index on the columns of urls_fts, which is keyed by
+// the page's id. Matches are in boolean mode, which ranks them by relevance.
+type fullText struct{}
+
+const qMatch = `MATCH (f.title, f.description, f.content_text) AGAINST (? IN BOOLEAN MODE)`
+
+func (f fullText) Index() string {
+	return Dialect{}.Upsert("urls_fts", []string{"id"}, "id", "title", "description", "content_text")
+}
+
+func (f fullText) Key() string {
+	return "id"
+}
+
+// Each term is required with +.
+func (f fullText) Match(terms []string) (string, []any) {
+	return qMatch, []any{booleanQuery(terms)}
+}
+
+func (f fullText) Rank(terms []string) (string, []any) {
+	return qMatch + " DESC", []any{booleanQuery(terms)}
+}
+
+func booleanQuery(terms []string) string {
+	required := make([]string, len(terms))
+	for i, t := range terms {
+		required[i] = "+" + t
+	}
+	return strings.Join(required, " ")
+}
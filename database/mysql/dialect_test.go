@@ -0,0 +1,26 @@
+package mysql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFullTextDialect(t *testing.T) {
+	ft := Dialect{}.FullText()
+	if ft == nil {
+		t.Fatal("Expected a full text dialect")
+	}
+	if expected := "REPLACE INTO urls_fts (id, title, description, content_text) VALUES (?, ?, ?, ?)"; ft.Index() != expected {
+		t.Errorf("Expected index statement %q, got %q", expected, ft.Index())
+	}
+	terms := []string{"martin", "fowler"}
+	match, args := ft.Match(terms)
+	if !strings.HasPrefix(match, "MATCH (") || !reflect.DeepEqual(args, []any{"+martin +fowler"}) {
+		t.Errorf("Unexpected match %q with %v", match, args)
+	}
+	rank, args := ft.Rank(terms)
+	if rank != match+" DESC" || !reflect.DeepEqual(args, []any{"+martin +fowler"}) {
+		t.Errorf("Unexpected rank %q with %v", rank, args)
+	}
+}
@@ -0,0 +1,136 @@
+package mysql
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/efixler/scrape/database"
+)
+
+const (
+	MaintenanceSchedule = 1 * time.Hour
+	// Tables are rebuilt (to reclaim the space left by deleted rows) on the
+	// first maintenance run, and then at most this often.
+	OptimizeInterval = 7 * 24 * time.Hour
+	// Expired rows are deleted in batches of this size, so that deletes
+	// don't hold locks on a busy table for long.
+	DeleteBatchSize = 1000
+	qDeleteExpired  = `DELETE FROM urls WHERE expires < ? LIMIT ?`
+	// Mappings to urls that have expired, or been deleted.
+	qDeleteOrphans = `DELETE FROM id_map WHERE NOT EXISTS
+		(SELECT 1 FROM urls WHERE urls.id = id_map.canonical_id) LIMIT ?`
+	qOptimize = `OPTIMIZE TABLE urls, id_map, urls_fts, url_history, url_archive, url_renders`
+)
+
+// Schedule maintenance once the connection is open.
+func (s *MySQL) AfterOpen(dbh *database.DBHandle) error {
+	return dbh.Maintenance(MaintenanceSchedule, s.Maintain)
+}
+
+// Implements database.Maintainable. Deletes expired urls and the id mappings
+// that point to urls that are no longer stored, and optimizes the tables if
+// they haven't been optimized for OptimizeInterval.
+func (s *MySQL) Maintain(dbh *database.DBHandle) error {
+	expired, err := deleteInBatches(dbh, qDeleteExpired, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("error deleting expired urls: %w", err)
+	}
+	orphans, err := deleteInBatches(dbh, qDeleteOrphans)
+	if err != nil {
+		return fmt.Errorf("error deleting orphaned id mappings: %w", err)
+	}
+	slog.Info("mysql: maintenance", "expired_urls", expired, "orphaned_ids", orphans, "dsn", dbh)
+	if s.maintenance.optimizeDue() {
+		if err = optimize(dbh); err != nil {
+			return fmt.Errorf("error optimizing tables: %w", err)
+		}
+		s.maintenance.optimized()
+	}
+	s.maintenance.ran()
+	return nil
+}
+
+// Run a delete query, that takes a LIMIT as its last param, until it
+// deletes fewer rows than the batch size. Returns the number of rows deleted.
+func deleteInBatches(dbh *database.DBHandle, query string, args ...any) (int64, error) {
+	args = append(args, DeleteBatchSize)
+	var total int64
+	for {
+		result, err := dbh.DB.ExecContext(dbh.Ctx, query, args...)
+		if err != nil {
+			return total, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += rows
+		if rows < DeleteBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// OPTIMIZE TABLE returns a result set with a row for each table (InnoDB
+// reports that it recreates the table instead), which needs to be read
+// through for errors.
+func optimize(dbh *database.DBHandle) error {
+	rows, err := dbh.DB.QueryContext(dbh.Ctx, qOptimize)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, op, msgType, msgText string
+		if err = rows.Scan(&table, &op, &msgType, &msgText); err != nil {
+			return err
+		}
+		if strings.EqualFold(msgType, "error") {
+			return fmt.Errorf("%s: %s", table, msgText)
+		}
+	}
+	return rows.Err()
+}
+
+// Times of the last maintenance runs, which are reported in Stats.
+type maintenanceState struct {
+	mutex         sync.Mutex
+	lastRun       time.Time
+	lastOptimized time.Time
+}
+
+func (m *maintenanceState) optimizeDue() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return time.Since(m.lastOptimized) > OptimizeInterval
+}
+
+func (m *maintenanceState) optimized() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastOptimized = time.Now()
+}
+
+func (m *maintenanceState) ran() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastRun = time.Now()
+}
+
+// Returns nil times for maintenance that hasn't run in this process.
+func (m *maintenanceState) times() (lastRun, lastOptimized *time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.lastRun.IsZero() {
+		t := m.lastRun
+		lastRun = &t
+	}
+	if !m.lastOptimized.IsZero() {
+		t := m.lastOptimized
+		lastOptimized = &t
+	}
+	return lastRun, lastOptimized
+}
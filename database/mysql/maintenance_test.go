@@ -0,0 +1,119 @@
+//go:build mysql
+
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/database"
+)
+
+// A migrated test database. With a single connection, the `USE` in the
+// create script applies to everything that follows.
+func testDatabase(t *testing.T) *database.DBHandle {
+	dbh := database.New(MustNew(
+		Username("root"),
+		Password(""),
+		NetAddress("localhost:3306"),
+		Schema("scrape_test"),
+		WithMaxConnections(1),
+		ForMigration(),
+	))
+	if err := dbh.Open(context.Background()); err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := dbh.MigrateReset(); err != nil {
+			t.Errorf("Error resetting mysql test db: %v", err)
+		}
+		if err := dbh.Close(); err != nil {
+			t.Errorf("Error closing mysql database: %v", err)
+		}
+	})
+	if err := dbh.MigrateUp(); err != nil {
+		t.Fatalf("Error migrating database: %v", err)
+	}
+	return dbh
+}
+
+func count(t *testing.T, dbh *database.DBHandle, table string) int {
+	var n int
+	if err := dbh.QueryRowContext(dbh.Ctx, "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil {
+		t.Fatalf("Error counting %s: %v", table, err)
+	}
+	return n
+}
+
+func TestMaintain(t *testing.T) {
+	dbh := testDatabase(t)
+	_, err := dbh.ExecContext(
+		dbh.Ctx,
+		`INSERT INTO urls (id, url, parsed_url, fetch_time, expires, metadata) VALUES (?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?)`,
+		1, "https://example.com/expired", "https://example.com/expired", 0, 1, "{}",
+		2, "https://example.com/current", "https://example.com/current", 0, 1<<40, "{}",
+	)
+	if err != nil {
+		t.Fatalf("Error inserting urls: %v", err)
+	}
+	_, err = dbh.ExecContext(
+		dbh.Ctx,
+		`INSERT INTO id_map (requested_id, canonical_id) VALUES (10, 1), (11, 2), (12, 3)`,
+	)
+	if err != nil {
+		t.Fatalf("Error inserting id mappings: %v", err)
+	}
+	e := dbh.Engine.(*MySQL)
+	if err := e.Maintain(dbh); err != nil {
+		t.Fatalf("Error maintaining database: %v", err)
+	}
+	if n := count(t, dbh, "urls"); n != 1 {
+		t.Errorf("Expected 1 url after removing expired urls, got %d", n)
+	}
+	if n := count(t, dbh, "id_map"); n != 1 {
+		t.Errorf("Expected 1 id mapping after removing orphans, got %d", n)
+	}
+	lastRun, lastOptimized := e.maintenance.times()
+	if lastRun == nil || lastOptimized == nil {
+		t.Errorf("Expected maintenance and optimize times, got %v, %v", lastRun, lastOptimized)
+	}
+	if e.maintenance.optimizeDue() {
+		t.Errorf("Expected optimize not to be due right after optimizing")
+	}
+}
+
+func TestStats(t *testing.T) {
+	dbh := testDatabase(t)
+	_, err := dbh.ExecContext(
+		dbh.Ctx,
+		`INSERT INTO urls (id, url, parsed_url, fetch_time, expires, metadata) VALUES (?, ?, ?, ?, ?, ?)`,
+		1, "https://example.com/expired", "https://example.com/expired", 0, time.Now().Add(-time.Hour).Unix(), "{}",
+	)
+	if err != nil {
+		t.Fatalf("Error inserting url: %v", err)
+	}
+	stats, err := dbh.Stats()
+	if err != nil {
+		t.Fatalf("Error getting stats: %v", err)
+	}
+	mStats, ok := stats.Engine.(*Stats)
+	if !ok {
+		t.Fatalf("Expected *mysql.Stats, got %T", stats.Engine)
+	}
+	if mStats.ServerVersion == "" {
+		t.Errorf("Expected a server version")
+	}
+	if mStats.ExpiredURLs != 1 {
+		t.Errorf("Expected 1 expired url, got %d", mStats.ExpiredURLs)
+	}
+	tables := make(map[string]bool, len(mStats.Tables))
+	for _, ts := range mStats.Tables {
+		tables[ts.Name] = true
+	}
+	for _, name := range []string{"urls", "id_map", "domain_settings", "urls_fts"} {
+		if !tables[name] {
+			t.Errorf("Expected stats for table %s, got %+v", name, mStats.Tables)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+-- This migration adds a full text index over stored content. The index
+-- is kept in its own table, which is maintained by the application when urls
+-- are saved. Deletes cascade from the urls table.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS `urls_fts` (
+    `id` BIGINT UNSIGNED NOT NULL,
+    `title` VARCHAR(512) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_0900_ai_ci' NULL,
+    `description` TEXT CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_0900_ai_ci' NULL,
+    `content_text` MEDIUMTEXT CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_0900_ai_ci' NULL,
+    PRIMARY KEY (`id`),
+    FULLTEXT INDEX `urls_fts_index` (`title`, `description`, `content_text`),
+    CONSTRAINT `urls_fts_urls_fk` FOREIGN KEY (`id`) REFERENCES `urls` (`id`) ON DELETE CASCADE
+);
+INSERT INTO `urls_fts` (`id`, `title`, `description`, `content_text`)
+    SELECT `id`, LEFT(`metadata`->>'$.title', 512), `metadata`->>'$.description', `content_text` FROM `urls`;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS `urls_fts`;
+-- +goose StatementEnd
@@ -0,0 +1,49 @@
+-- This migration adds hostname, published and status_code columns to
+-- the urls table, so that stored pages can be listed and filtered without
+-- parsing the metadata.
+-- Backfilled publish dates ignore the timezone offset of the stored date.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `urls`
+    ADD COLUMN `hostname` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_0900_ai_ci' NULL,
+    ADD COLUMN `published` BIGINT NULL,
+    ADD COLUMN `status_code` INT NOT NULL DEFAULT 0;
+
+UPDATE `urls` SET
+    `hostname` = LOWER(`metadata`->>'$.hostname'),
+    `published` = CASE WHEN JSON_TYPE(`metadata`->'$.date') = 'STRING' THEN
+        TIMESTAMPDIFF(
+            SECOND, 
+            '1970-01-01 00:00:00', 
+            STR_TO_DATE(LEFT(`metadata`->>'$.date', 19), '%Y-%m-%dT%H:%i:%s')
+        )
+    END,
+    `status_code` = CASE WHEN JSON_TYPE(`metadata`->'$.status_code') = 'INTEGER' THEN
+        `metadata`->>'$.status_code'
+    ELSE 0 END;
+
+CREATE INDEX urls_fetch_time_index ON urls (
+    fetch_time DESC,
+    id DESC
+);
+
+CREATE INDEX urls_hostname_index ON urls (
+    hostname ASC,
+    fetch_time DESC
+);
+
+CREATE INDEX urls_published_index ON urls (
+    published DESC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `urls`
+    DROP INDEX `urls_published_index`,
+    DROP INDEX `urls_hostname_index`,
+    DROP INDEX `urls_fetch_time_index`,
+    DROP COLUMN `status_code`,
+    DROP COLUMN `published`,
+    DROP COLUMN `hostname`;
+-- +goose StatementEnd
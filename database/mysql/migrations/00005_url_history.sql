@@ -0,0 +1,22 @@
+-- This migration adds a table for keeping previous versions of stored
+-- content. History is opt-in; nothing is written here unless it's enabled
+-- in the URL data store.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS `url_history` (
+    `id` BIGINT UNSIGNED NOT NULL,
+    `fetch_time` BIGINT NOT NULL,
+    `checksum` BIGINT NOT NULL,
+    `url` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_0900_ai_ci' NOT NULL,
+    `fetch_method` INT UNSIGNED NOT NULL DEFAULT 0,
+    `metadata` JSON NOT NULL,
+    `content_text` MEDIUMTEXT CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_0900_ai_ci' NULL,
+    PRIMARY KEY (`id`, `fetch_time`),
+    INDEX `url_history_fetch_time_index` (`fetch_time` ASC)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS `url_history`;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- This migration adds a table for archiving the raw responses that content
+-- is extracted from. Archiving is opt-in; nothing is written here unless
+-- an archive store is configured for fetching.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS `url_archive` (
+    `id` BIGINT UNSIGNED NOT NULL,
+    `url` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_0900_ai_ci' NOT NULL,
+    `fetch_time` BIGINT NOT NULL,
+    `expires` BIGINT NOT NULL DEFAULT 0,
+    `headers` JSON NULL,
+    `body` LONGBLOB NULL,
+    PRIMARY KEY (`id`),
+    INDEX `url_archive_expires_index` (`expires` ASC)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS `url_archive`;
+-- +goose StatementEnd
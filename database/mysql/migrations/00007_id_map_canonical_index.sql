@@ -0,0 +1,13 @@
+-- This migration indexes id_map by canonical id, so that all of the
+-- requested urls that map to a stored page can be found.
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX id_map_canonical_index ON `id_map` (
+    `canonical_id` ASC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX id_map_canonical_index ON `id_map`;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- This migration makes content_text a binary column, so that it can hold
+-- compressed text, and adds a content_size column with the uncompressed
+-- size of the text.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `urls`
+    MODIFY COLUMN `content_text` MEDIUMBLOB NULL,
+    ADD COLUMN `content_size` INT UNSIGNED NOT NULL DEFAULT 0;
+
+UPDATE `urls` SET `content_size` = COALESCE(LENGTH(`content_text`), 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- Compressed content can't be read by earlier versions, so those pages are
+-- removed, to be fetched again when they're next requested.
+-- +goose StatementBegin
+DELETE FROM `urls` WHERE LEFT(`content_text`, 1) = X'FF';
+
+ALTER TABLE `urls`
+    MODIFY COLUMN `content_text` MEDIUMTEXT CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_0900_ai_ci' NULL,
+    DROP COLUMN `content_size`;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- This migration adds a table for screenshots and PDF renders of pages,
+-- which are made with the headless browser.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS `url_renders` (
+    `id` BIGINT UNSIGNED NOT NULL,
+    `format` VARCHAR(16) NOT NULL,
+    `url` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_0900_ai_ci' NOT NULL,
+    `render_time` BIGINT NOT NULL,
+    `expires` BIGINT NOT NULL DEFAULT 0,
+    `body` LONGBLOB NULL,
+    PRIMARY KEY (`id`, `format`),
+    INDEX `url_renders_expires_index` (`expires` ASC)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS `url_renders`;
+-- +goose StatementEnd
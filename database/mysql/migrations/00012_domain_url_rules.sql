@@ -0,0 +1,10 @@
+-- This migration adds per-domain url normalization rules to domain settings.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `domain_settings` ADD COLUMN `url_rules` JSON;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `domain_settings` DROP COLUMN `url_rules`;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- This migration adds a hostname_key column to the urls table, with the
+-- reversed hostname, so that pages from a hostname and its subdomains can be
+-- listed with an indexed range. Keys are compared byte by byte.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `urls`
+    ADD COLUMN `hostname_key` VARCHAR(256) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_bin' NULL;
+
+UPDATE `urls` SET `hostname_key` = CONCAT(REVERSE(LOWER(`hostname`)), '.')
+WHERE `hostname` IS NOT NULL AND `hostname` != '';
+
+CREATE INDEX urls_hostname_key_index ON urls (
+    hostname_key ASC,
+    fetch_time DESC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `urls`
+    DROP INDEX `urls_hostname_key_index`,
+    DROP COLUMN `hostname_key`;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- This migration makes url_history.content_text a binary column, so that
+-- previous versions of pages can be stored compressed, like the urls table.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `url_history`
+    MODIFY COLUMN `content_text` MEDIUMBLOB NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Compressed versions can't be read by earlier versions, so they're removed.
+-- +goose StatementBegin
+DELETE FROM `url_history` WHERE LEFT(`content_text`, 1) = X'FF';
+
+ALTER TABLE `url_history`
+    MODIFY COLUMN `content_text` MEDIUMTEXT CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_0900_ai_ci' NULL;
+-- +goose StatementEnd
@@ -1,4 +1,4 @@
-// DSN options and migration support for MySQL databases.
+// DSN options, migration support and maintenance for MySQL databases.
 package mysql
 
 import (
@@ -14,7 +14,9 @@ const MySQLDriver = "mysql"
 var migrationFS embed.FS
 
 type MySQL struct {
-	config Config
+	config      Config
+	maintenance *maintenanceState
+	stats       *Stats
 }
 
 func New(options ...Option) (*MySQL, error) {
@@ -28,7 +30,8 @@ func New(options ...Option) (*MySQL, error) {
 		config.migrationFS = migrationFS
 	}
 	s := &MySQL{
-		config: config,
+		config:      config,
+		maintenance: &maintenanceState{},
 	}
 	return s, nil
 }
@@ -53,6 +56,16 @@ func (s MySQL) MigrationFS() fs.FS {
 	return s.config.migrationFS
 }
 
+func (s MySQL) Dialect() database.Dialect {
+	return Dialect{}
+}
+
 func (s MySQL) MigrationEnv() []string {
 	return []string{"TargetSchema", s.config.Schema()}
 }
+
+// The MySQL full text index is created by migration, so search is
+// always available.
+func (s MySQL) FullTextSearch() bool {
+	return true
+}
@@ -3,6 +3,9 @@ package mysql
 import (
 	"errors"
 	"testing"
+	"time"
+
+	"github.com/efixler/scrape/database"
 )
 
 func TestOptionError(t *testing.T) {
@@ -31,3 +34,38 @@ func TestMigrationEnvContainsTargetSchema(t *testing.T) {
 	}
 	t.Errorf("TargetSchema not found in env")
 }
+
+func TestMaintenanceState(t *testing.T) {
+	var m maintenanceState
+	if !m.optimizeDue() {
+		t.Errorf("Expected optimize to be due before the first run")
+	}
+	if lastRun, lastOptimized := m.times(); lastRun != nil || lastOptimized != nil {
+		t.Errorf("Expected no maintenance times before the first run, got %v, %v", lastRun, lastOptimized)
+	}
+	m.ran()
+	m.optimized()
+	if m.optimizeDue() {
+		t.Errorf("Expected optimize not to be due after optimizing")
+	}
+	m.lastOptimized = time.Now().Add(-OptimizeInterval - time.Second)
+	if !m.optimizeDue() {
+		t.Errorf("Expected optimize to be due after %s", OptimizeInterval)
+	}
+	if lastRun, _ := m.times(); lastRun == nil {
+		t.Errorf("Expected a last run time")
+	}
+}
+
+func TestImplementsMaintenanceInterfaces(t *testing.T) {
+	var e any = MustNew()
+	if _, ok := e.(database.Maintainable); !ok {
+		t.Errorf("Expected MySQL to implement database.Maintainable")
+	}
+	if _, ok := e.(database.Observable); !ok {
+		t.Errorf("Expected MySQL to implement database.Observable")
+	}
+	if _, ok := e.(database.AfterOpenHook); !ok {
+		t.Errorf("Expected MySQL to implement database.AfterOpenHook")
+	}
+}
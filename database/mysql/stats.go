@@ -0,0 +1,95 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/efixler/scrape/database"
+)
+
+const (
+	minStatsInterval = 1 * time.Minute
+	qTableStats      = `SELECT TABLE_NAME, COALESCE(TABLE_ROWS, 0), COALESCE(DATA_LENGTH, 0),
+		COALESCE(INDEX_LENGTH, 0), COALESCE(DATA_FREE, 0), UPDATE_TIME
+		FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY TABLE_NAME`
+	qExpiredCount = `SELECT COUNT(*) FROM urls WHERE expires < ?`
+)
+
+type Stats struct {
+	ServerVersion string       `json:"server_version"`
+	Tables        []TableStats `json:"tables"`
+	// Expired urls that haven't been deleted by maintenance yet.
+	ExpiredURLs     int64      `json:"expired_urls"`
+	LastMaintenance *time.Time `json:"last_maintenance,omitempty"`
+	LastOptimized   *time.Time `json:"last_optimized,omitempty"`
+	fetchTime       time.Time
+}
+
+// Row counts are estimates for InnoDB tables, and along with the sizes,
+// are only as current as MySQL's cached table statistics.
+type TableStats struct {
+	Name        string     `json:"name"`
+	Rows        int64      `json:"rows"`
+	DataMB      int        `json:"data_mb"`
+	IndexMB     int        `json:"index_mb"`
+	FreeMB      int        `json:"free_mb"`
+	LastUpdated *time.Time `json:"last_updated,omitempty"`
+}
+
+func (s Stats) expired() bool {
+	return time.Since(s.fetchTime) > minStatsInterval
+}
+
+// Implements the database.Observable interface. Return value intended to be
+// included in JSON outputs. For introspection of the results, type assert
+// to *mysql.Stats.
+func (s *MySQL) Stats(dbh *database.DBHandle) (any, error) {
+	if s.stats != nil && !s.stats.expired() {
+		return s.stats, nil
+	}
+	rows, err := dbh.DB.QueryContext(dbh.Ctx, qTableStats)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := make([]TableStats, 0, 8)
+	for rows.Next() {
+		var (
+			ts                TableStats
+			data, index, free int64
+		)
+		if err := rows.Scan(&ts.Name, &ts.Rows, &data, &index, &free, &ts.LastUpdated); err != nil {
+			return nil, err
+		}
+		ts.DataMB = megabytes(data)
+		ts.IndexMB = megabytes(index)
+		ts.FreeMB = megabytes(free)
+		tables = append(tables, ts)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var expiredURLs int64
+	err = dbh.DB.QueryRowContext(dbh.Ctx, qExpiredCount, time.Now().Unix()).Scan(&expiredURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.stats == nil {
+		var version string
+		if err := dbh.DB.QueryRowContext(dbh.Ctx, "SELECT VERSION()").Scan(&version); err != nil {
+			return nil, err
+		}
+		s.stats = &Stats{ServerVersion: version}
+	}
+	s.stats.Tables = tables
+	s.stats.ExpiredURLs = expiredURLs
+	s.stats.LastMaintenance, s.stats.LastOptimized = s.maintenance.times()
+	s.stats.fetchTime = time.Now()
+	return s.stats, nil
+}
+
+func megabytes(bytes int64) int {
+	return int(bytes / (1024 * 1024))
+}
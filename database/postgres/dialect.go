@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/efixler/scrape/database"
+)
+
+type Dialect struct{}
+
+// Postgres doesn't support REPLACE INTO, so upserts update the existing row
+// on a key conflict.
+func (d Dialect) Upsert(table string, keys []string, columns ...string) string {
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ")")
+	sb.WriteString(" VALUES (" + database.Placeholders(len(columns)) + ")")
+	sb.WriteString(" ON CONFLICT (" + strings.Join(keys, ", ") + ")")
+	updates := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if !slices.Contains(keys, c) {
+			updates = append(updates, c+" = EXCLUDED."+c)
+		}
+	}
+	if len(updates) == 0 {
+		sb.WriteString(" DO NOTHING")
+	} else {
+		sb.WriteString(" DO UPDATE SET " + strings.Join(updates, ", "))
+	}
+	return sb.String()
+}
+
+// LIKE is case-sensitive in Postgres.
+func (d Dialect) ILike() string {
+	return "ILIKE"
+}
+
+func (d Dialect) JSONField(column string, field string) string {
+	return column + "->>'" + field + "'"
+}
+
+func (d Dialect) FullText() database.FullTextDialect {
+	return fullText{}
+}
+
+const (
+	// The text search configuration for the index and for queries. Like the
+	// porter tokenizer of SQLite's index, it stems English words.
+	textSearchConfig = "english"
+	// to_tsvector fails for a document whose index would be over 1MB, so only
+	// the start of very long content is indexed.
+	maxIndexedContent = 1 << 19
+)
+
+var (
+	qIndex = fmt.Sprintf(
+		`INSERT INTO urls_fts (id, document) VALUES (?, `+
+			`setweight(to_tsvector('%[1]s', coalesce(?::TEXT, '')), 'A') || `+
+			`setweight(to_tsvector('%[1]s', coalesce(?::TEXT, '')), 'B') || `+
+			`to_tsvector('%[1]s', left(coalesce(?::TEXT, ''), %[2]d))) `+
+			`ON CONFLICT (id) DO UPDATE SET document = EXCLUDED.document`,
+		textSearchConfig, maxIndexedContent,
+	)
+	qTSQuery = fmt.Sprintf(`to_tsquery('%s', ?)`, textSearchConfig)
+)
+
+// The index is a weighted tsvector of each page's title, description and
+// content text, keyed by the page's id, with a GIN index. Matches are ranked
+// with ts_rank, which favors matches in the title and description.
+type fullText struct{}
+
+func (f fullText) Index() string {
+	return qIndex
+}
+
+func (f fullText) Key() string {
+	return "id"
+}
+
+// All of the terms are required with &.
+func (f fullText) Match(terms []string) (string, []any) {
+	return "f.document @@ " + qTSQuery, []any{strings.Join(terms, " & ")}
+}
+
+func (f fullText) Rank(terms []string) (string, []any) {
+	return "ts_rank(f.document, " + qTSQuery + ") DESC", []any{strings.Join(terms, " & ")}
+}
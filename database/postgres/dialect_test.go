@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpsert(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []string
+		columns  []string
+		expected string
+	}{
+		{
+			name:     "single key",
+			keys:     []string{"id"},
+			columns:  []string{"id", "url", "body"},
+			expected: "INSERT INTO t (id, url, body) VALUES (?, ?, ?) ON CONFLICT (id) DO UPDATE SET url = EXCLUDED.url, body = EXCLUDED.body",
+		},
+		{
+			name:     "compound key",
+			keys:     []string{"id", "fetch_time"},
+			columns:  []string{"id", "fetch_time", "body"},
+			expected: "INSERT INTO t (id, fetch_time, body) VALUES (?, ?, ?) ON CONFLICT (id, fetch_time) DO UPDATE SET body = EXCLUDED.body",
+		},
+		{
+			name:     "key columns only",
+			keys:     []string{"id"},
+			columns:  []string{"id"},
+			expected: "INSERT INTO t (id) VALUES (?) ON CONFLICT (id) DO NOTHING",
+		},
+	}
+	d := Dialect{}
+	for _, test := range tests {
+		if got := d.Upsert("t", test.keys, test.columns...); got != test.expected {
+			t.Errorf("[%s] expected %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestFullTextDialect(t *testing.T) {
+	ft := Dialect{}.FullText()
+	if ft == nil {
+		t.Fatal("Expected a full text dialect")
+	}
+	if index := ft.Index(); strings.Count(index, "?") != 4 || !strings.HasPrefix(index, "INSERT INTO urls_fts (id, document)") {
+		t.Errorf("Unexpected index statement %q", index)
+	}
+	if ft.Key() != "id" {
+		t.Errorf("Expected key column id, got %q", ft.Key())
+	}
+	terms := []string{"martin", "fowler"}
+	match, args := ft.Match(terms)
+	if match != "f.document @@ to_tsquery('english', ?)" || !reflect.DeepEqual(args, []any{"martin & fowler"}) {
+		t.Errorf("Unexpected match %q with %v", match, args)
+	}
+	rank, args := ft.Rank(terms)
+	if rank != "ts_rank(f.document, to_tsquery('english', ?)) DESC" || !reflect.DeepEqual(args, []any{"martin & fowler"}) {
+		t.Errorf("Unexpected rank %q with %v", rank, args)
+	}
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Queries in this module are written with ? placeholders, which SQLite and
+// MySQL accept, but Postgres only understands numbered placeholders ($1, $2...).
+// The driver registered here wraps the pgx driver and numbers the ?
+// placeholders in queries before they're sent.
+func init() {
+	sql.Register(PostgresDriver, &rebindDriver{pgx: stdlib.GetDefaultDriver()})
+}
+
+type rebindDriver struct {
+	pgx driver.Driver
+}
+
+func (d *rebindDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.pgx.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &rebindConn{Conn: c.(*stdlib.Conn)}, nil
+}
+
+// Everything but query preparation and execution is passed through to the
+// pgx connection.
+type rebindConn struct {
+	*stdlib.Conn
+}
+
+func (c *rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rebind(query))
+}
+
+func (c *rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.PrepareContext(ctx, rebind(query))
+}
+
+func (c *rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.ExecContext(ctx, rebind(query), args)
+}
+
+func (c *rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.QueryContext(ctx, rebind(query), args)
+}
+
+// Replace the ? placeholders in a query with numbered placeholders. Question
+// marks in quoted strings and identifiers, dollar-quoted strings and comments
+// are left alone. The jsonb ? operators can't be used in queries passed
+// to this driver.
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var (
+		sb strings.Builder
+		n  int
+	)
+	sb.Grow(len(query) + 8)
+	for i := 0; i < len(query); i++ {
+		var end int
+		switch c := query[i]; {
+		case c == '?':
+			n++
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(n))
+			continue
+		case c == '\'' || c == '"':
+			end = closing(query, i+1, string(c))
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end = closing(query, i+2, "\n")
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end = closing(query, i+2, "*/")
+		case c == '$':
+			tag := dollarQuoteTag(query[i:])
+			if tag == "" {
+				sb.WriteByte(c)
+				continue
+			}
+			end = closing(query, i+len(tag), tag)
+		default:
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteString(query[i:end])
+		i = end - 1
+	}
+	return sb.String()
+}
+
+// Returns the index just past the first occurrence of delim in query at or
+// after start, or the length of the query if delim isn't found.
+func closing(query string, start int, delim string) int {
+	if idx := strings.Index(query[start:], delim); idx >= 0 {
+		return start + idx + len(delim)
+	}
+	return len(query)
+}
+
+// Returns the opening tag ($$ or $tag$) if s starts with a dollar quote.
+// A $ followed by a digit is a placeholder, not a quote.
+func dollarQuoteTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		case c >= '0' && c <= '9' && i > 1:
+		default:
+			return ""
+		}
+	}
+	return ""
+}
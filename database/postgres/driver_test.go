@@ -0,0 +1,48 @@
+package postgres
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		query  string
+		expect string
+	}{
+		{
+			"no placeholders",
+			"SELECT 1",
+			"SELECT 1",
+		},
+		{
+			"placeholders",
+			"SELECT url FROM urls WHERE id = ? AND expires > ? LIMIT ?",
+			"SELECT url FROM urls WHERE id = $1 AND expires > $2 LIMIT $3",
+		},
+		{
+			"quoted",
+			`SELECT '?', 'it''s ?', "?" FROM t WHERE a LIKE ? ESCAPE '!'`,
+			`SELECT '?', 'it''s ?', "?" FROM t WHERE a LIKE $1 ESCAPE '!'`,
+		},
+		{
+			"comments",
+			"SELECT ? -- why?\nFROM t /* really? */ WHERE a = ?",
+			"SELECT $1 -- why?\nFROM t /* really? */ WHERE a = $2",
+		},
+		{
+			"dollar quoted",
+			"SELECT $$?$$, $tag$ ? $tag$, ?",
+			"SELECT $$?$$, $tag$ ? $tag$, $1",
+		},
+		{
+			"unterminated quote",
+			"SELECT ?, 'abc?",
+			"SELECT $1, 'abc?",
+		},
+	}
+	for _, test := range tests {
+		if got := rebind(test.query); got != test.expect {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expect, got)
+		}
+	}
+}
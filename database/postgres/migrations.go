@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+const searchMigrationVersion = 15
+
+// Implements database.GoMigrationProvider. The full text index is created
+// in Go so that content stored before it existed, which may be compressed,
+// can be decompressed and indexed.
+func (s *Postgres) GoMigrations() []*goose.Migration {
+	return []*goose.Migration{
+		goMigration(searchMigrationVersion, "search_index", createSearchIndex, dropSearchIndex),
+	}
+}
+
+func goMigration(
+	version int64,
+	name string,
+	up, down func(context.Context, *sql.Tx) error,
+) *goose.Migration {
+	m := goose.NewGoMigration(version, &goose.GoFunc{RunTx: up}, &goose.GoFunc{RunTx: down})
+	// goose requires a source named like a migration file
+	m.Source = fmt.Sprintf("%05d_%s.go", version, name)
+	return m
+}
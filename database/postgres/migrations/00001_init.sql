@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS id_map (
+    requested_id BIGINT NOT NULL PRIMARY KEY,
+    canonical_id BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS urls (
+    id           BIGINT  NOT NULL PRIMARY KEY,
+    url          TEXT    NOT NULL,
+    parsed_url   TEXT    NOT NULL,
+    fetch_time   BIGINT  NOT NULL DEFAULT extract(epoch FROM now())::BIGINT,
+    fetch_method INTEGER NOT NULL DEFAULT 0,
+    expires      BIGINT  NOT NULL DEFAULT extract(epoch FROM now())::BIGINT + (86400 * 30),
+    metadata     JSONB   NOT NULL,
+    content_text TEXT
+);
+
+CREATE INDEX IF NOT EXISTS fetch_method_expires_index ON urls (
+    expires DESC,
+    fetch_method ASC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS urls;
+DROP TABLE IF EXISTS id_map;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- This migration adds per-domain settings to the database.
+-- fetch_client holds the numeric client identifier.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS domain_settings (
+    domain       VARCHAR(255) NOT NULL PRIMARY KEY,
+    sitename     VARCHAR(255),
+    fetch_client INTEGER,
+    user_agent   VARCHAR(512),
+    headers      JSONB
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS domain_settings;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- This migration adds a feed_refresh table to the database.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS feed_refresh (
+    url              TEXT   NOT NULL PRIMARY KEY,
+    last_request     BIGINT NOT NULL DEFAULT extract(epoch FROM now())::BIGINT,
+    refresh_interval BIGINT NOT NULL DEFAULT (3600 * 12),
+    last_refresh     BIGINT NOT NULL DEFAULT 0,
+    idle_timeout     BIGINT NOT NULL DEFAULT (86400 * 7)
+);
+
+-- urls are case insensitive here, as in the other engines
+CREATE UNIQUE INDEX IF NOT EXISTS feed_refresh_url_index ON feed_refresh (
+    lower(url)
+);
+
+CREATE INDEX IF NOT EXISTS feed_refresh_time_index ON feed_refresh (
+    last_refresh ASC,
+    refresh_interval ASC,
+    url ASC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS feed_refresh;
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- This migration adds hostname, published and status_code columns to
+-- the urls table, so that stored pages can be listed and filtered without
+-- parsing the metadata.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE urls
+    ADD COLUMN hostname TEXT,
+    ADD COLUMN published BIGINT,
+    ADD COLUMN status_code INTEGER NOT NULL DEFAULT 0;
+
+UPDATE urls SET
+    hostname = lower(metadata->>'hostname'),
+    published = CASE WHEN metadata->>'date' ~ '^\d{4}-\d{2}-\d{2}T' THEN
+        extract(epoch FROM (metadata->>'date')::TIMESTAMPTZ)::BIGINT
+    END,
+    status_code = CASE WHEN jsonb_typeof(metadata->'status_code') = 'number' THEN
+        (metadata->>'status_code')::INTEGER
+    ELSE 0 END;
+
+CREATE INDEX IF NOT EXISTS urls_fetch_time_index ON urls (
+    fetch_time DESC,
+    id DESC
+);
+
+CREATE INDEX IF NOT EXISTS urls_hostname_index ON urls (
+    hostname ASC,
+    fetch_time DESC
+);
+
+CREATE INDEX IF NOT EXISTS urls_published_index ON urls (
+    published DESC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS urls_published_index;
+DROP INDEX IF EXISTS urls_hostname_index;
+DROP INDEX IF EXISTS urls_fetch_time_index;
+ALTER TABLE urls
+    DROP COLUMN status_code,
+    DROP COLUMN published,
+    DROP COLUMN hostname;
+-- +goose StatementEnd
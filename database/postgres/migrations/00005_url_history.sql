@@ -0,0 +1,25 @@
+-- This migration adds a table for keeping previous versions of stored
+-- content. History is opt-in; nothing is written here unless it's enabled
+-- in the URL data store.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS url_history (
+    id           BIGINT  NOT NULL,
+    fetch_time   BIGINT  NOT NULL,
+    checksum     BIGINT  NOT NULL,
+    url          TEXT    NOT NULL,
+    fetch_method INTEGER NOT NULL DEFAULT 0,
+    metadata     JSONB   NOT NULL,
+    content_text TEXT,
+    PRIMARY KEY (id, fetch_time)
+);
+
+CREATE INDEX IF NOT EXISTS url_history_fetch_time_index ON url_history (
+    fetch_time ASC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS url_history;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- This migration adds a table for archiving the raw responses that content
+-- is extracted from. Archiving is opt-in; nothing is written here unless
+-- an archive store is configured for fetching.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS url_archive (
+    id         BIGINT NOT NULL PRIMARY KEY,
+    url        TEXT   NOT NULL,
+    fetch_time BIGINT NOT NULL,
+    expires    BIGINT NOT NULL DEFAULT 0,
+    headers    JSONB,
+    body       BYTEA
+);
+
+CREATE INDEX IF NOT EXISTS url_archive_expires_index ON url_archive (
+    expires ASC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS url_archive;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- This migration makes content_text a binary column, so that it can hold
+-- compressed text, and adds a content_size column with the uncompressed
+-- size of the text.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE urls
+    ALTER COLUMN content_text TYPE BYTEA USING convert_to(content_text, 'UTF8'),
+    ADD COLUMN content_size BIGINT NOT NULL DEFAULT 0;
+
+UPDATE urls SET content_size = coalesce(octet_length(content_text), 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- Compressed content can't be read by earlier versions, so those pages are
+-- removed, to be fetched again when they're next requested.
+-- +goose StatementBegin
+DELETE FROM urls WHERE substring(content_text FROM 1 FOR 1) = '\xff'::BYTEA;
+
+ALTER TABLE urls
+    ALTER COLUMN content_text TYPE TEXT USING convert_from(content_text, 'UTF8'),
+    DROP COLUMN content_size;
+-- +goose StatementEnd
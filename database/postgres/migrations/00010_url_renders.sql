@@ -0,0 +1,23 @@
+-- This migration adds a table for screenshots and PDF renders of pages,
+-- which are made with the headless browser.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS url_renders (
+    id          BIGINT      NOT NULL,
+    format      VARCHAR(16) NOT NULL,
+    url         TEXT        NOT NULL,
+    render_time BIGINT      NOT NULL,
+    expires     BIGINT      NOT NULL DEFAULT 0,
+    body        BYTEA,
+    PRIMARY KEY (id, format)
+);
+
+CREATE INDEX IF NOT EXISTS url_renders_expires_index ON url_renders (
+    expires ASC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS url_renders;
+-- +goose StatementEnd
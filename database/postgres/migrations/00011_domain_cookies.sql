@@ -0,0 +1,23 @@
+-- This migration adds a table for the cookies sent with requests to each domain,
+-- and a domain setting to save the cookies that sites set.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS domain_cookies (
+    domain    VARCHAR(255) NOT NULL,
+    name      VARCHAR(255) NOT NULL,
+    path      VARCHAR(255) NOT NULL DEFAULT '/',
+    value     TEXT         NOT NULL,
+    expires   BIGINT       NOT NULL DEFAULT 0,
+    secure    BOOLEAN      NOT NULL DEFAULT FALSE,
+    http_only BOOLEAN      NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (domain, name, path)
+);
+
+ALTER TABLE domain_settings ADD COLUMN persist_cookies BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE domain_settings DROP COLUMN persist_cookies;
+DROP TABLE IF EXISTS domain_cookies;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- This migration adds a hostname_key column to the urls table, with the
+-- reversed hostname, so that pages from a hostname and its subdomains can be
+-- listed with an indexed range. Keys are compared byte by byte.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE urls ADD COLUMN hostname_key TEXT COLLATE "C";
+
+UPDATE urls SET hostname_key = reverse(lower(hostname)) || '.'
+WHERE hostname IS NOT NULL AND hostname != '';
+
+CREATE INDEX IF NOT EXISTS urls_hostname_key_index ON urls (
+    hostname_key ASC,
+    fetch_time DESC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS urls_hostname_key_index;
+ALTER TABLE urls DROP COLUMN hostname_key;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- This migration makes url_history.content_text a binary column, so that
+-- previous versions of pages can be stored compressed, like the urls table.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE url_history
+    ALTER COLUMN content_text TYPE BYTEA USING convert_to(content_text, 'UTF8');
+-- +goose StatementEnd
+
+-- +goose Down
+-- Compressed versions can't be read by earlier versions, so they're removed.
+-- +goose StatementBegin
+DELETE FROM url_history WHERE substring(content_text FROM 1 FOR 1) = '\xff'::BYTEA;
+
+ALTER TABLE url_history
+    ALTER COLUMN content_text TYPE TEXT USING convert_from(content_text, 'UTF8');
+-- +goose StatementEnd
@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	nurl "net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SSLMode string
+type Option func(*Config) error
+
+const (
+	SSLDisable    SSLMode = "disable"
+	SSLAllow      SSLMode = "allow"
+	SSLPrefer     SSLMode = "prefer"
+	SSLRequire    SSLMode = "require"
+	SSLVerifyCA   SSLMode = "verify-ca"
+	SSLVerifyFull SSLMode = "verify-full"
+
+	DefaultPort            = 5432
+	defaultHost            = "localhost"
+	defaultDatabase        = "scrape"
+	DefaultMaxConnections  = 32
+	DefaultConnMaxLifetime = 1 * time.Hour
+	DefaultConnectTimeout  = 30 * time.Second
+	DefaultQueryTimeout    = 60 * time.Second
+)
+
+var (
+	ErrValueNotAllowed = errors.New("value not allowed")
+	sslModes           = []SSLMode{SSLDisable, SSLAllow, SSLPrefer, SSLRequire, SSLVerifyCA, SSLVerifyFull}
+)
+
+// Set the host and port of the Postgres server. The port is optional, and
+// defaults to 5432.
+func NetAddress(addr string) Option {
+	return func(c *Config) error {
+		if addr == "" {
+			return errors.Join(ErrValueNotAllowed, errors.New("postgres address cannot be empty"))
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			// no port
+			host, port = strings.Trim(addr, "[]"), strconv.Itoa(DefaultPort)
+		} else if _, err := strconv.Atoi(port); err != nil {
+			return errors.Join(ErrValueNotAllowed, errors.New("postgres port must be a number"), err)
+		}
+		c.Addr = net.JoinHostPort(host, port)
+		return nil
+	}
+}
+
+func Username(username string) Option {
+	return func(c *Config) error {
+		if username == "" {
+			return errors.Join(ErrValueNotAllowed, errors.New("username cannot be empty"))
+		}
+		c.User = username
+		return nil
+	}
+}
+
+func Password(password string) Option {
+	return func(c *Config) error {
+		c.Password = password
+		return nil
+	}
+}
+
+// The database to connect to. The database must already exist; migrations
+// create the tables in it, but not the database itself.
+func Database(name string) Option {
+	return func(c *Config) error {
+		if name == "" {
+			return errors.Join(ErrValueNotAllowed, errors.New("database name cannot be empty"))
+		}
+		c.Database = name
+		return nil
+	}
+}
+
+// Whether, and how, to use TLS for the connection. The default is SSLPrefer,
+// which uses TLS if the server supports it.
+func WithSSLMode(mode SSLMode) Option {
+	return func(c *Config) error {
+		if !slices.Contains(sslModes, mode) {
+			return errors.Join(ErrValueNotAllowed, fmt.Errorf("unknown ssl mode %q", mode))
+		}
+		c.SSLMode = mode
+		return nil
+	}
+}
+
+func WithMaxConnections(max int) Option {
+	return func(c *Config) error {
+		if max < 1 {
+			return errors.Join(ErrValueNotAllowed, errors.New("max connections must be at least 1"))
+		}
+		c.maxConns = max
+		return nil
+	}
+}
+
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(c *Config) error {
+		c.queryTimeout = timeout
+		return nil
+	}
+}
+
+func WithMigrationFS(fs fs.FS) Option {
+	return func(c *Config) error {
+		c.migrationFS = fs
+		return nil
+	}
+}
+
+type Config struct {
+	Addr            string
+	User            string
+	Password        string
+	Database        string
+	SSLMode         SSLMode
+	ConnectTimeout  time.Duration
+	queryTimeout    time.Duration
+	maxConns        int
+	connMaxLifetime time.Duration
+	migrationFS     fs.FS
+}
+
+func defaultConfig() Config {
+	return Config{
+		Addr:            net.JoinHostPort(defaultHost, strconv.Itoa(DefaultPort)),
+		Database:        defaultDatabase,
+		SSLMode:         SSLPrefer,
+		ConnectTimeout:  DefaultConnectTimeout,
+		queryTimeout:    DefaultQueryTimeout,
+		maxConns:        DefaultMaxConnections,
+		connMaxLifetime: DefaultConnMaxLifetime,
+	}
+}
+
+// Returns the DSN as a postgres:// url.
+func (c Config) DSN() string {
+	return c.url(c.Password).String()
+}
+
+func (c Config) url(password string) *nurl.URL {
+	u := &nurl.URL{
+		Scheme: "postgres",
+		Host:   c.Addr,
+		Path:   "/" + c.Database,
+	}
+	switch {
+	case c.User == "":
+		// the driver will use the current os user
+	case password == "":
+		u.User = nurl.User(c.User)
+	default:
+		u.User = nurl.UserPassword(c.User, password)
+	}
+	q := nurl.Values{}
+	q.Set("sslmode", string(c.SSLMode))
+	if c.ConnectTimeout > 0 {
+		q.Set("connect_timeout", strconv.Itoa(int(c.ConnectTimeout.Seconds())))
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
+func (c Config) QueryTimeout() time.Duration {
+	return c.queryTimeout
+}
+
+func (c Config) MaxConnections() int {
+	return c.maxConns
+}
+
+func (c Config) ConnMaxLifetime() time.Duration {
+	return c.connMaxLifetime
+}
+
+func (c Config) String() string {
+	s := c.url("").String()
+	if c.User != "" && c.Password != "" {
+		// the url would escape the mask
+		s = strings.Replace(s, "@", ":*****@", 1)
+	}
+	return s
+}
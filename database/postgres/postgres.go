@@ -0,0 +1,92 @@
+// DSN options, migration support and maintenance for PostgreSQL databases.
+package postgres
+
+import (
+	"embed"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/efixler/scrape/database"
+)
+
+const (
+	PostgresDriver      = string(database.Postgres)
+	MaintenanceSchedule = 24 * time.Hour
+	qDeleteExpired      = `DELETE FROM urls WHERE expires < extract(epoch FROM now())`
+	// Mappings to urls that have expired, or been deleted.
+	qDeleteOrphans = `DELETE FROM id_map WHERE NOT EXISTS
+		(SELECT 1 FROM urls WHERE urls.id = id_map.canonical_id)`
+	// VACUUM can't run in a transaction, which includes the implicit one
+	// around a multi-statement query, so it's run on its own.
+	qVacuum = `VACUUM (ANALYZE)`
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+type Postgres struct {
+	config     Config
+	statsMutex sync.Mutex
+	stats      *Stats
+}
+
+func New(options ...Option) (*Postgres, error) {
+	config := defaultConfig()
+	for _, opt := range options {
+		if err := opt(&config); err != nil {
+			return nil, err
+		}
+	}
+	if config.migrationFS == nil {
+		config.migrationFS = migrationFS
+	}
+	s := &Postgres{
+		config: config,
+	}
+	return s, nil
+}
+
+func MustNew(options ...Option) *Postgres {
+	s, err := New(options...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *Postgres) Driver() string {
+	return PostgresDriver
+}
+
+func (s *Postgres) DSNSource() database.DataSource {
+	return s.config
+}
+
+func (s *Postgres) MigrationFS() fs.FS {
+	return s.config.migrationFS
+}
+
+func (s *Postgres) Dialect() database.Dialect {
+	return Dialect{}
+}
+
+// Schedule maintenance once the connection is open. Postgres autovacuums
+// on its own, but expired urls are only removed here.
+func (s *Postgres) AfterOpen(dbh *database.DBHandle) error {
+	return dbh.Maintenance(MaintenanceSchedule, s.Maintain)
+}
+
+// Implements database.Maintainable. Deletes expired urls and the id mappings
+// that point to urls that are no longer stored, then vacuums and analyzes the
+// database.
+func (s *Postgres) Maintain(dbh *database.DBHandle) error {
+	if _, err := dbh.DB.ExecContext(dbh.Ctx, qDeleteExpired); err != nil {
+		return err
+	}
+	if _, err := dbh.DB.ExecContext(dbh.Ctx, qDeleteOrphans); err != nil {
+		return err
+	}
+	_, err := dbh.DB.ExecContext(dbh.Ctx, qVacuum)
+	return err
+}
@@ -0,0 +1,98 @@
+//go:build postgres
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/efixler/scrape/database"
+)
+
+// Postgres integration tests assume a running postgres server at
+// localhost:5432 with a postgres login that doesn't need a password,
+// and an existing scrape_test database. See `make test-postgres`.
+func testDatabase(t *testing.T) *database.DBHandle {
+	dbh := database.New(MustNew(
+		NetAddress("localhost:5432"),
+		Username("postgres"),
+		Database("scrape_test"),
+		WithSSLMode(SSLDisable),
+	))
+	if err := dbh.Open(context.Background()); err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := dbh.MigrateReset(); err != nil {
+			t.Errorf("Error resetting postgres test db: %v", err)
+		}
+		if err := dbh.Close(); err != nil {
+			t.Errorf("Error closing postgres database: %v", err)
+		}
+	})
+	if err := dbh.MigrateUp(); err != nil {
+		t.Fatalf("Error migrating database: %v", err)
+	}
+	return dbh
+}
+
+func TestMaintain(t *testing.T) {
+	dbh := testDatabase(t)
+	_, err := dbh.ExecContext(
+		dbh.Ctx,
+		`INSERT INTO urls (id, url, parsed_url, fetch_time, expires, metadata) VALUES (?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?)`,
+		1, "https://example.com/expired", "https://example.com/expired", 0, 1, "{}",
+		2, "https://example.com/current", "https://example.com/current", 0, 1<<40, "{}",
+	)
+	if err != nil {
+		t.Fatalf("Error inserting urls: %v", err)
+	}
+	_, err = dbh.ExecContext(
+		dbh.Ctx,
+		`INSERT INTO id_map (requested_id, canonical_id) VALUES (10, 1), (11, 2), (12, 3)`,
+	)
+	if err != nil {
+		t.Fatalf("Error inserting id mappings: %v", err)
+	}
+	e := dbh.Engine.(database.Maintainable)
+	if err := e.Maintain(dbh); err != nil {
+		t.Fatalf("Error maintaining database: %v", err)
+	}
+	var count int
+	if err := dbh.QueryRowContext(dbh.Ctx, `SELECT COUNT(*) FROM urls`).Scan(&count); err != nil {
+		t.Fatalf("Error counting urls: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 url after removing expired urls, got %d", count)
+	}
+	if err := dbh.QueryRowContext(dbh.Ctx, `SELECT COUNT(*) FROM id_map`).Scan(&count); err != nil {
+		t.Fatalf("Error counting id mappings: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 id mapping after removing orphans, got %d", count)
+	}
+}
+
+func TestStats(t *testing.T) {
+	dbh := testDatabase(t)
+	stats, err := dbh.Stats()
+	if err != nil {
+		t.Fatalf("Error getting stats: %v", err)
+	}
+	pgStats, ok := stats.Engine.(*Stats)
+	if !ok {
+		t.Fatalf("Expected *postgres.Stats, got %T", stats.Engine)
+	}
+	if pgStats.ServerVersion == "" {
+		t.Errorf("Expected a server version")
+	}
+	tables := make(map[string]bool, len(pgStats.Tables))
+	for _, ts := range pgStats.Tables {
+		tables[ts.Name] = true
+	}
+	for _, name := range []string{"urls", "id_map", "domain_settings", "feed_refresh"} {
+		if !tables[name] {
+			t.Errorf("Expected stats for table %s, got %+v", name, pgStats.Tables)
+		}
+	}
+}
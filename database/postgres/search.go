@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+
+	"github.com/efixler/scrape/database"
+)
+
+//go:embed search.sql
+var searchSQL string
+
+const (
+	indexBatchSize = 500
+	qExistingBatch = `SELECT id, metadata->>'title', metadata->>'description', content_text
+	FROM urls WHERE id > ? ORDER BY id LIMIT ?`
+)
+
+type indexEntry struct {
+	id          int64
+	title       sql.NullString
+	description sql.NullString
+	content     []byte
+}
+
+// Implements database.FullTextSearchable. The full text index is created by
+// migration, so search is always available.
+func (s *Postgres) FullTextSearch() bool {
+	return true
+}
+
+// Create the full text index and index the content that's already stored.
+func createSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, searchSQL); err != nil {
+		return err
+	}
+	return indexExisting(ctx, tx)
+}
+
+func dropSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE IF EXISTS urls_fts`)
+	return err
+}
+
+// Index content that was stored before the index existed. Content text may
+// be compressed, so it's decompressed here rather than indexed in SQL.
+func indexExisting(ctx context.Context, tx *sql.Tx) error {
+	var lastID int64 = -1
+	for {
+		batch, err := existingBatch(ctx, tx, lastID)
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		for _, e := range batch {
+			text, err := database.DecompressText(e.content)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, qIndex, e.id, e.title, e.description, text)
+			if err != nil {
+				return err
+			}
+		}
+		lastID = batch[len(batch)-1].id
+	}
+}
+
+func existingBatch(ctx context.Context, tx *sql.Tx, afterID int64) ([]indexEntry, error) {
+	rows, err := tx.QueryContext(ctx, qExistingBatch, afterID, indexBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	batch := make([]indexEntry, 0, indexBatchSize)
+	for rows.Next() {
+		var e indexEntry
+		if err = rows.Scan(&e.id, &e.title, &e.description, &e.content); err != nil {
+			return nil, err
+		}
+		batch = append(batch, e)
+	}
+	return batch, rows.Err()
+}
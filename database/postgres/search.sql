@@ -0,0 +1,11 @@
+-- Full text index over stored content. The title, description and content
+-- text of each page are kept as one document, weighted so that matches in
+-- the title rank above matches in the description, and those above matches
+-- in the content. Inserts and updates to the index are handled by the
+-- application when urls are saved; deletes cascade from the urls table.
+CREATE TABLE IF NOT EXISTS urls_fts (
+    id       BIGINT   NOT NULL PRIMARY KEY REFERENCES urls (id) ON DELETE CASCADE,
+    document TSVECTOR NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS urls_fts_document_index ON urls_fts USING GIN (document);
@@ -0,0 +1,81 @@
+//go:build postgres
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/efixler/scrape/database"
+)
+
+func TestSearchIndexMigration(t *testing.T) {
+	dbh := testDatabase(t)
+	var version int64
+	err := dbh.QueryRowContext(dbh.Ctx, `SELECT MAX(version_id) FROM goose_db_version`).Scan(&version)
+	if err != nil || version < searchMigrationVersion {
+		t.Fatalf("Expected search index migration to be applied, got version %d (%v)", version, err)
+	}
+	if !dbh.Engine.(database.FullTextSearchable).FullTextSearch() {
+		t.Error("Expected full text search to be available")
+	}
+
+	// Content stored before the index was created is indexed by the migration,
+	// whether or not it's compressed.
+	compressed, err := database.CompressText("compressed content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dbh.ExecContext(
+		dbh.Ctx,
+		`INSERT INTO urls (id, url, parsed_url, metadata, content_text) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)`,
+		1, "https://example.com/1", "", `{"title": "Existing"}`, []byte("plain content"),
+		2, "https://example.com/2", "", `{"title": "Another"}`, compressed,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, migrate := range []func(context.Context, *sql.Tx) error{dropSearchIndex, createSearchIndex} {
+		tx, err := dbh.DB.BeginTx(dbh.Ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = migrate(dbh.Ctx, tx); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		query    string
+		expected int64
+	}{
+		{"existing", 1},
+		{"plain & content", 1},
+		{"another", 2},
+		{"compressed", 2},
+	}
+	for _, test := range tests {
+		var id int64
+		err = dbh.QueryRowContext(
+			dbh.Ctx,
+			`SELECT id FROM urls_fts WHERE document @@ to_tsquery('english', ?)`,
+			test.query,
+		).Scan(&id)
+		if err != nil || id != test.expected {
+			t.Errorf("[%s] expected %d, got %d (%v)", test.query, test.expected, id, err)
+		}
+	}
+
+	// the index follows deletes from urls
+	if _, err = dbh.ExecContext(dbh.Ctx, `DELETE FROM urls WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err = dbh.QueryRowContext(dbh.Ctx, `SELECT COUNT(*) FROM urls_fts`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("Expected 1 index entry after delete, got %d (%v)", count, err)
+	}
+}
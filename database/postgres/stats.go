@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/efixler/scrape/database"
+)
+
+const (
+	minStatsInterval = 1 * time.Minute
+	qDatabaseSize    = `SELECT pg_database_size(current_database())`
+	qTableStats      = `SELECT relname, n_live_tup, n_dead_tup, pg_total_relation_size(relid),
+		GREATEST(last_vacuum, last_autovacuum)
+		FROM pg_stat_user_tables ORDER BY relname`
+)
+
+type Stats struct {
+	ServerVersion  string       `json:"server_version"`
+	DatabaseSizeMB int          `json:"database_size_mb"`
+	Tables         []TableStats `json:"tables"`
+	fetchTime      time.Time
+}
+
+type TableStats struct {
+	Name       string     `json:"name"`
+	LiveRows   int64      `json:"live_rows"`
+	DeadRows   int64      `json:"dead_rows"`
+	SizeMB     int        `json:"size_mb"`
+	LastVacuum *time.Time `json:"last_vacuum,omitempty"`
+}
+
+func (s Stats) expired() bool {
+	return time.Since(s.fetchTime) > minStatsInterval
+}
+
+// Implements the database.Observable interface. Return value intended to be
+// included in JSON outputs. For introspection of the results, type assert
+// to *postgres.Stats. The returned stats aren't changed afterwards; they're
+// replaced when they're refreshed.
+func (s *Postgres) Stats(dbh *database.DBHandle) (any, error) {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	if s.stats != nil && !s.stats.expired() {
+		return s.stats, nil
+	}
+	var dbSize int64
+	if err := dbh.DB.QueryRowContext(dbh.Ctx, qDatabaseSize).Scan(&dbSize); err != nil {
+		return nil, err
+	}
+	rows, err := dbh.DB.QueryContext(dbh.Ctx, qTableStats)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := make([]TableStats, 0, 8)
+	for rows.Next() {
+		var (
+			ts         TableStats
+			size       int64
+			lastVacuum sql.NullTime
+		)
+		if err := rows.Scan(&ts.Name, &ts.LiveRows, &ts.DeadRows, &size, &lastVacuum); err != nil {
+			return nil, err
+		}
+		ts.SizeMB = megabytes(size)
+		if lastVacuum.Valid {
+			ts.LastVacuum = &lastVacuum.Time
+		}
+		tables = append(tables, ts)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var version string
+	if s.stats != nil {
+		version = s.stats.ServerVersion
+	} else if err := dbh.DB.QueryRowContext(dbh.Ctx, "SHOW server_version").Scan(&version); err != nil {
+		return nil, err
+	}
+	s.stats = &Stats{
+		ServerVersion:  version,
+		DatabaseSizeMB: megabytes(dbSize),
+		Tables:         tables,
+		fetchTime:      time.Now(),
+	}
+	return s.stats, nil
+}
+
+func megabytes(bytes int64) int {
+	return int(bytes / (1024 * 1024))
+}
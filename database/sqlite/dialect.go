@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/efixler/scrape/database"
+)
+
+// SQLite's dialect is the base dialect, with an FTS5 full text index.
+type Dialect struct {
+	database.BaseDialect
+}
+
+func (d Dialect) FullText() database.FullTextDialect {
+	return fts5{}
+}
+
+// FTS5 tables are keyed by their rowid, and rank matches with bm25, best first.
+type fts5 struct{}
+
+func (f fts5) Index() string {
+	return database.BaseDialect{}.Upsert("urls_fts", []string{"rowid"}, "rowid", "title", "description", "content_text")
+}
+
+func (f fts5) Key() string {
+	return "rowid"
+}
+
+// Each term is quoted, so it's matched as a string; FTS5 requires all of them
+// to match.
+func (f fts5) Match(terms []string) (string, []any) {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + t + `"`
+	}
+	return "urls_fts MATCH ?", []any{strings.Join(quoted, " ")}
+}
+
+func (f fts5) Rank(terms []string) (string, []any) {
+	return "f.rank", nil
+}
@@ -0,0 +1,23 @@
+package sqlite
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFullTextDialect(t *testing.T) {
+	ft := Dialect{}.FullText()
+	if ft == nil {
+		t.Fatal("Expected a full text dialect")
+	}
+	if expected := "REPLACE INTO urls_fts (rowid, title, description, content_text) VALUES (?, ?, ?, ?)"; ft.Index() != expected {
+		t.Errorf("Expected index statement %q, got %q", expected, ft.Index())
+	}
+	match, args := ft.Match([]string{"martin", "fowler"})
+	if match != "urls_fts MATCH ?" || !reflect.DeepEqual(args, []any{`"martin" "fowler"`}) {
+		t.Errorf("Unexpected match %q with %v", match, args)
+	}
+	if rank, args := ft.Rank([]string{"martin"}); rank != "f.rank" || len(args) != 0 {
+		t.Errorf("Unexpected rank %q with %v", rank, args)
+	}
+}
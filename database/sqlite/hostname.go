@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/efixler/scrape/database"
+)
+
+const (
+	hostnameBatchSize = 500
+	qHostnameBatch    = `SELECT id, hostname FROM urls WHERE id > ? AND hostname IS NOT NULL AND hostname != '' ORDER BY id LIMIT ?`
+	qSetHostnameKey   = `UPDATE urls SET hostname_key = ? WHERE id = ?`
+)
+
+type hostnameEntry struct {
+	id       int64
+	hostname string
+}
+
+// Add the hostname_key column to the urls table, with the reversed hostname,
+// so that pages from a hostname and its subdomains can be listed with an
+// indexed range. See database.HostnameKey.
+func addHostnameKey(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE urls ADD COLUMN hostname_key TEXT;`); err != nil {
+		return err
+	}
+	var lastID int64 = -1
+	for {
+		batch, err := hostnameBatch(ctx, tx, lastID)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, e := range batch {
+			if _, err = tx.ExecContext(ctx, qSetHostnameKey, database.HostnameKey(e.hostname), e.id); err != nil {
+				return err
+			}
+		}
+		lastID = batch[len(batch)-1].id
+	}
+	_, err := tx.ExecContext(
+		ctx,
+		`CREATE INDEX IF NOT EXISTS urls_hostname_key_index ON urls (hostname_key ASC, fetch_time DESC);`,
+	)
+	return err
+}
+
+func dropHostnameKey(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`DROP INDEX IF EXISTS urls_hostname_key_index; ALTER TABLE urls DROP COLUMN hostname_key;`,
+	)
+	return err
+}
+
+// Each batch is read in full before it's updated, since the transaction only
+// has one connection.
+func hostnameBatch(ctx context.Context, tx *sql.Tx, afterID int64) ([]hostnameEntry, error) {
+	rows, err := tx.QueryContext(ctx, qHostnameBatch, afterID, hostnameBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	batch := make([]hostnameEntry, 0, hostnameBatchSize)
+	for rows.Next() {
+		var e hostnameEntry
+		if err = rows.Scan(&e.id, &e.hostname); err != nil {
+			return nil, err
+		}
+		batch = append(batch, e)
+	}
+	return batch, rows.Err()
+}
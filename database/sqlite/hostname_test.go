@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/efixler/scrape/database"
+)
+
+func TestHostnameKeyMigration(t *testing.T) {
+	db := database.New(MustNew(InMemoryDB()))
+	if err := db.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err := db.DB.ExecContext(
+		db.Ctx,
+		`INSERT INTO urls (id, url, parsed_url, hostname) VALUES (1, 'https://www.example.com/', '', 'www.example.com'), (2, 'https://example.com/', '', NULL)`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pages stored before the column was added get their keys from the migration.
+	for _, migrate := range []func(context.Context, *sql.Tx) error{dropHostnameKey, addHostnameKey} {
+		tx, err := db.DB.BeginTx(db.Ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = migrate(db.Ctx, tx); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		id     int
+		expect sql.NullString
+	}{
+		{1, sql.NullString{String: "moc.elpmaxe.www.", Valid: true}},
+		{2, sql.NullString{}},
+	}
+	for _, test := range tests {
+		var key sql.NullString
+		if err := db.DB.QueryRowContext(db.Ctx, `SELECT hostname_key FROM urls WHERE id = ?`, test.id).Scan(&key); err != nil {
+			t.Fatal(err)
+		}
+		if key != test.expect {
+			t.Errorf("[%d] Expected key %v, got %v", test.id, test.expect, key)
+		}
+	}
+}
@@ -1,5 +1,6 @@
 PRAGMA foreign_keys = OFF;
 DELETE from urls where expires < strftime('%s', 'now');
+DELETE FROM id_map WHERE NOT EXISTS (SELECT 1 FROM urls WHERE urls.id = id_map.canonical_id);
 PRAGMA page_size = 32768;
 PRAGMA journal_mode = WAL;
 PRAGMA wal_checkpoint(TRUNCATE);
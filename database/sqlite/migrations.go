@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+const (
+	searchMigrationVersion      = 13
+	hostnameKeyMigrationVersion = 14
+)
+
+// Implements database.GoMigrationProvider. These migrations need to do things
+// that SQLite can't do in SQL: the full text index can only be created when
+// the SQLite library is built with FTS5, and SQLite has no function to
+// reverse the hostnames for their keys.
+func (s *SQLite) GoMigrations() []*goose.Migration {
+	return []*goose.Migration{
+		goMigration(searchMigrationVersion, "search_index", createSearchIndex, dropSearchIndex),
+		goMigration(hostnameKeyMigrationVersion, "hostname_key", addHostnameKey, dropHostnameKey),
+	}
+}
+
+func goMigration(
+	version int64,
+	name string,
+	up, down func(context.Context, *sql.Tx) error,
+) *goose.Migration {
+	m := goose.NewGoMigration(version, &goose.GoFunc{RunTx: up}, &goose.GoFunc{RunTx: down})
+	// goose requires a source named like a migration file
+	m.Source = fmt.Sprintf("%05d_%s.go", version, name)
+	return m
+}
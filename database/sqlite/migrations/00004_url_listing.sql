@@ -0,0 +1,38 @@
+-- This migration adds hostname, published and status_code columns to
+-- the urls table, so that stored pages can be listed and filtered without
+-- parsing the metadata.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE urls ADD COLUMN hostname TEXT;
+ALTER TABLE urls ADD COLUMN published INTEGER;
+ALTER TABLE urls ADD COLUMN status_code INTEGER NOT NULL DEFAULT 0;
+
+UPDATE urls SET
+    hostname = lower(json_extract(metadata, '$.hostname')),
+    published = unixepoch(json_extract(metadata, '$.date')),
+    status_code = coalesce(json_extract(metadata, '$.status_code'), 0);
+
+CREATE INDEX IF NOT EXISTS urls_fetch_time_index ON urls (
+    fetch_time DESC,
+    id DESC
+);
+
+CREATE INDEX IF NOT EXISTS urls_hostname_index ON urls (
+    hostname ASC,
+    fetch_time DESC
+);
+
+CREATE INDEX IF NOT EXISTS urls_published_index ON urls (
+    published DESC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS urls_published_index;
+DROP INDEX IF EXISTS urls_hostname_index;
+DROP INDEX IF EXISTS urls_fetch_time_index;
+ALTER TABLE urls DROP COLUMN status_code;
+ALTER TABLE urls DROP COLUMN published;
+ALTER TABLE urls DROP COLUMN hostname;
+-- +goose StatementEnd
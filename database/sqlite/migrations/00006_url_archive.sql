@@ -0,0 +1,25 @@
+-- This migration adds a table for archiving the raw responses that content
+-- is extracted from. Archiving is opt-in; nothing is written here unless
+-- an archive store is configured for fetching.
+-- Bodies can be large, so unlike the other tables this one keeps a rowid.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS url_archive (
+    id          INTEGER PRIMARY KEY ON CONFLICT REPLACE,
+    url         TEXT    NOT NULL,
+    fetch_time  INTEGER NOT NULL,
+    expires     INTEGER NOT NULL DEFAULT 0,
+    headers     TEXT,
+    body        BLOB
+)
+STRICT;
+
+CREATE INDEX IF NOT EXISTS url_archive_expires_index ON url_archive (
+    expires ASC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS url_archive;
+-- +goose StatementEnd
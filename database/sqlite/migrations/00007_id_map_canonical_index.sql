@@ -0,0 +1,13 @@
+-- This migration indexes id_map by canonical id, so that all of the
+-- requested urls that map to a stored page can be found.
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS id_map_canonical_index ON id_map (
+    canonical_id ASC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS id_map_canonical_index;
+-- +goose StatementEnd
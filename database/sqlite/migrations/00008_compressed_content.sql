@@ -0,0 +1,114 @@
+-- This migration makes content_text a BLOB column, so that it can hold
+-- compressed text, and adds a content_size column with the uncompressed
+-- size of the text. SQLite can't change the type of a column in a STRICT
+-- table, so the table is rebuilt.
+-- The urls_fts_delete trigger is dropped along with the old table, and is
+-- recreated by the search index migration (00013, in search.go).
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE urls_compressed (
+    id           INTEGER PRIMARY KEY ON CONFLICT REPLACE
+                         NOT NULL,
+    url          TEXT    NOT NULL
+                         COLLATE NOCASE,
+    parsed_url   TEXT    NOT NULL,
+    fetch_time   INTEGER DEFAULT (unixepoch() ),
+    fetch_method INTEGER NOT NULL DEFAULT 0,
+    expires      INTEGER DEFAULT (unixepoch() + (86400 * 30)),
+    metadata     TEXT,
+    content_text BLOB,
+    hostname     TEXT,
+    published    INTEGER,
+    status_code  INTEGER NOT NULL DEFAULT 0,
+    content_size INTEGER NOT NULL DEFAULT 0
+)
+WITHOUT ROWID,
+STRICT;
+
+INSERT INTO urls_compressed (
+    id, url, parsed_url, fetch_time, fetch_method, expires, metadata,
+    content_text, hostname, published, status_code, content_size
+)
+SELECT 
+    id, url, parsed_url, fetch_time, fetch_method, expires, metadata,
+    CAST(content_text AS BLOB), hostname, published, status_code, 
+    coalesce(length(CAST(content_text AS BLOB)), 0)
+FROM urls;
+
+DROP TABLE urls;
+ALTER TABLE urls_compressed RENAME TO urls;
+
+CREATE INDEX IF NOT EXISTS fetch_method_expires_index ON urls (
+    expires DESC,
+    fetch_method ASC
+);
+
+CREATE INDEX IF NOT EXISTS urls_fetch_time_index ON urls (
+    fetch_time DESC,
+    id DESC
+);
+
+CREATE INDEX IF NOT EXISTS urls_hostname_index ON urls (
+    hostname ASC,
+    fetch_time DESC
+);
+
+CREATE INDEX IF NOT EXISTS urls_published_index ON urls (
+    published DESC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- Compressed content can't be read by earlier versions, so those pages are
+-- removed, to be fetched again when they're next requested.
+-- +goose StatementBegin
+CREATE TABLE urls_uncompressed (
+    id           INTEGER PRIMARY KEY ON CONFLICT REPLACE
+                         NOT NULL,
+    url          TEXT    NOT NULL
+                         COLLATE NOCASE,
+    parsed_url   TEXT    NOT NULL,
+    fetch_time   INTEGER DEFAULT (unixepoch() ),
+    fetch_method INTEGER NOT NULL DEFAULT 0,
+    expires      INTEGER DEFAULT (unixepoch() + (86400 * 30)),
+    metadata     TEXT,
+    content_text TEXT,
+    hostname     TEXT,
+    published    INTEGER,
+    status_code  INTEGER NOT NULL DEFAULT 0
+)
+WITHOUT ROWID,
+STRICT;
+
+INSERT INTO urls_uncompressed (
+    id, url, parsed_url, fetch_time, fetch_method, expires, metadata,
+    content_text, hostname, published, status_code
+)
+SELECT 
+    id, url, parsed_url, fetch_time, fetch_method, expires, metadata,
+    CAST(content_text AS TEXT), hostname, published, status_code
+FROM urls
+WHERE content_text IS NULL OR substr(content_text, 1, 1) != X'FF';
+
+DROP TABLE urls;
+ALTER TABLE urls_uncompressed RENAME TO urls;
+
+CREATE INDEX IF NOT EXISTS fetch_method_expires_index ON urls (
+    expires DESC,
+    fetch_method ASC
+);
+
+CREATE INDEX IF NOT EXISTS urls_fetch_time_index ON urls (
+    fetch_time DESC,
+    id DESC
+);
+
+CREATE INDEX IF NOT EXISTS urls_hostname_index ON urls (
+    hostname ASC,
+    fetch_time DESC
+);
+
+CREATE INDEX IF NOT EXISTS urls_published_index ON urls (
+    published DESC
+);
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- This migration adds per-domain headless browser options to domain settings.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE domain_settings ADD COLUMN headless TEXT CHECK (headless IS NULL OR json_valid(headless));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE domain_settings DROP COLUMN headless;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- This migration adds a table for screenshots and PDF renders of pages,
+-- which are made with the headless browser.
+-- Renders can be large, so like url_archive this table keeps a rowid.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS url_renders (
+    id          INTEGER NOT NULL,
+    format      TEXT    NOT NULL,
+    url         TEXT    NOT NULL,
+    render_time INTEGER NOT NULL,
+    expires     INTEGER NOT NULL DEFAULT 0,
+    body        BLOB,
+    PRIMARY KEY (id, format)
+)
+STRICT;
+
+CREATE INDEX IF NOT EXISTS url_renders_expires_index ON url_renders (
+    expires ASC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS url_renders;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- This migration adds a table for the cookies sent with requests to each domain,
+-- and a domain setting to save the cookies that sites set.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS domain_cookies (
+    domain    TEXT    NOT NULL,
+    name      TEXT    NOT NULL,
+    path      TEXT    NOT NULL DEFAULT '/',
+    value     TEXT    NOT NULL,
+    expires   INTEGER NOT NULL DEFAULT 0,
+    secure    INTEGER NOT NULL DEFAULT 0,
+    http_only INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (domain, name, path)
+)
+STRICT, WITHOUT ROWID;
+
+ALTER TABLE domain_settings ADD COLUMN persist_cookies INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE domain_settings DROP COLUMN persist_cookies;
+DROP TABLE IF EXISTS domain_cookies;
+-- +goose StatementEnd
@@ -0,0 +1,67 @@
+-- This migration makes url_history.content_text a BLOB column, so that
+-- previous versions of pages can be stored compressed, like the urls table.
+-- SQLite can't change the type of a column in a STRICT table, so the table
+-- is rebuilt.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE url_history_compressed (
+    id           INTEGER NOT NULL,
+    fetch_time   INTEGER NOT NULL,
+    checksum     INTEGER NOT NULL,
+    url          TEXT    NOT NULL,
+    fetch_method INTEGER NOT NULL DEFAULT 0,
+    metadata     TEXT,
+    content_text BLOB,
+    PRIMARY KEY (id, fetch_time) ON CONFLICT REPLACE
+)
+WITHOUT ROWID,
+STRICT;
+
+INSERT INTO url_history_compressed (
+    id, fetch_time, checksum, url, fetch_method, metadata, content_text
+)
+SELECT
+    id, fetch_time, checksum, url, fetch_method, metadata,
+    CAST(content_text AS BLOB)
+FROM url_history;
+
+DROP TABLE url_history;
+ALTER TABLE url_history_compressed RENAME TO url_history;
+
+CREATE INDEX IF NOT EXISTS url_history_fetch_time_index ON url_history (
+    fetch_time ASC
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- Compressed versions can't be read by earlier versions, so they're removed.
+-- +goose StatementBegin
+CREATE TABLE url_history_uncompressed (
+    id           INTEGER NOT NULL,
+    fetch_time   INTEGER NOT NULL,
+    checksum     INTEGER NOT NULL,
+    url          TEXT    NOT NULL,
+    fetch_method INTEGER NOT NULL DEFAULT 0,
+    metadata     TEXT,
+    content_text TEXT,
+    PRIMARY KEY (id, fetch_time) ON CONFLICT REPLACE
+)
+WITHOUT ROWID,
+STRICT;
+
+INSERT INTO url_history_uncompressed (
+    id, fetch_time, checksum, url, fetch_method, metadata, content_text
+)
+SELECT
+    id, fetch_time, checksum, url, fetch_method, metadata,
+    CAST(content_text AS TEXT)
+FROM url_history
+WHERE content_text IS NULL OR substr(content_text, 1, 1) != X'FF';
+
+DROP TABLE url_history;
+ALTER TABLE url_history_uncompressed RENAME TO url_history;
+
+CREATE INDEX IF NOT EXISTS url_history_fetch_time_index ON url_history (
+    fetch_time ASC
+);
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- This migration adds a domain setting that sends auto fetches for the domain's
+-- pages to the headless browser first, which is set when a direct fetch of one
+-- of its pages needed the headless browser.
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE domain_settings ADD COLUMN headless_preferred INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE domain_settings DROP COLUMN headless_preferred;
+-- +goose StatementEnd
@@ -116,9 +116,12 @@ func (o config) DSN() string {
 	return o.String()
 }
 
+// Transactions take the write lock when they begin, so that a transaction
+// that reads before it writes waits for other writers, instead of failing
+// when it tries to write.
 func (o config) String() string {
 	return fmt.Sprintf(
-		"file:%s?mode=%s&_busy_timeout=%d&_journal_mode=%s&_cache_size=%d&_sync=%s",
+		"file:%s?mode=%s&_busy_timeout=%d&_journal_mode=%s&_cache_size=%d&_sync=%s&_txlock=immediate",
 		o.filename,
 		o.accessMode,
 		o.busyTimeout.Milliseconds(),
@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"log/slog"
+
+	"github.com/efixler/scrape/database"
+)
+
+//go:embed search.sql
+var searchSQL string
+
+const (
+	indexBatchSize = 500
+	qExistingBatch = `SELECT id, json_extract(metadata, '$.title'), json_extract(metadata, '$.description'), content_text
+	FROM urls WHERE id > ? ORDER BY id LIMIT ?`
+)
+
+type indexEntry struct {
+	id          int64
+	title       sql.NullString
+	description sql.NullString
+	content     []byte
+}
+
+// Implements database.FullTextSearchable. Search is only available when
+// the SQLite library is built with FTS5, which requires the sqlite_fts5
+// build tag.
+func (s *SQLite) FullTextSearch() bool {
+	return s.fullTextSearch
+}
+
+// Create the full text index and its delete trigger, if FTS5 is available,
+// and index the content that's already stored. The trigger is recreated when
+// the index already exists, since rebuilding the urls table drops it.
+func createSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	fts5, err := fts5Available(ctx, tx)
+	if err != nil || !fts5 {
+		return err
+	}
+	hasIndex, err := searchIndexExists(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, searchSQL); err != nil {
+		return err
+	}
+	if hasIndex {
+		return nil
+	}
+	return indexExisting(ctx, tx)
+}
+
+func dropSearchIndex(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TRIGGER IF EXISTS urls_fts_delete; DROP TABLE IF EXISTS urls_fts;`)
+	return err
+}
+
+// Enable search when FTS5 is available and the index is in place. A database
+// that was migrated by a build without FTS5 has no index, and search stays
+// disabled for it.
+func (s *SQLite) enableSearch(dbh *database.DBHandle) error {
+	fts5, err := fts5Available(dbh.Ctx, dbh.DB)
+	if err != nil || !fts5 {
+		return err
+	}
+	hasIndex, err := searchIndexExists(dbh.Ctx, dbh.DB)
+	if err != nil {
+		return err
+	}
+	if !hasIndex {
+		slog.Warn("sqlite: full text index is missing, search is disabled", "database", dbh)
+		return nil
+	}
+	s.fullTextSearch = true
+	return nil
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func fts5Available(ctx context.Context, q querier) (bool, error) {
+	var fts5 bool
+	err := q.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5');").Scan(&fts5)
+	return fts5, err
+}
+
+func searchIndexExists(ctx context.Context, q querier) (bool, error) {
+	var exists bool
+	err := q.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'urls_fts');`,
+	).Scan(&exists)
+	return exists, err
+}
+
+// Index content that was stored before the index existed. Content text may
+// be compressed, so it's decompressed here rather than indexed in SQL. Each
+// batch is read in full before it's indexed, since the transaction only has
+// one connection.
+func indexExisting(ctx context.Context, tx *sql.Tx) error {
+	var lastID int64 = -1
+	for {
+		batch, err := existingBatch(ctx, tx, lastID)
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		for _, e := range batch {
+			text, err := database.DecompressText(e.content)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, fts5{}.Index(), e.id, e.title, e.description, text)
+			if err != nil {
+				return err
+			}
+		}
+		lastID = batch[len(batch)-1].id
+	}
+}
+
+func existingBatch(ctx context.Context, tx *sql.Tx, afterID int64) ([]indexEntry, error) {
+	rows, err := tx.QueryContext(ctx, qExistingBatch, afterID, indexBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	batch := make([]indexEntry, 0, indexBatchSize)
+	for rows.Next() {
+		var e indexEntry
+		if err = rows.Scan(&e.id, &e.title, &e.description, &e.content); err != nil {
+			return nil, err
+		}
+		batch = append(batch, e)
+	}
+	return batch, rows.Err()
+}
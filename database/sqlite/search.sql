@@ -0,0 +1,17 @@
+-- Full text index over stored content. The index is contentless, so the
+-- text is only stored once, in the urls table. Inserts and updates to the
+-- index are handled by the application when urls are saved; deletes
+-- are handled here.
+CREATE VIRTUAL TABLE IF NOT EXISTS urls_fts USING fts5(
+    title,
+    description,
+    content_text,
+    content='',
+    contentless_delete=1,
+    tokenize='porter unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS urls_fts_delete AFTER DELETE ON urls
+BEGIN
+    DELETE FROM urls_fts WHERE rowid = old.id;
+END;
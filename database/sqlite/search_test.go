@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/efixler/scrape/database"
+)
+
+func TestSearchIndexMigration(t *testing.T) {
+	db := database.New(MustNew(InMemoryDB()))
+	if err := db.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var version int64
+	err := db.DB.QueryRowContext(db.Ctx, `SELECT MAX(version_id) FROM goose_db_version`).Scan(&version)
+	if err != nil || version < searchMigrationVersion {
+		t.Fatalf("Expected search index migration to be applied, got version %d (%v)", version, err)
+	}
+	fts5, err := fts5Available(db.Ctx, db.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasIndex, err := searchIndexExists(db.Ctx, db.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasIndex != fts5 {
+		t.Errorf("Expected index to exist: %t, got %t", fts5, hasIndex)
+	}
+	if search := db.Engine.(database.FullTextSearchable).FullTextSearch(); search != fts5 {
+		t.Errorf("Expected full text search: %t, got %t", fts5, search)
+	}
+	if !fts5 {
+		return
+	}
+
+	// Content stored before the index was created is indexed by the migration.
+	_, err = db.DB.ExecContext(
+		db.Ctx,
+		`INSERT INTO urls (id, url, parsed_url, metadata, content_text) VALUES (1, 'https://example.com/', '', '{"title": "Existing"}', CAST('existing content' AS BLOB))`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, migrate := range []func(context.Context, *sql.Tx) error{dropSearchIndex, createSearchIndex} {
+		tx, err := db.DB.BeginTx(db.Ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = migrate(db.Ctx, tx); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var rowid int64
+	err = db.DB.QueryRowContext(db.Ctx, `SELECT rowid FROM urls_fts WHERE urls_fts MATCH 'existing'`).Scan(&rowid)
+	if err != nil || rowid != 1 {
+		t.Errorf("Expected existing content to be indexed, got %d (%v)", rowid, err)
+	}
+}
@@ -16,8 +16,9 @@ const SQLiteDriver = "sqlite3"
 var migrationFS embed.FS
 
 type SQLite struct {
-	config config
-	stats  *Stats
+	config         config
+	stats          *Stats
+	fullTextSearch bool
 }
 
 func MustNew(options ...Option) *SQLite {
@@ -63,6 +64,10 @@ func (s *SQLite) MigrationFS() fs.FS {
 	return s.config.migrationFS
 }
 
+func (s SQLite) Dialect() database.Dialect {
+	return Dialect{}
+}
+
 func (s *SQLite) AfterOpen(dbh *database.DBHandle) error {
 
 	// SQLite will open even if the the DB file is not present, it will only fail later.
@@ -73,6 +78,9 @@ func (s *SQLite) AfterOpen(dbh *database.DBHandle) error {
 			return err
 		}
 	}
+	if err := s.enableSearch(dbh); err != nil {
+		return err
+	}
 	dbh.Maintenance(
 		24*time.Hour,
 		s.Maintain,
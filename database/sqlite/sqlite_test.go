@@ -51,6 +51,7 @@ func TestMaintain(t *testing.T) {
 			expectError: true,
 		},
 	}
+	defer func(sql string) { maintenanceSQL = sql }(maintenanceSQL)
 	for _, tt := range tests {
 		engine, err := New(InMemoryDB(), WithoutAutoCreate())
 		if err != nil {
@@ -76,3 +77,34 @@ func TestMaintain(t *testing.T) {
 		}
 	}
 }
+
+func TestMaintainDeletesExpiredAndOrphans(t *testing.T) {
+	engine := MustNew(InMemoryDB())
+	db := database.New(engine)
+	if err := db.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err := db.DB.ExecContext(
+		db.Ctx,
+		`INSERT INTO urls (id, url, parsed_url, expires) VALUES (1, 'https://example.com/expired', '', 1), (2, 'https://example.com/current', '', 1 << 40)`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.DB.ExecContext(db.Ctx, `INSERT INTO id_map (requested_id, canonical_id) VALUES (10, 1), (11, 2), (12, 3)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = engine.Maintain(db); err != nil {
+		t.Fatalf("Error maintaining database: %v", err)
+	}
+	var urls, mappings int
+	err = db.DB.QueryRowContext(db.Ctx, `SELECT (SELECT COUNT(*) FROM urls), (SELECT COUNT(*) FROM id_map)`).Scan(&urls, &mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls != 1 || mappings != 1 {
+		t.Errorf("Expected 1 url and 1 id mapping after maintenance, got %d and %d", urls, mappings)
+	}
+}
@@ -11,6 +11,8 @@ import (
 
 const (
 	minStatsInterval = 1 * time.Minute
+	// Content stats scan the whole urls table, so they're refreshed less often.
+	contentStatsInterval = 1 * time.Hour
 )
 
 type Stats struct {
@@ -20,8 +22,10 @@ type Stats struct {
 	UnusedPages   int              `json:"unused_pages"`
 	MaxPageCount  int              `json:"max_page_count"`
 	Filesystem    *FilesystemStats `json:"fs,omitempty"`
+	Content       *ContentStats    `json:"content,omitempty"`
 	DBStats       any              `json:"db_stats,omitempty"`
 	fetchTime     time.Time
+	contentTime   time.Time
 }
 
 func (s *Stats) DatabaseSizeMB() int {
@@ -72,10 +76,49 @@ func (s *SQLite) Stats(dbh *database.DBHandle) (any, error) {
 	s.stats.UnusedPages = unusedPages
 	s.stats.MaxPageCount = maxPageCount
 	s.stats.Filesystem = s.filesystemStats()
+	if time.Since(s.stats.contentTime) > contentStatsInterval {
+		if s.stats.Content, err = contentStats(dbh); err != nil {
+			return nil, err
+		}
+		s.stats.contentTime = time.Now()
+	}
 	s.stats.fetchTime = time.Now()
 	return s.stats, nil
 }
 
+// Sizes of the stored content text. TextBytes is the size of the text
+// before compression, and StoredBytes is the size on disk. CompressionRatio
+// is TextBytes/StoredBytes.
+type ContentStats struct {
+	Pages            int     `json:"pages"`
+	TextBytes        int64   `json:"text_bytes"`
+	StoredBytes      int64   `json:"stored_bytes"`
+	CompressionRatio float64 `json:"compression_ratio"`
+}
+
+const (
+	qHasContentSize = `SELECT EXISTS (SELECT 1 FROM pragma_table_info('urls') WHERE name = 'content_size');`
+	qContentStats   = `SELECT COUNT(*), COALESCE(SUM(content_size), 0), COALESCE(SUM(length(CAST(content_text AS BLOB))), 0) FROM urls;`
+)
+
+// Returns nil if the schema doesn't have content sizes (yet).
+func contentStats(dbh *database.DBHandle) (*ContentStats, error) {
+	var hasContentSize bool
+	err := dbh.DB.QueryRowContext(dbh.Ctx, qHasContentSize).Scan(&hasContentSize)
+	if err != nil || !hasContentSize {
+		return nil, err
+	}
+	cs := &ContentStats{}
+	err = dbh.DB.QueryRowContext(dbh.Ctx, qContentStats).Scan(&cs.Pages, &cs.TextBytes, &cs.StoredBytes)
+	if err != nil {
+		return nil, err
+	}
+	if cs.StoredBytes > 0 {
+		cs.CompressionRatio = float64(cs.TextBytes) / float64(cs.StoredBytes)
+	}
+	return cs, nil
+}
+
 type FilesystemStats struct {
 	Path    string `json:"path"`
 	TotalMB uint   `json:"total_mb"`
@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -73,3 +74,54 @@ func TestStatsIsExpired(t *testing.T) {
 		t.Errorf("Expected stats to not be expired")
 	}
 }
+
+func TestContentStats(t *testing.T) {
+	engine, err := New(InMemoryDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.New(engine)
+	if err = db.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	text := strings.Repeat("Compress me. ", 100)
+	content, err := database.CompressText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.DB.ExecContext(
+		db.Ctx,
+		`INSERT INTO urls (id, url, parsed_url, content_text, content_size) VALUES (1, ?, ?, ?, ?)`,
+		"https://example.com", "https://example.com", content, len(text),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rows migrated from before compression hold their text uncompressed
+	legacy := []byte("Ünïcödé text")
+	_, err = db.DB.ExecContext(
+		db.Ctx,
+		`INSERT INTO urls (id, url, parsed_url, content_text, content_size) VALUES (2, ?, ?, ?, ?)`,
+		"https://example.com/legacy", "https://example.com/legacy", legacy, len(legacy),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullStats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := fullStats.Engine.(*Stats).Content
+	if stats == nil {
+		t.Fatalf("Expected content stats")
+	}
+	if stats.Pages != 2 ||
+		stats.TextBytes != int64(len(text)+len(legacy)) ||
+		stats.StoredBytes != int64(len(content)+len(legacy)) {
+		t.Errorf("Unexpected content stats %+v", stats)
+	}
+	if stats.CompressionRatio <= 1 {
+		t.Errorf("Expected compression ratio > 1, got %f", stats.CompressionRatio)
+	}
+}
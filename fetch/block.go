@@ -0,0 +1,133 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Types of resources that a headless browser can skip loading.
+type ResourceType string
+
+const (
+	ResourceImage      ResourceType = "image"
+	ResourceMedia      ResourceType = "media"
+	ResourceFont       ResourceType = "font"
+	ResourceStylesheet ResourceType = "stylesheet"
+)
+
+const MaxBlockedDomains = 100
+
+var (
+	ErrInvalidResourceType = errors.New("invalid resource type")
+	ErrInvalidBlockList    = errors.New("invalid block list")
+	domainPattern          = regexp.MustCompile(`(?i)^[a-z0-9_-]+(\.[a-z0-9_-]+)*$`)
+)
+
+// What headless pages load when a BlockList isn't set anywhere: no images, video or
+// fonts, and nothing from the built-in list of ad and tracker domains.
+var DefaultBlockList = BlockList{
+	Resources: []ResourceType{ResourceImage, ResourceMedia, ResourceFont},
+	Trackers:  true,
+}
+
+func (r *ResourceType) UnmarshalText(b []byte) error {
+	switch rt := ResourceType(strings.ToLower(string(b))); rt {
+	case ResourceImage, ResourceMedia, ResourceFont, ResourceStylesheet:
+		*r = rt
+		return nil
+	default:
+		return fmt.Errorf("%w %q, expected image, media, font or stylesheet", ErrInvalidResourceType, string(b))
+	}
+}
+
+// BlockList is the requests that a headless browser doesn't make when it loads a page.
+// A domain blocks its subdomains too. Requests to the page's own host are never blocked
+// by domain. A BlockList replaces the one it overrides as a whole, so an empty BlockList
+// blocks nothing.
+type BlockList struct {
+	// Types of resources not to load
+	Resources []ResourceType `json:"resources,omitempty"`
+	// Domains not to load anything from
+	Domains []string `json:"domains,omitempty"`
+	// Block requests to the built-in list of ad and tracker domains
+	Trackers bool `json:"trackers,omitempty"`
+}
+
+// Parses a comma separated block list, for command line flags and the like. Each
+// item is a resource type, a domain, or 'trackers' for the built-in tracker list.
+// 'none' is an empty list.
+func ParseBlockList(s string) (*BlockList, error) {
+	b := &BlockList{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		var rt ResourceType
+		switch {
+		case item == "" || item == "none":
+			continue
+		case item == "trackers":
+			b.Trackers = true
+		case rt.UnmarshalText([]byte(item)) == nil:
+			b.Resources = append(b.Resources, rt)
+		default:
+			b.Domains = append(b.Domains, item)
+		}
+	}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Unknown fields are errors, as with HeadlessOptions.
+func (b *BlockList) UnmarshalJSON(data []byte) error {
+	type alias BlockList
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode((*alias)(b)); err != nil {
+		return err
+	}
+	return b.Validate()
+}
+
+// Resource types are checked when they're unmarshaled.
+func (b BlockList) Validate() error {
+	if len(b.Domains) > MaxBlockedDomains {
+		return fmt.Errorf("%w: at most %d domains", ErrInvalidBlockList, MaxBlockedDomains)
+	}
+	for _, d := range b.Domains {
+		if !domainPattern.MatchString(d) {
+			return fmt.Errorf("%w: %q isn't a domain", ErrInvalidBlockList, d)
+		}
+	}
+	return nil
+}
+
+// The block list in the form that ParseBlockList reads.
+func (b BlockList) String() string {
+	items := make([]string, 0, len(b.Resources)+len(b.Domains)+1)
+	for _, r := range b.Resources {
+		items = append(items, string(r))
+	}
+	if b.Trackers {
+		items = append(items, "trackers")
+	}
+	items = append(items, b.Domains...)
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ",")
+}
+
+// For setting block lists from text, e.g. with command line flags.
+func (b *BlockList) UnmarshalText(text []byte) error {
+	parsed, err := ParseBlockList(string(text))
+	if err != nil {
+		return err
+	}
+	*b = *parsed
+	return nil
+}
@@ -0,0 +1,67 @@
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBlockList(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		expected  *BlockList
+		expectErr error
+	}{
+		{"none", "none", &BlockList{}, nil},
+		{"default", "image,media,font,trackers", &DefaultBlockList, nil},
+		{
+			"domains",
+			" Stylesheet, Ads.Example.com,trackers",
+			&BlockList{Resources: []ResourceType{ResourceStylesheet}, Domains: []string{"ads.example.com"}, Trackers: true},
+			nil,
+		},
+		{"bad domain", "image,ads.example.com/path", nil, ErrInvalidBlockList},
+	}
+	for _, test := range tests {
+		list, err := ParseBlockList(test.text)
+		if !errors.Is(err, test.expectErr) {
+			t.Errorf("[%s] Expected error %v, got %v", test.name, test.expectErr, err)
+		}
+		if !reflect.DeepEqual(list, test.expected) {
+			t.Errorf("[%s] Expected %+v, got %+v", test.name, test.expected, list)
+		}
+		if list != nil {
+			if again, _ := ParseBlockList(list.String()); !reflect.DeepEqual(again, list) {
+				t.Errorf("[%s] Expected %q to parse to the same list, got %+v", test.name, list.String(), again)
+			}
+		}
+	}
+}
+
+func TestBlockListJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		expectErr bool
+	}{
+		{"empty", `{"block":{}}`, false},
+		{"everything", `{"block":{"resources":["image","stylesheet"],"domains":["ads.example.com"],"trackers":true}}`, false},
+		{"bad resource type", `{"block":{"resources":["script"]}}`, true},
+		{"bad domain", `{"block":{"domains":["not a domain"]}}`, true},
+		{"too many domains", `{"block":{"domains":["a.com"` + strings.Repeat(`,"a.com"`, MaxBlockedDomains) + `]}}`, true},
+		{"unknown field", `{"block":{"types":["image"]}}`, true},
+	}
+	for _, test := range tests {
+		var o HeadlessOptions
+		err := json.Unmarshal([]byte(test.json), &o)
+		if (err != nil) != test.expectErr {
+			t.Errorf("[%s] Expected error %t, got %v", test.name, test.expectErr, err)
+		}
+		if err == nil && o.Block == nil {
+			t.Errorf("[%s] Expected a block list", test.name)
+		}
+	}
+}
@@ -33,20 +33,27 @@ func MustClient(options ...ClientOption) Client {
 
 func NewClient(options ...ClientOption) (Client, error) {
 	client := &defaultClient{
-		userAgent:  DefaultUserAgent,
-		httpClient: &http.Client{Timeout: DefaultTimeout},
+		userAgent:      DefaultUserAgent,
+		httpClient:     &http.Client{Timeout: DefaultTimeout},
+		maxRedirects:   DefaultMaxRedirects,
+		redirectPolicy: FollowRedirects,
 	}
 	for _, opt := range options {
 		if err := opt(client); err != nil {
 			return nil, err
 		}
 	}
+	if client.httpClient.CheckRedirect == nil {
+		client.httpClient.CheckRedirect = client.checkRedirect
+	}
 	return client, nil
 }
 
 type defaultClient struct {
-	userAgent  string
-	httpClient *http.Client
+	userAgent      string
+	httpClient     *http.Client
+	maxRedirects   int
+	redirectPolicy RedirectPolicy
 }
 
 func (c defaultClient) Identifier() resource.ClientIdentifier {
@@ -126,6 +133,17 @@ func WithHTTPClient(client *http.Client) ClientOption {
 	}
 }
 
+// Send the cookies from jar with requests, and give it the cookies that responses set.
+func WithCookieJar(jar http.CookieJar) ClientOption {
+	return func(o *defaultClient) error {
+		if o.httpClient == nil {
+			return errors.New("cannot use WithCookieJar with nil http.Client")
+		}
+		o.httpClient.Jar = jar
+		return nil
+	}
+}
+
 func WithTransport(transport http.RoundTripper) ClientOption {
 	return func(o *defaultClient) error {
 		o.httpClient.Transport = transport
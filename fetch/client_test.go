@@ -3,6 +3,8 @@ package fetch
 import (
 	"errors"
 	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -123,3 +125,32 @@ func TestWithFilesInvalidTransport(t *testing.T) {
 		t.Fatalf("WithFiles() error = %v, wantErr %v", err, true)
 	}
 }
+
+func TestWithCookieJar(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err == nil {
+			w.Write([]byte(c.Value))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+	}))
+	defer ts.Close()
+	jar, _ := cookiejar.New(nil)
+	client, err := NewClient(WithCookieJar(jar))
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	for i, expect := range []string{"", "abc"} {
+		resp, err := client.Get(ts.URL, nil)
+		if err != nil {
+			t.Fatalf("Error fetching: %v", err)
+		}
+		body := make([]byte, 16)
+		n, _ := resp.Body.Read(body)
+		resp.Body.Close()
+		if got := string(body[:n]); got != expect {
+			t.Errorf("[%d] Expected cookie %q, got %q", i, expect, got)
+		}
+	}
+}
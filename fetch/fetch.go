@@ -3,6 +3,7 @@ package fetch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +15,9 @@ import (
 
 const (
 	DefaultUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:88.0) Gecko/20100101 Firefox/88.0"
+	// Pages with less extracted text than this may be javascript app shells,
+	// whose content is rendered in the browser.
+	DefaultMinContentLength = 250
 )
 
 var (
@@ -24,6 +28,11 @@ var (
 			Message:    "Unsupported content type",
 		},
 	}
+	// The page's content is rendered with javascript, so it can't be extracted
+	// from the page as it's served.
+	ErrJavaScriptRequired = errors.New("page needs javascript to render its content")
+	// The response body is larger than the fetcher is allowed to read.
+	ErrBodyTooLarge = errors.New("response body is too large")
 )
 
 type URLFetcher interface {
@@ -34,8 +43,18 @@ type BatchURLFetcher interface {
 	Batch([]string, BatchOptions) <-chan *resource.WebPage
 }
 
+// Archivers keep the raw responses that web pages are extracted from,
+// so that content can be examined or extracted again later.
+type Archiver interface {
+	Archive(url *nurl.URL, header http.Header, body []byte) error
+}
+
 type BatchOptions struct {
 	//throttle time.Duration
+	// Fetch every url again, instead of returning stored content.
+	Refresh bool
+	// How a headless browser loads the pages that are fetched.
+	Headless *HeadlessOptions
 }
 
 type FeedFetcher interface {
@@ -0,0 +1,140 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	nurl "net/url"
+	"time"
+
+	"github.com/efixler/scrape/resource"
+)
+
+const (
+	MaxHeadlessDelay   = 30 * time.Second
+	MaxHeadlessScrolls = 20
+	MaxHeadlessClicks  = 10
+	MaxHeadlessScript  = 2048
+)
+
+var ErrInvalidHeadlessOptions = errors.New("invalid headless options")
+
+// HeadlessOptions tell a headless browser how to load a page before its DOM is
+// captured, for pages that lazy-load their content, hide it behind a cookie
+// wall, or need a 'read more' click. The steps run in the order of the fields:
+// wait for the selector, wait for the network to go idle, wait for the delay,
+// click, scroll, then run the script.
+type HeadlessOptions struct {
+	// CSS selector of an element to wait for
+	WaitFor string `json:"wait_for,omitempty"`
+	// Wait until the page stops making network requests
+	NetworkIdle bool `json:"network_idle,omitempty"`
+	// Fixed delay, in milliseconds
+	Delay int `json:"delay_ms,omitempty"`
+	// CSS selectors of elements to click, e.g. to dismiss cookie walls. Selectors
+	// that don't match anything are skipped.
+	Click []string `json:"click,omitempty"`
+	// Times to scroll to the bottom of the page, for pages that load more content
+	// as they're scrolled.
+	Scrolls int `json:"scrolls,omitempty"`
+	// Javascript to run last. If it returns a promise, the DOM is captured when
+	// the promise settles.
+	Script string `json:"script,omitempty"`
+	// What not to load, in place of the client's block list
+	Block *BlockList `json:"block,omitempty"`
+}
+
+// Unknown fields are errors, so that misspelled options aren't silently skipped.
+func (o *HeadlessOptions) UnmarshalJSON(b []byte) error {
+	type alias HeadlessOptions
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode((*alias)(o)); err != nil {
+		return err
+	}
+	return o.Validate()
+}
+
+func (o HeadlessOptions) Validate() error {
+	switch {
+	case o.Delay < 0 || time.Duration(o.Delay)*time.Millisecond > MaxHeadlessDelay:
+		return fmt.Errorf("%w: delay_ms must be between 0 and %d", ErrInvalidHeadlessOptions, MaxHeadlessDelay.Milliseconds())
+	case o.Scrolls < 0 || o.Scrolls > MaxHeadlessScrolls:
+		return fmt.Errorf("%w: scrolls must be between 0 and %d", ErrInvalidHeadlessOptions, MaxHeadlessScrolls)
+	case len(o.Click) > MaxHeadlessClicks:
+		return fmt.Errorf("%w: at most %d click selectors", ErrInvalidHeadlessOptions, MaxHeadlessClicks)
+	case len(o.Script) > MaxHeadlessScript:
+		return fmt.Errorf("%w: script must be at most %d bytes", ErrInvalidHeadlessOptions, MaxHeadlessScript)
+	}
+	if o.Block != nil {
+		if err := o.Block.Validate(); err != nil {
+			return errors.Join(ErrInvalidHeadlessOptions, err)
+		}
+	}
+	return nil
+}
+
+func (o HeadlessOptions) DelayDuration() time.Duration {
+	return time.Duration(o.Delay) * time.Millisecond
+}
+
+// Returns a copy of o, with the fields that are set in override replacing its own.
+// Either may be nil.
+func (o *HeadlessOptions) Merge(override *HeadlessOptions) *HeadlessOptions {
+	switch {
+	case o == nil && override == nil:
+		return nil
+	case o == nil:
+		merged := *override
+		return &merged
+	}
+	merged := *o
+	if override == nil {
+		return &merged
+	}
+	if override.WaitFor != "" {
+		merged.WaitFor = override.WaitFor
+	}
+	if override.NetworkIdle {
+		merged.NetworkIdle = true
+	}
+	if override.Delay != 0 {
+		merged.Delay = override.Delay
+	}
+	if len(override.Click) > 0 {
+		merged.Click = override.Click
+	}
+	if override.Scrolls != 0 {
+		merged.Scrolls = override.Scrolls
+	}
+	if override.Script != "" {
+		merged.Script = override.Script
+	}
+	if override.Block != nil {
+		merged.Block = override.Block
+	}
+	return &merged
+}
+
+// Clients that can load pages with HeadlessOptions.
+type HeadlessClient interface {
+	Client
+	GetHeadless(url string, headers http.Header, options *HeadlessOptions) (*http.Response, error)
+}
+
+// URLFetchers that can pass per-request HeadlessOptions along to their client.
+// Fetchers that don't use a headless browser ignore the options.
+type HeadlessURLFetcher interface {
+	URLFetcher
+	FetchHeadless(url *nurl.URL, options *HeadlessOptions) (*resource.WebPage, error)
+}
+
+// Fetch url with fetcher, passing options along if the fetcher takes them.
+func FetchWithOptions(fetcher URLFetcher, url *nurl.URL, options *HeadlessOptions) (*resource.WebPage, error) {
+	if hf, ok := fetcher.(HeadlessURLFetcher); ok && options != nil {
+		return hf.FetchHeadless(url, options)
+	}
+	return fetcher.Fetch(url)
+}
@@ -0,0 +1,71 @@
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeadlessOptionsValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		expectErr error
+	}{
+		{"empty", `{}`, nil},
+		{"all options", `{"wait_for":"article","network_idle":true,"delay_ms":500,"click":[".more"],"scrolls":3,"script":"1"}`, nil},
+		{"negative delay", `{"delay_ms":-1}`, ErrInvalidHeadlessOptions},
+		{"long delay", `{"delay_ms":30001}`, ErrInvalidHeadlessOptions},
+		{"too many scrolls", `{"scrolls":21}`, ErrInvalidHeadlessOptions},
+		{"too many clicks", `{"click":["a","b","c","d","e","f","g","h","i","j","k"]}`, ErrInvalidHeadlessOptions},
+		{"long script", `{"script":"` + strings.Repeat("x", MaxHeadlessScript+1) + `"}`, ErrInvalidHeadlessOptions},
+	}
+	for _, test := range tests {
+		var o HeadlessOptions
+		err := json.Unmarshal([]byte(test.json), &o)
+		if !errors.Is(err, test.expectErr) {
+			t.Errorf("[%s] Expected error %v, got %v", test.name, test.expectErr, err)
+		}
+	}
+	var o HeadlessOptions
+	if err := json.Unmarshal([]byte(`{"waitfor":"article"}`), &o); err == nil {
+		t.Errorf("Expected an error for an unknown option")
+	}
+}
+
+func TestHeadlessOptionsMerge(t *testing.T) {
+	domain := &HeadlessOptions{WaitFor: "article", Click: []string{"#accept"}, Scrolls: 2}
+	tests := []struct {
+		name     string
+		base     *HeadlessOptions
+		override *HeadlessOptions
+		expected *HeadlessOptions
+	}{
+		{"neither", nil, nil, nil},
+		{"base only", domain, nil, domain},
+		{"override only", nil, &HeadlessOptions{Delay: 100}, &HeadlessOptions{Delay: 100}},
+		{
+			"override",
+			domain,
+			&HeadlessOptions{WaitFor: "main", NetworkIdle: true, Script: "1"},
+			&HeadlessOptions{WaitFor: "main", NetworkIdle: true, Click: []string{"#accept"}, Scrolls: 2, Script: "1"},
+		},
+		{
+			"override block list",
+			&HeadlessOptions{Block: &DefaultBlockList},
+			&HeadlessOptions{Block: &BlockList{}},
+			&HeadlessOptions{Block: &BlockList{}},
+		},
+	}
+	for _, test := range tests {
+		merged := test.base.Merge(test.override)
+		if !reflect.DeepEqual(merged, test.expected) {
+			t.Errorf("[%s] Expected %+v, got %+v", test.name, test.expected, merged)
+		}
+		if merged != nil && (merged == test.base || merged == test.override) {
+			t.Errorf("[%s] Expected a copy", test.name)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	nurl "net/url"
+	"slices"
+	"strings"
+
+	"github.com/efixler/scrape/resource"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Which redirects the client follows, by where they go.
+type RedirectPolicy string
+
+const (
+	// Follow redirects to any domain.
+	FollowRedirects RedirectPolicy = "follow"
+	// Only follow redirects within the requested url's registered domain, so
+	// www.example.com, example.com and news.example.com can redirect to each other,
+	// but a.github.io and b.github.io can't.
+	SameDomainRedirects RedirectPolicy = "same-domain"
+)
+
+const DefaultMaxRedirects = 10
+
+var (
+	ErrTooManyRedirects      = errors.New("too many redirects")
+	ErrCrossDomainRedirect   = errors.New("redirect to another domain")
+	ErrInvalidRedirectPolicy = errors.New("invalid redirect policy")
+	errNoRegisteredDomain    = errors.New("no registered domain")
+)
+
+func (p *RedirectPolicy) UnmarshalText(b []byte) error {
+	switch rp := RedirectPolicy(strings.ToLower(string(b))); rp {
+	case FollowRedirects, SameDomainRedirects:
+		*p = rp
+		return nil
+	default:
+		return fmt.Errorf("%w %q, expected follow or same-domain", ErrInvalidRedirectPolicy, string(b))
+	}
+}
+
+func (p RedirectPolicy) String() string {
+	return string(p)
+}
+
+// Follow at most n redirects for a request. With 0, redirects aren't followed.
+// A request that's redirected too many times fails with ErrTooManyRedirects.
+func WithMaxRedirects(n int) ClientOption {
+	return func(o *defaultClient) error {
+		if n < 0 {
+			return errors.New("max redirects can't be negative")
+		}
+		o.maxRedirects = n
+		return nil
+	}
+}
+
+// Set which redirects are followed. A request with a redirect that isn't
+// followed fails with ErrCrossDomainRedirect.
+func WithRedirectPolicy(policy RedirectPolicy) ClientOption {
+	return func(o *defaultClient) error {
+		if err := policy.UnmarshalText([]byte(policy)); err != nil {
+			return err
+		}
+		o.redirectPolicy = policy
+		return nil
+	}
+}
+
+// Used as the http.Client's CheckRedirect. via holds the requests made so far,
+// starting with the original one.
+func (c *defaultClient) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > c.maxRedirects {
+		return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, c.maxRedirects)
+	}
+	from := via[0].URL.Hostname()
+	if c.redirectPolicy == SameDomainRedirects && !SameDomain(from, req.URL.Hostname()) {
+		return fmt.Errorf("%w: %s to %s", ErrCrossDomainRedirect, from, req.URL.Hostname())
+	}
+	return nil
+}
+
+// SameDomain reports whether hostnames a and b are in the same registered domain,
+// the public suffix (like com, co.uk or github.io) and the label before it.
+// Hosts without a registered domain, like IP addresses, localhost and public
+// suffixes themselves, are only the same domain as themselves.
+func SameDomain(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b {
+		return true
+	}
+	da, err := registeredDomain(a)
+	if err != nil {
+		return false
+	}
+	db, err := registeredDomain(b)
+	return err == nil && da == db
+}
+
+func registeredDomain(host string) (string, error) {
+	if net.ParseIP(host) != nil {
+		return "", errNoRegisteredDomain
+	}
+	return publicsuffix.EffectiveTLDPlusOne(host)
+}
+
+// Redirects returns the redirects that were followed to get resp, in order, and
+// the url they ended at, which is the url of resp's request. When resp is a redirect
+// that wasn't followed, it's included, and the url is its location.
+// Returns a nil url when resp doesn't have its request.
+func Redirects(resp *http.Response) ([]resource.Redirect, *nurl.URL) {
+	if resp == nil || resp.Request == nil {
+		return nil, nil
+	}
+	final, r := resp.Request.URL, resp.Request.Response
+	if isRedirect(resp.StatusCode) {
+		if location, err := resp.Location(); err == nil {
+			final, r = location, resp
+		}
+	}
+	var chain []resource.Redirect
+	for ; r != nil && r.Request != nil; r = r.Request.Response {
+		chain = append(chain, resource.Redirect{URL: r.Request.URL.String(), StatusCode: r.StatusCode})
+	}
+	slices.Reverse(chain)
+	return chain, final
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
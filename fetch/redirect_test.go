@@ -0,0 +1,153 @@
+package fetch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Serves /hops/N, which redirects to /hops/N-1 until it gets to /hops/0, and
+// /away, which redirects to the same server by another hostname.
+func redirectServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	var ts *httptest.Server
+	mux.HandleFunc("/hops/{n}", func(w http.ResponseWriter, r *http.Request) {
+		switch n := r.PathValue("n"); n {
+		case "0":
+			w.Write([]byte("done"))
+		case "1":
+			http.Redirect(w, r, "/hops/0", http.StatusMovedPermanently)
+		default:
+			http.Redirect(w, r, "/hops/1", http.StatusFound)
+		}
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)+"/hops/0", http.StatusFound)
+	})
+	ts = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRedirects(t *testing.T) {
+	t.Parallel()
+	ts := redirectServer(t)
+	tests := []struct {
+		name       string
+		path       string
+		options    []ClientOption
+		expectErr  error
+		expectHops []string
+		expectURL  string
+	}{
+		{"no redirects", "/hops/0", nil, nil, []string{}, "/hops/0"},
+		{"two redirects", "/hops/2", nil, nil, []string{"/hops/2:302", "/hops/1:301"}, "/hops/0"},
+		{
+			"too many redirects",
+			"/hops/2",
+			[]ClientOption{WithMaxRedirects(1)},
+			ErrTooManyRedirects,
+			[]string{"/hops/2:302", "/hops/1:301"},
+			"/hops/0",
+		},
+		{
+			"redirects not followed",
+			"/hops/1",
+			[]ClientOption{WithMaxRedirects(0)},
+			ErrTooManyRedirects,
+			[]string{"/hops/1:301"},
+			"/hops/0",
+		},
+		{"cross domain followed", "/away", nil, nil, []string{"/away:302"}, "/hops/0"},
+		{
+			"cross domain not followed",
+			"/away",
+			[]ClientOption{WithRedirectPolicy(SameDomainRedirects)},
+			ErrCrossDomainRedirect,
+			[]string{"/away:302"},
+			"/hops/0",
+		},
+	}
+	for _, test := range tests {
+		client := MustClient(test.options...)
+		resp, err := client.Get(ts.URL+test.path, nil)
+		if !errors.Is(err, test.expectErr) {
+			t.Errorf("[%s] Expected error %v, got %v", test.name, test.expectErr, err)
+			continue
+		}
+		chain, final := Redirects(resp)
+		if len(chain) != len(test.expectHops) {
+			t.Errorf("[%s] Expected hops %v, got %v", test.name, test.expectHops, chain)
+			continue
+		}
+		for i, hop := range chain {
+			path, status, _ := strings.Cut(test.expectHops[i], ":")
+			if !strings.HasSuffix(hop.URL, path) || status != strconv.Itoa(hop.StatusCode) {
+				t.Errorf("[%s] Expected hop %d to be %s, got %v", test.name, i, test.expectHops[i], hop)
+			}
+		}
+		if final == nil || final.Path != test.expectURL {
+			t.Errorf("[%s] Expected final url %s, got %v", test.name, test.expectURL, final)
+		}
+	}
+}
+
+func TestSameDomain(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		from, to string
+		expect   bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", true},
+		{"www.example.com", "example.com", true},
+		{"www.example.com", "news.example.com", true},
+		{"news.example.com", "Example.com", true},
+		{"news.example.com", "sports.example.com", true},
+		{"example.com", "example.org", false},
+		{"example.com", "notexample.com", false},
+		{"www.example.co.uk", "example.co.uk", true},
+		{"a.co.uk", "co.uk", false},
+		{"a.co.uk", "b.co.uk", false},
+		{"evil.github.io", "github.io", false},
+		{"evil.github.io", "efixler.github.io", false},
+		{"docs.efixler.github.io", "efixler.github.io", true},
+		{"github.io", "github.io", true},
+		{"localhost", "localhost", true},
+		{"localhost", "www.localhost", false},
+		{"127.0.0.1", "127.0.0.1", true},
+		{"10.0.0.1", "10.0.0.2", false},
+	}
+	for _, test := range tests {
+		if got := SameDomain(test.from, test.to); got != test.expect {
+			t.Errorf("[%s, %s] Expected %t, got %t", test.from, test.to, test.expect, got)
+		}
+	}
+}
+
+func TestRedirectOptions(t *testing.T) {
+	t.Parallel()
+	var policy RedirectPolicy
+	if err := policy.UnmarshalText([]byte("Same-Domain")); err != nil || policy != SameDomainRedirects {
+		t.Errorf("Expected same-domain policy, got %q, %v", policy, err)
+	}
+	if err := policy.UnmarshalText([]byte("never")); !errors.Is(err, ErrInvalidRedirectPolicy) {
+		t.Errorf("Expected ErrInvalidRedirectPolicy, got %v", err)
+	}
+	if _, err := NewClient(WithRedirectPolicy("sometimes")); !errors.Is(err, ErrInvalidRedirectPolicy) {
+		t.Errorf("Expected ErrInvalidRedirectPolicy, got %v", err)
+	}
+	if _, err := NewClient(WithMaxRedirects(-1)); err == nil {
+		t.Error("Expected an error for negative max redirects")
+	}
+	// an http.Client's own redirect check is kept
+	ts := redirectServer(t)
+	check := func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	c := MustClient(WithHTTPClient(&http.Client{CheckRedirect: check}))
+	if resp, err := c.Get(ts.URL+"/hops/1", nil); err != nil || resp.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("Expected the redirect to be returned, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	nurl "net/url"
+)
+
+// How a page is rendered: as a full-page PNG screenshot, or printed to PDF.
+type RenderFormat string
+
+const (
+	Screenshot RenderFormat = "screenshot"
+	PDF        RenderFormat = "pdf"
+)
+
+var (
+	ErrInvalidRenderFormat = errors.New("invalid render format")
+	ErrRenderTooLarge      = errors.New("render is too large")
+)
+
+func (f *RenderFormat) UnmarshalText(b []byte) error {
+	switch rf := RenderFormat(b); rf {
+	case Screenshot, PDF:
+		*f = rf
+		return nil
+	default:
+		return fmt.Errorf("%w %q, expected screenshot or pdf", ErrInvalidRenderFormat, rf)
+	}
+}
+
+func (f RenderFormat) ContentType() string {
+	switch f {
+	case PDF:
+		return "application/pdf"
+	default:
+		return "image/png"
+	}
+}
+
+// Renderers capture what a page looks like in a browser, after loading it with
+// the passed HeadlessOptions, which may be nil.
+type Renderer interface {
+	Render(url *nurl.URL, format RenderFormat, options *HeadlessOptions) ([]byte, error)
+}
@@ -0,0 +1,48 @@
+package trafilatura
+
+import (
+	"io"
+
+	"github.com/efixler/scrape/fetch"
+)
+
+// Response bodies larger than this aren't extracted, unless a different limit
+// is set with WithMaxBodySize.
+const DefaultMaxBodySize int64 = 10 * 1024 * 1024
+
+// Fail fetches of pages whose response bodies are larger than maxSize bytes
+// with fetch.ErrBodyTooLarge. If maxSize isn't positive, DefaultMaxBodySize
+// is used.
+func WithMaxBodySize(maxSize int64) Option {
+	return func(f *TrafilaturaFetcher) error {
+		if maxSize <= 0 {
+			maxSize = DefaultMaxBodySize
+		}
+		f.maxBodySize = maxSize
+		return nil
+	}
+}
+
+// Reads up to remaining bytes from r, then fails with fetch.ErrBodyTooLarge
+// if there's more to read.
+type maxBodyReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (m *maxBodyReader) Read(p []byte) (int, error) {
+	if m.remaining <= 0 {
+		var probe [1]byte
+		n, err := m.r.Read(probe[:])
+		if n > 0 {
+			return 0, fetch.ErrBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > m.remaining {
+		p = p[:m.remaining]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	return n, err
+}
@@ -2,9 +2,13 @@
 package trafilatura
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"mime"
+	"net/http"
 	nurl "net/url"
 	"strings"
 
@@ -15,19 +19,38 @@ import (
 	"github.com/markusmobius/go-trafilatura"
 )
 
+type Option func(*TrafilaturaFetcher) error
+
+// Keep a copy of the raw response body and headers for every page
+// that's fetched successfully, using the passed Archiver.
+func WithArchiver(a fetch.Archiver) Option {
+	return func(f *TrafilaturaFetcher) error {
+		if a == nil {
+			return errors.New("nil archiver")
+		}
+		f.archiver = a
+		return nil
+	}
+}
+
 type TrafilaturaFetcher struct {
-	client fetch.Client
+	client           fetch.Client
+	archiver         fetch.Archiver
+	minContentLength int
+	resolveVariants  bool
+	normalizer       *resource.URLNormalizer
+	maxBodySize      int64
 }
 
-func MustNew(client fetch.Client) fetch.URLFetcher {
-	f, err := New(client)
+func MustNew(client fetch.Client, options ...Option) fetch.URLFetcher {
+	f, err := New(client, options...)
 	if err != nil {
 		panic(err)
 	}
 	return f
 }
 
-func New(client fetch.Client) (*TrafilaturaFetcher, error) {
+func New(client fetch.Client, options ...Option) (*TrafilaturaFetcher, error) {
 	var err error
 	if client == nil {
 		if client, err = fetch.NewClient(); err != nil {
@@ -35,7 +58,13 @@ func New(client fetch.Client) (*TrafilaturaFetcher, error) {
 		}
 	}
 	fetcher := &TrafilaturaFetcher{
-		client: client,
+		client:      client,
+		maxBodySize: DefaultMaxBodySize,
+	}
+	for _, opt := range options {
+		if err = opt(fetcher); err != nil {
+			return nil, err
+		}
 	}
 	return fetcher, nil
 }
@@ -44,13 +73,48 @@ func New(client fetch.Client) (*TrafilaturaFetcher, error) {
 // The web page will be fetched and parsed using the Trafilatura library.
 // The returned resource will contain the metadata and content text.
 // The request's StatusCode will be set to the HTTP status code returned.
+// Redirects that were followed are recorded, along with the final url, which
+// is the url the page is extracted as.
+// With variant resolution, the desktop page is fetched for AMP and mobile urls.
 // If there's an error fetching the page, in addition to the returned error,
 // the *resource.WebPage will contain partial data pertaining to the request.
 func (f *TrafilaturaFetcher) Fetch(url *nurl.URL) (*resource.WebPage, error) {
+	return f.fetch(url, nil)
+}
+
+// Same as Fetch, passing options to the client if it's a headless client.
+func (f *TrafilaturaFetcher) FetchHeadless(url *nurl.URL, options *fetch.HeadlessOptions) (*resource.WebPage, error) {
+	return f.fetch(url, options)
+}
+
+func (f *TrafilaturaFetcher) get(url *nurl.URL, options *fetch.HeadlessOptions) (*http.Response, error) {
+	if hc, ok := f.client.(fetch.HeadlessClient); ok && options != nil {
+		return hc.GetHeadless(url.String(), nil, options)
+	}
+	return f.client.Get(url.String(), nil)
+}
+
+func (f *TrafilaturaFetcher) fetch(url *nurl.URL, options *fetch.HeadlessOptions) (*resource.WebPage, error) {
+	if f.resolveVariants {
+		return f.fetchDesktop(url, options)
+	}
+	page, _, err := f.fetchPage(url, options)
+	return page, err
+}
+
+// Fetch and extract the page at url. The raw body is returned along with the page
+// when it's read before extraction, and is nil otherwise.
+func (f *TrafilaturaFetcher) fetchPage(url *nurl.URL, options *fetch.HeadlessOptions) (*resource.WebPage, []byte, error) {
 	var httpErr fetch.HttpError
 	// FetchTime is inserted below
 	rval := resource.NewWebPage(*url)
-	resp, err := f.client.Get(url.String(), nil)
+	resp, err := f.get(url, options)
+	final := url
+	if redirects, location := fetch.Redirects(resp); location != nil {
+		rval.Redirects = redirects
+		rval.FinalURL = location.String()
+		final = location
+	}
 	if err != nil {
 		// if we get an httpError back from doRequest, trust it
 		if errors.As(err, &httpErr) {
@@ -59,7 +123,7 @@ func (f *TrafilaturaFetcher) Fetch(url *nurl.URL) (*resource.WebPage, error) {
 			rval.StatusCode = resp.StatusCode
 		}
 		rval.Error = err
-		return rval, err
+		return rval, nil, err
 	}
 
 	defer resp.Body.Close()
@@ -68,7 +132,7 @@ func (f *TrafilaturaFetcher) Fetch(url *nurl.URL) (*resource.WebPage, error) {
 		// include the error in the resource, and return it.
 		err = fetch.NewHTTPError(resp)
 		rval.Error = err
-		return rval, err
+		return rval, nil, err
 	}
 	if ctype, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil {
 		slog.Warn("Error parsing Content-Type", "err", err, "url", url)
@@ -83,26 +147,66 @@ func (f *TrafilaturaFetcher) Fetch(url *nurl.URL) (*resource.WebPage, error) {
 			slog.Info("Unsupported Content-Type", "url", url, "ctype", ctype)
 			err = fetch.NewUnsupportedContentTypeError(ctype)
 			rval.Error = err
-			return rval, err
+			return rval, nil, err
+		}
+	}
+	var (
+		body io.Reader = &maxBodyReader{r: resp.Body, remaining: f.maxBodySize}
+		raw  []byte
+	)
+	// The raw body is only kept when it's needed after extraction
+	if f.archiver != nil || f.minContentLength > 0 || f.resolveVariants {
+		if raw, err = f.readBody(url, resp.Header, body); err != nil {
+			rval.Error = err
+			return rval, nil, err
 		}
+		body = bytes.NewReader(raw)
 	}
 	topts := trafilatura.Options{
 		EnableFallback:     true,
 		FallbackCandidates: &trafilatura.FallbackCandidates{},
-		OriginalURL:        url,
+		OriginalURL:        final,
 		IncludeImages:      true,
 	}
-	result, err := trafilatura.Extract(resp.Body, topts)
+	result, err := trafilatura.Extract(body, topts)
+	if errors.Is(err, fetch.ErrBodyTooLarge) {
+		rval.Error = fetch.ErrBodyTooLarge
+		return rval, nil, rval.Error
+	}
 	if err != nil {
 		// there's an error that is thrown here that typically indicates
 		// a JS-loaded page (that has no content at all, which isn't necessarily
 		// true in all of these cases)
 		// It's a plain error with the message:
 		// "text and comments are not long enough: 0 0"
-		return rval, err
+		if f.needsJavaScript("", raw) {
+			err = fmt.Errorf("%w: %w", fetch.ErrJavaScriptRequired, err)
+			rval.Error = err
+		}
+		return rval, raw, err
 	}
 	f.applyExtractResult(result, rval)
-	return rval, nil
+	if f.needsJavaScript(rval.ContentText, raw) {
+		rval.Error = fetch.ErrJavaScriptRequired
+		return rval, raw, rval.Error
+	}
+	return rval, raw, nil
+}
+
+// Read the response body, and pass it to the archiver if there is one.
+// A failure to archive is logged, but doesn't fail the fetch.
+func (f *TrafilaturaFetcher) readBody(url *nurl.URL, header http.Header, r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if f.archiver == nil {
+		return body, nil
+	}
+	if err = f.archiver.Archive(url, header, body); err != nil {
+		slog.Warn("Error archiving response", "url", url, "err", err)
+	}
+	return body, nil
 }
 
 func (f *TrafilaturaFetcher) applyExtractResult(
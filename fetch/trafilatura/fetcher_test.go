@@ -106,6 +106,13 @@ func TestClientFollowsRedirects(t *testing.T) {
 		}
 		if (resource == nil) || (resource.ContentText != "OK") {
 			t.Errorf("Expected 'OK' for %s, got %s", test.url, resource.ContentText)
+			continue
+		}
+		if len(resource.Redirects) != 1 || resource.Redirects[0].URL != url || "/"+strconv.Itoa(resource.Redirects[0].StatusCode) != test.url {
+			t.Errorf("Expected a redirect from %s, got %v", url, resource.Redirects)
+		}
+		if resource.FinalURL != ts.URL+"/200" || resource.CanonicalURL.String() != ts.URL+"/200" {
+			t.Errorf("Expected final and canonical urls %s/200, got %s, %s", ts.URL, resource.FinalURL, resource.CanonicalURL)
 		}
 	}
 }
@@ -270,3 +277,123 @@ func TestFetchCancelsOnTimeout(t *testing.T) {
 		}
 	}
 }
+
+type mockArchiver struct {
+	url    *nurl.URL
+	header http.Header
+	body   []byte
+	err    error
+}
+
+func (m *mockArchiver) Archive(url *nurl.URL, header http.Header, body []byte) error {
+	m.url, m.header, m.body = url, header, body
+	return m.err
+}
+
+func TestArchivesRawResponse(t *testing.T) {
+	html := "<html><head><title>Archived</title></head><body><p>OK</p></body></html>"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/404":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("X-Test", "archived")
+			w.Write([]byte(html))
+		}
+	}))
+	defer ts.Close()
+	tests := []struct {
+		name          string
+		path          string
+		archiveErr    error
+		expectArchive bool
+	}{
+		{"ok", "/ok", nil, true},
+		{"archive error doesn't fail fetch", "/ok", errors.New("archive failed"), true},
+		{"errors aren't archived", "/404", nil, false},
+	}
+	for _, test := range tests {
+		archiver := &mockArchiver{err: test.archiveErr}
+		fetcher, err := New(fetch.MustClient(fetch.WithHTTPClient(ts.Client())), WithArchiver(archiver))
+		if err != nil {
+			t.Fatalf("[%s] Error creating fetcher: %v", test.name, err)
+		}
+		url, _ := nurl.Parse(ts.URL + test.path)
+		page, err := fetcher.Fetch(url)
+		if !test.expectArchive {
+			if archiver.url != nil {
+				t.Errorf("[%s] Expected no archive, got one for %s", test.name, archiver.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%s] Expected no error, got %v", test.name, err)
+			continue
+		}
+		if page.Title != "Archived" {
+			t.Errorf("[%s] Expected title to be extracted after archiving, got %q", test.name, page.Title)
+		}
+		if archiver.url.String() != url.String() {
+			t.Errorf("[%s] Expected archived url %s, got %s", test.name, url, archiver.url)
+		}
+		if string(archiver.body) != html {
+			t.Errorf("[%s] Expected archived body %q, got %q", test.name, html, archiver.body)
+		}
+		if archiver.header.Get("X-Test") != "archived" {
+			t.Errorf("[%s] Expected archived headers, got %v", test.name, archiver.header)
+		}
+	}
+}
+
+func TestNewFailsWithNilArchiver(t *testing.T) {
+	if _, err := New(nil, WithArchiver(nil)); err == nil {
+		t.Error("Expected error with nil archiver")
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	html := "<html><head><title>Sized</title></head><body><p>" + strings.Repeat("Some text. ", 100) + "</p></body></html>"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(html))
+	}))
+	defer ts.Close()
+	tests := []struct {
+		name      string
+		maxSize   int64
+		archive   bool
+		expectErr error
+	}{
+		{"under limit", int64(len(html)), false, nil},
+		{"over limit", int64(len(html)) - 1, false, fetch.ErrBodyTooLarge},
+		{"under limit, archived", int64(len(html)), true, nil},
+		{"over limit, archived", int64(len(html)) - 1, true, fetch.ErrBodyTooLarge},
+	}
+	for _, test := range tests {
+		options := []Option{WithMaxBodySize(test.maxSize)}
+		archiver := &mockArchiver{}
+		if test.archive {
+			options = append(options, WithArchiver(archiver))
+		}
+		fetcher, err := New(fetch.MustClient(fetch.WithHTTPClient(ts.Client())), options...)
+		if err != nil {
+			t.Fatalf("[%s] Error creating fetcher: %v", test.name, err)
+		}
+		url, _ := nurl.Parse(ts.URL)
+		page, err := fetcher.Fetch(url)
+		if !errors.Is(err, test.expectErr) {
+			t.Errorf("[%s] Expected error %v, got %v", test.name, test.expectErr, err)
+			continue
+		}
+		if test.expectErr != nil {
+			if archiver.url != nil {
+				t.Errorf("[%s] Expected oversized body not to be archived", test.name)
+			}
+			continue
+		}
+		if page.Title != "Sized" {
+			t.Errorf("[%s] Expected title Sized, got %q", test.name, page.Title)
+		}
+	}
+}
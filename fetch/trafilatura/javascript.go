@@ -0,0 +1,50 @@
+package trafilatura
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/efixler/scrape/fetch"
+)
+
+// Markup that's typical of pages that are rendered in the browser: empty
+// elements that javascript apps are mounted on, and noscript messages asking
+// for javascript to be enabled.
+var appShellMarkers = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)<div[^>]+id=["']?(root|app|__next|__nuxt|svelte|main-app)["']?[^>]*>\s*</div>`),
+	regexp.MustCompile(`(?i)<app-root[^>]*>\s*</app-root>`),
+	regexp.MustCompile(`(?is)<noscript[^>]*>.{0,500}?(enable|requires?|needs?|turn on)\s+javascript`),
+}
+
+// Detect pages that need javascript to render their content. Fetches of pages
+// whose extracted text is shorter than minLength, and whose markup looks like
+// a javascript app shell, fail with fetch.ErrJavaScriptRequired. This lets
+// callers fetch those pages again with a headless browser. If minLength isn't
+// positive, fetch.DefaultMinContentLength is used.
+func WithJavaScriptDetection(minLength int) Option {
+	return func(f *TrafilaturaFetcher) error {
+		if minLength <= 0 {
+			minLength = fetch.DefaultMinContentLength
+		}
+		f.minContentLength = minLength
+		return nil
+	}
+}
+
+// Reports whether a page with the extracted text and raw body needs javascript.
+// Always false when javascript detection isn't enabled.
+func (f *TrafilaturaFetcher) needsJavaScript(text string, body []byte) bool {
+	if f.minContentLength <= 0 {
+		return false
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(text)) >= f.minContentLength {
+		return false
+	}
+	for _, marker := range appShellMarkers {
+		if marker.Match(body) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package trafilatura
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"strings"
+	"testing"
+
+	"github.com/efixler/scrape/fetch"
+)
+
+func TestJavaScriptDetection(t *testing.T) {
+	article := strings.Repeat("<p>This paragraph was rendered on the server, so it's in the page as it's served.</p>", 10)
+	pages := map[string]string{
+		"/shell":    `<html><head><title>App</title></head><body><div id="root"></div><script src="/app.js"></script></body></html>`,
+		"/angular":  `<html><head><title>App</title></head><body><app-root></app-root></body></html>`,
+		"/noscript": `<html><head><title>App</title></head><body><noscript>You need to enable JavaScript to run this app.</noscript><p>Loading</p></body></html>`,
+		"/ssr":      `<html><head><title>Article</title></head><body><div id="root"><article>` + article + `</article></div></body></html>`,
+		"/short":    `<html><head><title>Short</title></head><body><p>Just a short page.</p></body></html>`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(pages[r.URL.Path]))
+	}))
+	defer ts.Close()
+	tests := []struct {
+		path     string
+		detect   bool
+		expectJS bool
+	}{
+		{"/shell", true, true},
+		{"/angular", true, true},
+		{"/noscript", true, true},
+		{"/shell", false, false},
+		{"/ssr", true, false},
+		{"/short", true, false},
+	}
+	for _, test := range tests {
+		options := []Option{}
+		if test.detect {
+			options = append(options, WithJavaScriptDetection(0))
+		}
+		fetcher, err := New(fetch.MustClient(fetch.WithHTTPClient(ts.Client())), options...)
+		if err != nil {
+			t.Fatalf("[%s] Error creating fetcher: %v", test.path, err)
+		}
+		url, _ := nurl.Parse(ts.URL + test.path)
+		page, err := fetcher.Fetch(url)
+		if errors.Is(err, fetch.ErrJavaScriptRequired) != test.expectJS {
+			t.Errorf("[%s, detect: %t] Expected javascript required %t, got %v", test.path, test.detect, test.expectJS, err)
+		}
+		if test.expectJS && !errors.Is(page.Error, fetch.ErrJavaScriptRequired) {
+			t.Errorf("[%s] Expected the page to include the error, got %v", test.path, page.Error)
+		}
+	}
+}
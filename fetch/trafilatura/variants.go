@@ -0,0 +1,159 @@
+package trafilatura
+
+import (
+	"log/slog"
+	nurl "net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/resource"
+)
+
+// AMP caches serve publishers' AMP pages from urls like
+// https://www-example-com.cdn.ampproject.org/c/s/www.example.com/article/amp
+const ampCacheDomain = "cdn.ampproject.org"
+
+// Hostname labels of mobile and AMP sites, like m.example.com, en.m.wikipedia.org
+// and amp.example.com.
+var variantLabels = []string{"m", "mobile", "amp"}
+
+// The html tag of an AMP page has an amp or ⚡ attribute.
+var ampHTMLTag = regexp.MustCompile(`(?i)<html\b[^>]*\s(?:amp|⚡)[\s=/>]`)
+
+// Fetch the desktop version of AMP and mobile pages, instead of the pages themselves.
+// Urls of AMP caches, and of Google's AMP viewer, are fetched from the publisher's url.
+// AMP pages, and pages on mobile hosts like m.example.com, are fetched again from their
+// canonical url, when it's a different page on the same domain. The url that was
+// requested is the page's VariantURL, so that it can be mapped to the stored page.
+// When the desktop page can't be fetched, the page at the requested url is returned.
+// Desktop urls are normalized with n, which should be the normalizer for the urls
+// that are requested; when it's nil, resource.DefaultURLNormalizer is used.
+func WithVariantResolution(n *resource.URLNormalizer) Option {
+	return func(f *TrafilaturaFetcher) error {
+		f.resolveVariants = true
+		f.normalizer = n
+		return nil
+	}
+}
+
+func (f *TrafilaturaFetcher) fetchDesktop(url *nurl.URL, options *fetch.HeadlessOptions) (*resource.WebPage, error) {
+	page, raw, err := f.fetchPublisher(url, options)
+	if err != nil {
+		return page, err
+	}
+	if canonical := desktopCanonical(page, raw, f.normalizer); canonical != nil {
+		if desktop, _, err := f.fetchPage(canonical, options); err == nil {
+			page = desktop
+		} else {
+			slog.Debug("Error fetching desktop page, using the variant", "url", url, "desktop_url", canonical, "err", err)
+		}
+	}
+	if page.RequestedURL.String() != url.String() {
+		page.VariantURL = url.String()
+	}
+	return page, nil
+}
+
+// Fetch an AMP cache or viewer url from the publisher's url, falling back to the
+// url itself. Other urls are fetched as they are.
+func (f *TrafilaturaFetcher) fetchPublisher(url *nurl.URL, options *fetch.HeadlessOptions) (*resource.WebPage, []byte, error) {
+	if publisher := publisherURL(url, f.normalizer); publisher != nil {
+		page, raw, err := f.fetchPage(publisher, options)
+		if err == nil {
+			return page, raw, nil
+		}
+		slog.Debug("Error fetching publisher url for AMP url", "url", url, "publisher_url", publisher, "err", err)
+	}
+	return f.fetchPage(url, options)
+}
+
+// The publisher's url for an AMP cache or Google AMP viewer url, normalized with n,
+// or nil for other urls. Cache and viewer urls have the publisher's url in their path,
+// after a 's/' when it's an https url.
+func publisherURL(url *nurl.URL, n *resource.URLNormalizer) *nurl.URL {
+	host := strings.ToLower(url.Hostname())
+	var prefixes []string
+	switch {
+	case host == ampCacheDomain || strings.HasSuffix(host, "."+ampCacheDomain):
+		// pages are served from /c/, and in the AMP viewer from /v/
+		prefixes = []string{"/c/", "/v/"}
+	case strings.HasPrefix(strings.TrimPrefix(host, "www."), "google."):
+		prefixes = []string{"/amp/"}
+	default:
+		return nil
+	}
+	for _, prefix := range prefixes {
+		path, ok := strings.CutPrefix(url.EscapedPath(), prefix)
+		if !ok {
+			continue
+		}
+		scheme := "http"
+		if p, ok := strings.CutPrefix(path, "s/"); ok {
+			scheme, path = "https", p
+		}
+		publisher, err := nurl.Parse(scheme + "://" + path)
+		if err != nil || !strings.Contains(publisher.Hostname(), ".") {
+			return nil
+		}
+		query := url.Query()
+		for name := range query {
+			if lname := strings.ToLower(name); strings.HasPrefix(lname, "amp") || lname == "usqp" || lname == "aoh" {
+				query.Del(name)
+			}
+		}
+		publisher.RawQuery = query.Encode()
+		return n.Normalize(publisher)
+	}
+	return nil
+}
+
+// The url of the desktop page for an AMP page, or a page on a mobile host: its
+// canonical url, when that's a different page on the same domain that isn't a
+// variant itself, normalized with n. Returns nil for other pages.
+func desktopCanonical(page *resource.WebPage, raw []byte, n *resource.URLNormalizer) *nurl.URL {
+	from := *page.RequestedURL
+	if final, err := nurl.Parse(page.FinalURL); err == nil && page.FinalURL != "" {
+		from = *final
+	}
+	if page.CanonicalURL == nil || (!isVariantHost(from.Hostname()) && !ampHTMLTag.Match(raw)) {
+		return nil
+	}
+	canonical := *page.CanonicalURL
+	n.Normalize(&canonical)
+	switch {
+	case canonical.Scheme != "http" && canonical.Scheme != "https":
+	case canonical.String() == n.Normalize(&from).String():
+	case isVariantHost(canonical.Hostname()), publisherURL(&canonical, n) != nil:
+	case !fetch.SameDomain(desktopHost(from.Hostname()), canonical.Hostname()):
+	default:
+		return &canonical
+	}
+	return nil
+}
+
+// Reports whether hostname is a mobile or AMP site, with one of the variant labels
+// before its domain.
+func isVariantHost(hostname string) bool {
+	labels := strings.Split(strings.ToLower(hostname), ".")
+	if len(labels) < 3 {
+		return false
+	}
+	return slices.ContainsFunc(labels[:len(labels)-2], func(l string) bool {
+		return slices.Contains(variantLabels, l)
+	})
+}
+
+// The hostname without its mobile or AMP labels: example.com for m.example.com.
+func desktopHost(hostname string) string {
+	if !isVariantHost(hostname) {
+		return hostname
+	}
+	labels := strings.Split(strings.ToLower(hostname), ".")
+	domain := labels[len(labels)-2:]
+	labels = slices.DeleteFunc(labels[:len(labels)-2], func(l string) bool {
+		return slices.Contains(variantLabels, l)
+	})
+	return strings.Join(append(labels, domain...), ".")
+}
@@ -0,0 +1,124 @@
+package trafilatura
+
+import (
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"strings"
+	"testing"
+
+	"github.com/efixler/scrape/fetch"
+)
+
+func TestPublisherURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		url    string
+		expect string
+	}{
+		{"https://www-example-com.cdn.ampproject.org/c/s/www.example.com/news/story/amp", "https://www.example.com/news/story/amp"},
+		{"https://www-example-com.cdn.ampproject.org/v/s/www.example.com/story.amp.html?amp_js_v=0.1&usqp=mq331AQ", "https://www.example.com/story.amp.html"},
+		{"https://example-com.cdn.ampproject.org/c/example.com/story?id=1", "http://example.com/story?id=1"},
+		{"https://www.google.com/amp/s/www.example.com/story/amp/", "https://www.example.com/story/amp/"},
+		{"https://www-example-com.cdn.ampproject.org/i/s/www.example.com/logo.png", ""},
+		{"https://www.google.com/search?q=amp", ""},
+		{"https://www.example.com/amp/s/story", ""},
+	}
+	for _, test := range tests {
+		url, _ := nurl.Parse(test.url)
+		got := publisherURL(url, nil)
+		switch {
+		case got == nil && test.expect != "":
+			t.Errorf("[%s] Expected %s, got nil", test.url, test.expect)
+		case got != nil && got.String() != test.expect:
+			t.Errorf("[%s] Expected %q, got %s", test.url, test.expect, got)
+		}
+	}
+}
+
+func TestVariantHosts(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		hostname string
+		variant  bool
+		desktop  string
+	}{
+		{"m.example.com", true, "example.com"},
+		{"en.m.wikipedia.org", true, "en.wikipedia.org"},
+		{"Mobile.Example.com", true, "example.com"},
+		{"amp.example.co.uk", true, "example.co.uk"},
+		{"www.example.com", false, "www.example.com"},
+		{"m.com", false, "m.com"},
+		{"amp.dev", false, "amp.dev"},
+	}
+	for _, test := range tests {
+		if got := isVariantHost(test.hostname); got != test.variant {
+			t.Errorf("[%s] Expected variant %t, got %t", test.hostname, test.variant, got)
+		}
+		if got := desktopHost(test.hostname); got != test.desktop {
+			t.Errorf("[%s] Expected desktop host %s, got %s", test.hostname, test.desktop, got)
+		}
+	}
+}
+
+func TestVariantResolution(t *testing.T) {
+	article := strings.Repeat("<p>This is the article text, which is the same on every version of the page.</p>", 10)
+	page := func(html, canonical, title string) string {
+		return `<!doctype html><html ` + html + `><head><title>` + title + `</title>` +
+			`<link rel="canonical" href="` + canonical + `"></head><body><article>` + article + `</article></body></html>`
+	}
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/story":
+			w.Write([]byte(page(`lang="en"`, ts.URL+"/story", "Desktop")))
+		case "/amp-story":
+			w.Write([]byte(page(`⚡ lang="en"`, ts.URL+"/story", "AMP")))
+		case "/amp-missing":
+			w.Write([]byte(page(`amp`, ts.URL+"/missing", "AMP")))
+		case "/syndicated":
+			w.Write([]byte(page(`lang="en"`, ts.URL+"/story", "Syndicated")))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	tests := []struct {
+		path          string
+		resolve       bool
+		expectTitle   string
+		expectVariant bool
+	}{
+		{"/amp-story", true, "Desktop", true},
+		{"/amp-story", false, "AMP", false},
+		{"/amp-missing", true, "AMP", false},
+		{"/syndicated", true, "Syndicated", false},
+		{"/story", true, "Desktop", false},
+	}
+	for _, test := range tests {
+		options := []Option{}
+		if test.resolve {
+			options = append(options, WithVariantResolution(nil))
+		}
+		fetcher, err := New(fetch.MustClient(fetch.WithHTTPClient(ts.Client())), options...)
+		if err != nil {
+			t.Fatalf("[%s] Error creating fetcher: %v", test.path, err)
+		}
+		url, _ := nurl.Parse(ts.URL + test.path)
+		page, err := fetcher.Fetch(url)
+		if err != nil {
+			t.Errorf("[%s, resolve: %t] Error fetching: %v", test.path, test.resolve, err)
+			continue
+		}
+		if page.Title != test.expectTitle {
+			t.Errorf("[%s, resolve: %t] Expected title %q, got %q", test.path, test.resolve, test.expectTitle, page.Title)
+		}
+		switch {
+		case test.expectVariant && (page.VariantURL != url.String() || page.RequestedURL.Path != "/story"):
+			t.Errorf("[%s] Expected desktop page with variant url %s, got %s from %s", test.path, url, page.VariantURL, page.RequestedURL)
+		case !test.expectVariant && page.VariantURL != "":
+			t.Errorf("[%s, resolve: %t] Expected no variant url, got %s", test.path, test.resolve, page.VariantURL)
+		}
+	}
+}
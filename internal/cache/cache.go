@@ -0,0 +1,288 @@
+// In-memory caching of stored pages, in front of a URLStore.
+package cache
+
+import (
+	"container/list"
+	"errors"
+	nurl "net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/internal"
+	"github.com/efixler/scrape/resource"
+)
+
+const (
+	// Approximate size of a cached page, apart from the strings it holds.
+	entryOverhead = 512
+	// Pages are fetched from the underlying store again after this long, so
+	// that changes that weren't saved through the cache show up.
+	DefaultMaxAge = 5 * time.Minute
+)
+
+var ErrInvalidSize = errors.New("cache size must be greater than 0")
+
+type entry struct {
+	key       string
+	canonical string
+	page      *resource.WebPage
+	size      int64
+	expires   time.Time
+}
+
+type Option func(*Store) error
+
+// Fetch cached pages from the underlying store again after maxAge, so that
+// changes that weren't made through the cache, like imports and re-keying
+// with the scrape command, show up within maxAge. If maxAge isn't positive,
+// DefaultMaxAge is used.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(s *Store) error {
+		if maxAge <= 0 {
+			maxAge = DefaultMaxAge
+		}
+		s.maxAge = maxAge
+		return nil
+	}
+}
+
+// Store is a least-recently-used cache of pages in front of a URLStore,
+// bounded by the approximate size of the pages it holds. Pages are cached
+// by the URL they were fetched with, and are removed when they expire, after
+// their maximum age in the cache, and when their URL (or the canonical URL of
+// the page) is saved or deleted through the Store. Implements internal.URLStore.
+type Store struct {
+	store       internal.URLStore
+	maxBytes    int64
+	maxAge      time.Duration
+	mutex       sync.Mutex
+	lru         *list.List
+	entries     map[string]*list.Element
+	byCanonical map[string]map[string]bool
+	size        int64
+	// incremented on every invalidation, so that pages loaded while an
+	// invalidation is in progress aren't cached
+	generation uint64
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+	evictions  atomic.Uint64
+}
+
+// Make a cache in front of store, holding up to maxBytes of pages.
+func New(store internal.URLStore, maxBytes int64, options ...Option) (*Store, error) {
+	if maxBytes <= 0 {
+		return nil, ErrInvalidSize
+	}
+	s := &Store{
+		store:       store,
+		maxBytes:    maxBytes,
+		maxAge:      DefaultMaxAge,
+		lru:         list.New(),
+		entries:     make(map[string]*list.Element),
+		byCanonical: make(map[string]map[string]bool),
+	}
+	for _, opt := range options {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func MustNew(store internal.URLStore, maxBytes int64, options ...Option) *Store {
+	s, err := New(store, maxBytes, options...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *Store) Database() *database.DBHandle {
+	return s.store.Database()
+}
+
+// Fetch the page for a URL from the cache, or from the underlying store
+// if it isn't cached. Each call returns its own copy of the page.
+func (s *Store) Fetch(url *nurl.URL) (*resource.WebPage, error) {
+	key := url.String()
+	s.mutex.Lock()
+	if elem, ok := s.entries[key]; ok {
+		e := elem.Value.(*entry)
+		if time.Now().Before(e.expires) {
+			s.lru.MoveToFront(elem)
+			s.mutex.Unlock()
+			s.hits.Add(1)
+			return e.page.Clone(), nil
+		}
+		s.remove(elem)
+	}
+	generation := s.generation
+	s.mutex.Unlock()
+	s.misses.Add(1)
+
+	page, err := s.store.Fetch(url)
+	if err != nil {
+		return page, err
+	}
+	s.add(key, page, generation)
+	return page, nil
+}
+
+// Save the page to the underlying store, and remove any cached copies of it.
+func (s *Store) Save(page *resource.WebPage) (uint64, error) {
+	key, err := s.store.Save(page)
+	s.Invalidate(page.RequestedURL)
+	s.Invalidate(page.CanonicalURL)
+	// urls the page was redirected from may be cached with another page
+	for _, r := range page.Redirects {
+		if u, err := nurl.Parse(r.URL); err == nil {
+			s.Invalidate(u)
+		}
+	}
+	if u, err := nurl.Parse(page.VariantURL); err == nil && page.VariantURL != "" {
+		s.Invalidate(u)
+	}
+	return key, err
+}
+
+// Delete the page from the underlying store, and remove any cached copies of it.
+// The url can be any url that maps to the page, so the page is looked up first,
+// to find the canonical url whose cached copies need to be removed.
+func (s *Store) Delete(url *nurl.URL) (bool, error) {
+	var canonical *nurl.URL
+	if page, err := s.store.Fetch(url); err == nil {
+		canonical = page.CanonicalURL
+	}
+	deleted, err := s.store.Delete(url)
+	s.Invalidate(url)
+	s.Invalidate(canonical)
+	return deleted, err
+}
+
+// Remove the cached page for a URL, along with the page cached for any
+// other URL that has the same canonical URL.
+func (s *Store) Invalidate(url *nurl.URL) {
+	if url == nil {
+		return
+	}
+	key := url.String()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.generation++
+	canonicals := []string{key}
+	if elem, ok := s.entries[key]; ok {
+		canonicals = append(canonicals, elem.Value.(*entry).canonical)
+		s.remove(elem)
+	}
+	for _, canonical := range canonicals {
+		for alias := range s.byCanonical[canonical] {
+			s.remove(s.entries[alias])
+		}
+	}
+}
+
+type Stats struct {
+	Entries   int    `json:"entries"`
+	SizeBytes int64  `json:"size_bytes"`
+	MaxBytes  int64  `json:"max_bytes"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// Evictions counts the pages removed to make room for others, and doesn't
+// include pages removed because they expired or were invalidated.
+func (s *Store) Stats() (any, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &Stats{
+		Entries:   s.lru.Len(),
+		SizeBytes: s.size,
+		MaxBytes:  s.maxBytes,
+		Hits:      s.hits.Load(),
+		Misses:    s.misses.Load(),
+		Evictions: s.evictions.Load(),
+	}, nil
+}
+
+func (s *Store) add(key string, page *resource.WebPage, generation uint64) {
+	expires, err := page.ExpireTime()
+	if err != nil {
+		// pages without a TTL aren't stored, so shouldn't be cached either
+		return
+	}
+	size := pageSize(page)
+	if size > s.maxBytes {
+		return
+	}
+	if maxExpires := time.Now().Add(s.maxAge); expires.After(maxExpires) {
+		expires = maxExpires
+	}
+	e := &entry{
+		key:     key,
+		page:    page.Clone(),
+		size:    size,
+		expires: expires,
+	}
+	if page.CanonicalURL != nil {
+		e.canonical = page.CanonicalURL.String()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if generation != s.generation {
+		return
+	}
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	for s.size+size > s.maxBytes {
+		s.remove(s.lru.Back())
+		s.evictions.Add(1)
+	}
+	s.entries[key] = s.lru.PushFront(e)
+	s.size += size
+	if e.canonical != "" {
+		if s.byCanonical[e.canonical] == nil {
+			s.byCanonical[e.canonical] = make(map[string]bool)
+		}
+		s.byCanonical[e.canonical][key] = true
+	}
+}
+
+// Must be called with the mutex held.
+func (s *Store) remove(elem *list.Element) {
+	e := s.lru.Remove(elem).(*entry)
+	delete(s.entries, e.key)
+	s.size -= e.size
+	if aliases, ok := s.byCanonical[e.canonical]; ok {
+		delete(aliases, e.key)
+		if len(aliases) == 0 {
+			delete(s.byCanonical, e.canonical)
+		}
+	}
+}
+
+// The approximate memory used by a cached page.
+func pageSize(page *resource.WebPage) int64 {
+	size := entryOverhead + len(page.OriginalURL) + len(page.FinalURL) + len(page.VariantURL) + len(page.Hostname) +
+		len(page.Title) + len(page.Description) + len(page.Sitename) +
+		len(page.Language) + len(page.Image) + len(page.PageType) +
+		len(page.License) + len(page.ID) + len(page.Fingerprint) +
+		len(page.ContentText)
+	for _, url := range []*nurl.URL{page.RequestedURL, page.CanonicalURL} {
+		if url != nil {
+			size += len(url.String())
+		}
+	}
+	for _, r := range page.Redirects {
+		size += len(r.URL)
+	}
+	for _, values := range [][]string{page.Authors, page.Categories, page.Tags} {
+		for _, v := range values {
+			size += len(v)
+		}
+	}
+	return int64(size)
+}
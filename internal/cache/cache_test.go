@@ -0,0 +1,325 @@
+package cache
+
+import (
+	nurl "net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+// mapStore is a URLStore that keeps pages in a map, by their canonical URL,
+// and counts fetches.
+type mapStore struct {
+	mutex   sync.Mutex
+	pages   map[string]*resource.WebPage
+	aliases map[string]string
+	fetches int
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{
+		pages:   make(map[string]*resource.WebPage),
+		aliases: make(map[string]string),
+	}
+}
+
+func (m *mapStore) Database() *database.DBHandle {
+	return nil
+}
+
+func (m *mapStore) Fetch(url *nurl.URL) (*resource.WebPage, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.fetches++
+	key := url.String()
+	if canonical, ok := m.aliases[key]; ok {
+		key = canonical
+	}
+	page, ok := m.pages[key]
+	if !ok {
+		return nil, storage.ErrResourceNotFound
+	}
+	cpage := *page
+	return &cpage, nil
+}
+
+func (m *mapStore) Save(page *resource.WebPage) (uint64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	canonical := page.CanonicalURL.String()
+	cpage := *page
+	m.pages[canonical] = &cpage
+	m.aliases[page.RequestedURL.String()] = canonical
+	return 1, nil
+}
+
+func (m *mapStore) Delete(url *nurl.URL) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	key := url.String()
+	if canonical, ok := m.aliases[key]; ok {
+		key = canonical
+	}
+	_, ok := m.pages[key]
+	delete(m.pages, key)
+	for alias, canonical := range m.aliases {
+		if canonical == key {
+			delete(m.aliases, alias)
+		}
+	}
+	return ok, nil
+}
+
+func (m *mapStore) fetchCount() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.fetches
+}
+
+func testPage(requested, canonical, title string, ttl time.Duration) *resource.WebPage {
+	now := time.Now()
+	page := &resource.WebPage{
+		FetchTime:   &now,
+		TTL:         ttl,
+		Title:       title,
+		ContentText: strings.Repeat("x", 1000),
+	}
+	page.RequestedURL, _ = nurl.Parse(requested)
+	page.CanonicalURL, _ = nurl.Parse(canonical)
+	return page
+}
+
+func stats(t *testing.T, s *Store) *Stats {
+	st, err := s.Stats()
+	if err != nil {
+		t.Fatalf("Error getting stats: %v", err)
+	}
+	return st.(*Stats)
+}
+
+func TestFetchHitsAndMisses(t *testing.T) {
+	backing := newMapStore()
+	backing.Save(testPage("http://example.com/a", "http://example.com/a", "A", time.Hour))
+	cache := MustNew(backing, 1<<20)
+	url, _ := nurl.Parse("http://example.com/a")
+	for i := 0; i < 3; i++ {
+		page, err := cache.Fetch(url)
+		if err != nil {
+			t.Fatalf("[%d] Error fetching: %v", i, err)
+		}
+		if page.Title != "A" {
+			t.Errorf("[%d] Expected title A, got %q", i, page.Title)
+		}
+		// callers can modify the returned page without changing the cache
+		page.Title = "changed"
+	}
+	if backing.fetchCount() != 1 {
+		t.Errorf("Expected 1 fetch from the backing store, got %d", backing.fetchCount())
+	}
+	st := stats(t, cache)
+	if st.Hits != 2 || st.Misses != 1 || st.Entries != 1 {
+		t.Errorf("Expected 2 hits, 1 miss and 1 entry, got %+v", st)
+	}
+
+	missing, _ := nurl.Parse("http://example.com/missing")
+	if _, err := cache.Fetch(missing); err != storage.ErrResourceNotFound {
+		t.Errorf("Expected ErrResourceNotFound, got %v", err)
+	}
+	if st := stats(t, cache); st.Entries != 1 {
+		t.Errorf("Expected not found pages not to be cached, got %d entries", st.Entries)
+	}
+}
+
+func TestExpiredPagesAreRefetched(t *testing.T) {
+	backing := newMapStore()
+	page := testPage("http://example.com/a", "http://example.com/a", "A", time.Hour)
+	fetchTime := time.Now().Add(-time.Hour - time.Second)
+	page.FetchTime = &fetchTime
+	backing.Save(page)
+	cache := MustNew(backing, 1<<20)
+	cache.Fetch(page.RequestedURL)
+	cache.Fetch(page.RequestedURL)
+	if backing.fetchCount() != 2 {
+		t.Errorf("Expected expired page to be refetched, got %d fetches", backing.fetchCount())
+	}
+}
+
+func TestEviction(t *testing.T) {
+	backing := newMapStore()
+	urls := []string{"http://example.com/a", "http://example.com/b", "http://example.com/c"}
+	for _, u := range urls {
+		backing.Save(testPage(u, u, u, time.Hour))
+	}
+	size := pageSize(testPage(urls[0], urls[0], urls[0], time.Hour))
+	// room for two pages
+	cache := MustNew(backing, 2*size+1)
+	parsed := make([]*nurl.URL, len(urls))
+	for i, u := range urls {
+		parsed[i], _ = nurl.Parse(u)
+	}
+	cache.Fetch(parsed[0])
+	cache.Fetch(parsed[1])
+	// a is now the most recently used, so b will be evicted
+	cache.Fetch(parsed[0])
+	cache.Fetch(parsed[2])
+	st := stats(t, cache)
+	if st.Entries != 2 || st.Evictions != 1 {
+		t.Errorf("Expected 2 entries and 1 eviction, got %+v", st)
+	}
+	if st.SizeBytes > st.MaxBytes {
+		t.Errorf("Cache size %d exceeds max %d", st.SizeBytes, st.MaxBytes)
+	}
+	fetches := backing.fetchCount()
+	cache.Fetch(parsed[0])
+	if backing.fetchCount() != fetches {
+		t.Errorf("Expected a to still be cached")
+	}
+	cache.Fetch(parsed[1])
+	if backing.fetchCount() != fetches+1 {
+		t.Errorf("Expected b to have been evicted")
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	canonical := "http://example.com/a"
+	alias := "http://example.com/a?from=feed"
+	tests := []struct {
+		name   string
+		change func(*Store)
+	}{
+		{
+			name: "save",
+			change: func(s *Store) {
+				s.Save(testPage(canonical, canonical, "B", time.Hour))
+			},
+		},
+		{
+			name: "delete",
+			change: func(s *Store) {
+				url, _ := nurl.Parse(canonical)
+				s.Delete(url)
+			},
+		},
+	}
+	for _, test := range tests {
+		backing := newMapStore()
+		backing.Save(testPage(alias, canonical, "A", time.Hour))
+		cache := MustNew(backing, 1<<20)
+		canonicalURL, _ := nurl.Parse(canonical)
+		aliasURL, _ := nurl.Parse(alias)
+		cache.Fetch(canonicalURL)
+		cache.Fetch(aliasURL)
+		if st := stats(t, cache); st.Entries != 2 {
+			t.Fatalf("[%s] Expected 2 entries, got %d", test.name, st.Entries)
+		}
+		test.change(cache)
+		if st := stats(t, cache); st.Entries != 0 || st.SizeBytes != 0 {
+			t.Errorf("[%s] Expected empty cache after change, got %+v", test.name, st)
+		}
+		page, err := cache.Fetch(aliasURL)
+		switch test.name {
+		case "save":
+			if err != nil || page.Title != "B" {
+				t.Errorf("[%s] Expected updated page, got %v, %v", test.name, page, err)
+			}
+		case "delete":
+			if err != storage.ErrResourceNotFound {
+				t.Errorf("[%s] Expected ErrResourceNotFound, got %v", test.name, err)
+			}
+		}
+	}
+}
+
+func TestDeleteByUncachedAlias(t *testing.T) {
+	canonical := "http://example.com/a"
+	alias := "http://example.com/a?from=feed"
+	other := "http://example.com/a?from=email"
+	backing := newMapStore()
+	backing.Save(testPage(alias, canonical, "A", time.Hour))
+	backing.Save(testPage(other, canonical, "A", time.Hour))
+	cache := MustNew(backing, 1<<20)
+	canonicalURL, _ := nurl.Parse(canonical)
+	aliasURL, _ := nurl.Parse(alias)
+	otherURL, _ := nurl.Parse(other)
+	cache.Fetch(canonicalURL)
+	cache.Fetch(otherURL)
+	deleted, err := cache.Delete(aliasURL)
+	if err != nil || !deleted {
+		t.Fatalf("Expected page to be deleted, got %t, %v", deleted, err)
+	}
+	if st := stats(t, cache); st.Entries != 0 {
+		t.Errorf("Expected all copies of the page to be removed, got %d entries", st.Entries)
+	}
+	if _, err := cache.Fetch(otherURL); err != storage.ErrResourceNotFound {
+		t.Errorf("Expected ErrResourceNotFound, got %v", err)
+	}
+}
+
+func TestInvalidSize(t *testing.T) {
+	if _, err := New(newMapStore(), 0); err != ErrInvalidSize {
+		t.Errorf("Expected ErrInvalidSize, got %v", err)
+	}
+}
+
+func TestReturnedPagesAreCopies(t *testing.T) {
+	canonical := "http://example.com/a"
+	backing := newMapStore()
+	page := testPage(canonical, canonical, "A", time.Hour)
+	page.Authors = []string{"Author"}
+	page.Categories = []string{"Category"}
+	page.Tags = []string{"Tag"}
+	backing.Save(page)
+	cache := MustNew(backing, 1<<20)
+	url, _ := nurl.Parse(canonical)
+	// the first fetch is a miss, and the second a hit
+	for i := 0; i < 2; i++ {
+		got, err := cache.Fetch(url)
+		if err != nil {
+			t.Fatalf("[%d] Error fetching: %v", i, err)
+		}
+		got.Authors[0] = "Changed"
+		got.Categories[0] = "Changed"
+		got.Tags[0] = "Changed"
+		got.RequestedURL.Path = "/changed"
+		got.CanonicalURL.Path = "/changed"
+		*got.FetchTime = time.Time{}
+	}
+	got, err := cache.Fetch(url)
+	if err != nil {
+		t.Fatalf("Error fetching: %v", err)
+	}
+	if st := stats(t, cache); st.Hits != 2 {
+		t.Fatalf("Expected 2 hits, got %d", st.Hits)
+	}
+	switch {
+	case got.Authors[0] != "Author", got.Categories[0] != "Category", got.Tags[0] != "Tag":
+		t.Errorf("Expected cached lists to be unchanged, got %v, %v, %v", got.Authors, got.Categories, got.Tags)
+	case got.RequestedURL.String() != canonical, got.CanonicalURL.String() != canonical:
+		t.Errorf("Expected cached urls to be unchanged, got %s, %s", got.RequestedURL, got.CanonicalURL)
+	case got.FetchTime.IsZero():
+		t.Error("Expected cached fetch time to be unchanged")
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	canonical := "http://example.com/a"
+	backing := newMapStore()
+	backing.Save(testPage(canonical, canonical, "A", time.Hour))
+	cache := MustNew(backing, 1<<20, WithMaxAge(50*time.Millisecond))
+	url, _ := nurl.Parse(canonical)
+	cache.Fetch(url)
+	// changed without going through the cache
+	backing.Save(testPage(canonical, canonical, "B", time.Hour))
+	if page, _ := cache.Fetch(url); page.Title != "A" {
+		t.Errorf("Expected cached page before max age, got %q", page.Title)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if page, _ := cache.Fetch(url); page.Title != "B" {
+		t.Errorf("Expected changed page after max age, got %q", page.Title)
+	}
+}
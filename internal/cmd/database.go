@@ -11,6 +11,7 @@ import (
 	"github.com/efixler/envflags"
 	db "github.com/efixler/scrape/database"
 	"github.com/efixler/scrape/database/mysql"
+	"github.com/efixler/scrape/database/postgres"
 	"github.com/efixler/scrape/database/sqlite"
 )
 
@@ -41,6 +42,7 @@ func (d DatabaseSpec) String() string {
 // Format: <type>:<path spec>
 // Example: sqlite:scrape_data/scrape.db
 // Example: sqlite::memory:
+// Example: postgres:localhost:5432/scrape
 func NewDatabaseSpec(s string) (DatabaseSpec, error) {
 	matches := dsnRegex.FindStringSubmatch(s)
 	if matches == nil {
@@ -156,6 +158,24 @@ func database(spec DatabaseSpec, username string, password string, migration Mig
 			return nil, err
 		}
 		return db.New(engine), nil
+	case "postgresql":
+		fallthrough
+	case "postgres":
+		// Path format: host[:port][/database]
+		addr, dbName, _ := strings.Cut(spec.Path, "/")
+		options := []postgres.Option{
+			postgres.NetAddress(addr),
+			postgres.Username(username),
+			postgres.Password(password),
+		}
+		if dbName != "" {
+			options = append(options, postgres.Database(dbName))
+		}
+		engine, err := postgres.New(options...)
+		if err != nil {
+			return nil, err
+		}
+		return db.New(engine), nil
 	default:
 		return nil, errors.New("no implementation for " + spec.Type)
 	}
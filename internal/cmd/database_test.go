@@ -119,6 +119,13 @@ func TestFlagInput(t *testing.T) {
 			env:           []string{"SCRAPE_DB", "mysql:localhost:3306", "SCRAPE_DB_USER", "scrape", "SCRAPE_DB_PASSWORD", "password"},
 			expected:      []string{"mysql", "localhost:3306", "scrape", "password"},
 		},
+		{
+			name:          "postgres with database",
+			envFlagPrefix: "SCRAPE_",
+			args:          []string{"--database=postgres:localhost:5432/scrape", "--db-user", "scrape", "--db-password", "password"},
+			env:           []string{},
+			expected:      []string{"postgres", "localhost:5432/scrape", "scrape", "password"},
+		},
 	}
 	for _, test := range tests {
 		envflags.EnvPrefix = test.envFlagPrefix
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/efixler/envflags"
+	"github.com/efixler/scrape/resource"
+)
+
+type URLFlags struct {
+	stripParams    *envflags.Value[string]
+	normalizePaths *envflags.Value[bool]
+}
+
+// Set up the command line args and environment variables for the global url
+// normalization rules.
+func AddURLFlags(flags *flag.FlagSet) *URLFlags {
+	u := &URLFlags{
+		stripParams:    envflags.NewString("STRIP_PARAMS", strings.Join(resource.DefaultTrackingParams, ",")),
+		normalizePaths: envflags.NewBool("NORMALIZE_PATHS", resource.DefaultURLNormalizer.NormalizePaths),
+	}
+	u.stripParams.AddTo(
+		flags,
+		"strip-params",
+		"Query params to remove from every url, comma separated, or 'none'. Names ending in * are prefixes",
+	)
+	u.normalizePaths.AddTo(flags, "normalize-paths", "Remove trailing slashes from url paths")
+	return u
+}
+
+func (u *URLFlags) StripParams() []string {
+	params := make([]string, 0)
+	for _, p := range strings.Split(u.stripParams.Get(), ",") {
+		if p = strings.TrimSpace(p); p != "" && p != "none" {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// The normalizer for the flags' rules, along with the per-domain rules from
+// domainRules, which can be nil.
+func (u *URLFlags) Normalizer(domainRules func(string) (*resource.URLRules, error)) *resource.URLNormalizer {
+	return &resource.URLNormalizer{
+		TrackingParams: u.StripParams(),
+		NormalizePaths: u.normalizePaths.Get(),
+		DomainRules:    domainRules,
+	}
+}
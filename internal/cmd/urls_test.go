@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"flag"
+	"slices"
+	"testing"
+
+	"github.com/efixler/scrape/resource"
+)
+
+func TestURLFlags(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		expectParams   []string
+		normalizePaths bool
+	}{
+		{"defaults", []string{}, resource.DefaultTrackingParams, false},
+		{"params", []string{"-strip-params", "utm_*, ref,,fbclid", "-normalize-paths"}, []string{"utm_*", "ref", "fbclid"}, true},
+		{"no params", []string{"-strip-params", "none", "-normalize-paths=false"}, []string{}, false},
+	}
+	for _, test := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		u := AddURLFlags(flags)
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatalf("[%s] Error parsing flags: %v", test.name, err)
+		}
+		n := u.Normalizer(nil)
+		if !slices.Equal(n.TrackingParams, test.expectParams) {
+			t.Errorf("[%s] Expected params %v, got %v", test.name, test.expectParams, n.TrackingParams)
+		}
+		if n.NormalizePaths != test.normalizePaths {
+			t.Errorf("[%s] Expected normalize paths %t, got %t", test.name, test.normalizePaths, n.NormalizePaths)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Line based text diffs, for comparing versions of stored content.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Texts whose differing regions would need a comparison table larger
+	// than this are diffed as a wholesale replacement of that region.
+	maxCells       = 1 << 22
+	DefaultContext = 3
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+func (o Op) prefix() string {
+	switch o {
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	default:
+		return " "
+	}
+}
+
+// A single line in a diff. For Equal and Delete edits, FromLine is the
+// 1-based line number of the line in the original text; for Equal and Insert
+// edits ToLine is the line number in the new text.
+type Edit struct {
+	Op       Op
+	Line     string
+	FromLine int
+	ToLine   int
+}
+
+// Lines returns the line by line edits that transform from into to.
+func Lines(from, to string) []Edit {
+	a, b := splitLines(from), splitLines(to)
+	// trim the common prefix and suffix, which is usually most of the text
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	edits := make([]Edit, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, Edit{Op: Equal, Line: a[i], FromLine: i + 1, ToLine: i + 1})
+	}
+	edits = append(edits, middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := suffix; i > 0; i-- {
+		ai, bi := len(a)-i, len(b)-i
+		edits = append(edits, Edit{Op: Equal, Line: a[ai], FromLine: ai + 1, ToLine: bi + 1})
+	}
+	return edits
+}
+
+// Diff the differing middle section of two texts using a longest common
+// subsequence table. aOffset and bOffset are the line offsets of the sections
+// in the original texts.
+func middle(a, b []string, aOffset, bOffset int) []Edit {
+	n, m := len(a), len(b)
+	edits := make([]Edit, 0, n+m)
+	if n*m > maxCells {
+		for i, line := range a {
+			edits = append(edits, Edit{Op: Delete, Line: line, FromLine: aOffset + i + 1})
+		}
+		for j, line := range b {
+			edits = append(edits, Edit{Op: Insert, Line: line, ToLine: bOffset + j + 1})
+		}
+		return edits
+	}
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			edits = append(edits, Edit{Op: Equal, Line: a[i], FromLine: aOffset + i + 1, ToLine: bOffset + j + 1})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			edits = append(edits, Edit{Op: Insert, Line: b[j], ToLine: bOffset + j + 1})
+			j++
+		default:
+			edits = append(edits, Edit{Op: Delete, Line: a[i], FromLine: aOffset + i + 1})
+			i++
+		}
+	}
+	return edits
+}
+
+// Unified returns a diff of the two texts in unified diff format, with
+// context lines of unchanged text around each change. The result is empty
+// when the texts are the same.
+func Unified(fromLabel, toLabel, from, to string, context int) string {
+	edits := Lines(from, to)
+	var (
+		sb strings.Builder
+		// the number of lines from each text before edits[pos]
+		fromBefore, toBefore, pos int
+	)
+	advance := func(to int) {
+		for ; pos < to; pos++ {
+			if edits[pos].Op != Insert {
+				fromBefore++
+			}
+			if edits[pos].Op != Delete {
+				toBefore++
+			}
+		}
+	}
+	for start := 0; start < len(edits); {
+		// find the next change
+		for start < len(edits) && edits[start].Op == Equal {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromLabel, toLabel)
+		}
+		// extend the hunk until there's a run of more than 2*context unchanged lines
+		end := start
+		for end < len(edits) {
+			if edits[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].Op == Equal {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				break
+			}
+			end = run
+		}
+		first := max(start-context, 0)
+		last := min(end+context, len(edits))
+		advance(first)
+		writeHunk(&sb, edits[first:last], fromBefore, toBefore)
+		start = last
+	}
+	return sb.String()
+}
+
+// Write a hunk, where fromBefore and toBefore are the number of lines in each
+// text that precede the hunk.
+func writeHunk(sb *strings.Builder, hunk []Edit, fromBefore, toBefore int) {
+	var fromCount, toCount int
+	for _, e := range hunk {
+		if e.Op != Insert {
+			fromCount++
+		}
+		if e.Op != Delete {
+			toCount++
+		}
+	}
+	fmt.Fprintf(
+		sb,
+		"@@ -%s +%s @@\n",
+		hunkRange(fromBefore, fromCount),
+		hunkRange(toBefore, toCount),
+	)
+	for _, e := range hunk {
+		sb.WriteString(e.Op.prefix())
+		sb.WriteString(e.Line)
+		sb.WriteByte('\n')
+	}
+}
+
+// Formats a hunk range. Empty ranges are reported as starting at the line
+// before the hunk, as in GNU diff.
+func hunkRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, count)
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,137 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		expected string // one char per edit: = - +
+	}{
+		{"empty", "", "", ""},
+		{"same", "a\nb\nc", "a\nb\nc", "==="},
+		{"trailing newline ignored", "a\nb\n", "a\nb", "=="},
+		{"all new", "", "a\nb", "++"},
+		{"all gone", "a\nb", "", "--"},
+		{"changed line", "a\nb\nc", "a\nx\nc", "=-+="},
+		{"inserted line", "a\nc", "a\nb\nc", "=+="},
+		{"deleted line", "a\nb\nc", "a\nc", "=-="},
+		{"interleaved", "a\nb\nc\nd", "b\nx\nd\ne", "-=-+=+"},
+	}
+	ops := map[Op]string{Equal: "=", Delete: "-", Insert: "+"}
+	for _, test := range tests {
+		var sb strings.Builder
+		for _, e := range Lines(test.from, test.to) {
+			sb.WriteString(ops[e.Op])
+		}
+		if sb.String() != test.expected {
+			t.Errorf("[%s] expected %q, got %q", test.name, test.expected, sb.String())
+		}
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	t.Parallel()
+	edits := Lines("a\nb\nc\nd", "a\nx\nc\nd\ne")
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			if e.FromLine == 0 || e.ToLine == 0 {
+				t.Errorf("expected both line numbers for %+v", e)
+			}
+		case Delete:
+			if e.Line != "b" || e.FromLine != 2 {
+				t.Errorf("expected b deleted at line 2, got %+v", e)
+			}
+		case Insert:
+			if (e.Line == "x" && e.ToLine != 2) || (e.Line == "e" && e.ToLine != 5) {
+				t.Errorf("unexpected insert %+v", e)
+			}
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	t.Parallel()
+	lines := func(n int, change map[int]string) string {
+		var sb strings.Builder
+		for i := 1; i <= n; i++ {
+			if c, ok := change[i]; ok {
+				sb.WriteString(c)
+			} else {
+				sb.WriteString("line")
+				sb.WriteByte(byte('a' + i%26))
+			}
+			sb.WriteByte('\n')
+		}
+		return sb.String()
+	}
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		expected string
+	}{
+		{"no changes", "a\nb\n", "a\nb\n", ""},
+		{
+			"one change",
+			"a\nb\nc\n",
+			"a\nB\nc\n",
+			"--- v1\n+++ v2\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"from empty",
+			"",
+			"a\nb\n",
+			"--- v1\n+++ v2\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			"to empty",
+			"a\n",
+			"",
+			"--- v1\n+++ v2\n@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			"separate hunks",
+			lines(20, nil),
+			lines(20, map[int]string{2: "changed", 18: "also changed"}),
+			"--- v1\n+++ v2\n" +
+				"@@ -1,5 +1,5 @@\n lineb\n-linec\n+changed\n lined\n linee\n linef\n" +
+				"@@ -15,6 +15,6 @@\n linep\n lineq\n liner\n-lines\n+also changed\n linet\n lineu\n",
+		},
+		{
+			"merged hunks",
+			lines(10, nil),
+			lines(10, map[int]string{2: "changed", 8: "also changed"}),
+			"--- v1\n+++ v2\n" +
+				"@@ -1,10 +1,10 @@\n lineb\n-linec\n+changed\n lined\n linee\n linef\n lineg\n lineh\n-linei\n+also changed\n linej\n linek\n",
+		},
+	}
+	for _, test := range tests {
+		got := Unified("v1", "v2", test.from, test.to, DefaultContext)
+		if got != test.expected {
+			t.Errorf("[%s] expected:\n%s\ngot:\n%s", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestLargeTextsFallBackToReplacement(t *testing.T) {
+	t.Parallel()
+	var a, b strings.Builder
+	for i := 0; i < 3000; i++ {
+		a.WriteString("a line\n")
+		b.WriteString("b line\n")
+	}
+	edits := Lines(a.String(), b.String())
+	if len(edits) != 6000 {
+		t.Fatalf("expected 6000 edits, got %d", len(edits))
+	}
+	if edits[0].Op != Delete || edits[5999].Op != Insert {
+		t.Errorf("expected deletes followed by inserts, got %v and %v", edits[0].Op, edits[5999].Op)
+	}
+}
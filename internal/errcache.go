@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"container/list"
+	"errors"
+	"net/http"
+	nurl "net/url"
+	"sync"
+	"time"
+
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/resource"
+)
+
+const (
+	// Pages that don't exist are unlikely to appear soon.
+	NotFoundErrorTTL = 24 * time.Hour
+	// Servers that are failing may recover at any time.
+	ServerErrorTTL = 5 * time.Minute
+	// Non-HTML resources don't become HTML.
+	UnsupportedContentTypeErrorTTL = 7 * 24 * time.Hour
+	DefaultErrorCacheSize          = 10000
+)
+
+// Returns how long a fetch error should be cached for, by the kind of
+// error. Errors that aren't worth caching, like network errors and most
+// 4xx statuses, return 0.
+func ErrorTTL(err error) time.Duration {
+	if errors.Is(err, fetch.ErrUnsupportedContentType) {
+		return UnsupportedContentTypeErrorTTL
+	}
+	var httpErr fetch.HttpError
+	if !errors.As(err, &httpErr) {
+		return 0
+	}
+	switch {
+	case httpErr.StatusCode == http.StatusNotFound, httpErr.StatusCode == http.StatusGone:
+		return NotFoundErrorTTL
+	case httpErr.StatusCode >= 500:
+		return ServerErrorTTL
+	default:
+		return 0
+	}
+}
+
+type cachedError struct {
+	key     string
+	page    *resource.WebPage
+	err     error
+	expires time.Time
+}
+
+// ErrorCache is a negative cache: it keeps the results of failed fetches,
+// so that urls that are known to fail aren't fetched again and again. Errors
+// are cached for the ErrorTTL of the error, and the least recently used
+// errors are dropped when the cache is full.
+type ErrorCache struct {
+	maxEntries int
+	mutex      sync.Mutex
+	lru        *list.List
+	entries    map[string]*list.Element
+}
+
+// Make an error cache holding up to maxEntries errors. If maxEntries is
+// not positive, DefaultErrorCacheSize is used.
+func NewErrorCache(maxEntries int) *ErrorCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultErrorCacheSize
+	}
+	return &ErrorCache{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Returns a copy of the page that was returned with the cached error for url,
+// along with the error. ok is false if no unexpired error is cached.
+func (c *ErrorCache) Get(url *nurl.URL) (page *resource.WebPage, err error, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elem, found := c.entries[url.String()]
+	if !found {
+		return nil, nil, false
+	}
+	ce := elem.Value.(*cachedError)
+	if time.Now().After(ce.expires) {
+		c.remove(elem)
+		return nil, nil, false
+	}
+	c.lru.MoveToFront(elem)
+	if ce.page != nil {
+		cpage := *ce.page
+		page = &cpage
+	}
+	return page, ce.err, true
+}
+
+// Cache the result of a failed fetch of url, if the error is worth caching.
+func (c *ErrorCache) Add(url *nurl.URL, page *resource.WebPage, err error) {
+	ttl := ErrorTTL(err)
+	if ttl <= 0 {
+		return
+	}
+	ce := &cachedError{
+		key:     url.String(),
+		err:     err,
+		expires: time.Now().Add(ttl),
+	}
+	if page != nil {
+		cpage := *page
+		ce.page = &cpage
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, found := c.entries[ce.key]; found {
+		c.remove(elem)
+	}
+	c.entries[ce.key] = c.lru.PushFront(ce)
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Drop the cached error for url, if there is one.
+func (c *ErrorCache) Remove(url *nurl.URL) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, found := c.entries[url.String()]; found {
+		c.remove(elem)
+	}
+}
+
+func (c *ErrorCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+func (c *ErrorCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cachedError).key)
+}
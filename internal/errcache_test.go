@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	nurl "net/url"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/resource"
+)
+
+func TestErrorTTL(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		ttl  time.Duration
+	}{
+		{"not found", fetch.HttpError{StatusCode: http.StatusNotFound}, NotFoundErrorTTL},
+		{"gone", fetch.HttpError{StatusCode: http.StatusGone}, NotFoundErrorTTL},
+		{"server error", fetch.HttpError{StatusCode: http.StatusBadGateway}, ServerErrorTTL},
+		{"gateway timeout", fetch.HttpError{StatusCode: http.StatusGatewayTimeout}, ServerErrorTTL},
+		{"unsupported content type", fetch.NewUnsupportedContentTypeError("application/pdf"), UnsupportedContentTypeErrorTTL},
+		{"415 status", fetch.HttpError{StatusCode: http.StatusUnsupportedMediaType}, UnsupportedContentTypeErrorTTL},
+		{"forbidden", fetch.HttpError{StatusCode: http.StatusForbidden}, 0},
+		{"other", errors.New("connection refused"), 0},
+	}
+	for _, test := range tests {
+		if ttl := ErrorTTL(test.err); ttl != test.ttl {
+			t.Errorf("[%s] Expected TTL %s, got %s", test.name, test.ttl, ttl)
+		}
+	}
+}
+
+func TestErrorCache(t *testing.T) {
+	cache := NewErrorCache(2)
+	urls := make([]*nurl.URL, 3)
+	for i, u := range []string{"http://example.com/a", "http://example.com/b", "http://example.com/c"} {
+		urls[i], _ = nurl.Parse(u)
+	}
+	notFound := fetch.HttpError{StatusCode: http.StatusNotFound}
+	cache.Add(urls[0], &resource.WebPage{StatusCode: http.StatusNotFound}, notFound)
+	page, err, ok := cache.Get(urls[0])
+	if !ok {
+		t.Fatalf("Expected cached error")
+	}
+	if err != notFound || page.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected cached 404, got %v, %v", page, err)
+	}
+	// returned pages are copies
+	page.StatusCode = 0
+	if page, _, _ = cache.Get(urls[0]); page.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected cached page to be unchanged, got status %d", page.StatusCode)
+	}
+
+	cache.Add(urls[1], nil, errors.New("not cacheable"))
+	if _, _, ok := cache.Get(urls[1]); ok {
+		t.Errorf("Expected uncacheable error not to be cached")
+	}
+
+	cache.Add(urls[1], nil, notFound)
+	cache.Get(urls[0])
+	// b is the least recently used, so it's dropped
+	cache.Add(urls[2], nil, notFound)
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.Len())
+	}
+	if _, _, ok := cache.Get(urls[1]); ok {
+		t.Errorf("Expected least recently used error to be dropped")
+	}
+
+	cache.Remove(urls[0])
+	if _, _, ok := cache.Get(urls[0]); ok {
+		t.Errorf("Expected removed error not to be cached")
+	}
+}
+
+func TestExpiredErrorsAreDropped(t *testing.T) {
+	cache := NewErrorCache(0)
+	url, _ := nurl.Parse("http://example.com/a")
+	cache.Add(url, nil, fetch.HttpError{StatusCode: http.StatusServiceUnavailable})
+	elem := cache.entries[url.String()]
+	elem.Value.(*cachedError).expires = time.Now().Add(-time.Second)
+	if _, _, ok := cache.Get(url); ok {
+		t.Errorf("Expected expired error not to be returned")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected expired error to be dropped, got %d entries", cache.Len())
+	}
+}
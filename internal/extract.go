@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"net/http"
+	nurl "net/url"
+
+	"github.com/efixler/scrape/fetch/trafilatura"
+	"github.com/efixler/scrape/resource"
+)
+
+// Run the extractor over a response that's already been received, as if
+// it had just been fetched from url using method. The returned page and
+// error are the same as for a fetch.
+func ExtractResponse(url *nurl.URL, resp *http.Response, method resource.ClientIdentifier) (*resource.WebPage, error) {
+	fetcher, err := trafilatura.New(&responseClient{resp: resp, method: method})
+	if err != nil {
+		return nil, err
+	}
+	return fetcher.Fetch(url)
+}
+
+// responseClient is a fetch.Client that returns a response it already
+// has instead of fetching.
+type responseClient struct {
+	resp   *http.Response
+	method resource.ClientIdentifier
+}
+
+func (c *responseClient) Get(url string, headers http.Header) (*http.Response, error) {
+	return c.resp, nil
+}
+
+func (c *responseClient) Identifier() resource.ClientIdentifier {
+	return c.method
+}
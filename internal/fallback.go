@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	nurl "net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/resource"
+)
+
+const (
+	// How long to send a host's pages straight to the fallback fetcher, after
+	// one of its pages needed it, when hosts aren't persisted.
+	DefaultFallbackHostTTL = 24 * time.Hour
+	// How long a host's persisted preference is cached, so that preferences set
+	// by other servers sharing the database are picked up.
+	FallbackPreferenceTTL = 10 * time.Minute
+	// The most hosts to keep in memory.
+	MaxFallbackHosts = 10000
+)
+
+// HeadlessPreferences persist whether hosts' pages are fetched with the fallback
+// first, so that the hosts are remembered across restarts, and by every server
+// sharing them. Domain settings implement this interface.
+type HeadlessPreferences interface {
+	HeadlessPreferred(hostname string) (bool, error)
+	SetHeadlessPreferred(hostname string, preferred bool) error
+}
+
+type FallbackOption func(*FallbackFetcher)
+
+// Pages from hosts that are remembered as needing the fallback are fetched
+// again with the primary fetcher when the fallback gets content text shorter
+// than n, since the host's pages may not need it any more.
+func WithMinContentLength(n int) FallbackOption {
+	return func(f *FallbackFetcher) {
+		f.minContentLength = n
+	}
+}
+
+// Remember the hosts that needed the fallback for ttl, when they aren't persisted
+// with WithHeadlessPreferences.
+func WithFallbackHostTTL(ttl time.Duration) FallbackOption {
+	return func(f *FallbackFetcher) {
+		f.hostTTL = ttl
+	}
+}
+
+// Persist the hosts that need the fallback in prefs. Hosts are remembered until
+// the fallback stops working for them, and the preferences are cached in memory
+// for FallbackPreferenceTTL.
+func WithHeadlessPreferences(prefs HeadlessPreferences) FallbackOption {
+	return func(f *FallbackFetcher) {
+		f.preferences = prefs
+	}
+}
+
+// FallbackFetcher fetches urls with a primary fetcher, and fetches them again with
+// a fallback fetcher when the primary fetcher reports that the page needs
+// javascript (see trafilatura.WithJavaScriptDetection), or is turned away. This
+// is how the `auto` fetch method tries a direct fetch first, and then a
+// headless browser.
+//
+// Pages that come from the fallback are marked with the resource.HeadlessFallback
+// fetch method. Hosts whose pages needed the fallback are remembered, and
+// their pages are fetched with the fallback first until the host expires, or
+// until the fallback stops working for them when hosts are persisted.
+type FallbackFetcher struct {
+	Primary          fetch.URLFetcher
+	Fallback         fetch.URLFetcher
+	minContentLength int
+	hostTTL          time.Duration
+	preferences      HeadlessPreferences
+	mutex            sync.Mutex
+	hosts            map[string]fallbackHost
+}
+
+// Whether a host's pages need the fallback, until expires.
+type fallbackHost struct {
+	needed  bool
+	expires time.Time
+}
+
+func NewFallbackFetcher(primary, fallback fetch.URLFetcher, options ...FallbackOption) *FallbackFetcher {
+	f := &FallbackFetcher{
+		Primary:          primary,
+		Fallback:         fallback,
+		minContentLength: fetch.DefaultMinContentLength,
+		hostTTL:          DefaultFallbackHostTTL,
+		hosts:            make(map[string]fallbackHost),
+	}
+	for _, opt := range options {
+		opt(f)
+	}
+	return f
+}
+
+func (f *FallbackFetcher) Fetch(url *nurl.URL) (*resource.WebPage, error) {
+	return f.FetchHeadless(url, nil)
+}
+
+// Same as Fetch, passing options along to the fallback fetcher.
+func (f *FallbackFetcher) FetchHeadless(url *nurl.URL, options *fetch.HeadlessOptions) (*resource.WebPage, error) {
+	host := strings.ToLower(url.Hostname())
+	var (
+		fbPage *resource.WebPage
+		fbErr  error
+	)
+	remembered := f.needsFallback(host)
+	if remembered {
+		fbPage, fbErr = fetch.FetchWithOptions(f.Fallback, url, options)
+		if fbErr == nil && contentLength(fbPage) >= f.minContentLength {
+			return fallbackPage(fbPage), nil
+		}
+		// The host's pages may not need the fallback any more
+		f.forget(host)
+	}
+	page, err := f.Primary.Fetch(url)
+	if !incomplete(err) {
+		return page, err
+	}
+	if !remembered {
+		slog.Debug("Fetching again with fallback fetcher", "url", url, "error", err)
+		fbPage, fbErr = fetch.FetchWithOptions(f.Fallback, url, options)
+	}
+	if fbErr != nil {
+		slog.Debug("Fallback fetch failed", "url", url, "error", fbErr)
+		return page, err
+	}
+	f.remember(host)
+	return fallbackPage(fbPage), nil
+}
+
+// Whether a fetch failed with an error that another client might not get: the
+// page needs javascript, or the server sent a 403 (which is how bot protection
+// usually turns clients away). A page that's only short isn't enough, since
+// plenty of pages are; the primary fetcher has to find app shell markup too.
+// Other HTTP errors, including timeouts, and network errors would be the same
+// with any client, and a host that's down shouldn't cost a browser fetch as well.
+func incomplete(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, fetch.ErrJavaScriptRequired) {
+		return true
+	}
+	var httpErr fetch.HttpError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusForbidden
+}
+
+func contentLength(page *resource.WebPage) int {
+	if page == nil {
+		return 0
+	}
+	return utf8.RuneCountInString(strings.TrimSpace(page.ContentText))
+}
+
+func fallbackPage(page *resource.WebPage) *resource.WebPage {
+	if page != nil {
+		page.FetchMethod = resource.HeadlessFallback
+	}
+	return page
+}
+
+// Whether host's pages need the fallback, from memory, or from the persisted
+// preferences when the host isn't in memory. Persisted preferences that can't be
+// read are taken as false, so the page is fetched with the primary fetcher.
+func (f *FallbackFetcher) needsFallback(host string) bool {
+	f.mutex.Lock()
+	h, ok := f.hosts[host]
+	if ok && time.Now().After(h.expires) {
+		delete(f.hosts, host)
+		h, ok = fallbackHost{}, false
+	}
+	f.mutex.Unlock()
+	if ok || f.preferences == nil {
+		return h.needed
+	}
+	needed, err := f.preferences.HeadlessPreferred(host)
+	if err != nil {
+		slog.Warn("Error loading headless preference", "host", host, "error", err)
+		return false
+	}
+	f.cache(host, needed)
+	return needed
+}
+
+func (f *FallbackFetcher) remember(host string) {
+	f.persist(host, true)
+	f.cache(host, true)
+}
+
+func (f *FallbackFetcher) forget(host string) {
+	f.persist(host, false)
+	if f.preferences != nil {
+		f.cache(host, false)
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.hosts, host)
+}
+
+func (f *FallbackFetcher) persist(host string, needed bool) {
+	if f.preferences == nil {
+		return
+	}
+	if err := f.preferences.SetHeadlessPreferred(host, needed); err != nil {
+		slog.Warn("Error saving headless preference", "host", host, "preferred", needed, "error", err)
+	}
+}
+
+// Keep whether host's pages need the fallback in memory, for the host ttl, or
+// for FallbackPreferenceTTL when it's persisted.
+func (f *FallbackFetcher) cache(host string, needed bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	now := time.Now()
+	if _, ok := f.hosts[host]; !ok && len(f.hosts) >= MaxFallbackHosts {
+		for h, fh := range f.hosts {
+			if now.After(fh.expires) {
+				delete(f.hosts, h)
+			}
+		}
+		if len(f.hosts) >= MaxFallbackHosts {
+			return
+		}
+	}
+	ttl := f.hostTTL
+	if f.preferences != nil {
+		ttl = FallbackPreferenceTTL
+	}
+	f.hosts[host] = fallbackHost{needed: needed, expires: now.Add(ttl)}
+}
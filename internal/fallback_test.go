@@ -0,0 +1,263 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	nurl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/sqlite"
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+var longText = strings.Repeat("All work and no play makes Jack a dull boy. ", 10)
+
+type stubFetcher struct {
+	method  resource.ClientIdentifier
+	text    string
+	err     error
+	calls   int
+	options *fetch.HeadlessOptions
+}
+
+func (s *stubFetcher) FetchHeadless(url *nurl.URL, options *fetch.HeadlessOptions) (*resource.WebPage, error) {
+	s.options = options
+	return s.Fetch(url)
+}
+
+func (s *stubFetcher) Fetch(url *nurl.URL) (*resource.WebPage, error) {
+	s.calls++
+	page := &resource.WebPage{
+		RequestedURL: url,
+		CanonicalURL: url,
+		StatusCode:   200,
+		FetchMethod:  s.method,
+		ContentText:  s.text,
+		Error:        s.err,
+	}
+	return page, s.err
+}
+
+func TestFallbackFetcher(t *testing.T) {
+	tests := []struct {
+		name           string
+		text           string
+		err            error
+		fallbackText   string
+		fallbackErr    error
+		expectFallback bool
+		expectMethod   resource.ClientIdentifier
+	}{
+		{"success", longText, nil, longText, nil, false, resource.DefaultClient},
+		{"extraction error", "", errors.New("text and comments are not long enough: 0 0"), longText, nil, false, resource.DefaultClient},
+		{"connection refused", "", errors.New("dial tcp: connect: connection refused"), longText, nil, false, resource.DefaultClient},
+		{"body too large", "", fetch.ErrBodyTooLarge, longText, nil, false, resource.DefaultClient},
+		{"forbidden", "", fetch.HttpError{StatusCode: 403}, longText, nil, true, resource.HeadlessFallback},
+		{"timeout", "", fetch.HttpError{StatusCode: 504}, longText, nil, false, resource.DefaultClient},
+		{"javascript required", "Loading", fetch.ErrJavaScriptRequired, "Loading", nil, true, resource.HeadlessFallback},
+		{"thin content without app markup", "Loading", nil, longText, nil, false, resource.DefaultClient},
+		{"short page", "A short page", nil, "A short page", nil, false, resource.DefaultClient},
+		{"fallback fails", "", fetch.ErrJavaScriptRequired, "", errors.New("tab crashed"), true, resource.DefaultClient},
+		{"not found", "", fetch.HttpError{StatusCode: 404}, longText, nil, false, resource.DefaultClient},
+		{"wrapped http error", "", fmt.Errorf("fetching: %w", fetch.HttpError{StatusCode: 503}), longText, nil, false, resource.DefaultClient},
+		{"unsupported content type", "", fetch.ErrUnsupportedContentType, longText, nil, false, resource.DefaultClient},
+	}
+	url, _ := nurl.Parse("https://example.com/")
+	for _, test := range tests {
+		primary := &stubFetcher{method: resource.DefaultClient, text: test.text, err: test.err}
+		fallback := &stubFetcher{method: resource.HeadlessChromium, text: test.fallbackText, err: test.fallbackErr}
+		f := NewFallbackFetcher(primary, fallback)
+		page, err := f.Fetch(url)
+		if (fallback.calls != 0) != test.expectFallback {
+			t.Errorf("[%s] Expected fallback fetch %t, got %d", test.name, test.expectFallback, fallback.calls)
+		}
+		if page.FetchMethod != test.expectMethod {
+			t.Errorf("[%s] Expected fetch method %s, got %s", test.name, test.expectMethod, page.FetchMethod)
+		}
+		if page.FetchMethod == resource.HeadlessFallback {
+			if err != nil || page.ContentText != test.fallbackText {
+				t.Errorf("[%s] Expected the fallback result, got %v, %q", test.name, err, page.ContentText)
+			}
+			if !f.needsFallback("example.com") {
+				t.Errorf("[%s] Expected host to be remembered", test.name)
+			}
+		} else {
+			if err != test.err {
+				t.Errorf("[%s] Expected primary error %v, got %v", test.name, test.err, err)
+			}
+			if f.needsFallback("example.com") {
+				t.Errorf("[%s] Expected host not to be remembered", test.name)
+			}
+		}
+	}
+}
+
+func TestFallbackRemembersHosts(t *testing.T) {
+	primary := &stubFetcher{method: resource.DefaultClient, err: fetch.ErrJavaScriptRequired}
+	fallback := &stubFetcher{method: resource.HeadlessChromium, text: longText}
+	f := NewFallbackFetcher(primary, fallback, WithFallbackHostTTL(time.Hour))
+	spa, _ := nurl.Parse("https://SPA.example.com/a")
+	other, _ := nurl.Parse("https://other.example.com/a")
+	f.Fetch(spa)
+	if primary.calls != 1 || fallback.calls != 1 {
+		t.Fatalf("Expected a primary and a fallback fetch, got %d and %d", primary.calls, fallback.calls)
+	}
+	spa, _ = nurl.Parse("https://spa.example.com/b")
+	if page, _ := f.Fetch(spa); page.FetchMethod != resource.HeadlessFallback {
+		t.Errorf("Expected fallback fetch method, got %s", page.FetchMethod)
+	}
+	if primary.calls != 1 || fallback.calls != 2 {
+		t.Errorf("Expected remembered host to go straight to the fallback, got %d and %d", primary.calls, fallback.calls)
+	}
+
+	primary.err, primary.text = nil, longText
+	if page, _ := f.Fetch(other); page.FetchMethod != resource.DefaultClient {
+		t.Errorf("Expected other hosts to use the primary fetcher, got %s", page.FetchMethod)
+	}
+
+	// once the host's pages don't need the fallback, it's forgotten
+	fallback.text = ""
+	if page, _ := f.Fetch(spa); page.FetchMethod != resource.DefaultClient {
+		t.Errorf("Expected the primary result when the fallback comes up empty, got %s", page.FetchMethod)
+	}
+	if f.needsFallback("spa.example.com") {
+		t.Errorf("Expected host to be forgotten")
+	}
+
+	f = NewFallbackFetcher(primary, fallback, WithFallbackHostTTL(-time.Second))
+	f.remember("spa.example.com")
+	if f.needsFallback("spa.example.com") {
+		t.Errorf("Expected remembered host to expire")
+	}
+}
+
+type stubPreferences struct {
+	preferred map[string]bool
+	err       error
+	reads     int
+}
+
+func (p *stubPreferences) HeadlessPreferred(hostname string) (bool, error) {
+	p.reads++
+	return p.preferred[hostname], p.err
+}
+
+func (p *stubPreferences) SetHeadlessPreferred(hostname string, preferred bool) error {
+	p.preferred[hostname] = preferred
+	return p.err
+}
+
+func TestFallbackPersistsHosts(t *testing.T) {
+	primary := &stubFetcher{method: resource.DefaultClient, err: fetch.ErrJavaScriptRequired}
+	fallback := &stubFetcher{method: resource.HeadlessChromium, text: longText}
+	prefs := &stubPreferences{preferred: map[string]bool{"known.example.com": true}}
+	f := NewFallbackFetcher(primary, fallback, WithHeadlessPreferences(prefs))
+
+	// hosts that were persisted go straight to the fallback
+	known, _ := nurl.Parse("https://known.example.com/a")
+	if page, _ := f.Fetch(known); page.FetchMethod != resource.HeadlessFallback || primary.calls != 0 {
+		t.Errorf("Expected a persisted host to go straight to the fallback, got %s and %d primary fetches", page.FetchMethod, primary.calls)
+	}
+	// hosts that need the fallback are persisted
+	spa, _ := nurl.Parse("https://spa.example.com/a")
+	f.Fetch(spa)
+	if !prefs.preferred["spa.example.com"] {
+		t.Errorf("Expected host to be persisted, got %v", prefs.preferred)
+	}
+	// a new fetcher, as after a restart, picks up the persisted host
+	f = NewFallbackFetcher(primary, fallback, WithHeadlessPreferences(prefs))
+	primary.calls = 0
+	f.Fetch(spa)
+	if primary.calls != 0 {
+		t.Errorf("Expected persisted host to go straight to the fallback, got %d primary fetches", primary.calls)
+	}
+	// preferences are cached, including hosts that don't need the fallback
+	primary.err, primary.text = nil, longText
+	other, _ := nurl.Parse("https://other.example.com/a")
+	reads := prefs.reads
+	for range 3 {
+		f.Fetch(spa)
+		f.Fetch(other)
+	}
+	if prefs.reads != reads+1 {
+		t.Errorf("Expected one read for the uncached host, got %d", prefs.reads-reads)
+	}
+	// cleared once the fallback stops working for the host
+	fallback.text = ""
+	if page, _ := f.Fetch(spa); page.FetchMethod != resource.DefaultClient {
+		t.Errorf("Expected the primary result when the fallback comes up empty, got %s", page.FetchMethod)
+	}
+	if prefs.preferred["spa.example.com"] || f.needsFallback("spa.example.com") {
+		t.Errorf("Expected host to be cleared, got %v", prefs.preferred)
+	}
+
+	// preferences that can't be read send pages to the primary fetcher
+	prefs = &stubPreferences{preferred: map[string]bool{"known.example.com": true}, err: errors.New("database is locked")}
+	f = NewFallbackFetcher(primary, fallback, WithHeadlessPreferences(prefs))
+	if page, _ := f.Fetch(known); page.FetchMethod != resource.DefaultClient {
+		t.Errorf("Expected the primary fetcher when preferences fail, got %s", page.FetchMethod)
+	}
+}
+
+func TestAlternateFetcherSharesStorage(t *testing.T) {
+	dbh := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := dbh.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	direct := &stubFetcher{method: resource.DefaultClient}
+	fetcher := NewStorageBackedFetcher(direct, storage.NewURLDataStore(dbh))
+	headless := &stubFetcher{method: resource.HeadlessChromium}
+	headlessFetcher, err := fetcher.WithAlternateURLFetcher(ctx, headless)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, _ := nurl.Parse("https://example.com/spa")
+	page, err := headlessFetcher.Fetch(url)
+	if err != nil || page.FetchMethod != resource.HeadlessChromium {
+		t.Fatalf("Expected headless fetch, got %v, %s", err, page.FetchMethod)
+	}
+	fetcher.Wait()
+	page, err = fetcher.Fetch(url)
+	if err != nil {
+		t.Fatalf("Error fetching stored page: %v", err)
+	}
+	if direct.calls != 0 || page.FetchMethod != resource.HeadlessChromium {
+		t.Errorf("Expected the stored headless page, got %d direct fetches, %s", direct.calls, page.FetchMethod)
+	}
+}
+
+func TestHeadlessOptionsPassThrough(t *testing.T) {
+	dbh := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := dbh.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	primary := &stubFetcher{method: resource.DefaultClient, err: fetch.ErrJavaScriptRequired}
+	fallback := &stubFetcher{method: resource.HeadlessChromium, text: longText}
+	fetcher := NewStorageBackedFetcher(NewFallbackFetcher(primary, fallback), storage.NewURLDataStore(dbh))
+	options := &fetch.HeadlessOptions{WaitFor: "article"}
+	url, _ := nurl.Parse("https://example.com/spa")
+	if _, err := fetcher.FetchHeadless(url, options); err != nil {
+		t.Fatalf("Error fetching: %v", err)
+	}
+	if fallback.options != options {
+		t.Errorf("Expected the options to be passed to the fallback, got %+v", fallback.options)
+	}
+	fetcher.Wait()
+	fallback.options = nil
+	if _, err := fetcher.RefreshHeadless(url, options); err != nil {
+		t.Fatalf("Error refreshing: %v", err)
+	}
+	if fallback.calls != 2 || fallback.options != options {
+		t.Errorf("Expected a refresh with the options, got %d fetches with %+v", fallback.calls, fallback.options)
+	}
+}
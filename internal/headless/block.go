@@ -0,0 +1,111 @@
+package headless
+
+import (
+	nurl "net/url"
+	"strings"
+
+	cdpfetch "github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/efixler/scrape/fetch"
+)
+
+// Decides which of a tab's requests aren't sent, from a fetch.BlockList.
+type blocker struct {
+	host      string
+	resources map[network.ResourceType]bool
+	domains   map[string]bool
+	trackers  bool
+}
+
+// A blocker for pages on host. Renders load every type of resource, so that they
+// look right, but still skip the blocked domains and trackers.
+func newBlocker(list *fetch.BlockList, host string, render bool) *blocker {
+	b := &blocker{
+		host:      strings.ToLower(host),
+		resources: make(map[network.ResourceType]bool),
+		domains:   make(map[string]bool),
+	}
+	if list == nil {
+		return b
+	}
+	if !render {
+		for _, r := range list.Resources {
+			b.resources[networkResourceType(r)] = true
+		}
+	}
+	for _, d := range list.Domains {
+		b.domains[strings.ToLower(d)] = true
+	}
+	b.trackers = list.Trackers
+	return b
+}
+
+func networkResourceType(r fetch.ResourceType) network.ResourceType {
+	switch r {
+	case fetch.ResourceImage:
+		return network.ResourceTypeImage
+	case fetch.ResourceMedia:
+		return network.ResourceTypeMedia
+	case fetch.ResourceFont:
+		return network.ResourceTypeFont
+	case fetch.ResourceStylesheet:
+		return network.ResourceTypeStylesheet
+	default:
+		return network.ResourceTypeOther
+	}
+}
+
+// The requests to intercept. When only resource types are blocked, every intercepted
+// request is blocked; otherwise every request is intercepted, and blocks decides.
+// Returns nil when nothing is blocked.
+func (b *blocker) patterns() []*cdpfetch.RequestPattern {
+	if len(b.domains) > 0 || b.trackers {
+		return []*cdpfetch.RequestPattern{{URLPattern: "*", RequestStage: cdpfetch.RequestStageRequest}}
+	}
+	patterns := make([]*cdpfetch.RequestPattern, 0, len(b.resources))
+	for rt := range b.resources {
+		patterns = append(patterns, &cdpfetch.RequestPattern{
+			URLPattern:   "*",
+			ResourceType: rt,
+			RequestStage: cdpfetch.RequestStageRequest,
+		})
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	return patterns
+}
+
+// Whether a request for url, of type rt, is blocked. The page itself isn't, and
+// nothing from the page's own host is blocked by domain.
+func (b *blocker) blocks(url string, rt network.ResourceType, page bool) bool {
+	switch {
+	case page:
+		return false
+	case b.resources[rt]:
+		return true
+	}
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" || host == b.host {
+		return false
+	}
+	return matchDomain(host, b.domains) || (b.trackers && matchDomain(host, trackerDomains))
+}
+
+// Whether host or one of its parent domains is in domains.
+func matchDomain(host string, domains map[string]bool) bool {
+	for {
+		if domains[host] {
+			return true
+		}
+		dot := strings.IndexByte(host, '.')
+		if dot < 0 {
+			return false
+		}
+		host = host[dot+1:]
+	}
+}
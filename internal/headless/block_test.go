@@ -0,0 +1,67 @@
+package headless
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/efixler/scrape/fetch"
+)
+
+func TestBlocker(t *testing.T) {
+	list := &fetch.BlockList{
+		Resources: []fetch.ResourceType{fetch.ResourceImage, fetch.ResourceFont},
+		Domains:   []string{"Ads.Example.net"},
+		Trackers:  true,
+	}
+	tests := []struct {
+		name   string
+		list   *fetch.BlockList
+		render bool
+		url    string
+		rt     network.ResourceType
+		page   bool
+		expect bool
+	}{
+		{"script", list, false, "https://cdn.example.com/app.js", network.ResourceTypeScript, false, false},
+		{"image", list, false, "https://example.com/a.png", network.ResourceTypeImage, false, true},
+		{"image in render", list, true, "https://example.com/a.png", network.ResourceTypeImage, false, false},
+		{"blocked domain", list, false, "https://ads.example.net/ad.js", network.ResourceTypeScript, false, true},
+		{"blocked subdomain", list, true, "https://x.ads.example.net/ad.js", network.ResourceTypeScript, false, true},
+		{"parent of blocked domain", list, false, "https://example.net/a.js", network.ResourceTypeScript, false, false},
+		{"tracker", list, false, "https://www.google-analytics.com/analytics.js", network.ResourceTypeScript, false, true},
+		{"first party", list, false, "https://www.doubleclick.net/a.js", network.ResourceTypeScript, false, false},
+		{"page", list, false, "https://ads.example.net/", network.ResourceTypeDocument, true, false},
+		{"tracker iframe", list, false, "https://ads.example.net/", network.ResourceTypeDocument, false, true},
+		{"no trackers", &fetch.BlockList{}, false, "https://www.google-analytics.com/analytics.js", network.ResourceTypeScript, false, false},
+		{"nil list", nil, false, "https://example.com/a.png", network.ResourceTypeImage, false, false},
+	}
+	for _, test := range tests {
+		b := newBlocker(test.list, "www.doubleclick.net", test.render)
+		if blocked := b.blocks(test.url, test.rt, test.page); blocked != test.expect {
+			t.Errorf("[%s] Expected blocked %t, got %t", test.name, test.expect, blocked)
+		}
+	}
+}
+
+func TestBlockerPatterns(t *testing.T) {
+	tests := []struct {
+		name   string
+		list   *fetch.BlockList
+		render bool
+		expect int
+	}{
+		{"nothing", &fetch.BlockList{}, false, 0},
+		{"resource types", &fetch.BlockList{Resources: []fetch.ResourceType{fetch.ResourceImage, fetch.ResourceMedia}}, false, 2},
+		{"resource types in render", &fetch.BlockList{Resources: []fetch.ResourceType{fetch.ResourceImage}}, true, 0},
+		{"trackers", &fetch.DefaultBlockList, false, 1},
+	}
+	for _, test := range tests {
+		patterns := newBlocker(test.list, "example.com", test.render).patterns()
+		if len(patterns) != test.expect {
+			t.Errorf("[%s] Expected %d patterns, got %d", test.name, test.expect, len(patterns))
+		}
+		if test.expect == 0 && patterns != nil {
+			t.Errorf("[%s] Expected nil patterns", test.name)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package headless
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	nurl "net/url"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Send the cookies from jar with page loads, and give it the cookies that pages set.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(c *client) error {
+		if jar == nil {
+			return errors.New("nil cookie jar")
+		}
+		c.jar = jar
+		return nil
+	}
+}
+
+// The cookies from the jar for a page, which are set in its tab before it loads,
+// so that they're sent with the page's requests to its host. Keeps track of them,
+// so that only the cookies that the page sets are given back to the jar.
+type pageCookies struct {
+	jar  http.CookieJar
+	url  *nurl.URL
+	sent map[string]string // values by cookieKey
+}
+
+func newPageCookies(jar http.CookieJar, url *nurl.URL) *pageCookies {
+	return &pageCookies{jar: jar, url: url, sent: make(map[string]string)}
+}
+
+func (p *pageCookies) set() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for _, c := range p.jar.Cookies(p.url) {
+			if err := setCookie(p.url, c).Do(ctx); err != nil {
+				return err
+			}
+			p.sent[cookieKey(c.Name, c.Path)] = c.Value
+		}
+		return nil
+	})
+}
+
+// Gives the jar the cookies for the page's url that the page set or changed.
+// Errors are logged, rather than failing the page load.
+func (p *pageCookies) save() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		cookies, err := network.GetCookies().WithUrls([]string{p.url.String()}).Do(ctx)
+		if err != nil {
+			slog.Warn("Error getting cookies from headless browser", "url", p.url, "err", err)
+			return nil
+		}
+		set := make([]*http.Cookie, 0, len(cookies))
+		for _, c := range cookies {
+			if value, ok := p.sent[cookieKey(c.Name, c.Path)]; ok && value == c.Value {
+				continue
+			}
+			set = append(set, httpCookie(c))
+		}
+		if len(set) > 0 {
+			p.jar.SetCookies(p.url, set)
+		}
+		return nil
+	})
+}
+
+// Sets a cookie from the jar in the browser, with its attributes. Cookies without
+// a domain are set for the url's host, and cookies without a path for all of it.
+func setCookie(url *nurl.URL, c *http.Cookie) *network.SetCookieParams {
+	path := c.Path
+	if path == "" {
+		path = "/"
+	}
+	params := network.SetCookie(c.Name, c.Value).
+		WithURL(url.String()).
+		WithPath(path).
+		WithSecure(c.Secure).
+		WithHTTPOnly(c.HttpOnly)
+	if c.Domain != "" {
+		params = params.WithDomain(c.Domain)
+	}
+	if !c.Expires.IsZero() {
+		expires := cdp.TimeSinceEpoch(c.Expires)
+		params = params.WithExpires(&expires)
+	}
+	switch c.SameSite {
+	case http.SameSiteStrictMode:
+		params = params.WithSameSite(network.CookieSameSiteStrict)
+	case http.SameSiteLaxMode:
+		params = params.WithSameSite(network.CookieSameSiteLax)
+	case http.SameSiteNoneMode:
+		params = params.WithSameSite(network.CookieSameSiteNone)
+	}
+	return params
+}
+
+func cookieKey(name, path string) string {
+	return name + "\x00" + path
+}
+
+func httpCookie(c *network.Cookie) *http.Cookie {
+	hc := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HTTPOnly,
+	}
+	if !c.Session && c.Expires > 0 {
+		sec, frac := math.Modf(c.Expires)
+		hc.Expires = time.Unix(int64(sec), int64(frac*1e9)).UTC()
+	}
+	return hc
+}
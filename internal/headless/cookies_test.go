@@ -0,0 +1,60 @@
+package headless
+
+import (
+	"net/http"
+	nurl "net/url"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestHTTPCookie(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		cookie *network.Cookie
+		expect time.Time
+	}{
+		{"session", &network.Cookie{Name: "a", Value: "1", Domain: "www.example.com", Path: "/", Session: true, Expires: -1}, time.Time{}},
+		{"expires", &network.Cookie{Name: "b", Value: "2", Domain: ".example.com", Path: "/news", Expires: float64(expires.Unix())}, expires},
+	}
+	for _, test := range tests {
+		hc := httpCookie(test.cookie)
+		if hc.Name != test.cookie.Name || hc.Value != test.cookie.Value || hc.Domain != test.cookie.Domain || hc.Path != test.cookie.Path {
+			t.Errorf("[%s] Unexpected cookie %+v", test.name, hc)
+		}
+		if !hc.Expires.Equal(test.expect) {
+			t.Errorf("[%s] Expected expiry %v, got %v", test.name, test.expect, hc.Expires)
+		}
+	}
+}
+
+func TestSetCookie(t *testing.T) {
+	url, _ := nurl.Parse("https://www.example.com/news/a")
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	params := setCookie(url, &http.Cookie{
+		Name:     "session",
+		Value:    "abc",
+		Domain:   "example.com",
+		Path:     "/news",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  expires,
+	})
+	if params.URL != url.String() || params.Domain != "example.com" || params.Path != "/news" {
+		t.Errorf("Unexpected cookie scope %+v", params)
+	}
+	if !params.Secure || !params.HTTPOnly || params.SameSite != network.CookieSameSiteLax {
+		t.Errorf("Expected the cookie's attributes to be set, got %+v", params)
+	}
+	if params.Expires == nil || !params.Expires.Time().Equal(expires) {
+		t.Errorf("Expected expiry %v, got %v", expires, params.Expires)
+	}
+
+	params = setCookie(url, &http.Cookie{Name: "consent", Value: "yes"})
+	if params.Domain != "" || params.Path != "/" || params.Expires != nil || params.SameSite != "" {
+		t.Errorf("Expected a session cookie for the host, got %+v", params)
+	}
+}
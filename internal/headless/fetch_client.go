@@ -3,51 +3,233 @@ package headless
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	nurl "net/url"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/efixler/headless"
-	"github.com/efixler/headless/browser"
+	"github.com/chromedp/chromedp"
+	"github.com/efixler/headless/ua"
 	"github.com/efixler/scrape/fetch"
 	"github.com/efixler/scrape/resource"
 )
 
+const (
+	// How long a fetch waits for a free tab.
+	DefaultTabAcquireTimeout = 10 * time.Second
+	// How long a page gets to load, including the waits and scripts in its options.
+	DefaultPageTimeout = 60 * time.Second
+)
+
+var (
+	ErrMaxTabs          = errors.New("maximum number of tabs reached")
+	ErrInvalidTabNumber = errors.New("maximum number of tabs must be at least 1")
+	ErrInvalidTimeout   = errors.New("timeout must be greater than 0")
+)
+
+// Looks up the headless options for a url's hostname. Returns nil when there
+// aren't any.
+type DomainOptions func(hostname string) (*fetch.HeadlessOptions, error)
+
+type Option func(*client) error
+
+// Load pages with the headless options for their domain. Options passed with a
+// request override the domain's options.
+func WithDomainOptions(f DomainOptions) Option {
+	return func(c *client) error {
+		if f == nil {
+			return errors.New("nil domain options")
+		}
+		c.domainOptions = f
+		return nil
+	}
+}
+
+// What pages don't load when neither their domain's options nor the request's
+// have a block list. Defaults to fetch.DefaultBlockList.
+func WithBlockList(list fetch.BlockList) Option {
+	return func(c *client) error {
+		if err := list.Validate(); err != nil {
+			return err
+		}
+		c.blockList = &list
+		return nil
+	}
+}
+
+// How long a page gets to load, including the waits and scripts in its options.
+// Defaults to DefaultPageTimeout.
+func WithPageTimeout(d time.Duration) Option {
+	return func(c *client) error {
+		if d <= 0 {
+			return ErrInvalidTimeout
+		}
+		c.pageTimeout = d
+		return nil
+	}
+}
+
+// How long a fetch waits for a free tab before failing with ErrMaxTabs.
+// Defaults to DefaultTabAcquireTimeout.
+func WithTabTimeout(d time.Duration) Option {
+	return func(c *client) error {
+		if d <= 0 {
+			return ErrInvalidTimeout
+		}
+		c.tabTimeout = d
+		return nil
+	}
+}
+
+// Recycle the browser after it's loaded n pages. 0 never recycles it for the
+// number of pages. Defaults to DefaultMaxPages.
+func WithMaxPages(n int) Option {
+	return func(c *client) error {
+		if n < 0 {
+			return errors.New("max pages can't be negative")
+		}
+		c.maxPages = n
+		return nil
+	}
+}
+
+// Recycle the browser when its processes use more than this many bytes of memory.
+// 0, the default, never recycles it for its memory use. Memory is only checked
+// on Linux.
+func WithMaxMemory(bytes int64) Option {
+	return func(c *client) error {
+		if bytes < 0 {
+			return errors.New("max memory can't be negative")
+		}
+		c.maxMemory = bytes
+		return nil
+	}
+}
+
 type client struct {
-	browser headless.TabFactory
+	ctx           context.Context
+	cancel        context.CancelFunc
+	tabs          chan struct{}
+	tabTimeout    time.Duration
+	pageTimeout   time.Duration
+	domainOptions DomainOptions
+	blockList     *fetch.BlockList
+	jar           http.CookieJar
+	maxPages      int
+	maxMemory     int64
+	checkInterval time.Duration
+	mutex         sync.Mutex
+	browser       *browser
+	launching     bool
+	launched      *sync.Cond
+	start         func() (*browser, error)
+	pages         uint64
+	launches      uint64
+	crashes       uint64
+	recycles      uint64
 }
 
-func MustChromeClient(ctx context.Context, userAgent string, maxConcurrent int) fetch.Client {
-	c, err := NewChromeClient(ctx, userAgent, maxConcurrent)
+func MustChromeClient(ctx context.Context, userAgent string, maxConcurrent int, options ...Option) Client {
+	c, err := NewChromeClient(ctx, userAgent, maxConcurrent, options...)
 	if err != nil {
 		panic(err)
 	}
 	return c
 }
 
-func NewChromeClient(ctx context.Context, userAgent string, maxConcurrent int) (fetch.Client, error) {
-	browser, err := browser.NewChrome(
-		ctx,
-		browser.MaxTabs(maxConcurrent),
-		browser.Headless(true),
-		browser.AsFirefox(),
-		browser.UserAgentIfNotEmpty(userAgent),
-	)
-	if err != nil {
-		return nil, err
+// NewChromeClient returns a client that loads pages in up to maxConcurrent tabs of a
+// headless Chrome browser. Chrome is launched when the first page is loaded, and exits
+// when ctx is done. The browser is restarted if it crashes, and recycled after
+// DefaultMaxPages pages (see WithMaxPages and WithMaxMemory). Pages are loaded with
+// the Firefox user agent if userAgent is empty.
+//
+// Tabs are driven with chromedp directly, rather than through the headless
+// library's browser, which only loads a url in a new browser and returns the
+// response; the wait conditions and actions in fetch.HeadlessOptions need the tab.
+func NewChromeClient(ctx context.Context, userAgent string, maxConcurrent int, options ...Option) (Client, error) {
+	if maxConcurrent < 1 {
+		return nil, ErrInvalidTabNumber
 	}
 	c := &client{
-		browser: browser,
+		tabs:          make(chan struct{}, maxConcurrent),
+		tabTimeout:    DefaultTabAcquireTimeout,
+		pageTimeout:   DefaultPageTimeout,
+		blockList:     &fetch.DefaultBlockList,
+		maxPages:      DefaultMaxPages,
+		checkInterval: DefaultCheckInterval,
+	}
+	c.launched = sync.NewCond(&c.mutex)
+	c.start = c.launch
+	for _, opt := range options {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
 	}
+	// Like the headless library's browser.AsFirefox
+	if userAgent == "" {
+		userAgent = ua.Firefox88
+	}
+	c.ctx, c.cancel = chromedp.NewExecAllocator(
+		ctx,
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.NoFirstRun,
+		chromedp.NoDefaultBrowserCheck,
+		chromedp.Headless,
+		chromedp.UserAgent(userAgent),
+		chromedp.WindowSize(1366, 768),
+	)
 	return c, nil
 }
 
-func (c client) Identifier() resource.ClientIdentifier {
+func (c *client) Identifier() resource.ClientIdentifier {
 	return resource.HeadlessChromium
 }
 
 func (c *client) Get(url string, headers http.Header) (*http.Response, error) {
-	tab, err := c.browser.AcquireTab()
+	return c.GetHeadless(url, headers, nil)
+}
+
+// Load url in a tab, with the options for its domain, overridden by options.
+func (c *client) GetHeadless(url string, headers http.Header, options *fetch.HeadlessOptions) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	return tab.Get(url, headers)
+	if err = c.acquireTab(); err != nil {
+		return nil, err
+	}
+	defer c.releaseTab()
+	return c.load(request, headers, c.optionsFor(request.URL).Merge(options))
+}
+
+func (c *client) acquireTab() error {
+	timer := time.NewTimer(c.tabTimeout)
+	defer timer.Stop()
+	select {
+	case c.tabs <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrMaxTabs
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+func (c *client) releaseTab() {
+	<-c.tabs
+}
+
+func (c *client) optionsFor(url *nurl.URL) *fetch.HeadlessOptions {
+	if c.domainOptions == nil {
+		return nil
+	}
+	options, err := c.domainOptions(strings.ToLower(url.Hostname()))
+	if err != nil {
+		slog.Warn("Error getting headless options for domain", "url", url, "error", err)
+		return nil
+	}
+	return options
 }
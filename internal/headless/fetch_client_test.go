@@ -0,0 +1,32 @@
+package headless
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireTab(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := NewChromeClient(ctx, "", 0); !errors.Is(err, ErrInvalidTabNumber) {
+		t.Errorf("Expected ErrInvalidTabNumber, got %v", err)
+	}
+	c, err := NewChromeClient(ctx, "", 1)
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	cc := c.(*client)
+	cc.tabTimeout = 10 * time.Millisecond
+	if err := cc.acquireTab(); err != nil {
+		t.Fatalf("Error acquiring tab: %v", err)
+	}
+	if err := cc.acquireTab(); !errors.Is(err, ErrMaxTabs) {
+		t.Errorf("Expected ErrMaxTabs, got %v", err)
+	}
+	cc.releaseTab()
+	if err := cc.acquireTab(); err != nil {
+		t.Errorf("Expected a released tab to be available, got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package headless
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cdpbrowser "github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// Recycle the browser after it's loaded this many pages.
+	DefaultMaxPages = 500
+	// How often the browser is checked for crashes and its memory use.
+	DefaultCheckInterval = 30 * time.Second
+	// How long the browser gets to answer a check before it's restarted.
+	CheckTimeout = 10 * time.Second
+)
+
+// A running Chrome, shared by the tabs that open while it's the client's current
+// browser. Once it's retired, it closes when its open tabs are done.
+type browser struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started time.Time
+	pid     int
+	pages   int
+	retired bool
+	open    sync.WaitGroup
+	memory  atomic.Int64
+}
+
+// Browser state for healthchecks. Pages, Launches, Crashes and Recycles count
+// since the server started; BrowserPages counts the pages loaded by the running
+// browser. MemoryBytes is the memory used by the browser's processes as of the
+// last check, when it can be read (on Linux).
+type Stats struct {
+	Running      bool   `json:"running"`
+	Launching    bool   `json:"launching,omitempty"`
+	PID          int    `json:"pid,omitempty"`
+	StartTime    string `json:"start_time,omitempty"`
+	MemoryBytes  int64  `json:"memory_bytes,omitempty"`
+	OpenTabs     int    `json:"open_tabs"`
+	MaxTabs      int    `json:"max_tabs"`
+	BrowserPages int    `json:"browser_pages"`
+	Pages        uint64 `json:"pages"`
+	Launches     uint64 `json:"launches"`
+	Crashes      uint64 `json:"crashes"`
+	Recycles     uint64 `json:"recycles"`
+}
+
+func (c *client) Stats() (any, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stats := &Stats{
+		OpenTabs:  len(c.tabs),
+		MaxTabs:   cap(c.tabs),
+		Launching: c.launching,
+		Pages:     c.pages,
+		Launches:  c.launches,
+		Crashes:   c.crashes,
+		Recycles:  c.recycles,
+	}
+	if b := c.browser; b != nil {
+		stats.Running = true
+		stats.PID = b.pid
+		stats.StartTime = b.started.UTC().Format(time.RFC3339)
+		stats.MemoryBytes = b.memory.Load()
+		stats.BrowserPages = b.pages
+	}
+	return stats, nil
+}
+
+// Open a tab in the current browser, launching one if there isn't one running.
+// The returned cancel func closes the tab and its browser context.
+func (c *client) newTab() (context.Context, context.CancelFunc, error) {
+	b, err := c.openTab()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Each tab has its own browser context, so pages don't share cookies or storage
+	ctx, cancel := chromedp.NewContext(b.ctx, chromedp.WithNewBrowserContext())
+	return ctx, func() {
+		cancel()
+		b.open.Done()
+	}, nil
+}
+
+// Count a tab opening in the current browser, and return the browser. Call
+// b.open.Done when the tab closes.
+//
+// Chrome is launched without the mutex held, so that Stats and the tabs of a
+// retiring browser aren't held up while it starts. Tabs that need the browser
+// while it's launching wait for it.
+func (c *client) openTab() (*browser, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for c.browser == nil {
+		if c.launching {
+			c.launched.Wait()
+			continue
+		}
+		c.launching = true
+		c.mutex.Unlock()
+		b, err := c.start()
+		c.mutex.Lock()
+		c.launching = false
+		c.launched.Broadcast()
+		if err != nil {
+			return nil, err
+		}
+		c.launches++
+		// b is already being watched, and may have crashed since it started
+		if !b.retired {
+			c.browser = b
+		}
+	}
+	b := c.browser
+	b.pages++
+	c.pages++
+	b.open.Add(1)
+	if c.maxPages > 0 && b.pages >= c.maxPages {
+		slog.Info("Recycling headless browser", "pid", b.pid, "reason", "max pages", "pages", b.pages)
+		c.recycles++
+		c.retire(b)
+	}
+	return b, nil
+}
+
+// Start a browser, and watch it until it's retired. newTab makes it the
+// current browser.
+func (c *client) launch() (*browser, error) {
+	ctx, cancel := chromedp.NewContext(c.ctx)
+	// Running without actions starts the browser, with a blank first tab
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, errors.Join(errors.New("error launching headless browser"), err)
+	}
+	b := &browser{
+		ctx:     ctx,
+		cancel:  cancel,
+		started: time.Now(),
+	}
+	if p := chromedp.FromContext(ctx).Browser.Process(); p != nil {
+		b.pid = p.Pid
+	}
+	slog.Info("Launched headless browser", "pid", b.pid)
+	go c.watch(b)
+	return b, nil
+}
+
+// Stop using b for new tabs, and close it once its open tabs are done. Must be
+// called with the mutex held.
+func (c *client) retire(b *browser) {
+	if b.retired {
+		return
+	}
+	b.retired = true
+	if c.browser == b {
+		c.browser = nil
+	}
+	go func() {
+		b.open.Wait()
+		b.cancel()
+	}()
+}
+
+// Restart the browser if it crashes or stops responding, and recycle it if its
+// memory use goes over the limit.
+func (c *client) watch(b *browser) {
+	ticker := time.NewTicker(c.checkInterval)
+	defer ticker.Stop()
+	lost := chromedp.FromContext(b.ctx).Browser.LostConnection
+	for {
+		select {
+		case <-b.ctx.Done():
+			c.crashed(b, b.ctx.Err())
+			return
+		case <-lost:
+			c.crashed(b, errors.New("lost connection to browser"))
+			return
+		case <-ticker.C:
+		}
+		if err := ping(b.ctx); err != nil {
+			c.crashed(b, err)
+			return
+		}
+		if b.pid == 0 {
+			continue
+		}
+		memory, err := processTreeMemory(b.pid)
+		if err != nil {
+			slog.Debug("Error reading headless browser memory", "pid", b.pid, "err", err)
+			continue
+		}
+		b.memory.Store(memory)
+		if c.maxMemory > 0 && memory > c.maxMemory {
+			c.mutex.Lock()
+			if !b.retired {
+				slog.Info("Recycling headless browser", "pid", b.pid, "reason", "max memory", "memory_bytes", memory)
+				c.recycles++
+				c.retire(b)
+			}
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// Retire b if it stopped without being retired. Its open tabs fail with errors
+// of their own, and the next tab launches a new browser.
+func (c *client) crashed(b *browser, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if b.retired || c.ctx.Err() != nil {
+		return
+	}
+	slog.Error("Headless browser stopped unexpectedly, the next page will relaunch it", "pid", b.pid, "err", err)
+	c.crashes++
+	c.retire(b)
+}
+
+func ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+	ctx = cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Browser)
+	_, _, _, _, _, err := cdpbrowser.GetVersion().Do(ctx)
+	return err
+}
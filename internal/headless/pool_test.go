@@ -0,0 +1,144 @@
+package headless
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBrowserRetirement(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewChromeClient(ctx, "", 2)
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	cc := c.(*client)
+	bctx, bcancel := context.WithCancel(ctx)
+	b := &browser{ctx: bctx, cancel: bcancel, started: time.Now(), pid: 42, pages: 3}
+	cc.browser = b
+	stats, _ := cc.Stats()
+	if s := stats.(*Stats); !s.Running || s.PID != 42 || s.BrowserPages != 3 || s.MaxTabs != 2 {
+		t.Errorf("Unexpected stats for a running browser: %+v", s)
+	}
+
+	b.open.Add(1)
+	cc.crashed(b, errors.New("crashed"))
+	cc.crashed(b, errors.New("crashed again"))
+	if cc.browser != nil || cc.crashes != 1 {
+		t.Errorf("Expected the crashed browser to be retired once, got %v and %d crashes", cc.browser, cc.crashes)
+	}
+	select {
+	case <-bctx.Done():
+		t.Fatalf("Expected the retired browser to stay open for its open tab")
+	case <-time.After(10 * time.Millisecond):
+	}
+	b.open.Done()
+	select {
+	case <-bctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Expected the retired browser to close when its tab was done")
+	}
+	stats, _ = cc.Stats()
+	if s := stats.(*Stats); s.Running || s.Crashes != 1 {
+		t.Errorf("Unexpected stats after a crash: %+v", s)
+	}
+}
+
+func TestLaunchDoesNotHoldLock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, err := NewChromeClient(ctx, "", 3)
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	cc := c.(*client)
+	started, release := make(chan struct{}), make(chan struct{})
+	cc.start = func() (*browser, error) {
+		close(started)
+		<-release
+		bctx, bcancel := context.WithCancel(ctx)
+		return &browser{ctx: bctx, cancel: bcancel, started: time.Now(), pid: 42}, nil
+	}
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b, err := cc.openTab(); err != nil {
+				t.Errorf("Error opening tab: %v", err)
+			} else {
+				b.open.Done()
+			}
+		}()
+	}
+	<-started
+	statsDone := make(chan *Stats)
+	go func() {
+		stats, _ := cc.Stats()
+		statsDone <- stats.(*Stats)
+	}()
+	select {
+	case s := <-statsDone:
+		if !s.Launching || s.Running {
+			t.Errorf("Expected stats for a launching browser, got %+v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Stats not to wait for the browser to launch")
+	}
+	close(release)
+	wg.Wait()
+	stats, _ := cc.Stats()
+	if s := stats.(*Stats); s.Launches != 1 || s.BrowserPages != 3 || s.Launching {
+		t.Errorf("Expected one launch shared by the tabs, got %+v", s)
+	}
+}
+
+func TestPoolOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tests := []struct {
+		name      string
+		option    Option
+		expectErr bool
+	}{
+		{"page timeout", WithPageTimeout(time.Second), false},
+		{"zero page timeout", WithPageTimeout(0), true},
+		{"tab timeout", WithTabTimeout(time.Second), false},
+		{"negative tab timeout", WithTabTimeout(-time.Second), true},
+		{"max pages", WithMaxPages(0), false},
+		{"negative max pages", WithMaxPages(-1), true},
+		{"max memory", WithMaxMemory(1 << 30), false},
+		{"negative max memory", WithMaxMemory(-1), true},
+	}
+	for _, test := range tests {
+		if _, err := NewChromeClient(ctx, "", 1, test.option); (err != nil) != test.expectErr {
+			t.Errorf("[%s] Expected error %t, got %v", test.name, test.expectErr, err)
+		}
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	pid, ppid, rss, ok := parseStat([]byte("1234 (chrome (renderer)) S 1200 1234 1234 0 -1 4194560 100 0 0 0 5 2 0 0 20 0 12 0 500 123456789 2048 18446744073709551615"))
+	if !ok || pid != 1234 || ppid != 1200 || rss != 2048 {
+		t.Errorf("Expected 1234, 1200, 2048, got %d, %d, %d (%t)", pid, ppid, rss, ok)
+	}
+	if _, _, _, ok := parseStat([]byte("1234 (chrome) S")); ok {
+		t.Errorf("Expected a short stat not to parse")
+	}
+}
+
+func TestProcessTreeMemory(t *testing.T) {
+	memory, err := processTreeMemory(os.Getpid())
+	switch {
+	case errors.Is(err, errNoProcFS):
+		t.Skip("no /proc")
+	case err != nil:
+		t.Fatalf("Error reading memory: %v", err)
+	case memory <= 0:
+		t.Errorf("Expected this process to use some memory, got %d", memory)
+	}
+}
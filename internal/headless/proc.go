@@ -0,0 +1,75 @@
+package headless
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var errNoProcFS = errors.New("process memory isn't available without /proc")
+
+// The resident memory of the process with pid and all its descendants, which for
+// Chrome are its renderer, GPU and utility processes. Reads /proc, so only works
+// on Linux.
+func processTreeMemory(pid int) (int64, error) {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return 0, err
+	}
+	if len(stats) == 0 {
+		return 0, errNoProcFS
+	}
+	children := make(map[int][]int)
+	rss := make(map[int]int64)
+	for _, path := range stats {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			// processes come and go
+			continue
+		}
+		p, ppid, pages, ok := parseStat(b)
+		if !ok {
+			continue
+		}
+		children[ppid] = append(children[ppid], p)
+		rss[p] = pages
+	}
+	if _, ok := rss[pid]; !ok {
+		return 0, os.ErrNotExist
+	}
+	var total int64
+	for queue := []int{pid}; len(queue) > 0; queue = queue[1:] {
+		total += rss[queue[0]]
+		queue = append(queue, children[queue[0]]...)
+	}
+	return total * int64(os.Getpagesize()), nil
+}
+
+// Parses the pid, parent pid and resident set size (in pages) from the contents of
+// /proc/[pid]/stat. See proc(5).
+func parseStat(stat []byte) (pid, ppid int, rss int64, ok bool) {
+	start, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return 0, 0, 0, false
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(stat[:start])))
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	// the fields after the command name start with the state, field 3
+	fields := bytes.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return 0, 0, 0, false
+	}
+	ppid, err = strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	rss, err = strconv.ParseInt(string(fields[21]), 10, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return pid, ppid, rss, true
+}
@@ -0,0 +1,54 @@
+package headless
+
+import (
+	"context"
+	"net/http"
+	nurl "net/url"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/efixler/scrape/fetch"
+)
+
+// The browser client, which also renders pages.
+type Client interface {
+	fetch.HeadlessClient
+	fetch.Renderer
+	// Browser state, for healthchecks
+	Stats() (any, error)
+}
+
+// Load url in a tab, with the options for its domain overridden by options, and
+// render it as a full-page screenshot or a PDF. Pages that load with an error
+// status return a fetch.HttpError.
+func (c *client) Render(url *nurl.URL, format fetch.RenderFormat, options *fetch.HeadlessOptions) ([]byte, error) {
+	request, err := http.NewRequest(http.MethodGet, url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.acquireTab(); err != nil {
+		return nil, err
+	}
+	defer c.releaseTab()
+	var rendered []byte
+	capture := chromedp.FullScreenshot(&rendered, 100) // 100 for a PNG
+	if format == fetch.PDF {
+		capture = printToPDF(&rendered)
+	}
+	response, err := c.run(request, nil, c.optionsFor(request.URL).Merge(options), true, capture)
+	switch {
+	case err != nil:
+		return nil, err
+	case response.StatusCode >= 400:
+		return nil, fetch.HttpError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+	return rendered, nil
+}
+
+func printToPDF(res *[]byte) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		*res, _, err = page.PrintToPDF().WithPrintBackground(true).Do(ctx)
+		return err
+	})
+}
@@ -0,0 +1,350 @@
+package headless
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	nurl "net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	cdpfetch "github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/efixler/scrape/fetch"
+)
+
+const (
+	// How long pages get to render when their options don't say to wait for anything.
+	DefaultDelay = time.Second
+	// How long to wait for a selector or for the network to go idle, before
+	// capturing the page anyway.
+	DefaultWaitTimeout = 10 * time.Second
+	// The network is idle when it's been quiet this long.
+	NetworkIdleTime = 500 * time.Millisecond
+	// Requests that can stay open while the network is idle, for long polling
+	// and the like.
+	MaxIdleRequests = 2
+	// How long to wait after clicks and scrolls when not waiting for the network.
+	SettleDelay = 500 * time.Millisecond
+)
+
+const (
+	scrollScript = `window.scrollTo(0, document.body.scrollHeight)`
+	// Selectors that don't match, or aren't valid, are skipped
+	clickScript = `%s.forEach(s => {
+		try { document.querySelectorAll(s).forEach(e => e.click()) } catch (e) {}
+	})`
+)
+
+// Load the request's url in a new tab, without the resources in the block list,
+// run the options' steps, and return the page's DOM as the response body. If the page doesn't load, the
+// response has a 502 status as well as the error.
+func (c *client) load(request *http.Request, headers http.Header, options *fetch.HeadlessOptions) (*http.Response, error) {
+	var html string
+	response, err := c.run(request, headers, options, false, chromedp.OuterHTML("html", &html))
+	response.ContentLength = int64(len(html))
+	response.Body = io.NopCloser(strings.NewReader(html))
+	return response, err
+}
+
+// Load the request's url in a new tab, with the cookies from the client's jar,
+// run the options' steps, and then capture.
+// Requests are blocked by the options' block list, or the client's if the options
+// don't have one. Renders load every type of resource.
+// Returns a response with the status and headers of the page's document, and no body.
+func (c *client) run(
+	request *http.Request,
+	headers http.Header,
+	options *fetch.HeadlessOptions,
+	render bool,
+	capture chromedp.Action,
+) (*http.Response, error) {
+	ctx, cancel, err := c.newTab()
+	if err != nil {
+		return failed(newNetworkMonitor().response(request), err), err
+	}
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, c.pageTimeout)
+	defer cancelTimeout()
+
+	blockList := c.blockList
+	if options != nil && options.Block != nil {
+		blockList = options.Block
+	}
+	blocker := newBlocker(blockList, request.URL.Hostname(), render)
+	monitor := newNetworkMonitor()
+	chromedp.ListenTarget(ctx, func(ev any) {
+		monitor.observe(ev)
+		if paused, ok := ev.(*cdpfetch.EventRequestPaused); ok {
+			go handlePaused(ctx, blocker, paused)
+		}
+	})
+	actions := make([]chromedp.Action, 0, 8)
+	if patterns := blocker.patterns(); patterns != nil {
+		actions = append(actions, cdpfetch.Enable().WithPatterns(patterns))
+	}
+	if len(headers) > 0 {
+		actions = append(actions, network.SetExtraHTTPHeaders(extraHeaders(headers)))
+	}
+	var cookies *pageCookies
+	if c.jar != nil {
+		cookies = newPageCookies(c.jar, request.URL)
+		actions = append(actions, cookies.set())
+	}
+	actions = append(actions,
+		chromedp.Navigate(request.URL.String()),
+		chromedp.WaitReady("body"),
+	)
+	actions = append(actions, pageActions(options, monitor)...)
+	actions = append(actions, capture)
+	if cookies != nil {
+		actions = append(actions, cookies.save())
+	}
+	slog.Debug("Loading page in headless browser", "url", request.URL, "options", options)
+	err = chromedp.Run(ctx, actions...)
+
+	response := monitor.response(request)
+	if err != nil {
+		slog.Error("Error loading page in headless browser", "url", request.URL, "err", err)
+		response = failed(response, err)
+	}
+	return response, err
+}
+
+// Sets the response's status for a page that didn't load.
+func failed(response *http.Response, err error) *http.Response {
+	response.StatusCode = http.StatusBadGateway
+	response.Status = fmt.Sprintf("%d %s", response.StatusCode, err.Error())
+	return response
+}
+
+// The steps to run after the page loads. Without options, the page gets
+// DefaultDelay to render.
+func pageActions(o *fetch.HeadlessOptions, monitor *networkMonitor) []chromedp.Action {
+	if o == nil {
+		return []chromedp.Action{chromedp.Sleep(DefaultDelay)}
+	}
+	var actions []chromedp.Action
+	if o.WaitFor != "" {
+		actions = append(actions, waitFor(o.WaitFor))
+	}
+	if o.NetworkIdle {
+		actions = append(actions, monitor.waitIdle())
+	}
+	switch {
+	case o.Delay > 0:
+		actions = append(actions, chromedp.Sleep(o.DelayDuration()))
+	case o.WaitFor == "" && !o.NetworkIdle:
+		actions = append(actions, chromedp.Sleep(DefaultDelay))
+	}
+	settle := chromedp.Sleep(SettleDelay)
+	if o.NetworkIdle {
+		settle = monitor.waitIdle()
+	}
+	if len(o.Click) > 0 {
+		selectors, _ := json.Marshal(o.Click)
+		actions = append(actions, chromedp.Evaluate(fmt.Sprintf(clickScript, selectors), nil), settle)
+	}
+	for range o.Scrolls {
+		actions = append(actions, chromedp.Evaluate(scrollScript, nil), settle)
+	}
+	if o.Script != "" {
+		actions = append(actions, chromedp.Evaluate(o.Script, nil, awaitPromise))
+	}
+	return actions
+}
+
+func awaitPromise(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+	return p.WithAwaitPromise(true)
+}
+
+// Wait for an element matching selector to be visible, for up to DefaultWaitTimeout.
+func waitFor(selector string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		wctx, cancel := context.WithTimeout(ctx, DefaultWaitTimeout)
+		defer cancel()
+		err := chromedp.WaitVisible(selector, chromedp.ByQuery).Do(wctx)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			slog.Debug("Timed out waiting for selector", "selector", selector)
+			return nil
+		}
+		return err
+	})
+}
+
+// Fail an intercepted request if it's blocked, so that it isn't loaded, and let
+// it through otherwise.
+func handlePaused(ctx context.Context, blocker *blocker, ev *cdpfetch.EventRequestPaused) {
+	target := chromedp.FromContext(ctx).Target
+	ctx = cdp.WithExecutor(ctx, target)
+	// the page's own document, in the tab's main frame
+	page := ev.ResourceType == network.ResourceTypeDocument && string(ev.FrameID) == string(target.TargetID)
+	var err error
+	if blocker.blocks(ev.Request.URL, ev.ResourceType, page) {
+		err = cdpfetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient).Do(ctx)
+	} else {
+		err = cdpfetch.ContinueRequest(ev.RequestID).Do(ctx)
+	}
+	if err != nil && ctx.Err() == nil {
+		slog.Debug("Error handling intercepted request", "url", ev.Request.URL, "err", err)
+	}
+}
+
+func extraHeaders(headers http.Header) network.Headers {
+	nh := make(network.Headers, len(headers))
+	for k, v := range headers {
+		nh[k] = strings.Join(v, ", ")
+	}
+	return nh
+}
+
+// Keeps track of a tab's open requests, to tell when its network is idle, and of
+// the response for the page's document and the redirects that led to it.
+type networkMonitor struct {
+	mutex        sync.Mutex
+	requests     map[network.RequestID]bool
+	lastActivity time.Time
+	document     *network.Response
+	redirects    []redirect
+}
+
+// A redirect response for the page's document, and the url it redirected to.
+type redirect struct {
+	response *network.Response
+	location string
+}
+
+func newNetworkMonitor() *networkMonitor {
+	return &networkMonitor{
+		requests:     make(map[network.RequestID]bool),
+		lastActivity: time.Now(),
+	}
+}
+
+func (m *networkMonitor) observe(ev any) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	switch ev := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		m.requests[ev.RequestID] = true
+		// Redirects of the page's document all come before its response
+		if m.document == nil && ev.RedirectResponse != nil && ev.Type == network.ResourceTypeDocument {
+			m.redirects = append(m.redirects, redirect{response: ev.RedirectResponse, location: ev.Request.URL})
+		}
+	case *network.EventLoadingFinished:
+		delete(m.requests, ev.RequestID)
+	case *network.EventLoadingFailed:
+		delete(m.requests, ev.RequestID)
+	case *network.EventResponseReceived:
+		// see https://chromedevtools.github.io/devtools-protocol/tot/Network/#type-Response
+		// The first document response has the status of the page load
+		if m.document == nil && ev.Type == network.ResourceTypeDocument {
+			m.document = ev.Response
+		}
+		return
+	default:
+		return
+	}
+	m.lastActivity = time.Now()
+}
+
+func (m *networkMonitor) idle() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.requests) <= MaxIdleRequests && time.Since(m.lastActivity) >= NetworkIdleTime
+}
+
+// Wait for the network to go idle, for up to DefaultWaitTimeout.
+func (m *networkMonitor) waitIdle() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		timer := time.NewTimer(DefaultWaitTimeout)
+		defer timer.Stop()
+		ticker := time.NewTicker(NetworkIdleTime / 5)
+		defer ticker.Stop()
+		for !m.idle() {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+				slog.Debug("Timed out waiting for network idle")
+				return nil
+			case <-ticker.C:
+			}
+		}
+		return nil
+	})
+}
+
+// An http.Response for request, with the status and headers of the page's document.
+// Like the responses from an http.Client, its request is the last one made, with
+// the redirects that led to it chained from it.
+func (m *networkMonitor) response(request *http.Request) *http.Response {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	response := &http.Response{
+		Header:  http.Header{},
+		Request: m.redirected(request),
+	}
+	if m.document == nil {
+		return response
+	}
+	response.StatusCode = int(m.document.Status)
+	response.Status = fmt.Sprintf("%d %s", response.StatusCode, m.document.StatusText)
+	response.Proto = strings.ToUpper(m.document.Protocol)
+	response.ProtoMajor, response.ProtoMinor = httpVersion(m.document.Protocol)
+	for k, v := range m.document.Headers {
+		switch http.CanonicalHeaderKey(k) {
+		case "Content-Length", "Content-Encoding":
+			// the body is the DOM, not what was sent
+			continue
+		}
+		response.Header.Add(k, fmt.Sprintf("%v", v))
+	}
+	return response
+}
+
+// The request for the last url that request was redirected to, with each request's
+// Response set to the redirect that it followed. Must be called with the mutex held.
+func (m *networkMonitor) redirected(request *http.Request) *http.Request {
+	for _, r := range m.redirects {
+		location, err := nurl.Parse(r.location)
+		if err != nil {
+			break
+		}
+		hop := &http.Response{
+			StatusCode: int(r.response.Status),
+			Status:     fmt.Sprintf("%d %s", r.response.Status, r.response.StatusText),
+			Header:     http.Header{},
+			Request:    request,
+		}
+		request = &http.Request{
+			Method:   http.MethodGet,
+			URL:      location,
+			Header:   request.Header,
+			Response: hop,
+		}
+	}
+	return request
+}
+
+func httpVersion(protocol string) (major, minor int) {
+	protocol = strings.ToUpper(protocol)
+	if major, minor, ok := http.ParseHTTPVersion(protocol); ok {
+		return major, minor
+	}
+	switch {
+	case strings.HasPrefix(protocol, "H2"):
+		return 2, 0
+	case strings.HasPrefix(protocol, "H3"):
+		return 3, 0
+	}
+	return 1, 1
+}
@@ -0,0 +1,125 @@
+package headless
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/efixler/scrape/fetch"
+)
+
+func TestPageActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *fetch.HeadlessOptions
+		expect  int
+	}{
+		{"no options", nil, 1},
+		{"empty options", &fetch.HeadlessOptions{}, 1},
+		{"wait for selector", &fetch.HeadlessOptions{WaitFor: "article"}, 1},
+		{"wait and delay", &fetch.HeadlessOptions{NetworkIdle: true, Delay: 100}, 2},
+		{"click and scroll", &fetch.HeadlessOptions{Click: []string{".more"}, Scrolls: 3}, 9},
+		{"everything", &fetch.HeadlessOptions{
+			WaitFor:     "article",
+			NetworkIdle: true,
+			Delay:       100,
+			Click:       []string{".more"},
+			Scrolls:     1,
+			Script:      "1",
+		}, 8},
+	}
+	for _, test := range tests {
+		if actions := pageActions(test.options, newNetworkMonitor()); len(actions) != test.expect {
+			t.Errorf("[%s] Expected %d actions, got %d", test.name, test.expect, len(actions))
+		}
+	}
+}
+
+func TestNetworkMonitor(t *testing.T) {
+	m := newNetworkMonitor()
+	m.observe(&network.EventRequestWillBeSent{RequestID: "doc"})
+	m.observe(&network.EventResponseReceived{
+		RequestID: "doc",
+		Type:      network.ResourceTypeDocument,
+		Response: &network.Response{
+			Status:     200,
+			StatusText: "OK",
+			Protocol:   "h2",
+			Headers:    network.Headers{"content-type": "text/html", "content-length": "10"},
+		},
+	})
+	m.observe(&network.EventResponseReceived{
+		RequestID: "frame",
+		Type:      network.ResourceTypeDocument,
+		Response:  &network.Response{Status: 404},
+	})
+	m.observe(&network.EventLoadingFinished{RequestID: "doc"})
+	for _, id := range []network.RequestID{"a", "b", "c"} {
+		m.observe(&network.EventRequestWillBeSent{RequestID: id})
+	}
+	m.lastActivity = time.Now().Add(-NetworkIdleTime)
+	if m.idle() {
+		t.Errorf("Expected the network not to be idle with 3 open requests")
+	}
+	m.observe(&network.EventLoadingFailed{RequestID: "a"})
+	if m.idle() {
+		t.Errorf("Expected the network not to be idle right after a request finished")
+	}
+	m.lastActivity = time.Now().Add(-NetworkIdleTime)
+	if !m.idle() {
+		t.Errorf("Expected the network to be idle with %d open requests", MaxIdleRequests)
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	response := m.response(request)
+	if response.StatusCode != 200 || response.Status != "200 OK" {
+		t.Errorf("Expected the first document's status, got %d, %q", response.StatusCode, response.Status)
+	}
+	if response.ProtoMajor != 2 {
+		t.Errorf("Expected HTTP/2, got %d.%d", response.ProtoMajor, response.ProtoMinor)
+	}
+	if response.Header.Get("Content-Type") != "text/html" || response.Header.Get("Content-Length") != "" {
+		t.Errorf("Expected the document's headers without content length, got %v", response.Header)
+	}
+}
+
+func TestNetworkMonitorRedirects(t *testing.T) {
+	m := newNetworkMonitor()
+	m.observe(&network.EventRequestWillBeSent{RequestID: "doc", Type: network.ResourceTypeDocument})
+	m.observe(&network.EventRequestWillBeSent{
+		RequestID:        "doc",
+		Type:             network.ResourceTypeDocument,
+		Request:          &network.Request{URL: "https://www.example.com/a"},
+		RedirectResponse: &network.Response{URL: "https://example.com/a", Status: 301},
+	})
+	m.observe(&network.EventRequestWillBeSent{
+		RequestID:        "doc",
+		Type:             network.ResourceTypeDocument,
+		Request:          &network.Request{URL: "https://www.example.com/b"},
+		RedirectResponse: &network.Response{URL: "https://www.example.com/a", Status: 302},
+	})
+	m.observe(&network.EventResponseReceived{
+		RequestID: "doc",
+		Type:      network.ResourceTypeDocument,
+		Response:  &network.Response{Status: 200},
+	})
+	// redirects in frames, after the page's document
+	m.observe(&network.EventRequestWillBeSent{
+		RequestID:        "frame",
+		Type:             network.ResourceTypeDocument,
+		Request:          &network.Request{URL: "https://ads.example.net/2"},
+		RedirectResponse: &network.Response{URL: "https://ads.example.net/1", Status: 302},
+	})
+
+	request, _ := http.NewRequest(http.MethodGet, "https://example.com/a", nil)
+	redirects, final := fetch.Redirects(m.response(request))
+	if len(redirects) != 2 ||
+		redirects[0].URL != "https://example.com/a" || redirects[0].StatusCode != 301 ||
+		redirects[1].URL != "https://www.example.com/a" || redirects[1].StatusCode != 302 {
+		t.Errorf("Expected the document's redirects, got %+v", redirects)
+	}
+	if final == nil || final.String() != "https://www.example.com/b" {
+		t.Errorf("Expected final url https://www.example.com/b, got %v", final)
+	}
+}
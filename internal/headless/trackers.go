@@ -0,0 +1,74 @@
+package headless
+
+// The built-in list of ad and tracker domains, blocked when a BlockList's Trackers
+// is set. Subdomains are blocked too.
+var trackerDomains = map[string]bool{
+	// ad networks and exchanges
+	"2mdn.net":              true,
+	"adnxs.com":             true,
+	"adform.net":            true,
+	"adroll.com":            true,
+	"adsafeprotected.com":   true,
+	"adservice.google.com":  true,
+	"adsrvr.org":            true,
+	"advertising.com":       true,
+	"amazon-adsystem.com":   true,
+	"bidswitch.net":         true,
+	"casalemedia.com":       true,
+	"criteo.com":            true,
+	"criteo.net":            true,
+	"doubleclick.net":       true,
+	"doubleverify.com":      true,
+	"googleadservices.com":  true,
+	"googlesyndication.com": true,
+	"googletagservices.com": true,
+	"indexww.com":           true,
+	"moatads.com":           true,
+	"openx.net":             true,
+	"outbrain.com":          true,
+	"pubmatic.com":          true,
+	"rubiconproject.com":    true,
+	"sharethrough.com":      true,
+	"smartadserver.com":     true,
+	"taboola.com":           true,
+	"teads.tv":              true,
+	"yieldmo.com":           true,
+	// analytics and tracking
+	"ads-twitter.com":       true,
+	"agkn.com":              true,
+	"analytics.tiktok.com":  true,
+	"analytics.twitter.com": true,
+	"bat.bing.com":          true,
+	"bluekai.com":           true,
+	"chartbeat.com":         true,
+	"chartbeat.net":         true,
+	"clarity.ms":            true,
+	"connect.facebook.net":  true,
+	"crazyegg.com":          true,
+	"demdex.net":            true,
+	"everesttech.net":       true,
+	"exelator.com":          true,
+	"fullstory.com":         true,
+	"google-analytics.com":  true,
+	"googletagmanager.com":  true,
+	"hotjar.com":            true,
+	"krxd.net":              true,
+	"mathtag.com":           true,
+	"mixpanel.com":          true,
+	"mouseflow.com":         true,
+	"newrelic.com":          true,
+	"nr-data.net":           true,
+	"omtrdc.net":            true,
+	"optimizely.com":        true,
+	"parsely.com":           true,
+	"permutive.app":         true,
+	"permutive.com":         true,
+	"px.ads.linkedin.com":   true,
+	"quantserve.com":        true,
+	"rlcdn.com":             true,
+	"scorecardresearch.com": true,
+	"segment.com":           true,
+	"segment.io":            true,
+	"snap.licdn.com":        true,
+	"tapad.com":             true,
+}
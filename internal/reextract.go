@@ -0,0 +1,336 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	nurl "net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+type ReextractStatus string
+
+const (
+	ReextractUpdated     ReextractStatus = "updated"
+	ReextractUnchanged   ReextractStatus = "unchanged"
+	ReextractNotArchived ReextractStatus = "not_archived"
+	ReextractNotFound    ReextractStatus = "not_found"
+	ReextractFailed      ReextractStatus = "error"
+)
+
+// Selects the stored pages to re-extract: the pages for the passed URLs,
+// the pages from a hostname (and its subdomains), or, if All is set,
+// every stored page.
+type ReextractQuery struct {
+	URLs     []*nurl.URL `json:"-"`
+	Hostname string      `json:"hostname,omitempty"`
+	All      bool        `json:"all,omitempty"`
+}
+
+// The outcome of re-extracting a page. Changed lists the fields whose
+// values were changed by re-extraction, using their JSON names.
+type ReextractResult struct {
+	URL     string          `json:"url"`
+	Status  ReextractStatus `json:"status"`
+	Changed []string        `json:"changed,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// Collects re-extraction results, counting them by status.
+type ReextractReport struct {
+	Summary map[ReextractStatus]int `json:"summary"`
+	Results []*ReextractResult      `json:"results"`
+}
+
+func NewReextractReport() *ReextractReport {
+	return &ReextractReport{
+		Summary: make(map[ReextractStatus]int),
+		Results: make([]*ReextractResult, 0),
+	}
+}
+
+// Add a result to the report. Can be passed to Reextractor.Reextract.
+func (r *ReextractReport) Add(result *ReextractResult) {
+	r.Summary[result.Status]++
+	r.Results = append(r.Results, result)
+}
+
+var (
+	ErrNoReextractTarget = errors.New("no urls or hostname provided, and not re-extracting all pages")
+	ErrReextractRunning  = errors.New("a re-extraction job is already running")
+)
+
+// The state of a re-extraction job running in the background. Summary counts
+// the results so far by status, and Error is set if the job stopped early.
+type ReextractJob struct {
+	Query    ReextractQuery          `json:"query"`
+	Running  bool                    `json:"running"`
+	Started  time.Time               `json:"started"`
+	Finished *time.Time              `json:"finished,omitempty"`
+	Summary  map[ReextractStatus]int `json:"summary"`
+	Error    string                  `json:"error,omitempty"`
+}
+
+// The current or last background job, shared by a Reextractor and its clones.
+type reextractJobs struct {
+	mutex sync.Mutex
+	job   *ReextractJob
+}
+
+// Reextractor runs the extractor over the archived raw responses for
+// stored pages, and saves the results, without any network access. Use it
+// to bring stored content up to date after changes to extraction.
+type Reextractor struct {
+	archive    *storage.ArchiveStore
+	store      *storage.URLDataStore
+	saver      URLStore
+	normalizer *resource.URLNormalizer
+	jobs       *reextractJobs
+}
+
+func NewReextractor(archive *storage.ArchiveStore, store *storage.URLDataStore) *Reextractor {
+	return &Reextractor{
+		archive: archive,
+		store:   store,
+		saver:   store,
+		jobs:    &reextractJobs{},
+	}
+}
+
+// WithSaver returns a Reextractor that reads pages from the same store, but
+// saves re-extracted pages through saver instead, e.g. so that a cache in
+// front of the store doesn't keep serving the old versions.
+func (r *Reextractor) WithSaver(saver URLStore) *Reextractor {
+	clone := *r
+	clone.saver = saver
+	return &clone
+}
+
+// WithURLNormalizer returns a Reextractor that normalizes the urls it's asked to
+// re-extract with n, instead of resource.DefaultURLNormalizer, to find them in the
+// same store.
+func (r *Reextractor) WithURLNormalizer(n *resource.URLNormalizer) *Reextractor {
+	clone := *r
+	clone.normalizer = n
+	return &clone
+}
+
+// Re-extract the pages selected by the query, passing the result for each
+// page to report as it's processed. Pages that couldn't be re-extracted
+// are reported with a status explaining why; an error is only returned when
+// the pages to re-extract can't be listed.
+func (r *Reextractor) Reextract(q ReextractQuery, report func(*ReextractResult)) error {
+	return r.reextractAll(context.Background(), q, report)
+}
+
+// Start re-extracting the pages selected by the query in the background, and
+// return the state of the job. Only one job runs at a time, so this returns
+// ErrReextractRunning while another job is running. The job stops early when
+// ctx is done.
+func (r *Reextractor) Start(ctx context.Context, q ReextractQuery) (*ReextractJob, error) {
+	if len(q.URLs) == 0 && q.Hostname == "" && !q.All {
+		return nil, ErrNoReextractTarget
+	}
+	r.jobs.mutex.Lock()
+	defer r.jobs.mutex.Unlock()
+	if r.jobs.job != nil && r.jobs.job.Running {
+		return nil, ErrReextractRunning
+	}
+	job := &ReextractJob{
+		Query:   q,
+		Running: true,
+		Started: time.Now().UTC(),
+		Summary: make(map[ReextractStatus]int),
+	}
+	r.jobs.job = job
+	go r.run(ctx, job)
+	return job.copy(), nil
+}
+
+// The state of the running background job, or of the last one if none is
+// running. Returns nil if no job has been started.
+func (r *Reextractor) Job() *ReextractJob {
+	r.jobs.mutex.Lock()
+	defer r.jobs.mutex.Unlock()
+	if r.jobs.job == nil {
+		return nil
+	}
+	return r.jobs.job.copy()
+}
+
+func (r *Reextractor) run(ctx context.Context, job *ReextractJob) {
+	err := r.reextractAll(ctx, job.Query, func(result *ReextractResult) {
+		r.jobs.mutex.Lock()
+		defer r.jobs.mutex.Unlock()
+		job.Summary[result.Status]++
+	})
+	if err != nil {
+		slog.Error("Re-extraction job stopped", "query", job.Query, "err", err)
+	}
+	r.jobs.mutex.Lock()
+	defer r.jobs.mutex.Unlock()
+	finished := time.Now().UTC()
+	job.Running = false
+	job.Finished = &finished
+	if err != nil {
+		job.Error = err.Error()
+	}
+}
+
+func (j *ReextractJob) copy() *ReextractJob {
+	c := *j
+	c.Summary = maps.Clone(j.Summary)
+	return &c
+}
+
+func (r *Reextractor) reextractAll(ctx context.Context, q ReextractQuery, report func(*ReextractResult)) error {
+	switch {
+	case len(q.URLs) > 0:
+		for _, url := range q.URLs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			url = r.normalizer.Normalize(url)
+			page, err := r.store.Fetch(url)
+			if err != nil {
+				result := &ReextractResult{URL: url.String(), Status: ReextractNotFound}
+				if !errors.Is(err, storage.ErrResourceNotFound) {
+					result.Status = ReextractFailed
+					result.Error = err.Error()
+				}
+				report(result)
+				continue
+			}
+			report(r.reextract(page))
+		}
+		return nil
+	case q.Hostname != "" || q.All:
+		query := storage.ListQuery{Hostname: q.Hostname, Limit: storage.MaxListLimit}
+		return r.store.Walk(query, func(page *resource.WebPage) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			report(r.reextract(page))
+			return nil
+		})
+	default:
+		return ErrNoReextractTarget
+	}
+}
+
+// Re-extract a stored page from its archived response, keeping its fetch
+// time, fetch method, TTL and redirects, and save the result if anything changed.
+func (r *Reextractor) reextract(stored *resource.WebPage) *ReextractResult {
+	result := &ReextractResult{URL: stored.CanonicalURL.String()}
+	archived, err := r.archive.Load(stored.RequestedURL)
+	if err != nil {
+		result.Status = ReextractNotArchived
+		if !errors.Is(err, storage.ErrResourceNotFound) {
+			result.Status = ReextractFailed
+			result.Error = err.Error()
+		}
+		return result
+	}
+	resp := &http.Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Header:     archived.Header,
+		Body:       io.NopCloser(bytes.NewReader(archived.Body)),
+	}
+	// the page is extracted as the url it was redirected to, as when it was fetched
+	if final, err := nurl.Parse(stored.FinalURL); err == nil && stored.FinalURL != "" {
+		resp.Request = &http.Request{Method: http.MethodGet, URL: final}
+	}
+	page, err := ExtractResponse(stored.RequestedURL, resp, stored.FetchMethod)
+	if err != nil {
+		result.Status = ReextractFailed
+		result.Error = err.Error()
+		return result
+	}
+	page.FetchTime = stored.FetchTime
+	page.TTL = stored.TTL
+	page.Redirects = stored.Redirects
+	page.VariantURL = stored.VariantURL
+	if result.Changed = changedFields(stored, page); len(result.Changed) == 0 {
+		result.Status = ReextractUnchanged
+		return result
+	}
+	if _, err = r.saver.Save(page); err != nil {
+		result.Status = ReextractFailed
+		result.Error = err.Error()
+		return result
+	}
+	// copies cached under the old canonical url aren't found by saving the page
+	if inv, ok := r.saver.(Invalidator); ok && slices.Contains(result.Changed, "url") {
+		inv.Invalidate(stored.CanonicalURL)
+	}
+	result.Status = ReextractUpdated
+	slog.Debug("Re-extracted page", "url", result.URL, "changed", result.Changed)
+	return result
+}
+
+// List the fields that differ between the stored page and the re-extracted
+// page, using their JSON names.
+func changedFields(stored, page *resource.WebPage) []string {
+	changed := make([]string, 0)
+	urlString := func(u *nurl.URL) string {
+		if u == nil {
+			return ""
+		}
+		return u.String()
+	}
+	for _, f := range []struct {
+		name     string
+		old, new string
+	}{
+		{"url", urlString(stored.CanonicalURL), urlString(page.CanonicalURL)},
+		{"hostname", stored.Hostname, page.Hostname},
+		{"title", stored.Title, page.Title},
+		{"description", stored.Description, page.Description},
+		{"sitename", stored.Sitename, page.Sitename},
+		{"language", stored.Language, page.Language},
+		{"image", stored.Image, page.Image},
+		{"page_type", stored.PageType, page.PageType},
+		{"license", stored.License, page.License},
+	} {
+		if f.old != f.new {
+			changed = append(changed, f.name)
+		}
+	}
+	for _, f := range []struct {
+		name     string
+		old, new []string
+	}{
+		{"authors", stored.Authors, page.Authors},
+		{"categories", stored.Categories, page.Categories},
+		{"tags", stored.Tags, page.Tags},
+	} {
+		// nil and empty are the same when stored
+		if (len(f.old) > 0 || len(f.new) > 0) && !slices.Equal(f.old, f.new) {
+			changed = append(changed, f.name)
+		}
+	}
+	if !sameTime(stored.Date, page.Date) {
+		changed = append(changed, "date")
+	}
+	if stored.ContentText != page.ContentText {
+		changed = append(changed, "content_text")
+	}
+	return changed
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
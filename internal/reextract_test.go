@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"slices"
+	"testing"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/sqlite"
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/fetch/trafilatura"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+const reextractHTML = `<html><head><title>Fresh Title</title></head>
+<body><article><p>Fresh content for re-extraction.</p></article></body></html>`
+
+func TestReextract(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(reextractHTML))
+	}))
+	defer ts.Close()
+	dbh := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := dbh.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	store := storage.NewURLDataStore(dbh)
+	archive := storage.NewArchiveStore(dbh, 0)
+	tf, err := trafilatura.New(
+		fetch.MustClient(fetch.WithHTTPClient(ts.Client())),
+		trafilatura.WithArchiver(archive),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fetch and archive one page, then store a stale version of it, as if it
+	// had been extracted with different rules. Store another page that has
+	// no archived response.
+	archivedURL, _ := nurl.Parse(ts.URL + "/archived")
+	page, err := tf.Fetch(archivedURL)
+	if err != nil {
+		t.Fatalf("Error fetching %s: %v", archivedURL, err)
+	}
+	stale := *page
+	stale.Title = "Stale Title"
+	stale.ContentText = "Stale content"
+	if _, err = store.Save(&stale); err != nil {
+		t.Fatalf("Error saving page: %v", err)
+	}
+	unarchivedURL, _ := nurl.Parse(ts.URL + "/unarchived")
+	unarchived := *page
+	unarchived.RequestedURL = unarchivedURL
+	unarchived.CanonicalURL = unarchivedURL
+	unarchived.FinalURL = unarchivedURL.String()
+	if _, err = store.Save(&unarchived); err != nil {
+		t.Fatalf("Error saving page: %v", err)
+	}
+	missingURL, _ := nurl.Parse(ts.URL + "/missing")
+
+	// no network access from here on
+	ts.Close()
+	r := NewReextractor(archive, store)
+	collect := func(q ReextractQuery) map[string]*ReextractResult {
+		results := make(map[string]*ReextractResult)
+		if err := r.Reextract(q, func(rr *ReextractResult) { results[rr.URL] = rr }); err != nil {
+			t.Fatalf("Error re-extracting: %v", err)
+		}
+		return results
+	}
+
+	results := collect(ReextractQuery{URLs: []*nurl.URL{archivedURL, unarchivedURL, missingURL}})
+	expected := map[string]ReextractStatus{
+		archivedURL.String():   ReextractUpdated,
+		unarchivedURL.String(): ReextractNotArchived,
+		missingURL.String():    ReextractNotFound,
+	}
+	for url, status := range expected {
+		if result, ok := results[url]; !ok || result.Status != status {
+			t.Errorf("Expected %s for %s, got %+v", status, url, result)
+		}
+	}
+	if changed := results[archivedURL.String()].Changed; !slices.Equal(changed, []string{"title", "content_text"}) {
+		t.Errorf("Expected title and content_text to change, got %v", changed)
+	}
+	updated, err := store.Fetch(archivedURL)
+	if err != nil {
+		t.Fatalf("Error fetching re-extracted page: %v", err)
+	}
+	if updated.Title != page.Title || updated.ContentText != page.ContentText {
+		t.Errorf("Expected re-extracted page to be saved, got %q / %q", updated.Title, updated.ContentText)
+	}
+	if !updated.FetchTime.Equal(*page.FetchTime) || updated.FetchMethod != resource.DefaultClient {
+		t.Errorf("Expected fetch time and method to be kept, got %v, %v", updated.FetchTime, updated.FetchMethod)
+	}
+
+	// everything's up to date now
+	results = collect(ReextractQuery{All: true})
+	if len(results) != 2 {
+		t.Errorf("Expected 2 stored pages to be re-extracted, got %d", len(results))
+	}
+	if result := results[archivedURL.String()]; result == nil || result.Status != ReextractUnchanged {
+		t.Errorf("Expected %s to be unchanged, got %+v", archivedURL, result)
+	}
+
+	if err := r.Reextract(ReextractQuery{}, func(*ReextractResult) {}); !errors.Is(err, ErrNoReextractTarget) {
+		t.Errorf("Expected ErrNoReextractTarget, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	nurl "net/url"
+	"time"
+
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+// The default size limit for renders: 10MB.
+const DefaultMaxRenderSize = 10 * 1024 * 1024
+
+// StorageBackedRenderer returns stored screenshots and PDFs of urls, and renders
+// them with its Renderer when they aren't stored. Renders larger than the size
+// limit aren't returned or stored.
+type StorageBackedRenderer struct {
+	Renderer fetch.Renderer
+	Storage  *storage.RenderStore
+	// Normalizes urls before they're looked up or rendered. When nil, urls are
+	// normalized with resource.DefaultURLNormalizer.
+	Normalizer *resource.URLNormalizer
+	maxSize    int
+}
+
+// If maxSize isn't positive, DefaultMaxRenderSize is used.
+func NewStorageBackedRenderer(renderer fetch.Renderer, store *storage.RenderStore, maxSize int) *StorageBackedRenderer {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxRenderSize
+	}
+	return &StorageBackedRenderer{
+		Renderer: renderer,
+		Storage:  store,
+		maxSize:  maxSize,
+	}
+}
+
+// Return the stored render of url in format, or render it if it isn't stored, or if
+// refresh is set. Options apply to new renders. Returns fetch.ErrRenderTooLarge if
+// the render is over the size limit.
+func (r *StorageBackedRenderer) Render(
+	url *nurl.URL,
+	format fetch.RenderFormat,
+	options *fetch.HeadlessOptions,
+	refresh bool,
+) (*storage.Render, error) {
+	url = r.Normalizer.Normalize(url)
+	if !refresh {
+		render, err := r.Storage.Load(url, format)
+		if err == nil {
+			return render, nil
+		} else if !errors.Is(err, storage.ErrResourceNotFound) {
+			return nil, err
+		}
+	}
+	body, err := r.Renderer.Render(url, format, options)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > r.maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", fetch.ErrRenderTooLarge, len(body), r.maxSize)
+	}
+	render, err := r.Storage.Save(url, format, body)
+	if err != nil {
+		// The render is still good, even if it couldn't be stored
+		slog.Error("Error storing render", "url", url, "format", format, "error", err)
+		return &storage.Render{URL: url, Format: format, RenderTime: time.Now().UTC(), Body: body}, nil
+	}
+	return render, nil
+}
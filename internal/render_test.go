@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	nurl "net/url"
+	"testing"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/sqlite"
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/internal/storage"
+)
+
+type stubRenderer struct {
+	body  []byte
+	calls int
+}
+
+func (s *stubRenderer) Render(url *nurl.URL, format fetch.RenderFormat, options *fetch.HeadlessOptions) ([]byte, error) {
+	s.calls++
+	return s.body, nil
+}
+
+func TestStorageBackedRenderer(t *testing.T) {
+	dbh := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := dbh.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stub := &stubRenderer{body: []byte("\x89PNG")}
+	r := NewStorageBackedRenderer(stub, storage.NewRenderStore(dbh, 0), 10)
+	url, _ := nurl.Parse("https://example.com/page?utm_source=feed")
+	for i := 0; i < 2; i++ {
+		render, err := r.Render(url, fetch.Screenshot, nil, false)
+		if err != nil {
+			t.Fatalf("Error rendering: %v", err)
+		}
+		if string(render.Body) != "\x89PNG" {
+			t.Errorf("Expected the render, got %q", render.Body)
+		}
+	}
+	if stub.calls != 1 {
+		t.Errorf("Expected the second render to be loaded from storage, got %d renders", stub.calls)
+	}
+	if _, err := r.Render(url, fetch.PDF, nil, false); err != nil || stub.calls != 2 {
+		t.Errorf("Expected a new render for another format, got %v, %d renders", err, stub.calls)
+	}
+	stub.body = []byte("\x89PNG, but larger than the limit")
+	if _, err := r.Render(url, fetch.Screenshot, nil, true); !errors.Is(err, fetch.ErrRenderTooLarge) {
+		t.Errorf("Expected ErrRenderTooLarge, got %v", err)
+	}
+	if render, err := r.Render(url, fetch.Screenshot, nil, false); err != nil || string(render.Body) != "\x89PNG" {
+		t.Errorf("Expected a render that's too large not to be stored, got %v", err)
+	}
+}
@@ -25,12 +25,25 @@ type URLStore interface {
 	Delete(*nurl.URL) (bool, error)
 }
 
+// Implemented by stores that cache pages, so that cached copies of a page can
+// be removed when it changes in a way that saving it doesn't reveal, like
+// when its canonical url changes.
+type Invalidator interface {
+	Invalidate(*nurl.URL)
+}
+
 // StorageBackedFetcher returns URLs from a storage backend, and fetches them if they are not found.
+// Failed fetches aren't stored, but are kept in an ErrorCache, so that urls that are known to
+// fail aren't fetched on every request.
 type StorageBackedFetcher struct {
 	Fetcher fetch.URLFetcher
 	Storage URLStore
-	saving  *sync.WaitGroup
-	closed  bool
+	// Normalizes urls before they're looked up or fetched. When nil, urls are
+	// normalized with resource.DefaultURLNormalizer.
+	Normalizer *resource.URLNormalizer
+	errorCache *ErrorCache
+	saving     *sync.WaitGroup
+	closed     bool
 }
 
 // NewStorageBackedFetcher returns a new StorageBackedFetcher that uses the given fetcher and storage.
@@ -40,9 +53,10 @@ func NewStorageBackedFetcher(
 	storage URLStore,
 ) *StorageBackedFetcher {
 	s := &StorageBackedFetcher{
-		Fetcher: fetcher,
-		Storage: storage,
-		saving:  new(sync.WaitGroup),
+		Fetcher:    fetcher,
+		Storage:    storage,
+		errorCache: NewErrorCache(DefaultErrorCacheSize),
+		saving:     new(sync.WaitGroup),
 	}
 	s.Storage.Database().AddCloseListener(func() {
 		s.Wait()
@@ -58,35 +72,73 @@ func (f *StorageBackedFetcher) WithAlternateURLFetcher(ctx context.Context, uf f
 		return nil, errors.New("StorageBackedFetcher is closed")
 	}
 	clone := &StorageBackedFetcher{
-		Fetcher: uf,
-		Storage: f.Storage,
-		saving:  f.saving,
+		Fetcher:    uf,
+		Storage:    f.Storage,
+		Normalizer: f.Normalizer,
+		errorCache: NewErrorCache(DefaultErrorCacheSize),
+		saving:     f.saving,
 	}
 	// Don't patch in a function to close the context here, because we only really need this to close the DB, which is already
 	// hooked by the parent. We also share the parent's WaitGroup for async saves for this reason.
+	// The clone gets its own error cache, since a different client can succeed where the parent's failed.
 	return clone, nil
 }
 
 func (f *StorageBackedFetcher) Fetch(url *nurl.URL) (*resource.WebPage, error) {
+	return f.fetch(url, false, nil)
+}
+
+// Refresh fetches url again, even if it's stored or its last fetch failed, and stores
+// the result.
+func (f *StorageBackedFetcher) Refresh(url *nurl.URL) (*resource.WebPage, error) {
+	return f.fetch(url, true, nil)
+}
+
+// Same as Fetch, passing options along to the fetcher when url isn't stored.
+func (f *StorageBackedFetcher) FetchHeadless(url *nurl.URL, options *fetch.HeadlessOptions) (*resource.WebPage, error) {
+	return f.fetch(url, false, options)
+}
+
+// Same as Refresh, passing options along to the fetcher.
+func (f *StorageBackedFetcher) RefreshHeadless(url *nurl.URL, options *fetch.HeadlessOptions) (*resource.WebPage, error) {
+	return f.fetch(url, true, options)
+}
+
+func (f *StorageBackedFetcher) fetch(url *nurl.URL, refresh bool, options *fetch.HeadlessOptions) (*resource.WebPage, error) {
 	// Treat this as the entry point for the url and apply cleaning here.
 	originalURL := url.String()
-	url = resource.CleanURL(url)
-	// Now fetch the item from storage
-	res, err := f.Storage.Fetch(url)
-	if err != nil && !errors.Is(err, storage.ErrResourceNotFound) {
-		return nil, err
+	url = f.Normalizer.Normalize(url)
+	var (
+		res *resource.WebPage
+		err error
+	)
+	if !refresh {
+		// Now fetch the item from storage
+		res, err = f.Storage.Fetch(url)
+		if err != nil && !errors.Is(err, storage.ErrResourceNotFound) {
+			return nil, err
+		}
+		if res == nil {
+			if page, err, ok := f.errorCache.Get(url); ok {
+				return erroredPage(page, originalURL, err), err
+			}
+		}
 	}
 	defer func() { res.OriginalURL = originalURL }()
 	if res == nil {
-		res, err = f.Fetcher.Fetch(url)
+		res, err = fetch.FetchWithOptions(f.Fetcher, url, options)
 		// never store a resource with an error, but do return a partial resource
 		if err != nil {
+			f.errorCache.Add(url, res, err)
 			return res, err
 		}
+		f.errorCache.Remove(url)
 		f.saving.Add(1)
+		// the returned page is changed by the caller while it's being saved
+		page := res.Clone()
 		go func() {
 			defer f.saving.Done()
-			key, err := f.Storage.Save(res)
+			key, err := f.Storage.Save(page)
 			if err != nil {
 				slog.Error("Error storing %s: %s\n", "url", url, "key", key, "error", err)
 			}
@@ -112,13 +164,13 @@ func (f StorageBackedFetcher) Batch(urls []string, options fetch.BatchOptions) <
 	// start the go func to fetch the urls if they aren't stored
 	go func() {
 		defer wg.Done()
-		f.fetchUnstored(unstoredChan, rchan)
+		f.fetchUnstored(unstoredChan, options.Headless, rchan)
 	}()
 
 	// start the go func that loads from the DB
 	go func() {
 		defer wg.Done()
-		f.loadBatch(urls, rchan, unstoredChan)
+		f.loadBatch(urls, options, rchan, unstoredChan)
 	}()
 	return rchan
 }
@@ -141,14 +193,21 @@ type fetchMsg struct {
 }
 
 // TODO: Apply rate limiting here
-func (f *StorageBackedFetcher) fetchUnstored(inchan <-chan fetchMsg, outchan chan<- *resource.WebPage) {
+func (f *StorageBackedFetcher) fetchUnstored(
+	inchan <-chan fetchMsg,
+	options *fetch.HeadlessOptions,
+	outchan chan<- *resource.WebPage,
+) {
 	for msg := range inchan {
-		res, err := f.Fetcher.Fetch(msg.cleanedURL)
+		res, err := fetch.FetchWithOptions(f.Fetcher, msg.cleanedURL, options)
 		rcopy := *res
 		rcopy.OriginalURL = msg.originalURL
 		rcopy.Error = err
 		outchan <- &rcopy
-		if err == nil {
+		if err != nil {
+			f.errorCache.Add(msg.cleanedURL, res, err)
+		} else {
+			f.errorCache.Remove(msg.cleanedURL)
 			go func() {
 				if _, err := f.Storage.Save(res); err != nil {
 					slog.Error("Error storing %s: %s\n", "url", res.RequestedURL, "error", err)
@@ -160,6 +219,7 @@ func (f *StorageBackedFetcher) fetchUnstored(inchan <-chan fetchMsg, outchan cha
 
 func (f *StorageBackedFetcher) loadBatch(
 	urls []string,
+	options fetch.BatchOptions,
 	foundChan chan<- *resource.WebPage,
 	notFoundChan chan<- fetchMsg) {
 	defer close(notFoundChan)
@@ -175,12 +235,20 @@ func (f *StorageBackedFetcher) loadBatch(
 			}
 			continue
 		}
-		url := resource.CleanURL(parsedURL)
+		url := f.Normalizer.Normalize(parsedURL)
+		if options.Refresh {
+			notFoundChan <- fetchMsg{cleanedURL: url, originalURL: originalURL}
+			continue
+		}
 		if res, err := f.Storage.Fetch(url); err == nil {
 			res.OriginalURL = originalURL
 			foundChan <- res
 		} else if errors.Is(err, storage.ErrResourceNotFound) {
-			notFoundChan <- fetchMsg{cleanedURL: url, originalURL: originalURL}
+			if page, err, ok := f.errorCache.Get(url); ok {
+				foundChan <- erroredPage(page, originalURL, err)
+			} else {
+				notFoundChan <- fetchMsg{cleanedURL: url, originalURL: originalURL}
+			}
 		} else { // this is really an error
 			slog.Error("Error fetching url in Batch", "url", url, "error", err)
 		}
@@ -188,5 +256,16 @@ func (f *StorageBackedFetcher) loadBatch(
 }
 
 func (f StorageBackedFetcher) Delete(url *nurl.URL) (bool, error) {
+	f.errorCache.Remove(f.Normalizer.Normalize(url))
 	return f.Storage.Delete(url)
 }
+
+// Prepare a page from the error cache to be returned for originalURL.
+func erroredPage(page *resource.WebPage, originalURL string, err error) *resource.WebPage {
+	if page == nil {
+		page = &resource.WebPage{}
+	}
+	page.OriginalURL = originalURL
+	page.Error = err
+	return page
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	nurl "net/url"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -192,7 +194,7 @@ func TestFetchUnstored(t *testing.T) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		fetcher.fetchUnstored(fetchChan, pageChan)
+		fetcher.fetchUnstored(fetchChan, nil, pageChan)
 	}()
 	wg.Wait()
 	close(pageChan)
@@ -224,7 +226,7 @@ func TestFetchUnstored(t *testing.T) {
 	// we can use loadBatch here to verify that fetchUnstored worked
 	go func() {
 		defer wg.Done()
-		fetcher.loadBatch(urls, pageChan, fetchChan)
+		fetcher.loadBatch(urls, fetch.BatchOptions{}, pageChan, fetchChan)
 	}()
 	wg.Wait()
 	close(pageChan)
@@ -277,3 +279,79 @@ func htmlTemplate() (*template.Template, error) {
 	}
 	return tmpl, nil
 }
+
+func TestFetchErrorsAreCached(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "Not found", http.StatusNotFound)
+	}))
+	defer ts.Close()
+	tf := trafilatura.MustNew(fetch.MustClient(fetch.WithHTTPClient(ts.Client())))
+	dbh := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := dbh.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	fetcher := NewStorageBackedFetcher(tf, storage.NewURLDataStore(dbh))
+	url := ts.URL + "/missing"
+	netURL, _ := nurl.Parse(url)
+
+	for i := 0; i < 2; i++ {
+		page, err := fetcher.Fetch(netURL)
+		var httpErr fetch.HttpError
+		if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+			t.Errorf("[%d] Expected 404 error, got %v", i, err)
+		}
+		if page.StatusCode != http.StatusNotFound || page.OriginalURL != url {
+			t.Errorf("[%d] Expected 404 page for %s, got %d for %s", i, url, page.StatusCode, page.OriginalURL)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected 1 request, got %d", requests.Load())
+	}
+	for page := range fetcher.Batch([]string{url}, fetch.BatchOptions{}) {
+		if page.Error == nil || page.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected cached 404 in batch, got %d, %v", page.StatusCode, page.Error)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected batch to use the cached error, got %d requests", requests.Load())
+	}
+
+	if _, err := fetcher.Refresh(netURL); err == nil {
+		t.Errorf("Expected error on refresh")
+	}
+	if requests.Load() != 2 {
+		t.Errorf("Expected refresh to fetch again, got %d requests", requests.Load())
+	}
+	for range fetcher.Batch([]string{url}, fetch.BatchOptions{Refresh: true}) {
+	}
+	if requests.Load() != 3 {
+		t.Errorf("Expected refreshed batch to fetch again, got %d requests", requests.Load())
+	}
+}
+
+func TestFetchWithNormalizer(t *testing.T) {
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		http.Error(w, "Not found", http.StatusNotFound)
+	}))
+	defer ts.Close()
+	tf := trafilatura.MustNew(fetch.MustClient(fetch.WithHTTPClient(ts.Client())))
+	dbh := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := dbh.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	fetcher := NewStorageBackedFetcher(tf, storage.NewURLDataStore(dbh))
+	fetcher.Normalizer = &resource.URLNormalizer{TrackingParams: []string{"ref"}}
+	netURL, _ := nurl.Parse(ts.URL + "/page?ref=home&utm_source=feed")
+	fetcher.Fetch(netURL)
+	if len(queries) != 1 || queries[0] != "utm_source=feed" {
+		t.Errorf("Expected the url to be normalized with the fetcher's rules, got queries %v", queries)
+	}
+}
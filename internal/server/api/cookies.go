@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/efixler/scrape/internal/server/middleware"
+	"github.com/efixler/scrape/internal/settings"
+)
+
+// Uploads can hold up to settings.MaxCookiesPerDomain cookies of up to about 4KB
+const MaxCookiesUploadBytes = 512 * 1024
+
+// Defines the output for a domain's cookies. Cookie values aren't included,
+// since they're often credentials.
+type DomainCookiesResponse struct {
+	Domain  string            `json:"domain"`
+	Cookies []settings.Cookie `json:"cookies"`
+}
+
+func (ss *Server) DomainCookies() http.HandlerFunc {
+	ms := ss.withAuthIfEnabled(middleware.MaxBytes(4096), extractDomainFromPath(dsKey{}))
+	return middleware.Chain(ss.getDomainCookies, ms...)
+}
+
+func (ss *Server) getDomainCookies(w http.ResponseWriter, r *http.Request) {
+	req, _ := r.Context().Value(dsKey{}).(*SingleDomainRequest)
+	cookies, err := ss.cookieStorage.FetchCookies(req.Domain)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	for i := range cookies {
+		cookies[i].Value = ""
+	}
+	middleware.WriteJSONOutput(w, &DomainCookiesResponse{
+		Domain:  req.Domain,
+		Cookies: cookies,
+	}, req.PrettyPrint, http.StatusOK)
+}
+
+// Replaces the domain's cookies with a JSON array of cookies.
+func (ss *Server) WriteDomainCookies() http.HandlerFunc {
+	ms := ss.withAuthIfEnabled(
+		middleware.MaxBytes(MaxCookiesUploadBytes),
+		extractDomainFromPath(dsKey{}),
+		middleware.DecodeJSONBody[[]settings.Cookie](payloadKey{}),
+	)
+	return middleware.Chain(ss.putDomainCookies, ms...)
+}
+
+func (ss *Server) putDomainCookies(w http.ResponseWriter, r *http.Request) {
+	req, _ := r.Context().Value(dsKey{}).(*SingleDomainRequest)
+	cookies, _ := r.Context().Value(payloadKey{}).(*[]settings.Cookie)
+	if err := ss.cookieStorage.ReplaceCookies(req.Domain, *cookies); err != nil {
+		if errors.Is(err, settings.ErrInvalidCookie) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+	ss.getDomainCookies(w, r)
+}
+
+func (ss *Server) DeleteDomainCookies() http.HandlerFunc {
+	ms := ss.withAuthIfEnabled(middleware.MaxBytes(4096), extractDomainFromPath(dsKey{}))
+	return middleware.Chain(ss.deleteDomainCookies, ms...)
+}
+
+func (ss *Server) deleteDomainCookies(w http.ResponseWriter, r *http.Request) {
+	req, _ := r.Context().Value(dsKey{}).(*SingleDomainRequest)
+	if _, err := ss.cookieStorage.ClearCookies(req.Domain); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
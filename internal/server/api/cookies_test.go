@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/sqlite"
+	"github.com/efixler/scrape/internal/settings"
+)
+
+func TestDomainCookies(t *testing.T) {
+	db := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	if err := db.Open(context.Background()); err != nil {
+		t.Fatalf("error opening db %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	ss := &Server{
+		ctx:           context.Background(),
+		cookieStorage: settings.NewCookieStorage(db),
+	}
+	tests := []struct {
+		name         string
+		method       string
+		payload      string
+		expectStatus int
+		expectNames  []string
+	}{
+		{"no cookies", "GET", "", 200, []string{}},
+		{"upload", "PUT", `[{"name":"session","value":"abc","secure":true},{"name":"consent","value":"yes"}]`, 200, []string{"consent", "session"}},
+		{"fetch", "GET", "", 200, []string{"consent", "session"}},
+		{"invalid cookie", "PUT", `[{"name":"bad name","value":"x"}]`, 400, nil},
+		{"unknown field", "PUT", `[{"name":"a","value":"b","samesite":"lax"}]`, 400, nil},
+		{"not a list", "PUT", `{"name":"a","value":"b"}`, 400, nil},
+		{"unchanged after errors", "GET", "", 200, []string{"consent", "session"}},
+		{"clear", "DELETE", "", 204, nil},
+		{"cleared", "GET", "", 200, []string{}},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/settings/domain/{DOMAIN}/cookies", strings.NewReader(test.payload))
+		r.SetPathValue("DOMAIN", "Example.com")
+		w := httptest.NewRecorder()
+		switch test.method {
+		case "GET":
+			ss.DomainCookies()(w, r)
+		case "PUT":
+			ss.WriteDomainCookies()(w, r)
+		case "DELETE":
+			ss.DeleteDomainCookies()(w, r)
+		}
+		if w.Code != test.expectStatus {
+			t.Errorf("[%s] Expected status %d, got %d: %s", test.name, test.expectStatus, w.Code, w.Body.String())
+			continue
+		}
+		if test.expectNames == nil {
+			continue
+		}
+		var response DomainCookiesResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Errorf("[%s] Error decoding response: %v", test.name, err)
+			continue
+		}
+		if response.Domain != "example.com" || len(response.Cookies) != len(test.expectNames) {
+			t.Errorf("[%s] Expected cookies %v for example.com, got %+v", test.name, test.expectNames, response)
+			continue
+		}
+		for i, c := range response.Cookies {
+			if c.Name != test.expectNames[i] || c.Domain != "example.com" {
+				t.Errorf("[%s] Expected cookie %s for example.com, got %+v", test.name, test.expectNames[i], c)
+			}
+			if c.Value != "" {
+				t.Errorf("[%s] Expected cookie %s's value to be redacted, got %q", test.name, c.Name, c.Value)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	nurl "net/url"
+	"strconv"
+	"time"
+
+	"github.com/efixler/scrape/internal/diff"
+	"github.com/efixler/scrape/internal/server/middleware"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+// Defines the output for a request for the versions of a url.
+type HistoryResponse struct {
+	URL      string            `json:"url"`
+	Versions []storage.Version `json:"versions"`
+}
+
+// Defines valid inputs for a diff request. From and To are versions
+// from a HistoryResponse. When To is zero the latest version is used, and when
+// From is zero the version before To is used.
+type DiffRequest struct {
+	URL         *nurl.URL
+	From        int64
+	To          int64
+	PrettyPrint bool
+}
+
+// Defines the output for a diff request. Diff is a unified diff of the
+// content text of the two versions, and is empty if the text is unchanged.
+type DiffResponse struct {
+	URL  string          `json:"url"`
+	From storage.Version `json:"from"`
+	To   storage.Version `json:"to"`
+	Diff string          `json:"diff"`
+}
+
+func WithHistoryIf(vs storage.VersionStore) option {
+	return func(ss *Server) error {
+		if vs == nil {
+			return nil
+		}
+		ss.versions = vs
+		return nil
+	}
+}
+
+func (ss *Server) History() http.HandlerFunc {
+	ms := ss.withAuthIfEnabled(middleware.MaxBytes(4096), parseSinglePayload())
+	return middleware.Chain(ss.history, ms...)
+}
+
+func (ss *Server) history(w http.ResponseWriter, r *http.Request) {
+	if ss.versions == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	req, ok := r.Context().Value(payloadKey{}).(*SingleURLRequest)
+	if !ok {
+		http.Error(w, "Can't process history request, no input data", http.StatusInternalServerError)
+		return
+	}
+	versions, err := ss.versions.Versions(ss.normalizer.Normalize(req.URL))
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	middleware.WriteJSONOutput(w, &HistoryResponse{
+		URL:      req.URL.String(),
+		Versions: versions,
+	}, req.PrettyPrint, http.StatusOK)
+}
+
+func (ss *Server) HistoryDiff() http.HandlerFunc {
+	ms := ss.withAuthIfEnabled(middleware.MaxBytes(4096), extractDiffQuery(payloadKey{}))
+	return middleware.Chain(ss.historyDiff, ms...)
+}
+
+func (ss *Server) historyDiff(w http.ResponseWriter, r *http.Request) {
+	if ss.versions == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	req, _ := r.Context().Value(payloadKey{}).(*DiffRequest)
+	url := ss.normalizer.Normalize(req.URL)
+	from, to := req.From, req.To
+	if from == 0 || to == 0 {
+		versions, err := ss.versions.Versions(url)
+		if err != nil {
+			writeHistoryError(w, err)
+			return
+		}
+		if from, to, err = defaultVersions(versions, from, to); err != nil {
+			writeHistoryError(w, err)
+			return
+		}
+	}
+	fromPage, err := ss.versions.Version(url, from)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	toPage, err := ss.versions.Version(url, to)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	middleware.WriteJSONOutput(w, &DiffResponse{
+		URL:  req.URL.String(),
+		From: versionOf(fromPage),
+		To:   versionOf(toPage),
+		Diff: diff.Unified(
+			versionLabel(fromPage),
+			versionLabel(toPage),
+			fromPage.ContentText,
+			toPage.ContentText,
+			diff.DefaultContext,
+		),
+	}, req.PrettyPrint, http.StatusOK)
+}
+
+var errNoEarlierVersion = errors.New("no earlier version to compare with")
+
+// Fill in missing from and to versions, from a list of versions ordered
+// newest first.
+func defaultVersions(versions []storage.Version, from, to int64) (int64, int64, error) {
+	if to == 0 {
+		to = versions[0].Version
+	}
+	if from == 0 {
+		for _, v := range versions {
+			if v.Version < to {
+				return v.Version, to, nil
+			}
+		}
+		return 0, 0, errNoEarlierVersion
+	}
+	return from, to, nil
+}
+
+func versionOf(page *resource.WebPage) storage.Version {
+	return storage.Version{
+		Version:     page.FetchTime.Unix(),
+		FetchTime:   *page.FetchTime,
+		FetchMethod: page.FetchMethod,
+		Title:       page.Title,
+	}
+}
+
+func versionLabel(page *resource.WebPage) string {
+	return fmt.Sprintf("%s\t%s", page.CanonicalURL, page.FetchTime.Format(time.RFC3339))
+}
+
+func writeHistoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrResourceNotFound),
+		errors.Is(err, storage.ErrVersionNotFound),
+		errors.Is(err, errNoEarlierVersion):
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	w.Write([]byte(err.Error()))
+}
+
+func extractDiffQuery(pkey any) middleware.Step {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			v := &DiffRequest{PrettyPrint: r.FormValue("pp") == "1"}
+			url := r.FormValue("url")
+			if url == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("No URL provided"))
+				return
+			}
+			var err error
+			if v.URL, err = nurl.Parse(url); err != nil || !v.URL.IsAbs() {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(fmt.Sprintf("Invalid URL provided: %q", url)))
+				return
+			}
+			for _, p := range []struct {
+				param string
+				dest  *int64
+			}{
+				{"from", &v.From},
+				{"to", &v.To},
+			} {
+				value := r.FormValue(p.param)
+				if value == "" {
+					continue
+				}
+				if *p.dest, err = strconv.ParseInt(value, 10, 64); err != nil || *p.dest <= 0 {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(fmt.Sprintf("Invalid %s %q: must be a version from the url's history", p.param, value)))
+					return
+				}
+			}
+			r = r.WithContext(context.WithValue(r.Context(), pkey, v))
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/sqlite"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+func historyServer(t *testing.T) (*Server, []int64) {
+	dbh := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := dbh.Open(ctx); err != nil {
+		t.Fatalf("Could not open database: %v", err)
+	}
+	store := storage.NewURLDataStore(dbh, storage.WithHistory(10, 0))
+	url, _ := nurl.Parse("https://example.com/story")
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	versions := make([]int64, 0, 3)
+	for i, content := range []string{"first\nsecond\nthird", "first\nSECOND\nthird", "first\nSECOND\nthird\nfourth"} {
+		fetchTime := base.Add(time.Duration(i) * time.Minute)
+		page := &resource.WebPage{
+			RequestedURL: url,
+			CanonicalURL: url,
+			FetchTime:    &fetchTime,
+			Title:        fmt.Sprintf("Story v%d", i+1),
+			ContentText:  content,
+		}
+		if _, err := store.Save(page); err != nil {
+			t.Fatalf("Error saving page: %v", err)
+		}
+		versions = append(versions, fetchTime.Unix())
+	}
+	return MustAPIServer(ctx, WithURLFetcher(&mockUrlFetcher{}), WithHistoryIf(store)), versions
+}
+
+func TestHistory503WhenUnavailable(t *testing.T) {
+	ss := MustAPIServer(context.Background(), WithURLFetcher(&mockUrlFetcher{}), WithHistoryIf(nil))
+	for _, h := range []func() http.HandlerFunc{ss.History, ss.HistoryDiff} {
+		req := httptest.NewRequest("GET", "http://foo.bar/history?url=https://example.com/story", nil)
+		w := httptest.NewRecorder()
+		h()(w, req)
+		if w.Result().StatusCode != 503 {
+			t.Errorf("Expected 503, got %d", w.Result().StatusCode)
+		}
+	}
+}
+
+func TestHistoryHandler(t *testing.T) {
+	ss, versions := historyServer(t)
+	tests := []struct {
+		name         string
+		url          string
+		expectStatus int
+		expectCount  int
+	}{
+		{"no url", "", 400, 0},
+		{"unknown url", "https://example.com/nothing", 404, 0},
+		{"versions", "https://example.com/story", 200, 3},
+		{"cleaned url", "https://example.com/story?utm_source=feed", 200, 3},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "http://foo.bar/history?url="+nurl.QueryEscape(test.url), nil)
+		w := httptest.NewRecorder()
+		ss.History()(w, req)
+		resp := w.Result()
+		if resp.StatusCode != test.expectStatus {
+			t.Errorf("[%s] Expected %d, got %d", test.name, test.expectStatus, resp.StatusCode)
+			continue
+		}
+		if resp.StatusCode != 200 {
+			continue
+		}
+		var hr HistoryResponse
+		if err := json.NewDecoder(resp.Body).Decode(&hr); err != nil {
+			t.Fatalf("[%s] Error decoding JSON: %s", test.name, err)
+		}
+		if len(hr.Versions) != test.expectCount {
+			t.Errorf("[%s] Expected %d versions, got %d", test.name, test.expectCount, len(hr.Versions))
+			continue
+		}
+		if hr.Versions[0].Version != versions[2] {
+			t.Errorf("[%s] Expected latest version %d first, got %d", test.name, versions[2], hr.Versions[0].Version)
+		}
+	}
+}
+
+func TestHistoryDiffHandler(t *testing.T) {
+	ss, versions := historyServer(t)
+	tests := []struct {
+		name         string
+		query        string
+		expectStatus int
+		expectFrom   int64
+		expectTo     int64
+		expectDiff   []string
+	}{
+		{"no url", "", 400, 0, 0, nil},
+		{"bad version", "&from=yesterday", 400, 0, 0, nil},
+		{"unknown version", "&from=12345", 404, 0, 0, nil},
+		{"latest two", "", 200, versions[1], versions[2], []string{"+fourth"}},
+		{"to with previous", fmt.Sprintf("&to=%d", versions[1]), 200, versions[0], versions[1], []string{"-second", "+SECOND"}},
+		{"first to latest", fmt.Sprintf("&from=%d", versions[0]), 200, versions[0], versions[2], []string{"-second", "+SECOND", "+fourth"}},
+		{"nothing earlier", fmt.Sprintf("&to=%d", versions[0]), 404, 0, 0, nil},
+		{"same version", fmt.Sprintf("&from=%d&to=%d", versions[1], versions[1]), 200, versions[1], versions[1], nil},
+	}
+	for _, test := range tests {
+		target := "http://foo.bar/history/diff"
+		if test.name != "no url" {
+			target += "?url=" + nurl.QueryEscape("https://example.com/story") + test.query
+		}
+		req := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		ss.HistoryDiff()(w, req)
+		resp := w.Result()
+		if resp.StatusCode != test.expectStatus {
+			t.Errorf("[%s] Expected %d, got %d", test.name, test.expectStatus, resp.StatusCode)
+			continue
+		}
+		if resp.StatusCode != 200 {
+			continue
+		}
+		var dr DiffResponse
+		if err := json.NewDecoder(resp.Body).Decode(&dr); err != nil {
+			t.Fatalf("[%s] Error decoding JSON: %s", test.name, err)
+		}
+		if dr.From.Version != test.expectFrom || dr.To.Version != test.expectTo {
+			t.Errorf("[%s] Expected %d..%d, got %d..%d", test.name, test.expectFrom, test.expectTo, dr.From.Version, dr.To.Version)
+		}
+		if len(test.expectDiff) == 0 && dr.Diff != "" {
+			t.Errorf("[%s] Expected empty diff, got %q", test.name, dr.Diff)
+		}
+		for _, line := range test.expectDiff {
+			if !strings.Contains(dr.Diff, "\n"+line+"\n") {
+				t.Errorf("[%s] Expected diff to contain %q, got:\n%s", test.name, line, dr.Diff)
+			}
+		}
+	}
+}
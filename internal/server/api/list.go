@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/efixler/scrape/internal/server/middleware"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+// Defines valid inputs for a request to list stored pages. The date fields
+// accept either RFC3339 timestamps or YYYY-MM-DD dates.
+type ListRequest struct {
+	Hostname       string                    `json:"hostname,omitempty"`
+	FetchedSince   *time.Time                `json:"fetched_since,omitempty"`
+	FetchedUntil   *time.Time                `json:"fetched_until,omitempty"`
+	PublishedSince *time.Time                `json:"published_since,omitempty"`
+	PublishedUntil *time.Time                `json:"published_until,omitempty"`
+	FetchMethod    resource.ClientIdentifier `json:"method,omitempty"`
+	StatusCode     int                       `json:"status,omitempty"`
+	Cursor         string                    `json:"cursor,omitempty"`
+	Limit          int                       `json:"limit,omitempty"`
+	PrettyPrint    bool                      `json:"-"`
+}
+
+// Defines the output for a request to list stored pages. Pass NextCursor
+// as the cursor param to get the next set of results.
+type ListResponse struct {
+	Request    ListRequest         `json:"request"`
+	Pages      []*resource.WebPage `json:"pages"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+func WithListerIf(l storage.Lister) option {
+	return func(ss *Server) error {
+		if l == nil {
+			return nil
+		}
+		ss.lister = l
+		return nil
+	}
+}
+
+func (ss *Server) List() http.HandlerFunc {
+	ms := ss.withAuthIfEnabled(middleware.MaxBytes(4096), extractListQuery(payloadKey{}))
+	return middleware.Chain(ss.list, ms...)
+}
+
+func (ss *Server) list(w http.ResponseWriter, r *http.Request) {
+	if ss.lister == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	req, _ := r.Context().Value(payloadKey{}).(*ListRequest)
+	query := storage.ListQuery{
+		Hostname:       req.Hostname,
+		FetchedSince:   timeOrZero(req.FetchedSince),
+		FetchedUntil:   timeOrZero(req.FetchedUntil),
+		PublishedSince: timeOrZero(req.PublishedSince),
+		PublishedUntil: timeOrZero(req.PublishedUntil),
+		FetchMethod:    req.FetchMethod,
+		StatusCode:     req.StatusCode,
+		Cursor:         req.Cursor,
+		Limit:          req.Limit,
+	}
+	result, err := ss.lister.List(query)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+	middleware.WriteJSONOutput(w, &ListResponse{
+		Request:    *req,
+		Pages:      result.Pages,
+		NextCursor: result.NextCursor,
+	}, req.PrettyPrint, http.StatusOK)
+}
+
+func extractListQuery(pkey any) middleware.Step {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			v := &ListRequest{
+				Hostname:    strings.ToLower(r.FormValue("hostname")),
+				Cursor:      r.FormValue("cursor"),
+				PrettyPrint: r.FormValue("pp") == "1",
+			}
+			badRequest := func(msg string) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(msg))
+			}
+			var err error
+			for _, d := range []struct {
+				param string
+				dest  **time.Time
+			}{
+				{"fetched_since", &v.FetchedSince},
+				{"fetched_until", &v.FetchedUntil},
+				{"published_since", &v.PublishedSince},
+				{"published_until", &v.PublishedUntil},
+			} {
+				if *d.dest, err = ParseDateParam(r.FormValue(d.param)); err != nil {
+					badRequest(fmt.Sprintf("Invalid %s: %s", d.param, err))
+					return
+				}
+			}
+			if method := r.FormValue("method"); method != "" {
+				if err = v.FetchMethod.UnmarshalText([]byte(method)); err != nil {
+					badRequest(fmt.Sprintf("Invalid method %q", method))
+					return
+				}
+			}
+			if status := r.FormValue("status"); status != "" {
+				v.StatusCode, err = strconv.Atoi(status)
+				if err != nil || v.StatusCode < 100 || v.StatusCode > 599 {
+					badRequest(fmt.Sprintf("Invalid status %q: must be an HTTP status code", status))
+					return
+				}
+			}
+			v.Limit = storage.DefaultListLimit
+			if limit := r.FormValue("limit"); limit != "" {
+				v.Limit, err = strconv.Atoi(limit)
+				if err != nil || v.Limit < 1 {
+					badRequest(fmt.Sprintf("Invalid limit %q: must be an integer > 0", limit))
+					return
+				}
+				if v.Limit > storage.MaxListLimit {
+					v.Limit = storage.MaxListLimit
+				}
+			}
+			r = r.WithContext(context.WithValue(r.Context(), pkey, v))
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+type mockLister struct {
+	query storage.ListQuery
+}
+
+func (m *mockLister) List(q storage.ListQuery) (*storage.ListResult, error) {
+	m.query = q
+	if q.Cursor == "bad" {
+		return nil, errors.Join(storage.ErrInvalidCursor, errors.New("bad cursor"))
+	}
+	return &storage.ListResult{
+		Pages:      []*resource.WebPage{{Title: "a page"}},
+		NextCursor: "next",
+	}, nil
+}
+
+func TestList503WhenUnavailable(t *testing.T) {
+	ss := MustAPIServer(context.Background(), WithURLFetcher(&mockUrlFetcher{}))
+	req := httptest.NewRequest("GET", "http://foo.bar/pages", nil)
+	w := httptest.NewRecorder()
+	ss.List()(w, req)
+	if w.Result().StatusCode != 503 {
+		t.Errorf("Expected 503, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestListHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expectStatus int
+		check        func(storage.ListQuery) bool
+	}{
+		{"defaults", "", 200, func(q storage.ListQuery) bool {
+			return q.Limit == storage.DefaultListLimit && q.FetchedSince.IsZero() && q.FetchMethod == resource.Unspecified
+		}},
+		{"hostname", "?hostname=NYTimes.com", 200, func(q storage.ListQuery) bool {
+			return q.Hostname == "nytimes.com"
+		}},
+		{"dates", "?fetched_since=2024-05-01&published_until=2024-05-02T10:00:00Z", 200, func(q storage.ListQuery) bool {
+			return q.FetchedSince.Day() == 1 && q.PublishedUntil.Hour() == 10 && q.FetchedUntil.IsZero()
+		}},
+		{"method", "?method=chromium-headless", 200, func(q storage.ListQuery) bool {
+			return q.FetchMethod == resource.HeadlessChromium
+		}},
+		{"status", "?status=404", 200, func(q storage.ListQuery) bool {
+			return q.StatusCode == 404
+		}},
+		{"limit capped", "?limit=100000", 200, func(q storage.ListQuery) bool {
+			return q.Limit == storage.MaxListLimit
+		}},
+		{"cursor", "?cursor=abc", 200, func(q storage.ListQuery) bool {
+			return q.Cursor == "abc"
+		}},
+		{"bad date", "?fetched_until=last+week", 400, nil},
+		{"bad method", "?method=carrier-pigeon", 400, nil},
+		{"bad status", "?status=42", 400, nil},
+		{"bad limit", "?limit=-5", 400, nil},
+		{"bad cursor", "?cursor=bad", 400, nil},
+	}
+	for _, test := range tests {
+		lister := &mockLister{}
+		ss := MustAPIServer(context.Background(), WithURLFetcher(&mockUrlFetcher{}), WithListerIf(lister))
+		req := httptest.NewRequest("GET", "http://foo.bar/pages"+test.query, nil)
+		w := httptest.NewRecorder()
+		ss.List()(w, req)
+		resp := w.Result()
+		if resp.StatusCode != test.expectStatus {
+			t.Errorf("[%s] Expected %d, got %d", test.name, test.expectStatus, resp.StatusCode)
+			continue
+		}
+		if test.check == nil {
+			continue
+		}
+		if !test.check(lister.query) {
+			t.Errorf("[%s] Unexpected query passed to lister: %+v", test.name, lister.query)
+		}
+		var lr ListResponse
+		if err := json.NewDecoder(resp.Body).Decode(&lr); err != nil {
+			t.Fatalf("[%s] Error decoding JSON: %s", test.name, err)
+		}
+		if len(lr.Pages) != 1 || lr.NextCursor != "next" {
+			t.Errorf("[%s] Unexpected response: %+v", test.name, lr)
+		}
+	}
+}
+
+func TestListUnavailableWithNilLister(t *testing.T) {
+	ss, err := NewAPIServer(context.Background(), WithURLFetcher(&mockUrlFetcher{}), WithListerIf(nil))
+	if err != nil {
+		t.Fatalf("Expected nil Lister to be ignored, got %v", err)
+	}
+	recorder := httptest.NewRecorder()
+	ss.List()(recorder, httptest.NewRequest("GET", "/list", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d without a Lister, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+}
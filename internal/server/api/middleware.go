@@ -15,6 +15,7 @@ func parseSinglePayload() middleware.Step {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			pp := r.FormValue("pp") == "1"
+			refresh := r.FormValue("refresh") == "1"
 			v := new(SingleURLRequest)
 			if middleware.IsJSONRequest(r) {
 				decoder := json.NewDecoder(r.Body)
@@ -41,9 +42,31 @@ func parseSinglePayload() middleware.Step {
 			if pp {
 				v.PrettyPrint = true
 			}
-			slog.Debug("ParseSingle", "url", v.URL, "pp", v.PrettyPrint, "encoding", r.Header.Get("Content-Type"))
+			if refresh {
+				v.Refresh = true
+			}
+			if method := r.FormValue("method"); method != "" {
+				if err := v.Method.UnmarshalText([]byte(method)); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			slog.Debug("ParseSingle", "url", v.URL, "pp", v.PrettyPrint, "refresh", v.Refresh, "method", v.Method, "encoding", r.Header.Get("Content-Type"))
 			r = r.WithContext(context.WithValue(r.Context(), payloadKey{}, v))
 			next(w, r)
 		}
 	}
 }
+
+// Use method to fetch the request's url, regardless of the method in the
+// payload. Must follow parseSinglePayload.
+func withFetchMethod(method FetchMethod) middleware.Step {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if req, ok := r.Context().Value(payloadKey{}).(*SingleURLRequest); ok {
+				req.Method = method
+			}
+			next(w, r)
+		}
+	}
+}
@@ -116,3 +116,72 @@ func TestParseSinglePostForm(t *testing.T) {
 		}
 	}
 }
+
+func TestParseSingleRefresh(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		target        string
+		body          string
+		expectRefresh bool
+	}{
+		{"get", "http://example.com?url=http://example.com&refresh=1", "", true},
+		{"get no refresh", "http://example.com?url=http://example.com", "", false},
+		{"json", "http://example.com", `{"url":"http://example.com","refresh":true}`, true},
+		{"json query param", "http://example.com?refresh=1", `{"url":"http://example.com"}`, true},
+	}
+	for _, tt := range tests {
+		method := "GET"
+		if tt.body != "" {
+			method = "POST"
+		}
+		req := httptest.NewRequest(method, tt.target, strings.NewReader(tt.body))
+		recorder := httptest.NewRecorder()
+		m := parseSinglePayload()
+		m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pp, _ := r.Context().Value(payloadKey{}).(*SingleURLRequest)
+			if pp.Refresh != tt.expectRefresh {
+				t.Errorf("[%s] ParseSingle, expected refresh %t, got %t", tt.name, tt.expectRefresh, pp.Refresh)
+			}
+		}))(recorder, req)
+		if recorder.Result().StatusCode != http.StatusOK {
+			t.Errorf("[%s] ParseSingle, expected status 200, got %d", tt.name, recorder.Result().StatusCode)
+		}
+	}
+}
+
+func TestParseSingleMethod(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		target       string
+		body         string
+		expectMethod FetchMethod
+		expectStatus int
+	}{
+		{"get", "http://example.com?url=http://example.com&method=headless", "", HeadlessFetch, 200},
+		{"get no method", "http://example.com?url=http://example.com", "", "", 200},
+		{"get invalid", "http://example.com?url=http://example.com&method=carrier-pigeon", "", "", 400},
+		{"json", "http://example.com", `{"url":"http://example.com","method":"auto"}`, AutoFetch, 200},
+		{"json invalid", "http://example.com", `{"url":"http://example.com","method":"carrier-pigeon"}`, "", 400},
+		{"json query param", "http://example.com?method=direct", `{"url":"http://example.com","method":"auto"}`, DirectFetch, 200},
+	}
+	for _, tt := range tests {
+		method := "GET"
+		if tt.body != "" {
+			method = "POST"
+		}
+		req := httptest.NewRequest(method, tt.target, strings.NewReader(tt.body))
+		recorder := httptest.NewRecorder()
+		m := parseSinglePayload()
+		m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pp, _ := r.Context().Value(payloadKey{}).(*SingleURLRequest)
+			if pp.Method != tt.expectMethod {
+				t.Errorf("[%s] ParseSingle, expected method %q, got %q", tt.name, tt.expectMethod, pp.Method)
+			}
+		}))(recorder, req)
+		if recorder.Result().StatusCode != tt.expectStatus {
+			t.Errorf("[%s] ParseSingle, expected status %d, got %d", tt.name, tt.expectStatus, recorder.Result().StatusCode)
+		}
+	}
+}
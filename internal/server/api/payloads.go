@@ -3,23 +3,57 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	nurl "net/url"
+
+	"github.com/efixler/scrape/fetch"
 )
 
 type payloadKey struct{}
 
+// How the pages for a request are fetched: with the direct http client, with
+// the headless browser, or automatically, with the headless browser when the
+// direct client can't extract a page. The empty value is the same as direct.
+type FetchMethod string
+
+const (
+	DirectFetch   FetchMethod = "direct"
+	HeadlessFetch FetchMethod = "headless"
+	AutoFetch     FetchMethod = "auto"
+)
+
+var ErrInvalidFetchMethod = errors.New("invalid fetch method")
+
+func (m *FetchMethod) UnmarshalText(b []byte) error {
+	switch fm := FetchMethod(b); fm {
+	case "", DirectFetch, HeadlessFetch, AutoFetch:
+		*m = fm
+		return nil
+	default:
+		return fmt.Errorf("%w %q, expected direct, headless, or auto", ErrInvalidFetchMethod, fm)
+	}
+}
+
 // Defines the input payload for a batch request.
 type BatchRequest struct {
-	Urls []string `json:"urls"`
+	Urls     []string               `json:"urls"`
+	Refresh  bool                   `json:"refresh,omitempty"`
+	Method   FetchMethod            `json:"method,omitempty"`
+	Headless *fetch.HeadlessOptions `json:"headless,omitempty"`
 }
 
 // Defines the input payload for a single URL request.
 // The URL field is required, converted from a string on input,
 // and must be an absolute URL.
+// Headless options apply to pages that are loaded in the headless browser, and
+// override the options in the settings for the url's domain.
 type SingleURLRequest struct {
-	URL         *nurl.URL `json:"url"`
-	PrettyPrint bool      `json:"pp,omitempty"`
+	URL         *nurl.URL              `json:"url"`
+	PrettyPrint bool                   `json:"pp,omitempty"`
+	Refresh     bool                   `json:"refresh,omitempty"`
+	Method      FetchMethod            `json:"method,omitempty"`
+	Headless    *fetch.HeadlessOptions `json:"headless,omitempty"`
 }
 
 var errNoURL = errors.New("URL is required")
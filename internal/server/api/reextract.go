@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	nurl "net/url"
+
+	"github.com/efixler/scrape/internal"
+	"github.com/efixler/scrape/internal/server/middleware"
+)
+
+// Up to this many urls can be re-extracted in one request.
+const MaxReextractURLs = 100
+
+// Defines valid inputs for a re-extraction request. Pages are selected by
+// url, or by hostname (including subdomains). Set All to re-extract every
+// stored page. Pages selected by url are re-extracted while the request
+// waits; the others are re-extracted in the background.
+type ReextractRequest struct {
+	Urls     []string `json:"urls,omitempty"`
+	Hostname string   `json:"hostname,omitempty"`
+	All      bool     `json:"all,omitempty"`
+}
+
+// Defines the output for a re-extraction request for urls. The summary
+// counts the results by status.
+type ReextractResponse struct {
+	Request ReextractRequest `json:"request"`
+	*internal.ReextractReport
+}
+
+// Defines the output for a re-extraction request for a hostname or all
+// pages, and for a request for the status of the background job.
+type ReextractJobResponse struct {
+	Request *ReextractRequest      `json:"request,omitempty"`
+	Job     *internal.ReextractJob `json:"job"`
+}
+
+func WithReextractorIf(r *internal.Reextractor) option {
+	return func(ss *Server) error {
+		if r == nil {
+			return nil
+		}
+		ss.reextractor = r
+		return nil
+	}
+}
+
+func (ss *Server) Reextract() http.HandlerFunc {
+	ms := ss.withAuthIfEnabled(
+		middleware.MaxBytes(32768),
+		middleware.DecodeJSONBody[ReextractRequest](payloadKey{}),
+	)
+	return middleware.Chain(ss.reextract, ms...)
+}
+
+func (ss *Server) reextract(w http.ResponseWriter, r *http.Request) {
+	if ss.reextractor == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	req, ok := r.Context().Value(payloadKey{}).(*ReextractRequest)
+	if !ok {
+		http.Error(w, "Can't process re-extract request, no input data", http.StatusInternalServerError)
+		return
+	}
+	if len(req.Urls) > MaxReextractURLs {
+		http.Error(w, fmt.Sprintf("Too many urls: up to %d can be re-extracted at a time", MaxReextractURLs), http.StatusBadRequest)
+		return
+	}
+	query := internal.ReextractQuery{
+		URLs:     make([]*nurl.URL, 0, len(req.Urls)),
+		Hostname: req.Hostname,
+		All:      req.All,
+	}
+	for _, u := range req.Urls {
+		url, err := nurl.Parse(u)
+		if err != nil || !url.IsAbs() {
+			http.Error(w, fmt.Sprintf("Invalid URL provided: %q", u), http.StatusBadRequest)
+			return
+		}
+		query.URLs = append(query.URLs, url)
+	}
+	if len(query.URLs) == 0 {
+		job, err := ss.reextractor.Start(ss.ctx, query)
+		switch {
+		case errors.Is(err, internal.ErrNoReextractTarget):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, internal.ErrReextractRunning):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response := &ReextractJobResponse{Request: req, Job: job}
+		middleware.WriteJSONOutput(w, response, r.FormValue("pp") == "1", http.StatusAccepted)
+		return
+	}
+	response := &ReextractResponse{
+		Request:         *req,
+		ReextractReport: internal.NewReextractReport(),
+	}
+	if err := ss.reextractor.Reextract(query, response.Add); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	middleware.WriteJSONOutput(w, response, r.FormValue("pp") == "1", http.StatusOK)
+}
+
+// Returns the status of the background re-extraction job that's running, or
+// of the last one if none is running.
+func (ss *Server) ReextractJob() http.HandlerFunc {
+	return middleware.Chain(ss.reextractJob, ss.withAuthIfEnabled()...)
+}
+
+func (ss *Server) reextractJob(w http.ResponseWriter, r *http.Request) {
+	if ss.reextractor == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	job := ss.reextractor.Job()
+	if job == nil {
+		http.Error(w, "No re-extraction job has been started", http.StatusNotFound)
+		return
+	}
+	middleware.WriteJSONOutput(w, &ReextractJobResponse{Job: job}, r.FormValue("pp") == "1", http.StatusOK)
+}
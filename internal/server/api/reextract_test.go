@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/sqlite"
+	"github.com/efixler/scrape/internal"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+func TestReextract503WhenUnavailable(t *testing.T) {
+	ss := MustAPIServer(context.Background(), WithURLFetcher(&mockUrlFetcher{}), WithReextractorIf(nil))
+	req := httptest.NewRequest("POST", "http://foo.bar/reextract", strings.NewReader(`{"all":true}`))
+	w := httptest.NewRecorder()
+	ss.Reextract()(w, req)
+	if w.Result().StatusCode != 503 {
+		t.Errorf("Expected 503, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestReextractHandler(t *testing.T) {
+	dbh := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := dbh.Open(ctx); err != nil {
+		t.Fatalf("Could not open database: %v", err)
+	}
+	store := storage.NewURLDataStore(dbh)
+	archive := storage.NewArchiveStore(dbh, 0)
+	url, _ := nurl.Parse("https://example.com/story")
+	header := http.Header{"Content-Type": {"text/html"}}
+	if err := archive.Archive(url, header, []byte("<html><head><title>New</title></head><body><p>New content</p></body></html>")); err != nil {
+		t.Fatalf("Error archiving: %v", err)
+	}
+	stale := &resource.WebPage{
+		RequestedURL: url,
+		CanonicalURL: url,
+		FetchMethod:  resource.DefaultClient,
+		Title:        "Old",
+		ContentText:  "Old content",
+	}
+	if _, err := store.Save(stale); err != nil {
+		t.Fatalf("Error saving page: %v", err)
+	}
+	ss := MustAPIServer(ctx, WithURLFetcher(&mockUrlFetcher{}), WithReextractorIf(internal.NewReextractor(archive, store)))
+
+	tests := []struct {
+		name         string
+		body         string
+		expectStatus int
+		expectResult internal.ReextractStatus
+	}{
+		{"no target", `{}`, 400, ""},
+		{"bad url", `{"urls":["not a url"]}`, 400, ""},
+		{"url", `{"urls":["https://example.com/story"]}`, 200, internal.ReextractUpdated},
+		{"too many urls", `{"urls":[` + strings.Repeat(`"https://example.com/story",`, MaxReextractURLs) + `"https://example.com/story"]}`, 400, ""},
+		{"unknown url", `{"urls":["https://example.com/nothing"]}`, 200, internal.ReextractNotFound},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "http://foo.bar/reextract", strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		ss.Reextract()(w, req)
+		resp := w.Result()
+		if resp.StatusCode != test.expectStatus {
+			t.Errorf("[%s] Expected %d, got %d", test.name, test.expectStatus, resp.StatusCode)
+			continue
+		}
+		if resp.StatusCode != 200 {
+			continue
+		}
+		var rr ReextractResponse
+		if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+			t.Fatalf("[%s] Error decoding JSON: %s", test.name, err)
+		}
+		if len(rr.Results) != 1 || rr.Results[0].Status != test.expectResult {
+			t.Errorf("[%s] Expected one %s result, got %+v", test.name, test.expectResult, rr.Results)
+			continue
+		}
+		if rr.Summary[test.expectResult] != 1 {
+			t.Errorf("[%s] Expected summary to count the result, got %v", test.name, rr.Summary)
+		}
+	}
+
+	// Hostnames are re-extracted in the background, and the job's status can be polled.
+	w := httptest.NewRecorder()
+	ss.ReextractJob()(w, httptest.NewRequest("GET", "http://foo.bar/reextract", nil))
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 before a job is started, got %d", w.Result().StatusCode)
+	}
+	w = httptest.NewRecorder()
+	ss.Reextract()(w, httptest.NewRequest("POST", "http://foo.bar/reextract", strings.NewReader(`{"hostname":"example.com"}`)))
+	if w.Result().StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected 202 for a hostname, got %d", w.Result().StatusCode)
+	}
+	var jr ReextractJobResponse
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		w = httptest.NewRecorder()
+		ss.ReextractJob()(w, httptest.NewRequest("GET", "http://foo.bar/reextract", nil))
+		if w.Result().StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200 for the job status, got %d", w.Result().StatusCode)
+		}
+		jr = ReextractJobResponse{}
+		if err := json.NewDecoder(w.Result().Body).Decode(&jr); err != nil {
+			t.Fatalf("Error decoding job status: %s", err)
+		}
+		if !jr.Job.Running || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if jr.Job.Running || jr.Job.Finished == nil || jr.Job.Error != "" {
+		t.Fatalf("Expected the job to finish without an error, got %+v", jr.Job)
+	}
+	if jr.Job.Summary[internal.ReextractUnchanged] != 1 {
+		t.Errorf("Expected the job to count one unchanged page, got %v", jr.Job.Summary)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/internal"
+	"github.com/efixler/scrape/internal/server/middleware"
+)
+
+// Renderer for screenshot and pdf requests, which get a 503 if this isn't set.
+func WithRendererIf(r *internal.StorageBackedRenderer) option {
+	return func(ss *Server) error {
+		if r == nil {
+			return nil
+		}
+		ss.renderer = r
+		return nil
+	}
+}
+
+// Returns a full-page screenshot of the requested url.
+func (ss *Server) Screenshot() http.HandlerFunc {
+	return ss.renderHandler(fetch.Screenshot)
+}
+
+// Returns the requested url, printed to PDF.
+func (ss *Server) PDF() http.HandlerFunc {
+	return ss.renderHandler(fetch.PDF)
+}
+
+func (ss *Server) renderHandler(format fetch.RenderFormat) http.HandlerFunc {
+	return middleware.Chain(
+		func(w http.ResponseWriter, r *http.Request) {
+			ss.render(w, r, format)
+		},
+		ss.withAuthIfEnabled(middleware.MaxBytes(4096), parseSinglePayload())...,
+	)
+}
+
+func (ss *Server) render(w http.ResponseWriter, r *http.Request, format fetch.RenderFormat) {
+	if ss.renderer == nil {
+		http.Error(w, fmt.Sprintf("Can't render %s: headless browser isn't enabled", format), http.StatusServiceUnavailable)
+		return
+	}
+	req, ok := r.Context().Value(payloadKey{}).(*SingleURLRequest)
+	if !ok {
+		http.Error(w, "Can't process render request, no input data", http.StatusInternalServerError)
+		return
+	}
+	render, err := ss.renderer.Render(req.URL, format, req.Headless, req.Refresh)
+	if err != nil {
+		var httpErr fetch.HttpError
+		switch {
+		case errors.Is(err, fetch.ErrRenderTooLarge):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errors.As(err, &httpErr):
+			http.Error(w, fmt.Sprintf("Error loading %s: %s", req.URL, httpErr.Error()), http.StatusBadGateway)
+		default:
+			slog.Error("Error rendering", "url", req.URL, "format", format, "error", err)
+			http.Error(w, fmt.Sprintf("Error rendering %s: %s", req.URL, err), http.StatusBadGateway)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Length", strconv.Itoa(len(render.Body)))
+	w.Header().Set("Last-Modified", render.RenderTime.Format(http.TimeFormat))
+	w.Write(render.Body)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	nurl "net/url"
+	"testing"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/sqlite"
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/internal"
+	"github.com/efixler/scrape/internal/storage"
+)
+
+type mockRenderer struct{}
+
+func (m mockRenderer) Render(url *nurl.URL, format fetch.RenderFormat, options *fetch.HeadlessOptions) ([]byte, error) {
+	switch url.Path {
+	case "/missing":
+		return nil, fetch.HttpError{StatusCode: 404}
+	case "/huge":
+		return make([]byte, 100), nil
+	}
+	return []byte(format), nil
+}
+
+func TestRender503WhenUnavailable(t *testing.T) {
+	ss := MustAPIServer(context.Background(), WithURLFetcher(&mockUrlFetcher{}), WithRendererIf(nil))
+	req := httptest.NewRequest("GET", "http://foo.bar/extract/screenshot?url=https://example.com/", nil)
+	w := httptest.NewRecorder()
+	ss.Screenshot()(w, req)
+	if w.Code != 503 {
+		t.Errorf("Expected 503, got %d", w.Code)
+	}
+}
+
+func TestRenderHandler(t *testing.T) {
+	dbh := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := dbh.Open(ctx); err != nil {
+		t.Fatalf("Could not open database: %v", err)
+	}
+	renderer := internal.NewStorageBackedRenderer(mockRenderer{}, storage.NewRenderStore(dbh, 0), 50)
+	ss := MustAPIServer(ctx, WithURLFetcher(&mockUrlFetcher{}), WithRendererIf(renderer))
+	tests := []struct {
+		name              string
+		format            fetch.RenderFormat
+		url               string
+		expectStatus      int
+		expectContentType string
+	}{
+		{"screenshot", fetch.Screenshot, "https://example.com/", 200, "image/png"},
+		{"pdf", fetch.PDF, "https://example.com/", 200, "application/pdf"},
+		{"not found", fetch.Screenshot, "https://example.com/missing", 502, ""},
+		{"too large", fetch.PDF, "https://example.com/huge", 422, ""},
+		{"no url", fetch.Screenshot, "", 400, ""},
+	}
+	for _, test := range tests {
+		handler := ss.Screenshot()
+		if test.format == fetch.PDF {
+			handler = ss.PDF()
+		}
+		req := httptest.NewRequest("GET", "http://foo.bar/extract/"+string(test.format)+"?url="+test.url, nil)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != test.expectStatus {
+			t.Fatalf("[%s] Expected %d, got %d: %s", test.name, test.expectStatus, w.Code, w.Body)
+		}
+		if test.expectStatus != 200 {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != test.expectContentType {
+			t.Errorf("[%s] Expected content type %s, got %s", test.name, test.expectContentType, ct)
+		}
+		if w.Body.String() != string(test.format) {
+			t.Errorf("[%s] Expected the render, got %q", test.name, w.Body)
+		}
+		if w.Header().Get("Last-Modified") == "" {
+			t.Errorf("[%s] Expected a Last-Modified header", test.name)
+		}
+	}
+}
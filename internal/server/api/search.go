@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/efixler/scrape/internal/server/middleware"
+	"github.com/efixler/scrape/internal/storage"
+)
+
+// Defines valid inputs for a search request. Since and Until bound
+// the fetch time of the results, and accept either RFC3339 timestamps
+// or YYYY-MM-DD dates.
+type SearchRequest struct {
+	Query       string     `json:"q"`
+	Hostname    string     `json:"hostname,omitempty"`
+	Since       *time.Time `json:"since,omitempty"`
+	Until       *time.Time `json:"until,omitempty"`
+	Offset      int        `json:"offset,omitempty"`
+	Limit       int        `json:"limit,omitempty"`
+	PrettyPrint bool       `json:"-"`
+}
+
+// Defines the output for a search request.
+type SearchResponse struct {
+	Request SearchRequest          `json:"request"`
+	Results []storage.SearchResult `json:"results"`
+}
+
+func WithSearchIf(s storage.Searcher) option {
+	return func(ss *Server) error {
+		if s == nil {
+			return nil
+		}
+		ss.searcher = s
+		return nil
+	}
+}
+
+func (ss *Server) Search() http.HandlerFunc {
+	ms := ss.withAuthIfEnabled(middleware.MaxBytes(4096), extractSearchQuery(payloadKey{}))
+	return middleware.Chain(ss.search, ms...)
+}
+
+func (ss *Server) search(w http.ResponseWriter, r *http.Request) {
+	if ss.searcher == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	req, _ := r.Context().Value(payloadKey{}).(*SearchRequest)
+	results, err := ss.searcher.Search(storage.SearchQuery{
+		Query:    req.Query,
+		Hostname: req.Hostname,
+		Since:    timeOrZero(req.Since),
+		Until:    timeOrZero(req.Until),
+		Offset:   req.Offset,
+		Limit:    req.Limit,
+	})
+	if err != nil {
+		switch err {
+		case storage.ErrEmptySearchQuery:
+			w.WriteHeader(http.StatusBadRequest)
+		case storage.ErrSearchUnavailable:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+	middleware.WriteJSONOutput(w, &SearchResponse{
+		Request: *req,
+		Results: results,
+	}, req.PrettyPrint, http.StatusOK)
+}
+
+func extractSearchQuery(pkey any) middleware.Step {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			v := &SearchRequest{
+				Query:       strings.TrimSpace(r.FormValue("q")),
+				Hostname:    strings.ToLower(r.FormValue("hostname")),
+				PrettyPrint: r.FormValue("pp") == "1",
+			}
+			if v.Query == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("No query provided"))
+				return
+			}
+			var err error
+			if v.Since, err = ParseDateParam(r.FormValue("since")); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(fmt.Sprintf("Invalid since: %s", err)))
+				return
+			}
+			if v.Until, err = ParseDateParam(r.FormValue("until")); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(fmt.Sprintf("Invalid until: %s", err)))
+				return
+			}
+			if r.FormValue("offset") != "" {
+				v.Offset, err = strconv.Atoi(r.FormValue("offset"))
+				if err != nil || v.Offset < 0 {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(fmt.Sprintf("Invalid offset %q: must be an integer >= 0", r.FormValue("offset"))))
+					return
+				}
+			}
+			v.Limit = storage.DefaultSearchLimit
+			if r.FormValue("limit") != "" {
+				v.Limit, err = strconv.Atoi(r.FormValue("limit"))
+				if err != nil || v.Limit < 1 {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(fmt.Sprintf("Invalid limit %q: must be an integer > 0", r.FormValue("limit"))))
+					return
+				}
+				if v.Limit > storage.MaxSearchLimit {
+					v.Limit = storage.MaxSearchLimit
+				}
+			}
+			r = r.WithContext(context.WithValue(r.Context(), pkey, v))
+			next(w, r)
+		}
+	}
+}
+
+// Parse a date or timestamp from a request param with storage.ParseDate.
+// An empty value returns nil.
+func ParseDateParam(value string) (*time.Time, error) {
+	t, err := storage.ParseDate(value)
+	if err != nil || t.IsZero() {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
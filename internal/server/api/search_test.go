@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	nurl "net/url"
+	"testing"
+
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+type mockSearcher struct {
+	err   error
+	query storage.SearchQuery
+}
+
+func (m *mockSearcher) Search(q storage.SearchQuery) ([]storage.SearchResult, error) {
+	m.query = q
+	if m.err != nil {
+		return nil, m.err
+	}
+	u, _ := nurl.Parse("https://example.com/hit")
+	return []storage.SearchResult{
+		{Snippet: "a hit", Page: &resource.WebPage{CanonicalURL: u}},
+	}, nil
+}
+
+func TestSearch503WhenUnavailable(t *testing.T) {
+	ss := MustAPIServer(context.Background(), WithURLFetcher(&mockUrlFetcher{}), WithSearchIf(nil))
+	req := httptest.NewRequest("GET", "http://foo.bar/search?q=hello", nil)
+	w := httptest.NewRecorder()
+	ss.Search()(w, req)
+	if w.Result().StatusCode != 503 {
+		t.Errorf("Expected 503, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		searchErr    error
+		expectStatus int
+		expectLimit  int
+		expectOffset int
+		expectSince  bool
+	}{
+		{"no query", "", nil, 400, 0, 0, false},
+		{"blank query", "?q=++", nil, 400, 0, 0, false},
+		{"bad since", "?q=foo&since=yesterday", nil, 400, 0, 0, false},
+		{"bad until", "?q=foo&until=2024-13-01", nil, 400, 0, 0, false},
+		{"bad offset", "?q=foo&offset=-1", nil, 400, 0, 0, false},
+		{"bad limit", "?q=foo&limit=0", nil, 400, 0, 0, false},
+		{"defaults", "?q=foo", nil, 200, storage.DefaultSearchLimit, 0, false},
+		{"limit capped", "?q=foo&limit=1000", nil, 200, storage.MaxSearchLimit, 0, false},
+		{"paging", "?q=foo&limit=5&offset=10", nil, 200, 5, 10, false},
+		{"date only", "?q=foo&since=2024-01-02", nil, 200, storage.DefaultSearchLimit, 0, true},
+		{"timestamp", "?q=foo&since=2024-01-02T10:00:00Z", nil, 200, storage.DefaultSearchLimit, 0, true},
+		{"empty terms", "?q=--", storage.ErrEmptySearchQuery, 400, 0, 0, false},
+		{"search unavailable", "?q=foo", storage.ErrSearchUnavailable, 503, 0, 0, false},
+		{"search error", "?q=foo", errors.New("boom"), 500, 0, 0, false},
+	}
+	for _, test := range tests {
+		searcher := &mockSearcher{err: test.searchErr}
+		ss := MustAPIServer(context.Background(), WithURLFetcher(&mockUrlFetcher{}), WithSearchIf(searcher))
+		req := httptest.NewRequest("GET", "http://foo.bar/search"+test.query, nil)
+		w := httptest.NewRecorder()
+		ss.Search()(w, req)
+		resp := w.Result()
+		if resp.StatusCode != test.expectStatus {
+			t.Errorf("[%s] Expected %d, got %d", test.name, test.expectStatus, resp.StatusCode)
+			continue
+		}
+		if resp.StatusCode != 200 {
+			continue
+		}
+		if searcher.query.Limit != test.expectLimit {
+			t.Errorf("[%s] Expected limit %d, got %d", test.name, test.expectLimit, searcher.query.Limit)
+		}
+		if searcher.query.Offset != test.expectOffset {
+			t.Errorf("[%s] Expected offset %d, got %d", test.name, test.expectOffset, searcher.query.Offset)
+		}
+		if searcher.query.Since.IsZero() == test.expectSince {
+			t.Errorf("[%s] Expected since set %v, got %v", test.name, test.expectSince, searcher.query.Since)
+		}
+		var sr SearchResponse
+		if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+			t.Fatalf("[%s] Error decoding JSON: %s", test.name, err)
+		}
+		if sr.Request.Query != "foo" {
+			t.Errorf("[%s] Expected query 'foo' in response, got %q", test.name, sr.Request.Query)
+		}
+		if len(sr.Results) != 1 {
+			t.Errorf("[%s] Expected 1 result, got %d", test.name, len(sr.Results))
+		}
+	}
+}
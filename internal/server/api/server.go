@@ -10,13 +10,13 @@ import (
 	"net/http"
 	nurl "net/url"
 
-	"github.com/efixler/scrape/database"
 	"github.com/efixler/scrape/fetch"
 	"github.com/efixler/scrape/fetch/feed"
 	"github.com/efixler/scrape/internal"
 	"github.com/efixler/scrape/internal/auth"
 	"github.com/efixler/scrape/internal/server/middleware"
 	"github.com/efixler/scrape/internal/settings"
+	"github.com/efixler/scrape/internal/storage"
 	"github.com/efixler/scrape/resource"
 	"github.com/efixler/webutil/jsonarray"
 )
@@ -25,6 +25,8 @@ const (
 	TokenCookieName = "token"
 )
 
+var ErrFetchMethodUnavailable = errors.New("fetch method is not available")
+
 func WithURLFetcher(f fetch.URLFetcher) option {
 	return func(s *Server) error {
 		if f == nil {
@@ -35,6 +37,9 @@ func WithURLFetcher(f fetch.URLFetcher) option {
 	}
 }
 
+// Fetcher for the headless fetch method. Requests for headless fetches get a
+// 503 if this isn't set. To store headless results with the rest of the pages,
+// use a StorageBackedFetcher from WithAlternateURLFetcher.
 func WithHeadlessIf(hf fetch.URLFetcher) option {
 	return func(s *Server) error {
 		if hf == nil {
@@ -45,6 +50,19 @@ func WithHeadlessIf(hf fetch.URLFetcher) option {
 	}
 }
 
+// Fetcher for the auto fetch method, which should fall back to the headless
+// browser when a direct fetch can't extract a page. When this isn't set, auto
+// requests are fetched with the URL fetcher.
+func WithAutoFetcherIf(af fetch.URLFetcher) option {
+	return func(s *Server) error {
+		if af == nil {
+			return nil
+		}
+		s.autoFetcher = af
+		return nil
+	}
+}
+
 func WithFeedFetcher(ff fetch.FeedFetcher) option {
 	return func(s *Server) error {
 		if ff == nil {
@@ -55,12 +73,25 @@ func WithFeedFetcher(ff fetch.FeedFetcher) option {
 	}
 }
 
-func WithSettingsFrom(db *database.DBHandle) option {
+// The stores for domain settings and cookies. Pass the domain settings store
+// that the server's url normalizer gets its rules from, so that saving settings
+// clears the normalizer's cached rules.
+func WithSettings(ds settings.DomainSettingsStore, cookies settings.CookieStore) option {
 	return func(s *Server) error {
-		if db == nil {
-			return errors.New("nil database handle provided")
+		if ds == nil || cookies == nil {
+			return errors.New("nil settings store provided")
 		}
-		s.settingsStorage = settings.NewDomainSettingsStorage(db)
+		s.settingsStorage = ds
+		s.cookieStorage = cookies
+		return nil
+	}
+}
+
+// Normalizes the urls that history is looked up for, the same way as the urls
+// that are fetched. When this isn't set, resource.DefaultURLNormalizer is used.
+func WithURLNormalizer(n *resource.URLNormalizer) option {
+	return func(s *Server) error {
+		s.normalizer = n
 		return nil
 	}
 }
@@ -77,6 +108,29 @@ func WithAuthorizationIf(key auth.HMACBase64Key) option {
 
 type option func(*Server) error
 
+// Fetchers that can fetch urls again, bypassing stored content and cached errors.
+type refresher interface {
+	Refresh(*nurl.URL) (*resource.WebPage, error)
+}
+
+type headlessRefresher interface {
+	RefreshHeadless(*nurl.URL, *fetch.HeadlessOptions) (*resource.WebPage, error)
+}
+
+// Fetch url with fetcher, refreshing it if refresh is set and the fetcher supports it,
+// and passing headless options along to fetchers that take them.
+func fetchURL(fetcher fetch.URLFetcher, url *nurl.URL, refresh bool, options *fetch.HeadlessOptions) (*resource.WebPage, error) {
+	if refresh {
+		if r, ok := fetcher.(headlessRefresher); ok && options != nil {
+			return r.RefreshHeadless(url, options)
+		}
+		if r, ok := fetcher.(refresher); ok {
+			return r.Refresh(url)
+		}
+	}
+	return fetch.FetchWithOptions(fetcher, url, options)
+}
+
 func MustAPIServer(ctx context.Context, opts ...option) *Server {
 	ss, err := NewAPIServer(ctx, opts...)
 	if err != nil {
@@ -107,9 +161,17 @@ type Server struct {
 	ctx             context.Context
 	urlFetcher      fetch.URLFetcher
 	headlessFetcher fetch.URLFetcher
+	autoFetcher     fetch.URLFetcher
 	feedFetcher     fetch.FeedFetcher
 	signingKey      auth.HMACBase64Key
 	settingsStorage settings.DomainSettingsStore
+	cookieStorage   settings.CookieStore
+	searcher        storage.Searcher
+	lister          storage.Lister
+	versions        storage.VersionStore
+	normalizer      *resource.URLNormalizer
+	reextractor     *internal.Reextractor
+	renderer        *internal.StorageBackedRenderer
 }
 
 func (ss Server) SigningKey() auth.HMACBase64Key {
@@ -138,48 +200,29 @@ func (ss *Server) Extract() http.HandlerFunc {
 	)
 }
 
+// Same as Extract, with the headless fetch method.
 func (ss *Server) ExtractHeadless() http.HandlerFunc {
-	ms := ss.withAuthIfEnabled(middleware.MaxBytes(4096), parseSinglePayload())
-	return middleware.Chain(extractWithFetcher(ss.headlessFetcher), ms...)
+	return middleware.Chain(
+		ss.extract,
+		ss.withAuthIfEnabled(middleware.MaxBytes(4096), parseSinglePayload(), withFetchMethod(HeadlessFetch))...,
+	)
 }
 
-// The nested handler here is the same as the one below, just enclosed around a fetcher.
-// This is here temporarily while experimenting with how to handle headless-variant requests.
-// The enclosed approach is tighter than using the context to carry the fetcher, but this won't
-// work for feed requests right now because of how they call h.batch at the end (which is malleable).
-// Right now headless-variant requests have their own endpoint; if we we to using a payload param
-// to choose headless, that'll drive moving a context-based solution for fetcher stashing.
-func extractWithFetcher(fetcher fetch.URLFetcher) http.HandlerFunc {
-	if fetcher == nil {
-		return func(w http.ResponseWriter, r *http.Request) {
-			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
-		}
-	}
-	return func(w http.ResponseWriter, r *http.Request) {
-		req, _ := r.Context().Value(payloadKey{}).(*SingleURLRequest)
-		w.Header().Set("Content-Type", "application/json")
-		page, err := fetcher.Fetch(req.URL)
-		if err != nil {
-			if errors.Is(err, fetch.HttpError{}) {
-				switch err.(fetch.HttpError).StatusCode {
-				case http.StatusUnsupportedMediaType:
-					fallthrough
-				case http.StatusGatewayTimeout:
-					w.WriteHeader(err.(fetch.HttpError).StatusCode)
-				}
-			} else {
-				slog.Error("Unexpected error fetching", "url", req.URL, "error", err)
-				w.WriteHeader(http.StatusUnprocessableEntity)
-			}
+// The fetcher for a fetch method. Returns ErrFetchMethodUnavailable for headless
+// fetches when there's no headless fetcher.
+func (ss *Server) fetcher(method FetchMethod) (fetch.URLFetcher, error) {
+	switch method {
+	case HeadlessFetch:
+		if ss.headlessFetcher == nil {
+			return nil, ErrFetchMethodUnavailable
 		}
-		page.FetchMethod = resource.HeadlessChromium
-		encoder := json.NewEncoder(w)
-		encoder.SetEscapeHTML(false)
-		if req.PrettyPrint {
-			encoder.SetIndent("", "  ")
+		return ss.headlessFetcher, nil
+	case AutoFetch:
+		if ss.autoFetcher != nil {
+			return ss.autoFetcher, nil
 		}
-		encoder.Encode(page)
 	}
+	return ss.urlFetcher, nil
 }
 
 func (h *Server) extract(w http.ResponseWriter, r *http.Request) {
@@ -188,8 +231,13 @@ func (h *Server) extract(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Can't process extract request, no input data", http.StatusInternalServerError)
 		return
 	}
+	fetcher, err := h.fetcher(req.Method)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can't fetch with method %q: %s", req.Method, err), http.StatusServiceUnavailable)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	page, err := h.urlFetcher.Fetch(req.URL)
+	page, err := fetchURL(fetcher, req.URL, req.Refresh, req.Headless)
 	if err != nil {
 		if errors.Is(err, fetch.HttpError{}) {
 			switch err.(fetch.HttpError).StatusCode {
@@ -230,6 +278,17 @@ func (h *Server) batch(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "No URLs provided", http.StatusUnprocessableEntity)
 		return
 	}
+	if method := r.FormValue("method"); method != "" {
+		if err := req.Method.UnmarshalText([]byte(method)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	fetcher, err := h.fetcher(req.Method)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Can't fetch with method %q: %s", req.Method, err), http.StatusServiceUnavailable)
+		return
+	}
 	// if we made it here we are going to return JSON
 	w.Header().Set("Content-Type", "application/json")
 
@@ -238,9 +297,9 @@ func (h *Server) batch(w http.ResponseWriter, r *http.Request) {
 	if pp {
 		encoder.SetIndent("", "  ")
 	}
-	var err error
-	if batchFetcher, ok := h.urlFetcher.(fetch.BatchURLFetcher); ok {
-		rchan := batchFetcher.Batch(req.Urls, fetch.BatchOptions{})
+	refresh := req.Refresh || r.FormValue("refresh") == "1"
+	if batchFetcher, ok := fetcher.(fetch.BatchURLFetcher); ok {
+		rchan := batchFetcher.Batch(req.Urls, fetch.BatchOptions{Refresh: refresh, Headless: req.Headless})
 		for page := range rchan {
 			err = encoder.Encode(page)
 			if err != nil {
@@ -248,7 +307,7 @@ func (h *Server) batch(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	} else { // transitionally while we iron out the throttle-able batch
-		h.synchronousBatch(req.Urls, encoder)
+		synchronousBatch(fetcher, req.Urls, refresh, req.Headless, encoder)
 	}
 	encoder.Finish()
 	if err != nil {
@@ -285,7 +344,13 @@ func (ss *Server) delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *Server) synchronousBatch(urls []string, encoder *jsonarray.Encoder[*resource.WebPage]) {
+func synchronousBatch(
+	fetcher fetch.URLFetcher,
+	urls []string,
+	refresh bool,
+	options *fetch.HeadlessOptions,
+	encoder *jsonarray.Encoder[*resource.WebPage],
+) {
 	var page *resource.WebPage
 	for _, url := range urls {
 		if parsedUrl, err := nurl.Parse(url); err != nil {
@@ -295,7 +360,7 @@ func (h *Server) synchronousBatch(urls []string, encoder *jsonarray.Encoder[*res
 			}
 		} else {
 			// In this case we ignore the error, since it'll be included in the page
-			page, _ = h.urlFetcher.Fetch(parsedUrl)
+			page, _ = fetchURL(fetcher, parsedUrl, refresh, options)
 		}
 		err := encoder.Encode(page)
 		if err != nil {
@@ -315,6 +380,11 @@ func (h *Server) feed(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Can't process extract request, no input data", http.StatusInternalServerError)
 		return
 	}
+	// check before fetching the feed, so its items can be fetched
+	if _, err := h.fetcher(req.Method); err != nil {
+		http.Error(w, fmt.Sprintf("Can't fetch with method %q: %s", req.Method, err), http.StatusServiceUnavailable)
+		return
+	}
 	resource, err := h.feedFetcher.FetchContext(r.Context(), req.URL)
 	if err != nil {
 		var httpErr fetch.HttpError
@@ -328,7 +398,7 @@ func (h *Server) feed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	links := resource.ItemLinks()
-	v := BatchRequest{Urls: links}
+	v := BatchRequest{Urls: links, Refresh: req.Refresh, Method: req.Method, Headless: req.Headless}
 	r = r.WithContext(context.WithValue(r.Context(), payloadKey{}, &v))
 	h.batch(w, r)
 }
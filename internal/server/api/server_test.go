@@ -152,16 +152,74 @@ func TestNewFailsWithNilFetcher(t *testing.T) {
 }
 
 func TestHeadless503WhenUnavailable(t *testing.T) {
-	ss := MustAPIServer(context.Background(), WithURLFetcher(&mockUrlFetcher{}))
+	ss := MustAPIServer(
+		context.Background(),
+		WithURLFetcher(&mockUrlFetcher{}),
+		WithFeedFetcher(&mockFeedFetcher{}),
+	)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+	}{
+		{"extract/headless", ss.ExtractHeadless(), "GET", "http://foo.bar?url=http://example.com", ""},
+		{"extract", ss.Extract(), "GET", "http://foo.bar?url=http://example.com&method=headless", ""},
+		{"feed", ss.Feed(), "GET", "http://foo.bar?url=http://example.com/200&method=headless", ""},
+		{"batch", ss.Batch(), "POST", "http://foo.bar/batch", `{"urls":["http://example.com"],"method":"headless"}`},
+		{"batch query param", ss.Batch(), "POST", "http://foo.bar/batch?method=headless", `{"urls":["http://example.com"]}`},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		test.handler(w, req)
+		resp := w.Result()
+		if resp.StatusCode != 503 {
+			t.Errorf("[%s] Expected 503, got %d", test.name, resp.StatusCode)
+		}
+	}
+}
 
-	// ss := &scrapeServer{headlessFetcher: nil}
-	handler := ss.ExtractHeadless()
-	req := httptest.NewRequest("GET", "http://foo.bar?url=http://example.com", nil)
-	w := httptest.NewRecorder()
-	handler(w, req)
-	resp := w.Result()
-	if resp.StatusCode != 503 {
-		t.Errorf("Expected 503, got %d", resp.StatusCode)
+func TestBatchMethod(t *testing.T) {
+	ss := MustAPIServer(
+		context.Background(),
+		WithURLFetcher(&mockUrlFetcher{fetchMethod: resource.DefaultClient}),
+		WithHeadlessIf(&mockUrlFetcher{fetchMethod: resource.HeadlessChromium}),
+		WithAutoFetcherIf(&mockUrlFetcher{fetchMethod: resource.HeadlessChromium}),
+	)
+	tests := []struct {
+		name         string
+		target       string
+		body         string
+		expectStatus int
+		expectMethod resource.ClientIdentifier
+	}{
+		{"default", "/batch", `{"urls":["http://example.com"]}`, 200, resource.DefaultClient},
+		{"headless", "/batch", `{"urls":["http://example.com"],"method":"headless"}`, 200, resource.HeadlessChromium},
+		{"auto", "/batch", `{"urls":["http://example.com"],"method":"auto"}`, 200, resource.HeadlessChromium},
+		{"query param", "/batch?method=direct", `{"urls":["http://example.com"],"method":"auto"}`, 200, resource.DefaultClient},
+		{"invalid", "/batch", `{"urls":["http://example.com"],"method":"carrier-pigeon"}`, 400, resource.Unspecified},
+		{"invalid query param", "/batch?method=carrier-pigeon", `{"urls":["http://example.com"]}`, 400, resource.Unspecified},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", test.target, strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		ss.Batch()(w, req)
+		resp := w.Result()
+		if resp.StatusCode != test.expectStatus {
+			t.Fatalf("[%s] Expected %d, got %d", test.name, test.expectStatus, resp.StatusCode)
+		}
+		if test.expectStatus != 200 {
+			continue
+		}
+		var pages []*resource.WebPage
+		if err := json.NewDecoder(resp.Body).Decode(&pages); err != nil {
+			t.Fatalf("[%s] Error decoding JSON: %s", test.name, err)
+		}
+		if len(pages) != 1 || pages[0].FetchMethod != test.expectMethod {
+			t.Errorf("[%s] Expected one page fetched with %s, got %v", test.name, test.expectMethod, pages)
+		}
 	}
 }
 
@@ -174,6 +232,7 @@ func TestSingleHandler(t *testing.T) {
 	tests := []struct {
 		name         string
 		url          string
+		params       string
 		handler      http.HandlerFunc
 		expectMethod resource.ClientIdentifier
 	}{
@@ -189,10 +248,38 @@ func TestSingleHandler(t *testing.T) {
 			handler:      ss.ExtractHeadless(),
 			expectMethod: resource.HeadlessChromium,
 		},
+		{
+			name:         "headless overrides method",
+			url:          "http://example.com",
+			params:       "&method=direct",
+			handler:      ss.ExtractHeadless(),
+			expectMethod: resource.HeadlessChromium,
+		},
+		{
+			name:         "method direct",
+			url:          "http://example.com",
+			params:       "&method=direct",
+			handler:      ss.Extract(),
+			expectMethod: resource.DefaultClient,
+		},
+		{
+			name:         "method headless",
+			url:          "http://example.com",
+			params:       "&method=headless",
+			handler:      ss.Extract(),
+			expectMethod: resource.HeadlessChromium,
+		},
+		{
+			name:         "method auto without auto fetcher",
+			url:          "http://example.com",
+			params:       "&method=auto",
+			handler:      ss.Extract(),
+			expectMethod: resource.DefaultClient,
+		},
 	}
 
 	for _, test := range tests {
-		req := httptest.NewRequest("GET", "http://foo.bar?url="+test.url, nil)
+		req := httptest.NewRequest("GET", "http://foo.bar?url="+test.url+test.params, nil)
 		w := httptest.NewRecorder()
 		test.handler(w, req)
 		resp := w.Result()
@@ -264,3 +351,58 @@ func TestDeleteHandler(t *testing.T) {
 func init() {
 	slog.SetLogLoggerLevel(slog.LevelWarn)
 }
+
+type mockHeadlessFetcher struct {
+	mockUrlFetcher
+	options *fetch.HeadlessOptions
+}
+
+func (m *mockHeadlessFetcher) FetchHeadless(url *nurl.URL, options *fetch.HeadlessOptions) (*resource.WebPage, error) {
+	m.options = options
+	return m.Fetch(url)
+}
+
+func TestHeadlessOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		batch         bool
+		body          string
+		expectStatus  int
+		expectWaitFor string
+	}{
+		{"extract", false, `{"url":"http://example.com","headless":{"wait_for":"article","scrolls":2}}`, 200, "article"},
+		{"extract without options", false, `{"url":"http://example.com"}`, 200, ""},
+		{"extract invalid", false, `{"url":"http://example.com","headless":{"scrolls":-1}}`, 400, ""},
+		{"extract unknown option", false, `{"url":"http://example.com","headless":{"hover":"a"}}`, 400, ""},
+		{"batch", true, `{"urls":["http://example.com"],"headless":{"wait_for":"article"}}`, 200, "article"},
+		{"batch invalid", true, `{"urls":["http://example.com"],"headless":{"delay_ms":600000}}`, 400, ""},
+	}
+	for _, test := range tests {
+		fetcher := &mockHeadlessFetcher{mockUrlFetcher: mockUrlFetcher{fetchMethod: resource.HeadlessChromium}}
+		ss := MustAPIServer(
+			context.Background(),
+			WithURLFetcher(&mockUrlFetcher{fetchMethod: resource.DefaultClient}),
+			WithHeadlessIf(fetcher),
+		)
+		handler := ss.ExtractHeadless()
+		if test.batch {
+			handler = ss.Batch()
+		}
+		req := httptest.NewRequest("POST", "/?method=headless", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != test.expectStatus {
+			t.Fatalf("[%s] Expected %d, got %d", test.name, test.expectStatus, w.Code)
+		}
+		if test.expectStatus != 200 {
+			continue
+		}
+		switch {
+		case test.expectWaitFor == "" && fetcher.options != nil:
+			t.Errorf("[%s] Expected no options, got %+v", test.name, fetcher.options)
+		case test.expectWaitFor != "" && (fetcher.options == nil || fetcher.options.WaitFor != test.expectWaitFor):
+			t.Errorf("[%s] Expected wait_for %q, got %+v", test.name, test.expectWaitFor, fetcher.options)
+		}
+	}
+}
@@ -178,6 +178,16 @@ func TestPutDomainSettings(t *testing.T) {
 			expectStatus: 400,
 			payload:      `{"sitename":"example.com","fetch_client":"noop","user_agent":"bar","headers":{}}`,
 		},
+		{
+			name:         "url rules",
+			expectStatus: 200,
+			payload:      `{"sitename":"example.com","url_rules":{"strip_params":["ref"],"keep_params":["id"]}}`,
+		},
+		{
+			name:         "invalid url rules",
+			expectStatus: 400,
+			payload:      `{"sitename":"example.com","url_rules":{"strip_params":["a=b"]}}`,
+		},
 	}
 
 	domainExtractor := func(next http.HandlerFunc) http.HandlerFunc {
@@ -12,11 +12,18 @@ import (
 	"github.com/efixler/scrape/database"
 )
 
-func Handler(root string, dbh *database.DBHandle) http.Handler {
+// The health check includes the stats from each of the observers, keyed
+// by their names (like "cache" or "storage").
+func Handler(root string, dbh *database.DBHandle, observers map[string]Observer) http.Handler {
 	root = strings.TrimSuffix(root, "/")
 	mux := http.NewServeMux()
 	mux.HandleFunc("/heartbeat", heartbeat)
-	mux.Handle("/health", HealthHandler(dbh))
+	// a nil *DBHandle would make a non-nil StatsProvider
+	var db database.StatsProvider
+	if dbh != nil {
+		db = dbh
+	}
+	mux.Handle("/health", HealthHandler(db, observers))
 	switch root {
 	case "":
 		return mux
@@ -36,9 +43,10 @@ type health struct {
 	Application Application `json:"application"`
 	Memory      *Memory     `json:"memory"`
 	database    database.StatsProvider
+	observers   map[string]Observer
 }
 
-func HealthHandler(db database.StatsProvider) http.Handler {
+func HealthHandler(db database.StatsProvider, observers map[string]Observer) http.Handler {
 	h := health{
 		Application: Application{
 			StartTime: time.Now().UTC().Format(time.RFC3339),
@@ -49,23 +57,31 @@ func HealthHandler(db database.StatsProvider) http.Handler {
 	} else {
 		slog.Warn("Healthchecks: no database observer, will not include database stats")
 	}
+	h.observers = make(map[string]Observer, len(observers))
+	for name, o := range observers {
+		if o != nil {
+			h.observers[name] = o
+		}
+	}
 	return h
 }
 
 func (h *health) MarshalJSON() ([]byte, error) {
-	type alias health
-	var dbStats interface{}
+	stats := map[string]any{
+		"application": h.Application,
+		"memory":      h.Memory,
+	}
 	if h.database != nil {
-		dbStats, _ = h.database.Stats()
+		if dbStats, _ := h.database.Stats(); dbStats != nil {
+			stats["database"] = dbStats
+		}
 	}
-
-	return json.Marshal(&struct {
-		*alias
-		Database interface{} `json:"database,omitempty"`
-	}{
-		alias:    (*alias)(h),
-		Database: dbStats,
-	})
+	for name, o := range h.observers {
+		if s, _ := o(); s != nil {
+			stats[name] = s
+		}
+	}
+	return json.Marshal(stats)
 }
 
 func (h health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -74,7 +90,7 @@ func (h health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.read()
 	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
-	encoder.Encode(h)
+	encoder.Encode(&h)
 }
 
 func (h *health) read() error {
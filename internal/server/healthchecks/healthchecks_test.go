@@ -20,7 +20,7 @@ func TestHeartbeat(t *testing.T) {
 	}
 
 	testF := func(root string) {
-		ts := httptest.NewServer(Handler(root, nil))
+		ts := httptest.NewServer(Handler(root, nil, nil))
 		defer ts.Close()
 		client := ts.Client()
 		urlPath := path.Clean(root + "/heartbeat")
@@ -52,7 +52,7 @@ func TestHealthHandler(t *testing.T) {
 	}
 
 	testF := func(root string) {
-		ts := httptest.NewServer(Handler(root, nil))
+		ts := httptest.NewServer(Handler(root, nil, nil))
 		defer ts.Close()
 		client := ts.Client()
 		urlPath := path.Clean(root + "/health")
@@ -97,3 +97,40 @@ func TestHealthHandler(t *testing.T) {
 		testF(test.root)
 	}
 }
+
+func TestHealthObservers(t *testing.T) {
+	t.Parallel()
+	observers := map[string]Observer{
+		"cache":   func() (any, error) { return map[string]int{"entries": 3}, nil },
+		"storage": func() (any, error) { return map[string]int{"key_collisions": 1}, nil },
+		"missing": nil,
+	}
+	ts := httptest.NewServer(Handler("", nil, observers))
+	defer ts.Close()
+	resp, err := ts.Client().Get(ts.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var h map[string]json.RawMessage
+	if err = json.NewDecoder(resp.Body).Decode(&h); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"application", "memory"} {
+		if _, ok := h[key]; !ok {
+			t.Errorf("Expected %q in health stats, got %v", key, h)
+		}
+	}
+	if !strings.Contains(string(h["cache"]), `"entries": 3`) {
+		t.Errorf("Expected cache stats, got %s", h["cache"])
+	}
+	if !strings.Contains(string(h["storage"]), `"key_collisions": 1`) {
+		t.Errorf("Expected storage stats, got %s", h["storage"])
+	}
+	if _, ok := h["missing"]; ok {
+		t.Errorf("Expected nil observer to be skipped, got %s", h["missing"])
+	}
+	if _, ok := h["database"]; ok {
+		t.Errorf("Expected no database stats without a database, got %s", h["database"])
+	}
+}
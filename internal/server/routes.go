@@ -14,12 +14,15 @@ import (
 // Mux Initialization Arguments:
 //   - ss: api.Server: used for setting up API routes
 //   - db: database.DBHandle: used for healthchecks
+//   - observers: map[string]healthchecks.Observer: stats to include in
+//     healthchecks, keyed by name (e.g. "cache", "storage")
 //   - openHome: bool: if true, the the page will always be open, even
 //     if auth is enabled
 //   - enableProfiling: bool: if true, pprof routes will be added to the mux
 func InitMux(
 	ss *api.Server,
 	db *database.DBHandle,
+	observers map[string]healthchecks.Observer,
 	openHome bool,
 	enableProfiling bool,
 ) (*http.ServeMux, error) {
@@ -40,24 +43,38 @@ func InitMux(
 	h = ss.ExtractHeadless()
 	mux.HandleFunc("GET /extract/headless", h)
 	mux.HandleFunc("POST /extract/headless", h)
+	h = ss.Screenshot()
+	mux.HandleFunc("GET /extract/screenshot", h)
+	mux.HandleFunc("POST /extract/screenshot", h)
+	h = ss.PDF()
+	mux.HandleFunc("GET /extract/pdf", h)
+	mux.HandleFunc("POST /extract/pdf", h)
 	mux.HandleFunc("POST /batch", ss.Batch())
 	mux.HandleFunc("DELETE /extract", ss.Delete())
 	h = ss.Feed()
 	mux.HandleFunc("GET /feed", h)
 	mux.HandleFunc("POST /feed", h)
+	mux.HandleFunc("GET /search", ss.Search())
+	mux.HandleFunc("GET /pages", ss.List())
+	mux.HandleFunc("GET /history", ss.History())
+	mux.HandleFunc("GET /history/diff", ss.HistoryDiff())
+	mux.HandleFunc("POST /reextract", ss.Reextract())
+	mux.HandleFunc("GET /reextract", ss.ReextractJob())
 	// settings
-	// Until settings migrations for MySQL are in place
-	if (db != nil) && db.Engine.Driver() == string(database.SQLite) {
+	if db != nil {
 		mux.HandleFunc("GET /settings/domain/{DOMAIN}", ss.DomainSettings())
 		mux.HandleFunc("PUT /settings/domain/{DOMAIN}", ss.WriteDomainSettings())
 		mux.HandleFunc("GET /settings/domain", ss.SearchDomainSettings())
 		mux.HandleFunc("DELETE /settings/domain/{DOMAIN}", ss.DeleteDomainSettings())
+		mux.HandleFunc("GET /settings/domain/{DOMAIN}/cookies", ss.DomainCookies())
+		mux.HandleFunc("PUT /settings/domain/{DOMAIN}/cookies", ss.WriteDomainCookies())
+		mux.HandleFunc("DELETE /settings/domain/{DOMAIN}/cookies", ss.DeleteDomainCookies())
 	} else {
 		mux.HandleFunc("/settings/domain/", serviceUnavailable)
 	}
 
 	// healthchecks
-	mux.Handle("GET /.well-known/", healthchecks.Handler("/.well-known", db))
+	mux.Handle("GET /.well-known/", healthchecks.Handler("/.well-known", db, observers))
 	return mux, nil
 }
 
@@ -20,7 +20,7 @@ func TestWellknown(t *testing.T) {
 	//ctx, cancel := context.WithCancel(context.Background())
 	//defer cancel()
 
-	mux, err := InitMux(&api.Server{}, nil, false, false)
+	mux, err := InitMux(&api.Server{}, nil, nil, false, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -65,7 +65,7 @@ func TestExtractErrors(t *testing.T) {
 		api.WithURLFetcher(trafilatura.MustNew(nil)),
 	)
 
-	mux, err := InitMux(ss, nil, false, false)
+	mux, err := InitMux(ss, nil, nil, false, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -145,6 +145,31 @@ func TestAPIRoutesAreProtected(t *testing.T) {
 			method:  http.MethodPost,
 			handler: ss.Feed,
 		},
+		{
+			name:    "GET /search",
+			method:  http.MethodGet,
+			handler: ss.Search,
+		},
+		{
+			name:    "GET /pages",
+			method:  http.MethodGet,
+			handler: ss.List,
+		},
+		{
+			name:    "GET /history",
+			method:  http.MethodGet,
+			handler: ss.History,
+		},
+		{
+			name:    "GET /history/diff",
+			method:  http.MethodGet,
+			handler: ss.HistoryDiff,
+		},
+		{
+			name:    "POST /reextract",
+			method:  http.MethodPost,
+			handler: ss.Reextract,
+		},
 	}
 	for _, test := range tests {
 		req := httptest.NewRequest(test.method, "http://foo.bar", nil)
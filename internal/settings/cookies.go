@@ -0,0 +1,373 @@
+package settings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	nurl "net/url"
+	"strings"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"golang.org/x/net/publicsuffix"
+)
+
+const MaxCookiesPerDomain = 100
+
+var ErrInvalidCookie = errors.New("invalid cookie")
+
+// A cookie that's sent with requests to its domain and the domain's subdomains.
+// Cookies without an expiry time are kept until they're replaced or cleared.
+type Cookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Domain   string     `json:"domain,omitempty"`
+	Path     string     `json:"path,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+	HTTPOnly bool       `json:"http_only,omitempty"`
+}
+
+func (c Cookie) Validate() error {
+	if err := c.httpCookie().Valid(); err != nil {
+		return errors.Join(ErrInvalidCookie, err)
+	}
+	return nil
+}
+
+func (c Cookie) expired(now time.Time) bool {
+	return c.Expires != nil && !c.Expires.After(now)
+}
+
+func (c Cookie) httpCookie() *http.Cookie {
+	hc := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HTTPOnly,
+	}
+	if c.Expires != nil {
+		hc.Expires = *c.Expires
+	}
+	return hc
+}
+
+type CookieStore interface {
+	FetchCookies(domain string) ([]Cookie, error)
+	ReplaceCookies(domain string, cookies []Cookie) error
+	ClearCookies(domain string) (int64, error)
+}
+
+// cookieStorage keeps cookies in the database, by domain, and is the http.CookieJar
+// for the fetch clients. Cookies that sites set are only saved for domains with
+// PersistCookies in their settings.
+type cookieStorage struct {
+	*database.DBHandle
+	settings *domainSettingsStorage
+}
+
+func NewCookieStorage(dbh *database.DBHandle) *cookieStorage {
+	return &cookieStorage{
+		DBHandle: dbh,
+		settings: NewDomainSettingsStorage(dbh),
+	}
+}
+
+const cookieColumns = `domain, name, path, value, expires, secure, http_only`
+
+// The unexpired cookies stored for domain, not including those of its parent domains.
+func (s *cookieStorage) FetchCookies(domain string) ([]Cookie, error) {
+	stmt, err := s.Statement(fetchCookies, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(
+			ctx,
+			`SELECT `+cookieColumns+` FROM domain_cookies WHERE domain = ? ORDER BY name, path`,
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryContext(s.Ctx, strings.ToLower(domain))
+	if err != nil {
+		return nil, err
+	}
+	return scanCookies(rows)
+}
+
+// The unexpired cookies stored for any of domains, in one query. The number of
+// domains varies with the request's host, so the query isn't a cached statement.
+func (s *cookieStorage) domainCookies(domains []string) ([]Cookie, error) {
+	args := make([]any, len(domains))
+	for i, d := range domains {
+		args[i] = d
+	}
+	rows, err := s.DB.QueryContext(
+		s.Ctx,
+		`SELECT `+cookieColumns+` FROM domain_cookies
+		WHERE domain IN (`+database.Placeholders(len(domains))+`) ORDER BY name, path, domain`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCookies(rows)
+}
+
+// Reads cookieColumns rows, skipping expired cookies, and closes rows.
+func scanCookies(rows *sql.Rows) ([]Cookie, error) {
+	defer rows.Close()
+	now := time.Now()
+	cookies := make([]Cookie, 0)
+	for rows.Next() {
+		var c Cookie
+		var expires int64
+		if err := rows.Scan(&c.Domain, &c.Name, &c.Path, &c.Value, &expires, &c.Secure, &c.HTTPOnly); err != nil {
+			return nil, err
+		}
+		if expires > 0 {
+			t := time.Unix(expires, 0).UTC()
+			c.Expires = &t
+		}
+		if !c.expired(now) {
+			cookies = append(cookies, c)
+		}
+	}
+	return cookies, rows.Err()
+}
+
+// Replace the cookies stored for domain. The cookies' domains are set to domain.
+func (s *cookieStorage) ReplaceCookies(domain string, cookies []Cookie) error {
+	if err := ValidateDomain(domain); err != nil {
+		return err
+	}
+	if len(cookies) > MaxCookiesPerDomain {
+		return fmt.Errorf("%w: at most %d cookies per domain", ErrInvalidCookie, MaxCookiesPerDomain)
+	}
+	domain = strings.ToLower(domain)
+	for i := range cookies {
+		cookies[i].Domain = domain
+		if cookies[i].Path == "" {
+			cookies[i].Path = "/"
+		}
+		if err := cookies[i].Validate(); err != nil {
+			return err
+		}
+	}
+	remove, err := s.clearStmt()
+	if err != nil {
+		return err
+	}
+	save, err := s.saveStmt()
+	if err != nil {
+		return err
+	}
+	tx, err := s.DB.BeginTx(s.Ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.StmtContext(s.Ctx, remove).ExecContext(s.Ctx, domain); err != nil {
+		return err
+	}
+	save = tx.StmtContext(s.Ctx, save)
+	for _, c := range cookies {
+		if err = s.save(save, c); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Remove the cookies stored for domain. Returns the number of cookies removed.
+func (s *cookieStorage) ClearCookies(domain string) (int64, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return 0, err
+	}
+	stmt, err := s.clearStmt()
+	if err != nil {
+		return 0, err
+	}
+	result, err := stmt.ExecContext(s.Ctx, strings.ToLower(domain))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Cookies returns the cookies to send with a request for u: the unexpired cookies
+// of u's host and its parent domains, up to but not including the public suffix,
+// with paths that match u's. Secure cookies are only sent over https. The cookies
+// keep their attributes, for clients (like the headless browser) that set them
+// on their own. Implements http.CookieJar.
+func (s *cookieStorage) Cookies(u *nurl.URL) []*http.Cookie {
+	domains := cookieDomains(strings.ToLower(u.Hostname()))
+	if len(domains) == 0 {
+		return nil
+	}
+	stored, err := s.domainCookies(domains)
+	if err != nil {
+		slog.Warn("Error loading cookies", "domain", domains[0], "error", err)
+		return nil
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	var cookies []*http.Cookie
+	for _, c := range stored {
+		if (c.Secure && u.Scheme != "https") || !pathMatch(path, c.Path) {
+			continue
+		}
+		cookies = append(cookies, c.httpCookie())
+	}
+	return cookies
+}
+
+// The domains whose cookies are sent to host: host and its parents, stopping
+// before the public suffix. Cookies for IP addresses only match the address.
+func cookieDomains(host string) []string {
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+	var domains []string
+	for domain := host; strings.Contains(domain, ".") && !isPublicSuffix(domain); {
+		domains = append(domains, domain)
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+	return domains
+}
+
+// Whether domain is a public suffix, like com or co.uk, that no site's cookies
+// should be shared across.
+func isPublicSuffix(domain string) bool {
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	return suffix == domain
+}
+
+// SetCookies saves the cookies set in a response from u, if u's domain has
+// PersistCookies in its settings. Cookies are saved for the domain they were set
+// for, which must be u's host or one of its parents. Implements http.CookieJar.
+func (s *cookieStorage) SetCookies(u *nurl.URL, cookies []*http.Cookie) {
+	if len(cookies) == 0 {
+		return
+	}
+	host := strings.ToLower(u.Hostname())
+	if persist, err := s.settings.PersistCookies(host); !persist {
+		if err != nil {
+			slog.Warn("Error loading cookie settings", "domain", host, "error", err)
+		}
+		return
+	}
+	save, err := s.saveStmt()
+	if err != nil {
+		slog.Warn("Error saving cookies", "domain", host, "error", err)
+		return
+	}
+	now := time.Now()
+	for _, hc := range cookies {
+		c, ok := fromHTTPCookie(hc, host, now)
+		if !ok {
+			continue
+		}
+		if c.expired(now) {
+			err = s.delete(c)
+		} else {
+			err = s.save(save, c)
+		}
+		if err != nil {
+			slog.Warn("Error saving cookie", "domain", c.Domain, "name", c.Name, "error", err)
+		}
+	}
+}
+
+// Converts a cookie set by host, returning false for cookies that host can't set.
+func fromHTTPCookie(hc *http.Cookie, host string, now time.Time) (Cookie, bool) {
+	c := Cookie{
+		Name:     hc.Name,
+		Value:    hc.Value,
+		Domain:   strings.ToLower(strings.TrimPrefix(hc.Domain, ".")),
+		Path:     hc.Path,
+		Secure:   hc.Secure,
+		HTTPOnly: hc.HttpOnly,
+	}
+	if c.Domain == "" {
+		c.Domain = host
+	}
+	switch {
+	case c.Domain == host:
+	case net.ParseIP(host) != nil, !strings.HasSuffix(host, "."+c.Domain):
+		return c, false
+	}
+	if !strings.Contains(c.Domain, ".") || isPublicSuffix(c.Domain) {
+		return c, false
+	}
+	if c.Path == "" || !strings.HasPrefix(c.Path, "/") {
+		c.Path = "/"
+	}
+	switch {
+	case hc.MaxAge < 0:
+		c.Expires = &now
+	case hc.MaxAge > 0:
+		t := now.Add(time.Duration(hc.MaxAge) * time.Second)
+		c.Expires = &t
+	case !hc.Expires.IsZero():
+		t := hc.Expires
+		c.Expires = &t
+	}
+	return c, c.Validate() == nil
+}
+
+// Whether a request path is in a cookie's path. See RFC 6265, 5.1.4.
+func pathMatch(requestPath, cookiePath string) bool {
+	switch {
+	case requestPath == cookiePath:
+		return true
+	case !strings.HasPrefix(requestPath, cookiePath):
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+func (s *cookieStorage) save(stmt *sql.Stmt, c Cookie) error {
+	var expires int64
+	if c.Expires != nil {
+		expires = c.Expires.Unix()
+	}
+	_, err := stmt.ExecContext(s.Ctx, c.Domain, c.Name, c.Path, c.Value, expires, c.Secure, c.HTTPOnly)
+	return err
+}
+
+func (s *cookieStorage) delete(c Cookie) error {
+	stmt, err := s.Statement(deleteCookie, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, `DELETE FROM domain_cookies WHERE domain = ? AND name = ? AND path = ?`)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(s.Ctx, c.Domain, c.Name, c.Path)
+	return err
+}
+
+func (s *cookieStorage) saveStmt() (*sql.Stmt, error) {
+	return s.Statement(saveCookie, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(
+			ctx,
+			s.Engine.Dialect().Upsert(
+				"domain_cookies",
+				[]string{"domain", "name", "path"},
+				"domain", "name", "path", "value", "expires", "secure", "http_only",
+			),
+		)
+	})
+}
+
+func (s *cookieStorage) clearStmt() (*sql.Stmt, error) {
+	return s.Statement(clearCookies, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, `DELETE FROM domain_cookies WHERE domain = ?`)
+	})
+}
@@ -0,0 +1,222 @@
+package settings
+
+import (
+	"errors"
+	"net/http"
+	nurl "net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cookieNames(cookies []*http.Cookie) []string {
+	names := make([]string, 0, len(cookies))
+	for _, c := range cookies {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestReplaceCookies(t *testing.T) {
+	s := NewCookieStorage(getDatabase(t))
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	err := s.ReplaceCookies("Example.com", []Cookie{
+		{Name: "session", Value: "abc", Secure: true, HTTPOnly: true},
+		{Name: "consent", Value: "yes", Path: "/news", Expires: &future},
+		{Name: "old", Value: "x", Expires: &past},
+	})
+	if err != nil {
+		t.Fatalf("Error saving cookies: %v", err)
+	}
+	cookies, err := s.FetchCookies("example.com")
+	if err != nil {
+		t.Fatalf("Error fetching cookies: %v", err)
+	}
+	if len(cookies) != 2 {
+		t.Fatalf("Expected 2 unexpired cookies, got %+v", cookies)
+	}
+	consent, session := cookies[0], cookies[1]
+	if session.Name != "session" || session.Path != "/" || !session.Secure || !session.HTTPOnly || session.Expires != nil {
+		t.Errorf("Unexpected session cookie: %+v", session)
+	}
+	if consent.Domain != "example.com" || consent.Path != "/news" || !consent.Expires.Equal(future) {
+		t.Errorf("Unexpected consent cookie: %+v", consent)
+	}
+
+	if err = s.ReplaceCookies("example.com", []Cookie{{Name: "session", Value: "def"}}); err != nil {
+		t.Fatalf("Error replacing cookies: %v", err)
+	}
+	if cookies, _ = s.FetchCookies("example.com"); len(cookies) != 1 || cookies[0].Value != "def" {
+		t.Errorf("Expected the cookies to be replaced, got %+v", cookies)
+	}
+	if err = s.ReplaceCookies("example.com", []Cookie{{Name: "bad name", Value: "x"}}); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("Expected ErrInvalidCookie, got %v", err)
+	}
+	if err = s.ReplaceCookies("example", nil); !errors.Is(err, ErrInvalidDomain) {
+		t.Errorf("Expected ErrInvalidDomain, got %v", err)
+	}
+	if cleared, err := s.ClearCookies("example.com"); err != nil || cleared != 1 {
+		t.Errorf("Expected 1 cookie cleared, got %d, %v", cleared, err)
+	}
+	if cookies, _ = s.FetchCookies("example.com"); len(cookies) != 0 {
+		t.Errorf("Expected no cookies after clearing, got %+v", cookies)
+	}
+}
+
+func TestCookieJar(t *testing.T) {
+	db := getDatabase(t)
+	s := NewCookieStorage(db)
+	if err := s.ReplaceCookies("example.com", []Cookie{
+		{Name: "all", Value: "1"},
+		{Name: "secure", Value: "1", Secure: true},
+		{Name: "news", Value: "1", Path: "/news"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReplaceCookies("www.example.com", []Cookie{{Name: "www", Value: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReplaceCookies("co.uk", []Cookie{{Name: "suffix", Value: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url    string
+		expect []string
+	}{
+		{"https://www.example.com/news/a", []string{"all", "news", "secure", "www"}},
+		{"http://www.example.com/newsroom", []string{"all", "www"}},
+		{"https://example.com", []string{"all", "secure"}},
+		{"https://example.org/", []string{}},
+		{"https://www.example.co.uk/", []string{}},
+	}
+	for _, test := range tests {
+		u, _ := nurl.Parse(test.url)
+		names := cookieNames(s.Cookies(u))
+		if len(names) != len(test.expect) {
+			t.Errorf("[%s] Expected %v, got %v", test.url, test.expect, names)
+			continue
+		}
+		for i := range names {
+			if names[i] != test.expect[i] {
+				t.Errorf("[%s] Expected %v, got %v", test.url, test.expect, names)
+				break
+			}
+		}
+	}
+
+	// cookies are only saved for domains that persist them
+	u, _ := nurl.Parse("https://www.example.org/login")
+	s.SetCookies(u, []*http.Cookie{{Name: "session", Value: "1"}})
+	if cookies, _ := s.FetchCookies("www.example.org"); len(cookies) != 0 {
+		t.Errorf("Expected cookies not to be saved, got %+v", cookies)
+	}
+	if err := NewDomainSettingsStorage(db).Save(&DomainSettings{Domain: "example.org", PersistCookies: true}); err != nil {
+		t.Fatal(err)
+	}
+	s.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "1"},
+		{Name: "shared", Value: "1", Domain: ".example.org", MaxAge: 60},
+		{Name: "other", Value: "1", Domain: "example.net"},
+		{Name: "suffix", Value: "1", Domain: "org"},
+	})
+	if cookies, _ := s.FetchCookies("www.example.org"); len(cookies) != 1 || cookies[0].Name != "session" {
+		t.Errorf("Expected the host's cookie to be saved, got %+v", cookies)
+	}
+	if cookies, _ := s.FetchCookies("example.org"); len(cookies) != 1 || cookies[0].Name != "shared" || cookies[0].Expires == nil {
+		t.Errorf("Expected the parent domain's cookie to be saved, got %+v", cookies)
+	}
+	if cookies, _ := s.FetchCookies("example.net"); len(cookies) != 0 {
+		t.Errorf("Expected another domain's cookie not to be saved, got %+v", cookies)
+	}
+	if cookies, _ := s.FetchCookies("org"); len(cookies) != 0 {
+		t.Errorf("Expected a public suffix's cookie not to be saved, got %+v", cookies)
+	}
+	s.SetCookies(u, []*http.Cookie{{Name: "session", MaxAge: -1}})
+	if cookies, _ := s.FetchCookies("www.example.org"); len(cookies) != 0 {
+		t.Errorf("Expected the deleted cookie to be removed, got %+v", cookies)
+	}
+}
+
+func TestJarCookieAttributes(t *testing.T) {
+	s := NewCookieStorage(getDatabase(t))
+	expires := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	if err := s.ReplaceCookies("example.com", []Cookie{
+		{Name: "session", Value: "abc", Path: "/news", Secure: true, HTTPOnly: true, Expires: &expires},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := nurl.Parse("https://www.example.com/news/a")
+	cookies := s.Cookies(u)
+	if len(cookies) != 1 {
+		t.Fatalf("Expected 1 cookie, got %+v", cookies)
+	}
+	c := cookies[0]
+	if c.Value != "abc" || c.Domain != "example.com" || c.Path != "/news" || !c.Secure || !c.HttpOnly || !c.Expires.Equal(expires) {
+		t.Errorf("Expected the cookie's attributes, got %+v", c)
+	}
+}
+
+func TestFromHTTPCookie(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		host, domain string
+		expect       bool
+	}{
+		{"www.example.com", "", true},
+		{"www.example.com", ".example.com", true},
+		{"www.example.co.uk", "example.co.uk", true},
+		{"www.example.co.uk", "co.uk", false},
+		{"www.example.com", "com", false},
+		{"www.example.com", "example.org", false},
+		{"127.0.0.1", "", true},
+		{"127.0.0.1", "0.0.1", false},
+		{"localhost", "", false},
+	}
+	for _, test := range tests {
+		_, ok := fromHTTPCookie(&http.Cookie{Name: "a", Value: "1", Domain: test.domain}, test.host, now)
+		if ok != test.expect {
+			t.Errorf("[%s, %s] Expected %t, got %t", test.host, test.domain, test.expect, ok)
+		}
+	}
+}
+
+func TestCookieDomains(t *testing.T) {
+	tests := []struct {
+		host   string
+		expect []string
+	}{
+		{"www.example.com", []string{"www.example.com", "example.com"}},
+		{"a.b.example.co.uk", []string{"a.b.example.co.uk", "b.example.co.uk", "example.co.uk"}},
+		{"co.uk", nil},
+		{"localhost", nil},
+		{"127.0.0.1", []string{"127.0.0.1"}},
+	}
+	for _, test := range tests {
+		got := cookieDomains(test.host)
+		if strings.Join(got, ",") != strings.Join(test.expect, ",") {
+			t.Errorf("[%s] Expected %v, got %v", test.host, test.expect, got)
+		}
+	}
+}
+
+func TestPathMatch(t *testing.T) {
+	tests := []struct {
+		request, cookie string
+		expect          bool
+	}{
+		{"/", "/", true},
+		{"/news", "/news", true},
+		{"/news/a", "/news", true},
+		{"/news/a", "/news/", true},
+		{"/newsroom", "/news", false},
+		{"/", "/news", false},
+	}
+	for _, test := range tests {
+		if got := pathMatch(test.request, test.cookie); got != test.expect {
+			t.Errorf("[%s, %s] Expected %t, got %t", test.request, test.cookie, test.expect, got)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/fetch"
 	"github.com/efixler/scrape/internal/storage"
 	"github.com/efixler/scrape/resource"
 	"github.com/efixler/scrape/ua"
@@ -21,10 +22,14 @@ type stmtKey int
 const (
 	_ stmtKey = iota
 	delete
-	fetch
+	fetchOne
 	save
 	fetchRange
 	fetchRangeWithQuery
+	fetchCookies
+	saveCookie
+	deleteCookie
+	clearCookies
 )
 
 const (
@@ -46,6 +51,14 @@ type DomainSettings struct {
 	FetchClient resource.ClientIdentifier `json:"fetch_client,omitempty"`
 	UserAgent   ua.UserAgent              `json:"user_agent,omitempty"`
 	Headers     MIMEHeader                `json:"headers,omitempty"`
+	Headless    *fetch.HeadlessOptions    `json:"headless,omitempty"`
+	// Save the cookies that the domain's sites set, to send with later requests
+	PersistCookies bool `json:"persist_cookies,omitempty"`
+	// How the domain's urls are normalized, along with the global rules
+	URLRules *resource.URLRules `json:"url_rules,omitempty"`
+	// Send auto fetches for the domain's pages to the headless browser first. This
+	// is set when a direct fetch of one of its pages needed the headless browser.
+	HeadlessPreferred bool `json:"headless_preferred,omitempty"`
 }
 
 // Domain names will be case-folded to lower case.
@@ -70,12 +83,17 @@ type DomainSettingsStore interface {
 type domainSettingsStorage struct {
 	*database.DBHandle
 	maxBatchSize int
+	urlRules     *urlRulesCache
 }
 
+// Url rules are cached by each storage, and the cache is cleared when settings are
+// saved or deleted through it, so share one storage between the api that changes
+// settings and the url normalizer.
 func NewDomainSettingsStorage(dbh *database.DBHandle) *domainSettingsStorage {
 	return &domainSettingsStorage{
 		DBHandle:     dbh,
 		maxBatchSize: MaxDomainSettingsBatchSize,
+		urlRules:     newURLRulesCache(),
 	}
 }
 
@@ -86,16 +104,18 @@ func (d *domainSettingsStorage) Delete(domain string) (bool, error) {
 	stmt, err := d.Statement(delete, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
 		return db.PrepareContext(
 			ctx,
-			`DELETE FROM domain_settings WHERE LOWER(domain) = LOWER(?)`,
+			`DELETE FROM domain_settings WHERE LOWER(domain) = ?`,
 		)
 	})
 	if err != nil {
 		return false, err
 	}
-	result, err := stmt.ExecContext(d.Ctx, domain)
+	// Lower-cased here because not all engines can infer a type for LOWER(?)
+	result, err := stmt.ExecContext(d.Ctx, strings.ToLower(domain))
 	if err != nil {
 		return false, err
 	}
+	d.urlRules.clear()
 	rows, err := result.RowsAffected()
 	if err != nil {
 		return false, err
@@ -111,10 +131,10 @@ func (d *domainSettingsStorage) Delete(domain string) (bool, error) {
 }
 
 func (d *domainSettingsStorage) Fetch(domain string) (DomainSettings, error) {
-	stmt, err := d.Statement(fetch, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+	stmt, err := d.Statement(fetchOne, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
 		return db.PrepareContext(
 			ctx,
-			`SELECT domain, sitename, fetch_client, user_agent, headers 
+			`SELECT domain, sitename, fetch_client, user_agent, headers, headless, persist_cookies, url_rules, headless_preferred 
 			FROM domain_settings WHERE domain = ?`,
 		)
 	})
@@ -138,17 +158,135 @@ func (d *domainSettingsStorage) Fetch(domain string) (DomainSettings, error) {
 
 func (d *domainSettingsStorage) loadSettingFromRow(rows *sql.Rows) (DomainSettings, error) {
 	ds := DomainSettings{}
-	var headers string
-	err := rows.Scan(&ds.Domain, &ds.Sitename, &ds.FetchClient, &ds.UserAgent, &headers)
+	var (
+		headers  string
+		headless sql.NullString
+		urlRules sql.NullString
+	)
+	err := rows.Scan(
+		&ds.Domain, &ds.Sitename, &ds.FetchClient, &ds.UserAgent, &headers, &headless, &ds.PersistCookies, &urlRules,
+		&ds.HeadlessPreferred,
+	)
 	if err != nil {
 		return ds, err
 	}
 	if err := json.Unmarshal([]byte(headers), &ds.Headers); err != nil {
 		return ds, err
 	}
+	if headless.Valid {
+		if err := json.Unmarshal([]byte(headless.String), &ds.Headless); err != nil {
+			return ds, err
+		}
+	}
+	if urlRules.Valid {
+		if err := json.Unmarshal([]byte(urlRules.String), &ds.URLRules); err != nil {
+			return ds, err
+		}
+	}
 	return ds, nil
 }
 
+// HeadlessOptions returns the headless options for hostname, from the settings for
+// hostname or for the closest of its parent domains that has them. Returns nil if
+// none of them do.
+func (d *domainSettingsStorage) HeadlessOptions(hostname string) (*fetch.HeadlessOptions, error) {
+	ds, err := d.closest(hostname, func(ds DomainSettings) bool { return ds.Headless != nil })
+	if ds == nil {
+		return nil, err
+	}
+	return ds.Headless, nil
+}
+
+// PersistCookies reports whether the cookies set by hostname should be saved, from
+// the settings for hostname or for the closest of its parent domains that has them.
+func (d *domainSettingsStorage) PersistCookies(hostname string) (bool, error) {
+	ds, err := d.closest(hostname, func(DomainSettings) bool { return true })
+	if ds == nil {
+		return false, err
+	}
+	return ds.PersistCookies, nil
+}
+
+// HeadlessPreferred reports whether auto fetches for hostname's pages go to the
+// headless browser first, from the settings for hostname or for the closest of
+// its parent domains that prefer it.
+func (d *domainSettingsStorage) HeadlessPreferred(hostname string) (bool, error) {
+	ds, err := d.closest(hostname, func(ds DomainSettings) bool { return ds.HeadlessPreferred })
+	return ds != nil, err
+}
+
+// SetHeadlessPreferred records whether auto fetches for hostname's pages should go
+// to the headless browser first. The preference is set in the closest settings
+// for hostname or its parent domains, or in new settings for hostname if there
+// aren't any, and is cleared from all of them. Hosts that can't have settings,
+// like IP addresses, aren't recorded.
+func (d *domainSettingsStorage) SetHeadlessPreferred(hostname string, preferred bool) error {
+	if ValidateDomain(hostname) != nil {
+		return nil
+	}
+	if !preferred {
+		for {
+			ds, err := d.closest(hostname, func(ds DomainSettings) bool { return ds.HeadlessPreferred })
+			if ds == nil {
+				return err
+			}
+			ds.HeadlessPreferred = false
+			if err = d.Save(ds); err != nil {
+				return err
+			}
+		}
+	}
+	ds, err := d.closest(hostname, func(DomainSettings) bool { return true })
+	switch {
+	case err != nil:
+		return err
+	case ds == nil:
+		if ds, err = NewDomainSettings(hostname); err != nil {
+			return err
+		}
+	case ds.HeadlessPreferred:
+		return nil
+	}
+	ds.HeadlessPreferred = true
+	return d.Save(ds)
+}
+
+// URLRules returns the url normalization rules for hostname, from the settings for
+// hostname or for the closest of its parent domains that has them. Returns nil if
+// none of them do. Rules are cached until settings are saved or deleted.
+func (d *domainSettingsStorage) URLRules(hostname string) (*resource.URLRules, error) {
+	hostname = strings.ToLower(hostname)
+	rules, ok, generation := d.urlRules.get(hostname)
+	if ok {
+		return rules, nil
+	}
+	ds, err := d.closest(hostname, func(ds DomainSettings) bool { return ds.URLRules != nil })
+	if err != nil {
+		return nil, err
+	}
+	if ds != nil {
+		rules = ds.URLRules
+	}
+	d.urlRules.put(hostname, rules, generation)
+	return rules, nil
+}
+
+// The settings for hostname, or for the closest of its parent domains, for which
+// has returns true. Returns nil if there aren't any.
+func (d *domainSettingsStorage) closest(hostname string, has func(DomainSettings) bool) (*DomainSettings, error) {
+	for domain := strings.ToLower(hostname); strings.Contains(domain, "."); {
+		ds, err := d.Fetch(domain)
+		switch {
+		case err == nil && has(ds):
+			return &ds, nil
+		case err != nil && !errors.Is(err, storage.ErrResourceNotFound):
+			return nil, err
+		}
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+	return nil, nil
+}
+
 // FetchRange returns a slice of domain settings, offset by the given offset and limited
 // by the given limit. If query is not empty, it will be used to filter the results.
 // The query string may contain a leading and/or trailing * to match anything before or after the
@@ -172,7 +310,7 @@ func (d *domainSettingsStorage) FetchRange(offset int, limit int, query string)
 		stmt, err = d.Statement(fetchRangeWithQuery, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
 			return db.PrepareContext(
 				ctx,
-				`SELECT domain, sitename, fetch_client, user_agent, headers FROM domain_settings 
+				`SELECT domain, sitename, fetch_client, user_agent, headers, headless, persist_cookies, url_rules, headless_preferred FROM domain_settings 
 				ORDER BY domain ASC LIMIT ? OFFSET ?`,
 			)
 		})
@@ -186,8 +324,8 @@ func (d *domainSettingsStorage) FetchRange(offset int, limit int, query string)
 		stmt, err = d.Statement(fetchRange, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
 			return db.PrepareContext(
 				ctx,
-				`SELECT domain, sitename, fetch_client, user_agent, headers FROM domain_settings 
-				WHERE domain LIKE ? 
+				`SELECT domain, sitename, fetch_client, user_agent, headers, headless, persist_cookies, url_rules, headless_preferred FROM domain_settings 
+				WHERE domain `+d.Engine.Dialect().ILike()+` ? 
 				ORDER BY domain ASC LIMIT ? OFFSET ?`,
 			)
 		})
@@ -238,8 +376,12 @@ func (d *domainSettingsStorage) Save(domain *DomainSettings) error {
 	stmt, err := d.Statement(save, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
 		return db.PrepareContext(
 			ctx,
-			`REPLACE INTO domain_settings (domain, sitename, fetch_client, user_agent, headers) 
-			VALUES (?, ?, ?, ?, ?)`,
+			d.Engine.Dialect().Upsert(
+				"domain_settings",
+				[]string{"domain"},
+				"domain", "sitename", "fetch_client", "user_agent", "headers", "headless", "persist_cookies", "url_rules",
+				"headless_preferred",
+			),
 		)
 	})
 	if err != nil {
@@ -249,6 +391,22 @@ func (d *domainSettingsStorage) Save(domain *DomainSettings) error {
 	if err != nil {
 		return err
 	}
+	var headless sql.NullString
+	if domain.Headless != nil {
+		b, err := json.Marshal(domain.Headless)
+		if err != nil {
+			return err
+		}
+		headless = sql.NullString{String: string(b), Valid: true}
+	}
+	var urlRules sql.NullString
+	if domain.URLRules != nil {
+		b, err := json.Marshal(domain.URLRules)
+		if err != nil {
+			return err
+		}
+		urlRules = sql.NullString{String: string(b), Valid: true}
+	}
 	_, err = stmt.ExecContext(
 		d.Ctx,
 		domain.Domain,
@@ -256,10 +414,15 @@ func (d *domainSettingsStorage) Save(domain *DomainSettings) error {
 		domain.FetchClient,
 		domain.UserAgent,
 		string(hb),
+		headless,
+		domain.PersistCookies,
+		urlRules,
+		domain.HeadlessPreferred,
 	)
 	if err != nil {
 		return err
 	}
+	d.urlRules.clear()
 	return nil
 }
 
@@ -0,0 +1,19 @@
+//go:build postgres
+
+package settings
+
+import (
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/postgres"
+)
+
+func testEngine() database.Engine {
+	engine := postgres.MustNew(
+		postgres.NetAddress("127.0.0.1:5432"),
+		postgres.Username("postgres"),
+		postgres.WithMaxConnections(1),
+		postgres.Database("scrape_test"),
+		postgres.WithSSLMode(postgres.SSLDisable),
+	)
+	return engine
+}
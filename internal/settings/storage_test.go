@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"log/slog"
 	"net/textproto"
+	"reflect"
 	"sort"
 	"testing"
 
 	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/fetch"
 	"github.com/efixler/scrape/internal/storage"
 	"github.com/efixler/scrape/resource"
 	"github.com/efixler/scrape/ua"
@@ -67,6 +69,36 @@ func TestStoreAndRetrieve(t *testing.T) {
 				Headers:     nil,
 			},
 		},
+		{
+			name: "persist cookies",
+			settings: DomainSettings{
+				Domain:         "example.com",
+				PersistCookies: true,
+			},
+		},
+		{
+			name: "headless options",
+			settings: DomainSettings{
+				Domain:      "example.com",
+				FetchClient: resource.HeadlessChromium,
+				Headless: &fetch.HeadlessOptions{
+					WaitFor: "article",
+					Click:   []string{"#accept-cookies"},
+					Scrolls: 2,
+				},
+			},
+		},
+		{
+			name: "url rules",
+			settings: DomainSettings{
+				Domain: "example.com",
+				URLRules: &resource.URLRules{
+					StripParams: []string{"ref"},
+					KeepParams:  []string{"id"},
+					KeepPath:    true,
+				},
+			},
+		},
 		{
 			name: "empty headers",
 			settings: DomainSettings{
@@ -108,6 +140,12 @@ func TestStoreAndRetrieve(t *testing.T) {
 		if ds.UserAgent != test.settings.UserAgent {
 			t.Errorf("%s: UserAgent: got %v, want %v", test.name, ds.UserAgent, test.settings.UserAgent)
 		}
+		if !reflect.DeepEqual(ds.Headless, test.settings.Headless) {
+			t.Errorf("%s: Headless: got %+v, want %+v", test.name, ds.Headless, test.settings.Headless)
+		}
+		if !reflect.DeepEqual(ds.URLRules, test.settings.URLRules) {
+			t.Errorf("%s: URLRules: got %+v, want %+v", test.name, ds.URLRules, test.settings.URLRules)
+		}
 		if len(ds.Headers) != len(test.settings.Headers) {
 			t.Errorf("%s: Headers: got %v, want %v", test.name, ds.Headers, test.settings.Headers)
 			continue
@@ -126,6 +164,132 @@ func TestStoreAndRetrieve(t *testing.T) {
 	}
 }
 
+func TestHeadlessOptions(t *testing.T) {
+	db := getDatabase(t)
+	dss := NewDomainSettingsStorage(db)
+	options := &fetch.HeadlessOptions{NetworkIdle: true}
+	for _, ds := range []*DomainSettings{
+		{Domain: "example.com", Headless: options},
+		{Domain: "news.example.com", Sitename: "no headless options"},
+		{Domain: "example.org"},
+	} {
+		if err := dss.Save(ds); err != nil {
+			t.Fatalf("can't save %s: %v", ds.Domain, err)
+		}
+	}
+	tests := []struct {
+		hostname string
+		expected *fetch.HeadlessOptions
+	}{
+		{"example.com", options},
+		{"www.example.com", options},
+		{"News.Example.com", options},
+		{"example.org", nil},
+		{"www.example.net", nil},
+		{"localhost", nil},
+	}
+	for _, test := range tests {
+		got, err := dss.HeadlessOptions(test.hostname)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", test.hostname, err)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("[%s] got %+v, want %+v", test.hostname, got, test.expected)
+		}
+	}
+}
+
+func TestHeadlessPreferred(t *testing.T) {
+	dss := NewDomainSettingsStorage(getDatabase(t))
+	if err := dss.Save(&DomainSettings{Domain: "example.com", Sitename: "Example", PersistCookies: true}); err != nil {
+		t.Fatal(err)
+	}
+	preferred := func(hostname string) bool {
+		t.Helper()
+		got, err := dss.HeadlessPreferred(hostname)
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %v", hostname, err)
+		}
+		return got
+	}
+	// set on the closest settings, keeping the rest of them
+	if err := dss.SetHeadlessPreferred("www.example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := dss.Fetch("example.com")
+	if err != nil || !ds.HeadlessPreferred || ds.Sitename != "Example" || !ds.PersistCookies {
+		t.Errorf("Expected the preference in the parent's settings, got %+v, %v", ds, err)
+	}
+	if !preferred("www.example.com") || !preferred("example.com") {
+		t.Error("Expected example.com and its subdomains to prefer headless")
+	}
+	// new settings for hosts without any
+	if err := dss.SetHeadlessPreferred("App.Example.org", true); err != nil {
+		t.Fatal(err)
+	}
+	if ds, err = dss.Fetch("app.example.org"); err != nil || !ds.HeadlessPreferred {
+		t.Errorf("Expected new settings preferring headless, got %+v, %v", ds, err)
+	}
+	if preferred("example.org") {
+		t.Error("Expected the parent of a new host's settings not to prefer headless")
+	}
+	// hosts that can't have settings
+	if err := dss.SetHeadlessPreferred("127.0.0.1", true); err != nil || preferred("127.0.0.1") {
+		t.Errorf("Expected IP addresses not to be recorded, got %v", err)
+	}
+	// cleared from all of the settings
+	if err := dss.Save(&DomainSettings{Domain: "www.example.com", HeadlessPreferred: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dss.SetHeadlessPreferred("www.example.com", false); err != nil {
+		t.Fatal(err)
+	}
+	if preferred("www.example.com") || preferred("example.com") {
+		t.Error("Expected the preference to be cleared")
+	}
+	if ds, err = dss.Fetch("example.com"); err != nil || ds.Sitename != "Example" {
+		t.Errorf("Expected the rest of the settings to be kept, got %+v, %v", ds, err)
+	}
+}
+
+func TestURLRules(t *testing.T) {
+	dss := NewDomainSettingsStorage(getDatabase(t))
+	rules := &resource.URLRules{KeepParams: []string{"id"}}
+	for _, ds := range []*DomainSettings{
+		{Domain: "example.com", URLRules: rules},
+		{Domain: "news.example.com", Sitename: "no url rules"},
+	} {
+		if err := dss.Save(ds); err != nil {
+			t.Fatalf("can't save %s: %v", ds.Domain, err)
+		}
+	}
+	for hostname, expected := range map[string]*resource.URLRules{
+		"www.example.com":  rules,
+		"news.example.com": rules,
+		"example.org":      nil,
+	} {
+		got, err := dss.URLRules(hostname)
+		if err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("[%s] got %+v, %v, want %+v", hostname, got, err, expected)
+		}
+	}
+
+	// cached rules, and cached hostnames without rules, are dropped when settings change
+	orgRules := &resource.URLRules{StripParams: []string{"ref"}}
+	if err := dss.Save(&DomainSettings{Domain: "example.org", URLRules: orgRules}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := dss.URLRules("example.org"); !reflect.DeepEqual(got, orgRules) {
+		t.Errorf("Expected saved rules %+v, got %+v", orgRules, got)
+	}
+	if _, err := dss.Delete("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := dss.URLRules("www.example.com"); got != nil {
+		t.Errorf("Expected no rules after deleting them, got %+v", got)
+	}
+}
+
 func TestFetchRange(t *testing.T) {
 	db := getDatabase(t)
 	dss := NewDomainSettingsStorage(db)
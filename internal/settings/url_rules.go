@@ -0,0 +1,54 @@
+package settings
+
+import (
+	"sync"
+
+	"github.com/efixler/scrape/resource"
+)
+
+// The most hostnames to keep url rules for. The cache is cleared when it's full.
+const maxCachedURLRules = 10000
+
+// The url rules for hostnames, including hostnames without rules, so that
+// normalizing a url doesn't look up the settings of each of its parent domains.
+// Since a hostname's rules can come from any of its parents, the whole cache is
+// cleared when any settings change.
+type urlRulesCache struct {
+	mutex      sync.RWMutex
+	rules      map[string]*resource.URLRules
+	generation int
+}
+
+func newURLRulesCache() *urlRulesCache {
+	return &urlRulesCache{rules: make(map[string]*resource.URLRules)}
+}
+
+// The rules for hostname, whether they're cached, and the cache's generation,
+// to pass to put when they aren't.
+func (c *urlRulesCache) get(hostname string) (*resource.URLRules, bool, int) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	rules, ok := c.rules[hostname]
+	return rules, ok, c.generation
+}
+
+// Cache the rules for hostname, unless the cache was cleared since generation,
+// when they were looked up.
+func (c *urlRulesCache) put(hostname string, rules *resource.URLRules, generation int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if len(c.rules) >= maxCachedURLRules {
+		clear(c.rules)
+	}
+	c.rules[hostname] = rules
+}
+
+func (c *urlRulesCache) clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	clear(c.rules)
+	c.generation++
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	nurl "net/url"
+	"time"
+
+	"github.com/efixler/scrape/database"
+)
+
+const (
+	qFetchArchive = `SELECT url, fetch_time, headers, body FROM url_archive WHERE id = ?`
+	qPruneArchive = `DELETE FROM url_archive WHERE expires > 0 AND expires < ?`
+	// Responses archived for a page, under its own key or the keys of the
+	// urls that were requested for it.
+	qDeleteArchives = `DELETE FROM url_archive WHERE id = ? OR id IN (SELECT requested_id FROM id_map WHERE canonical_id = ?)`
+)
+
+var urlArchiveColumns = []string{"id", "url", "fetch_time", "expires", "headers", "body"}
+
+// A raw response, as fetched, for a URL.
+type Archived struct {
+	URL       *nurl.URL
+	FetchTime time.Time
+	Header    http.Header
+	Body      []byte
+}
+
+// ArchiveStore keeps the most recent raw response for each fetched URL,
+// with the body gzip compressed. Archived responses are keyed by the
+// requested URL, and are kept for the store's TTL. Implements fetch.Archiver.
+type ArchiveStore struct {
+	dbh *database.DBHandle
+	ttl time.Duration
+}
+
+// Make an archive store. Archived responses expire after the ttl, and are
+// removed by Prune, which should be run periodically. If the ttl is zero
+// archived responses don't expire.
+func NewArchiveStore(dbh *database.DBHandle, ttl time.Duration) *ArchiveStore {
+	return &ArchiveStore{dbh: dbh, ttl: max(ttl, 0)}
+}
+
+// Archive the headers and body of a response for a URL, replacing any
+// response previously archived for it.
+func (a *ArchiveStore) Archive(url *nurl.URL, header http.Header, body []byte) error {
+	headers, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err = zw.Write(body); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	var expires int64
+	if a.ttl > 0 {
+		expires = now.Add(a.ttl).Unix()
+	}
+	stmt, err := a.dbh.Statement(saveArchive, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, a.dbh.Engine.Dialect().Upsert("url_archive", []string{"id"}, urlArchiveColumns...))
+	})
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(
+		a.dbh.Ctx,
+		Key(url),
+		url.String(),
+		now.Unix(),
+		expires,
+		string(headers),
+		compressed.Bytes(),
+	)
+	return err
+}
+
+// Load the archived response for a URL. The URL must be the one that was
+// requested when the response was archived. Returns ErrResourceNotFound if
+// there's no archived response for the URL, including when a different URL
+// with the same key was archived since.
+func (a *ArchiveStore) Load(url *nurl.URL) (*Archived, error) {
+	stmt, err := a.dbh.Statement(fetchArchive, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, qFetchArchive)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var (
+		archivedUrl string
+		fetchEpoch  int64
+		headers     sql.NullString
+		compressed  []byte
+	)
+	err = stmt.QueryRowContext(a.dbh.Ctx, Key(url)).Scan(&archivedUrl, &fetchEpoch, &headers, &compressed)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil, ErrResourceNotFound
+	default:
+		return nil, err
+	}
+	if archivedUrl != url.String() {
+		return nil, ErrResourceNotFound
+	}
+	archived := &Archived{
+		FetchTime: time.Unix(fetchEpoch, 0).UTC(),
+		Header:    make(http.Header),
+	}
+	if archived.URL, err = nurl.Parse(archivedUrl); err != nil {
+		return nil, err
+	}
+	if headers.Valid {
+		if err = json.Unmarshal([]byte(headers.String), &archived.Header); err != nil {
+			return nil, err
+		}
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	if archived.Body, err = io.ReadAll(zr); err != nil {
+		return nil, err
+	}
+	return archived, nil
+}
+
+// Remove expired archived responses. Returns the number of responses removed.
+func (a *ArchiveStore) Prune() (int64, error) {
+	result, err := a.dbh.DB.ExecContext(a.dbh.Ctx, qPruneArchive, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
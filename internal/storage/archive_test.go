@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"errors"
+	"net/http"
+	nurl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/fetch"
+)
+
+func getArchiveStore(t *testing.T, ttl time.Duration) *ArchiveStore {
+	return NewArchiveStore(getURLDataStore(t).dbh, ttl)
+}
+
+func TestArchive(t *testing.T) {
+	a := getArchiveStore(t, 0)
+	url, _ := nurl.Parse("https://example.com/archived")
+	if _, err := a.Load(url); !errors.Is(err, ErrResourceNotFound) {
+		t.Fatalf("Expected ErrResourceNotFound before archiving, got %v", err)
+	}
+	header := http.Header{"Content-Type": {"text/html; charset=utf-8"}, "Set-Cookie": {"a=1", "b=2"}}
+	for _, body := range []string{"<html>first</html>", strings.Repeat("<p>second</p>", 1000)} {
+		if err := a.Archive(url, header, []byte(body)); err != nil {
+			t.Fatalf("Error archiving: %v", err)
+		}
+		archived, err := a.Load(url)
+		if err != nil {
+			t.Fatalf("Error loading archive: %v", err)
+		}
+		if string(archived.Body) != body {
+			t.Errorf("Expected body %q, got %q", body, archived.Body)
+		}
+		if archived.URL.String() != url.String() {
+			t.Errorf("Expected url %s, got %s", url, archived.URL)
+		}
+		if archived.Header.Get("Content-Type") != "text/html; charset=utf-8" || len(archived.Header.Values("Set-Cookie")) != 2 {
+			t.Errorf("Expected headers %v, got %v", header, archived.Header)
+		}
+		if time.Since(archived.FetchTime) > time.Minute {
+			t.Errorf("Expected a recent fetch time, got %v", archived.FetchTime)
+		}
+	}
+}
+
+func TestPruneArchive(t *testing.T) {
+	a := getArchiveStore(t, time.Hour)
+	for _, u := range []string{"https://example.com/old", "https://example.com/new"} {
+		url, _ := nurl.Parse(u)
+		if err := a.Archive(url, http.Header{}, []byte(u)); err != nil {
+			t.Fatalf("Error archiving %s: %v", u, err)
+		}
+	}
+	old, _ := nurl.Parse("https://example.com/old")
+	_, err := a.dbh.DB.Exec("UPDATE url_archive SET expires = ? WHERE id = ?", time.Now().Add(-time.Minute).Unix(), Key(old))
+	if err != nil {
+		t.Fatalf("Error expiring archive: %v", err)
+	}
+	pruned, err := a.Prune()
+	if err != nil {
+		t.Fatalf("Error pruning archive: %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("Expected 1 archive pruned, got %d", pruned)
+	}
+	if _, err := a.Load(old); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("Expected pruned archive to be gone, got %v", err)
+	}
+	newer, _ := nurl.Parse("https://example.com/new")
+	if _, err := a.Load(newer); err != nil {
+		t.Errorf("Expected unexpired archive to remain, got %v", err)
+	}
+}
+
+func TestArchiveKeyCollision(t *testing.T) {
+	a := getArchiveStore(t, 0)
+	url, _ := nurl.Parse("https://example.com/archived")
+	if err := a.Archive(url, http.Header{}, []byte("<html></html>")); err != nil {
+		t.Fatalf("Error archiving: %v", err)
+	}
+	// a different url archived under the same key
+	_, err := a.dbh.DB.ExecContext(
+		a.dbh.Ctx,
+		`UPDATE url_archive SET url = ? WHERE id = ?`,
+		"https://example.com/other",
+		Key(url),
+	)
+	if err != nil {
+		t.Fatalf("Error updating archived url: %v", err)
+	}
+	if _, err := a.Load(url); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("Expected ErrResourceNotFound for another url's response, got %v", err)
+	}
+}
+
+func TestDeleteRemovesArchivesAndRenders(t *testing.T) {
+	s := getURLDataStore(t)
+	a := NewArchiveStore(s.dbh, 0)
+	rs := NewRenderStore(s.dbh, 0)
+	page := getWebPage(t)
+	if _, err := s.Save(page); err != nil {
+		t.Fatalf("Error storing page: %v", err)
+	}
+	alias, _ := nurl.Parse(page.CanonicalURL.String() + "?from=feed")
+	if err := s.SaveAliases(page.CanonicalURL, []uint64{Key(alias)}); err != nil {
+		t.Fatalf("Error saving alias: %v", err)
+	}
+	other, _ := nurl.Parse("https://example.com/other")
+	urls := []*nurl.URL{page.CanonicalURL, page.RequestedURL, alias, other}
+	for _, url := range urls {
+		if err := a.Archive(url, http.Header{}, []byte("<html></html>")); err != nil {
+			t.Fatalf("Error archiving %s: %v", url, err)
+		}
+		if _, err := rs.Save(url, fetch.Screenshot, []byte("\x89PNG")); err != nil {
+			t.Fatalf("Error saving render of %s: %v", url, err)
+		}
+	}
+	if ok, err := s.Delete(page.RequestedURL); err != nil || !ok {
+		t.Fatalf("Expected page to be deleted, got %t, %v", ok, err)
+	}
+	for _, url := range urls[:3] {
+		if _, err := a.Load(url); !errors.Is(err, ErrResourceNotFound) {
+			t.Errorf("Expected archived response for %s to be deleted, got %v", url, err)
+		}
+		if _, err := rs.Load(url, fetch.Screenshot); !errors.Is(err, ErrResourceNotFound) {
+			t.Errorf("Expected render of %s to be deleted, got %v", url, err)
+		}
+	}
+	if _, err := a.Load(other); err != nil {
+		t.Errorf("Expected archived response for another url to be kept, got %v", err)
+	}
+	if _, err := rs.Load(other, fetch.Screenshot); err != nil {
+		t.Errorf("Expected render of another url to be kept, got %v", err)
+	}
+}
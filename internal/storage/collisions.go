@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	nurl "net/url"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	qStoredURLs = `SELECT url, parsed_url, expires FROM urls WHERE id = ?`
+	qScanKeys   = `SELECT id, url FROM urls WHERE id > ? ORDER BY id LIMIT ?`
+	scanBatch   = 1000
+)
+
+var (
+	ErrKeyCollision = errors.New("url key is taken by a different url")
+	// Counts the key collisions found when storing and looking up urls,
+	// across all stores.
+	keyCollisions atomic.Uint64
+)
+
+type StorageStats struct {
+	KeyCollisions uint64 `json:"key_collisions"`
+}
+
+// Stats for the storage layer, for healthchecks. KeyCollisions counts
+// the times a url's key was found to be taken by a different url since
+// the process started.
+func Stats() (any, error) {
+	return &StorageStats{KeyCollisions: keyCollisions.Load()}, nil
+}
+
+func recordCollision(url *nurl.URL, key uint64, other string) {
+	keyCollisions.Add(1)
+	slog.Warn("storage: key collision", "url", url, "key", key, "other_url", other)
+}
+
+// The canonical and requested urls of a stored page.
+type storedURLs struct {
+	canonical string
+	requested string
+	expires   time.Time
+}
+
+// Returns nil if there's no page stored at key.
+func (s URLDataStore) storedURLs(key uint64) (*storedURLs, error) {
+	stmt, err := s.storedURLsStmt()
+	if err != nil {
+		return nil, err
+	}
+	return s.lookupStoredURLs(stmt, key)
+}
+
+func (s URLDataStore) storedURLsStmt() (*sql.Stmt, error) {
+	return s.dbh.Statement(lookupStoredURLs, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, qStoredURLs)
+	})
+}
+
+// Like storedURLs, with a statement from storedURLsStmt, which can be bound to
+// a transaction.
+func (s URLDataStore) lookupStoredURLs(stmt *sql.Stmt, key uint64) (*storedURLs, error) {
+	var (
+		su      storedURLs
+		expires int64
+	)
+	err := stmt.QueryRowContext(s.dbh.Ctx, key).Scan(&su.canonical, &su.requested, &expires)
+	switch err {
+	case nil:
+		su.expires = time.Unix(expires, 0)
+		return &su, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// Reports whether the page stored at key, which url was looked up with
+// requestedKey, belongs to a different url with the same key.
+func (su storedURLs) collides(url *nurl.URL, keyFn func(URLWithHostname) uint64, requestedKey, key uint64) bool {
+	u := url.String()
+	if u == su.canonical || u == su.requested {
+		return false
+	}
+	// The url isn't mapped to another page, so the page should be its own.
+	if requestedKey == key {
+		return true
+	}
+	// The url is mapped to the page, as one of its aliases, unless one of the
+	// page's own urls has the same key.
+	return keyFn(URLString(su.canonical)) == requestedKey || keyFn(URLString(su.requested)) == requestedKey
+}
+
+// Find the key of the stored page for url, trying each key scheme in turn
+// until the url resolves to a page that isn't another url's. load gets the
+// urls of the page stored at a key, or nil if there isn't one. If no page is
+// stored for url, the key of the first scheme is returned.
+// Returns ErrResourceNotFound if the url collides with a different url for
+// every key scheme.
+func (s URLDataStore) resolve(url *nurl.URL, load func(key uint64) (*storedURLs, error)) (uint64, error) {
+	for _, keyFn := range keySchemes {
+		requestedKey := keyFn(url)
+		key, err := s.lookupId(requestedKey)
+		switch err {
+		case nil:
+		case ErrMappingNotFound:
+			key = requestedKey
+		default:
+			return 0, err
+		}
+		su, err := load(key)
+		if err != nil {
+			return 0, err
+		}
+		if su == nil || !su.collides(url, keyFn, requestedKey, key) {
+			return key, nil
+		}
+		recordCollision(url, key, su.canonical)
+	}
+	return 0, ErrResourceNotFound
+}
+
+// The key to store a page for a canonical url under: the first key that isn't
+// taken by an unexpired page for a different url. replaces is true when the
+// key holds an expired page for a different url, which the new page will
+// replace. The stored pages are read with lookup, a statement from
+// storedURLsStmt.
+func (s *URLDataStore) storageKey(lookup *sql.Stmt, canonical *nurl.URL) (key uint64, replaces bool, err error) {
+	for _, keyFn := range keySchemes {
+		key = keyFn(canonical)
+		su, err := s.lookupStoredURLs(lookup, key)
+		if err != nil {
+			return 0, false, err
+		}
+		switch {
+		case su == nil, su.canonical == canonical.String():
+			return key, false, nil
+		case time.Now().After(su.expires):
+			return key, true, nil
+		}
+		recordCollision(canonical, key, su.canonical)
+	}
+	return 0, false, ErrKeyCollision
+}
+
+// The key to map a requested url to its stored page from: the first key that
+// isn't already mapped to the page for a different url with the same key.
+// Mappings and stored pages are read with the statements from mappingStmts.
+func (s *URLDataStore) mappingKey(ms *mappingStmts, requested *nurl.URL, canonicalKey uint64) (uint64, error) {
+	for _, keyFn := range keySchemes {
+		requestedKey := keyFn(requested)
+		key, err := s.mappedId(ms.lookupId, requestedKey)
+		switch {
+		case err == ErrMappingNotFound, err == nil && key == canonicalKey:
+			return requestedKey, nil
+		case err != nil:
+			return 0, err
+		}
+		su, err := s.lookupStoredURLs(ms.storedURLs, key)
+		if err != nil {
+			return 0, err
+		}
+		if su == nil || !su.collides(requested, keyFn, requestedKey, key) {
+			return requestedKey, nil
+		}
+		recordCollision(requested, requestedKey, su.canonical)
+	}
+	return 0, ErrKeyCollision
+}
+
+// A stored page whose url shares its key with another stored page.
+type KeyCollision struct {
+	ID       uint64 `json:"id"`
+	URL      string `json:"url"`
+	OtherID  uint64 `json:"other_id,omitempty"`
+	OtherURL string `json:"other_url,omitempty"`
+}
+
+// The results of scanning stored pages for key collisions. Collisions are
+// pages stored under a secondary key because another url had their primary
+// key. Mismatches are pages stored under a key that isn't one of their url's
+// keys, which happens when a url's key was taken before collisions were handled.
+type KeyScan struct {
+	Examined   int            `json:"examined"`
+	Collisions []KeyCollision `json:"collisions"`
+	Mismatches []KeyCollision `json:"mismatches"`
+}
+
+// Scan all of the stored pages for key collisions. This reads the whole urls
+// table, in batches, so it's intended to be run as part of maintenance.
+func (s *URLDataStore) ScanKeys() (*KeyScan, error) {
+	scan := &KeyScan{
+		Collisions: make([]KeyCollision, 0),
+		Mismatches: make([]KeyCollision, 0),
+	}
+	var lastID any = -1
+	for {
+		batch, err := s.keyBatch(lastID)
+		if err != nil {
+			return scan, err
+		}
+		if len(batch) == 0 {
+			return scan, nil
+		}
+		for _, kc := range batch {
+			scan.Examined++
+			switch scheme := keyScheme(URLString(kc.URL), kc.ID); {
+			case scheme == 0:
+			case scheme > 0:
+				kc.OtherID = keySchemes[0](URLString(kc.URL))
+				if su, err := s.storedURLs(kc.OtherID); err != nil {
+					return scan, err
+				} else if su != nil {
+					kc.OtherURL = su.canonical
+				}
+				scan.Collisions = append(scan.Collisions, kc)
+			default:
+				scan.Mismatches = append(scan.Mismatches, kc)
+			}
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
+// The index of the key scheme that makes key for url, or -1 if none of them do.
+func keyScheme(url URLWithHostname, key uint64) int {
+	for i, keyFn := range keySchemes {
+		if keyFn(url) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *URLDataStore) keyBatch(afterID any) ([]KeyCollision, error) {
+	rows, err := s.dbh.DB.QueryContext(s.dbh.Ctx, qScanKeys, afterID, scanBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	batch := make([]KeyCollision, 0, scanBatch)
+	for rows.Next() {
+		var kc KeyCollision
+		if err = rows.Scan(&kc.ID, &kc.URL); err != nil {
+			return nil, err
+		}
+		batch = append(batch, kc)
+	}
+	return batch, rows.Err()
+}
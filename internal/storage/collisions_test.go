@@ -0,0 +1,195 @@
+package storage
+
+import (
+	nurl "net/url"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/resource"
+)
+
+// Make every url's primary key the same, so that urls collide, until the test
+// finishes.
+func withCollidingKeys(t *testing.T) {
+	schemes := keySchemes
+	keySchemes = []func(URLWithHostname) uint64{
+		func(URLWithHostname) uint64 { return 42 },
+		Key,
+	}
+	t.Cleanup(func() { keySchemes = schemes })
+}
+
+func pageFor(t *testing.T, canonical, requested string) *resource.WebPage {
+	page := getWebPage(t)
+	page.CanonicalURL, _ = nurl.Parse(canonical)
+	page.RequestedURL, _ = nurl.Parse(requested)
+	return page
+}
+
+func TestSecondaryKey(t *testing.T) {
+	tests := []string{
+		"https://example.com/a",
+		"http://x.y/z?q=1",
+		"https://martinfowler.com/aboutMe.html",
+	}
+	for _, url := range tests {
+		u := URLString(url)
+		key, secondary := Key(u), SecondaryKey(u)
+		if key == secondary {
+			t.Errorf("[%s] Expected secondary key to differ from key %d", url, key)
+		}
+		if secondary&(1<<63) != 0 {
+			t.Errorf("[%s] Expected top bit of secondary key to be unset, got %b", url, secondary)
+		}
+		if secondary&CHECKSUM_MASK != key&CHECKSUM_MASK {
+			t.Errorf("[%s] Expected secondary key to have the same domain checksum as key", url)
+		}
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	withCollidingKeys(t)
+	s := getURLDataStore(t)
+	before := keyCollisions.Load()
+
+	a := pageFor(t, "https://example.com/a", "https://example.com/a")
+	if key, err := s.Save(a); err != nil {
+		t.Fatalf("Error saving first page: %v", err)
+	} else if key != 42 {
+		t.Errorf("Expected first page to be stored under its primary key, got %d", key)
+	}
+	b := pageFor(t, "https://example.com/b", "https://example.com/b")
+	if _, err := s.Fetch(b.CanonicalURL); err != ErrResourceNotFound {
+		t.Errorf("Expected a colliding url not to be found, got %v", err)
+	}
+	if keyCollisions.Load() == before {
+		t.Errorf("Expected the collision to be counted")
+	}
+	if key, err := s.Save(b); err != nil {
+		t.Fatalf("Error saving colliding page: %v", err)
+	} else if key != Key(b.CanonicalURL) {
+		t.Errorf("Expected colliding page to be stored under its secondary key, got %d", key)
+	}
+	c := pageFor(t, "https://example.com/c", "https://example.com/c?from=feed")
+	if _, err := s.Save(c); err != nil {
+		t.Fatalf("Error saving colliding page with a requested url: %v", err)
+	}
+
+	for _, page := range []*resource.WebPage{a, b, c} {
+		for _, url := range []*nurl.URL{page.CanonicalURL, page.RequestedURL} {
+			fetched, err := s.Fetch(url)
+			if err != nil {
+				t.Fatalf("Error fetching %s: %v", url, err)
+			}
+			if fetched.CanonicalURL.String() != page.CanonicalURL.String() {
+				t.Errorf("Fetching %s, expected %s, got %s", url, page.CanonicalURL, fetched.CanonicalURL)
+			}
+		}
+	}
+	if aliases, err := s.Aliases(c.CanonicalURL); err != nil {
+		t.Errorf("Error listing aliases: %v", err)
+	} else if len(aliases) != 1 || aliases[0] != Key(c.RequestedURL) {
+		t.Errorf("Expected the requested url's secondary key as the alias, got %v", aliases)
+	}
+
+	if ok, err := s.Delete(b.CanonicalURL); err != nil || !ok {
+		t.Fatalf("Error deleting colliding page: %t, %v", ok, err)
+	}
+	if _, err := s.Fetch(b.CanonicalURL); err != ErrResourceNotFound {
+		t.Errorf("Expected deleted page not to be found, got %v", err)
+	}
+	if _, err := s.Fetch(a.CanonicalURL); err != nil {
+		t.Errorf("Expected page under the primary key to survive the delete, got %v", err)
+	}
+	if ok, err := s.Delete(b.CanonicalURL); err != nil || ok {
+		t.Errorf("Expected deleting a missing colliding page to return false, got %t, %v", ok, err)
+	}
+}
+
+func TestSaveReplacesExpiredCollision(t *testing.T) {
+	withCollidingKeys(t)
+	s := getURLDataStore(t)
+	s = NewURLDataStore(s.dbh, WithHistory(5, 0))
+	expired := pageFor(t, "https://example.com/old", "https://example.com/old?from=feed")
+	fetchTime := time.Now().Add(-2 * time.Hour)
+	expired.FetchTime = &fetchTime
+	expired.TTL = time.Hour
+	if _, err := s.Save(expired); err != nil {
+		t.Fatalf("Error saving expired page: %v", err)
+	}
+	page := pageFor(t, "https://example.com/new", "https://example.com/new")
+	if key, err := s.Save(page); err != nil {
+		t.Fatalf("Error saving page: %v", err)
+	} else if key != 42 {
+		t.Errorf("Expected the expired page's key to be reused, got %d", key)
+	}
+	if _, err := s.Fetch(expired.RequestedURL); err != ErrResourceNotFound {
+		t.Errorf("Expected the expired page's alias not to resolve to the new page, got %v", err)
+	}
+	if fetched, err := s.Fetch(page.CanonicalURL); err != nil {
+		t.Errorf("Error fetching page: %v", err)
+	} else if fetched.CanonicalURL.String() != page.CanonicalURL.String() {
+		t.Errorf("Expected %s, got %s", page.CanonicalURL, fetched.CanonicalURL)
+	}
+	// the expired page's history went with it
+	if versions, err := s.Versions(page.CanonicalURL); err != nil {
+		t.Errorf("Error listing versions: %v", err)
+	} else if len(versions) != 1 || versions[0].Version != page.FetchTime.Unix() {
+		t.Errorf("Expected only the new page's version, got %+v", versions)
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	withCollidingKeys(t)
+	s := getURLDataStore(t)
+	pages := []*resource.WebPage{
+		pageFor(t, "https://example.com/a", "https://example.com/a"),
+		pageFor(t, "https://example.com/b", "https://example.com/b"),
+		pageFor(t, "https://example.com/c", "https://example.com/c"),
+	}
+	for _, page := range pages {
+		if _, err := s.Save(page); err != nil {
+			t.Fatalf("Error saving %s: %v", page.CanonicalURL, err)
+		}
+	}
+	// Move a page to a key that isn't one of its url's keys
+	_, err := s.dbh.DB.ExecContext(s.dbh.Ctx, `UPDATE urls SET id = ? WHERE id = ?`, 7, Key(pages[2].CanonicalURL))
+	if err != nil {
+		t.Fatalf("Error moving page: %v", err)
+	}
+
+	scan, err := s.ScanKeys()
+	if err != nil {
+		t.Fatalf("Error scanning keys: %v", err)
+	}
+	if scan.Examined != 3 {
+		t.Errorf("Expected 3 pages examined, got %d", scan.Examined)
+	}
+	if len(scan.Collisions) != 1 {
+		t.Fatalf("Expected 1 collision, got %+v", scan.Collisions)
+	}
+	expected := KeyCollision{
+		ID:       Key(pages[1].CanonicalURL),
+		URL:      pages[1].CanonicalURL.String(),
+		OtherID:  42,
+		OtherURL: pages[0].CanonicalURL.String(),
+	}
+	if scan.Collisions[0] != expected {
+		t.Errorf("Expected collision %+v, got %+v", expected, scan.Collisions[0])
+	}
+	if len(scan.Mismatches) != 1 || scan.Mismatches[0].ID != 7 {
+		t.Errorf("Expected a mismatch at key 7, got %+v", scan.Mismatches)
+	}
+}
+
+func TestStats(t *testing.T) {
+	before := keyCollisions.Load()
+	recordCollision(&nurl.URL{Scheme: "https", Host: "example.com"}, 42, "https://example.com/other")
+	stats, err := Stats()
+	if err != nil {
+		t.Fatalf("Error getting stats: %v", err)
+	}
+	if got := stats.(*StorageStats).KeyCollisions; got != before+1 {
+		t.Errorf("Expected %d key collisions, got %d", before+1, got)
+	}
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/efixler/scrape/database"
+)
+
+const (
+	compressBatchSize = 100
+	qContentBatch     = `SELECT id, 0, content_text FROM urls WHERE id > ? ORDER BY id LIMIT ?`
+	qCompressContent  = `UPDATE urls SET content_text = ?, content_size = ? WHERE id = ?`
+	qHistoryBatch     = `SELECT id, fetch_time, content_text FROM url_history WHERE id > ? OR (id = ? AND fetch_time > ?) ORDER BY id, fetch_time LIMIT ?`
+	qCompressVersion  = `UPDATE url_history SET content_text = ? WHERE id = ? AND fetch_time = ?`
+)
+
+// The outcome of compressing stored content. Examined and Compressed count
+// pages, and VersionsExamined and VersionsCompressed count previous versions
+// of pages. Sizes are in bytes, and are only for the content that was
+// compressed.
+type CompressionSummary struct {
+	Examined           int   `json:"examined"`
+	Compressed         int   `json:"compressed"`
+	VersionsExamined   int   `json:"versions_examined"`
+	VersionsCompressed int   `json:"versions_compressed"`
+	BytesBefore        int64 `json:"bytes_before"`
+	BytesAfter         int64 `json:"bytes_after"`
+}
+
+type storedContent struct {
+	id        uint64
+	fetchTime int64
+	content   []byte
+}
+
+// Compress the content text of stored pages, and of previous versions of
+// pages, that was saved before content was compressed. Content that's
+// already compressed, or too short to be worth compressing, is left as-is.
+// Content is compressed in batches, each in its own transaction, so this
+// can be safely interrupted and run again.
+func (s *URLDataStore) CompressContent() (*CompressionSummary, error) {
+	summary := &CompressionSummary{}
+	var lastID any = -1
+	for {
+		batch, err := s.contentBatch(qContentBatch, lastID, compressBatchSize)
+		if err != nil {
+			return summary, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		examined, compressed, err := s.compressBatch(batch, summary, func(tx *sql.Tx, sc storedContent, content []byte) error {
+			_, err := tx.ExecContext(s.dbh.Ctx, qCompressContent, content, len(sc.content), sc.id)
+			return err
+		})
+		if err != nil {
+			return summary, err
+		}
+		summary.Examined += examined
+		summary.Compressed += compressed
+		lastID = batch[len(batch)-1].id
+	}
+	lastID = -1
+	var lastFetchTime int64
+	for {
+		batch, err := s.contentBatch(qHistoryBatch, lastID, lastID, lastFetchTime, compressBatchSize)
+		if err != nil {
+			return summary, err
+		}
+		if len(batch) == 0 {
+			return summary, nil
+		}
+		examined, compressed, err := s.compressBatch(batch, summary, func(tx *sql.Tx, sc storedContent, content []byte) error {
+			_, err := tx.ExecContext(s.dbh.Ctx, qCompressVersion, content, sc.id, sc.fetchTime)
+			return err
+		})
+		if err != nil {
+			return summary, err
+		}
+		summary.VersionsExamined += examined
+		summary.VersionsCompressed += compressed
+		lastID, lastFetchTime = batch[len(batch)-1].id, batch[len(batch)-1].fetchTime
+	}
+}
+
+// Batches are read in full before they're compressed, so that a connection
+// isn't held open for the read while the updates are made.
+func (s *URLDataStore) contentBatch(query string, args ...any) ([]storedContent, error) {
+	rows, err := s.dbh.DB.QueryContext(s.dbh.Ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	batch := make([]storedContent, 0, compressBatchSize)
+	for rows.Next() {
+		var sc storedContent
+		if err = rows.Scan(&sc.id, &sc.fetchTime, &sc.content); err != nil {
+			return nil, err
+		}
+		batch = append(batch, sc)
+	}
+	return batch, rows.Err()
+}
+
+// Compress the content in a batch, saving it with update. Returns the number
+// of rows examined and compressed; the byte counts in summary are only
+// updated if the batch is committed.
+func (s *URLDataStore) compressBatch(
+	batch []storedContent,
+	summary *CompressionSummary,
+	update func(*sql.Tx, storedContent, []byte) error,
+) (int, int, error) {
+	tx, err := s.dbh.DB.BeginTx(s.dbh.Ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	var before, after int64
+	compressed := 0
+	for _, sc := range batch {
+		if database.IsCompressedText(sc.content) {
+			continue
+		}
+		content, err := database.CompressText(string(sc.content))
+		if err != nil {
+			return 0, 0, err
+		}
+		if !database.IsCompressedText(content) {
+			continue
+		}
+		if err = update(tx, sc, content); err != nil {
+			return 0, 0, err
+		}
+		compressed++
+		before += int64(len(sc.content))
+		after += int64(len(content))
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	summary.BytesBefore += before
+	summary.BytesAfter += after
+	return len(batch), compressed, nil
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	nurl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/database"
+)
+
+func storedContentText(t *testing.T, store *URLDataStore, url *nurl.URL) []byte {
+	var content []byte
+	err := store.dbh.DB.QueryRowContext(
+		store.dbh.Ctx,
+		`SELECT content_text FROM urls WHERE id = ?`,
+		Key(url),
+	).Scan(&content)
+	if err != nil {
+		t.Fatalf("Error loading stored content for %s: %v", url, err)
+	}
+	return content
+}
+
+func TestContentIsCompressed(t *testing.T) {
+	store := getURLDataStore(t)
+	tests := []struct {
+		name       string
+		text       string
+		compressed bool
+	}{
+		{"long", strings.Repeat("All work and no play makes Jack a dull boy. ", 100), true},
+		{"short", "Too short to compress", false},
+		{"empty", "", false},
+	}
+	for _, test := range tests {
+		page := getWebPage(t)
+		page.CanonicalURL, _ = nurl.Parse("https://example.com/" + test.name)
+		page.RequestedURL = page.CanonicalURL
+		page.ContentText = test.text
+		if _, err := store.Save(page); err != nil {
+			t.Fatalf("[%s] Error saving page: %v", test.name, err)
+		}
+		content := storedContentText(t, store, page.CanonicalURL)
+		if database.IsCompressedText(content) != test.compressed {
+			t.Errorf("[%s] Expected compressed %t, got %t", test.name, test.compressed, !test.compressed)
+		}
+		if test.compressed && len(content) >= len(test.text) {
+			t.Errorf("[%s] Expected compressed content to be smaller, got %d bytes for %d", test.name, len(content), len(test.text))
+		}
+		fetched, err := store.Fetch(page.CanonicalURL)
+		if err != nil {
+			t.Fatalf("[%s] Error fetching page: %v", test.name, err)
+		}
+		if fetched.ContentText != test.text {
+			t.Errorf("[%s] Fetched content text doesn't match the saved text", test.name)
+		}
+	}
+}
+
+func TestCompressContent(t *testing.T) {
+	store := getURLDataStore(t)
+	long := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100)
+	urls := make([]*nurl.URL, 0)
+	for i, text := range []string{long, long + "again", "short"} {
+		page := getWebPage(t)
+		page.CanonicalURL, _ = nurl.Parse("https://example.com/page/" + string(rune('a'+i)))
+		page.RequestedURL = page.CanonicalURL
+		page.ContentText = text
+		if _, err := store.Save(page); err != nil {
+			t.Fatalf("Error saving page: %v", err)
+		}
+		// store the content the way it was stored before compression
+		_, err := store.dbh.DB.ExecContext(
+			store.dbh.Ctx,
+			`UPDATE urls SET content_text = ? WHERE id = ?`,
+			[]byte(text),
+			Key(page.CanonicalURL),
+		)
+		if err != nil {
+			t.Fatalf("Error uncompressing content: %v", err)
+		}
+		urls = append(urls, page.CanonicalURL)
+	}
+	uncompressed, _ := store.Fetch(urls[0])
+	if uncompressed.ContentText != long {
+		t.Errorf("Expected uncompressed content to be readable")
+	}
+
+	summary, err := store.CompressContent()
+	if err != nil {
+		t.Fatalf("Error compressing content: %v", err)
+	}
+	if summary.Examined != 3 || summary.Compressed != 2 {
+		t.Errorf("Expected 3 pages examined and 2 compressed, got %+v", summary)
+	}
+	if summary.BytesAfter >= summary.BytesBefore {
+		t.Errorf("Expected compressed content to be smaller, got %+v", summary)
+	}
+	for i, url := range urls[:2] {
+		if !database.IsCompressedText(storedContentText(t, store, url)) {
+			t.Errorf("[%d] Expected content to be compressed", i)
+		}
+	}
+	page, err := store.Fetch(urls[0])
+	if err != nil {
+		t.Fatalf("Error fetching compressed page: %v", err)
+	}
+	if page.ContentText != long {
+		t.Errorf("Compressed content text doesn't match the original")
+	}
+
+	summary, err = store.CompressContent()
+	if err != nil {
+		t.Fatalf("Error compressing content again: %v", err)
+	}
+	if summary.Compressed != 0 {
+		t.Errorf("Expected nothing to compress on the second run, got %+v", summary)
+	}
+}
+
+func TestHistoryIsCompressed(t *testing.T) {
+	store := getURLDataStore(t)
+	store = NewURLDataStore(store.dbh, WithHistory(5, 0))
+	long := strings.Repeat("Versions are compressed like pages are. ", 100)
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	page := saveVersionOf(t, store, long, base)
+	saveVersionOf(t, store, long+"again", base.Add(time.Minute))
+
+	var content []byte
+	err := store.dbh.DB.QueryRowContext(
+		store.dbh.Ctx,
+		`SELECT content_text FROM url_history WHERE id = ? AND fetch_time = ?`,
+		Key(page.CanonicalURL),
+		base.Unix(),
+	).Scan(&content)
+	if err != nil {
+		t.Fatalf("Error loading stored version: %v", err)
+	}
+	if !database.IsCompressedText(content) {
+		t.Errorf("Expected version content to be compressed")
+	}
+	version, err := store.Version(page.CanonicalURL, base.Unix())
+	if err != nil {
+		t.Fatalf("Error fetching version: %v", err)
+	}
+	if version.ContentText != long {
+		t.Errorf("Version content text doesn't match the saved text")
+	}
+
+	// store the versions the way they were stored before compression
+	_, err = store.dbh.DB.ExecContext(
+		store.dbh.Ctx,
+		`UPDATE url_history SET content_text = ? WHERE fetch_time = ?`,
+		[]byte(long),
+		base.Unix(),
+	)
+	if err != nil {
+		t.Fatalf("Error uncompressing version: %v", err)
+	}
+	if version, _ = store.Version(page.CanonicalURL, base.Unix()); version.ContentText != long {
+		t.Errorf("Expected uncompressed version to be readable")
+	}
+	summary, err := store.CompressContent()
+	if err != nil {
+		t.Fatalf("Error compressing content: %v", err)
+	}
+	if summary.VersionsExamined != 2 || summary.VersionsCompressed != 1 {
+		t.Errorf("Expected 2 versions examined and 1 compressed, got %+v", summary)
+	}
+	if version, _ = store.Version(page.CanonicalURL, base.Unix()); version.ContentText != long {
+		t.Errorf("Compressed version content text doesn't match the original")
+	}
+}
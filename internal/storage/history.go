@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	nurl "net/url"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/resource"
+)
+
+const (
+	qLatestChecksum = `SELECT checksum FROM url_history WHERE id = ? ORDER BY fetch_time DESC LIMIT 1`
+	qVersionCutoff  = `SELECT fetch_time FROM url_history WHERE id = ? ORDER BY fetch_time DESC LIMIT 1 OFFSET ?`
+	qTrimVersions   = `DELETE FROM url_history WHERE id = ? AND fetch_time <= ?`
+	// Takes the dialect's expression for the title in the metadata column.
+	qListVersions  = `SELECT fetch_time, fetch_method, %s FROM url_history WHERE id = ? ORDER BY fetch_time DESC`
+	qFetchVersion  = `SELECT url, fetch_time, fetch_method, metadata, content_text FROM url_history WHERE id = ? AND fetch_time = ?`
+	qDeleteHistory = `DELETE FROM url_history WHERE id = ?`
+	qPruneHistory  = `DELETE FROM url_history WHERE fetch_time < ?`
+)
+
+var urlHistoryColumns = []string{"id", "fetch_time", "checksum", "url", "fetch_method", "metadata", "content_text"}
+
+var ErrVersionNotFound = errors.New("version not found in data store")
+
+// Keep the previous versions of stored content. Versions beyond maxVersions
+// for a URL, and versions fetched longer ago than maxAge, are removed.
+// Either limit can be zero, in which case it's not applied, but at least one
+// must be set to enable history.
+//
+// A new version is only recorded when a page's title or content changes.
+// Versions older than maxAge are removed by PruneHistory, which should be
+// run periodically.
+func WithHistory(maxVersions int, maxAge time.Duration) option {
+	return func(s *URLDataStore) {
+		s.historyVersions = max(maxVersions, 0)
+		s.historyMaxAge = max(maxAge, 0)
+	}
+}
+
+// A summary of a stored version of a page. Version identifies the
+// version in calls to Version, and is the Unix time when the version was
+// fetched.
+type Version struct {
+	Version     int64                     `json:"version"`
+	FetchTime   time.Time                 `json:"fetch_time"`
+	FetchMethod resource.ClientIdentifier `json:"fetch_method,omitempty"`
+	Title       string                    `json:"title,omitempty"`
+}
+
+type VersionStore interface {
+	Versions(*nurl.URL) ([]Version, error)
+	Version(*nurl.URL, int64) (*resource.WebPage, error)
+}
+
+// HistoryEnabled reports whether previous versions of content are kept.
+func (s *URLDataStore) HistoryEnabled() bool {
+	return s.historyVersions > 0 || s.historyMaxAge > 0
+}
+
+// The statements that record a version of a page. They're prepared before
+// Save's transaction is started, like the statements from clearKeyStmts.
+type versionStmts struct {
+	latest *sql.Stmt
+	save   *sql.Stmt
+	cutoff *sql.Stmt
+	trim   *sql.Stmt
+}
+
+// Returns nil when history isn't enabled.
+func (s *URLDataStore) versionStmts() (*versionStmts, error) {
+	if !s.HistoryEnabled() {
+		return nil, nil
+	}
+	var (
+		vs  versionStmts
+		err error
+	)
+	vs.latest, err = s.dbh.Statement(latestChecksum, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, qLatestChecksum)
+	})
+	if err != nil {
+		return nil, err
+	}
+	vs.save, err = s.dbh.Statement(saveVersion, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(
+			ctx,
+			s.dbh.Engine.Dialect().Upsert("url_history", []string{"id", "fetch_time"}, urlHistoryColumns...),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	vs.cutoff, err = s.dbh.Statement(versionCutoff, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, qVersionCutoff)
+	})
+	if err != nil {
+		return nil, err
+	}
+	vs.trim, err = s.dbh.Statement(trimVersions, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, qTrimVersions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &vs, nil
+}
+
+// Record a version of a page in tx, with the statements from versionStmts,
+// unless its content is unchanged from the most recent version, then trim the
+// history for the page to the configured number of versions. This is a no-op
+// when history isn't enabled.
+func (s *URLDataStore) recordVersion(tx *sql.Tx, vs *versionStmts, key uint64, page *resource.WebPage, metadata string) error {
+	if vs == nil {
+		return nil
+	}
+	checksum := contentChecksum(page)
+	var latest int64
+	switch err := tx.StmtContext(s.dbh.Ctx, vs.latest).QueryRowContext(s.dbh.Ctx, key).Scan(&latest); err {
+	case nil:
+		if latest == checksum {
+			return nil
+		}
+	case sql.ErrNoRows:
+		// first version
+	default:
+		return err
+	}
+	content, err := database.CompressText(page.ContentText)
+	if err != nil {
+		return err
+	}
+	_, err = tx.StmtContext(s.dbh.Ctx, vs.save).ExecContext(
+		s.dbh.Ctx,
+		key,
+		page.FetchTime.Unix(),
+		checksum,
+		page.CanonicalURL.String(),
+		int(page.FetchMethod),
+		metadata,
+		content,
+	)
+	if err != nil {
+		return err
+	}
+	return s.trimVersions(tx, vs, key)
+}
+
+func (s *URLDataStore) trimVersions(tx *sql.Tx, vs *versionStmts, key uint64) error {
+	if s.historyVersions <= 0 {
+		return nil
+	}
+	var cutoff int64
+	switch err := tx.StmtContext(s.dbh.Ctx, vs.cutoff).QueryRowContext(s.dbh.Ctx, key, s.historyVersions).Scan(&cutoff); err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil
+	default:
+		return err
+	}
+	_, err := tx.StmtContext(s.dbh.Ctx, vs.trim).ExecContext(s.dbh.Ctx, key, cutoff)
+	return err
+}
+
+// Remove all versions older than the configured maximum age. Returns the
+// number of versions removed.
+func (s *URLDataStore) PruneHistory() (int64, error) {
+	if s.historyMaxAge <= 0 {
+		return 0, nil
+	}
+	result, err := s.dbh.DB.ExecContext(s.dbh.Ctx, qPruneHistory, time.Now().Add(-s.historyMaxAge).Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// List the stored versions of a page, most recent first. Returns
+// ErrResourceNotFound if there are no versions of the page.
+func (s *URLDataStore) Versions(url *nurl.URL) ([]Version, error) {
+	key, err := s.resolveKey(url)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := s.dbh.Statement(listVersions, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, fmt.Sprintf(qListVersions, s.dbh.Engine.Dialect().JSONField("metadata", "title")))
+	})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryContext(s.dbh.Ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := make([]Version, 0)
+	for rows.Next() {
+		var (
+			v     Version
+			title sql.NullString
+		)
+		if err = rows.Scan(&v.Version, &v.FetchMethod, &title); err != nil {
+			return nil, err
+		}
+		v.FetchTime = time.Unix(v.Version, 0).UTC()
+		v.Title = title.String
+		versions = append(versions, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrResourceNotFound
+	}
+	return versions, nil
+}
+
+// Fetch a stored version of a page, including its content text.
+func (s *URLDataStore) Version(url *nurl.URL, version int64) (*resource.WebPage, error) {
+	key, err := s.resolveKey(url)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := s.dbh.Statement(fetchVersion, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, qFetchVersion)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var (
+		canonicalUrl string
+		fetchEpoch   int64
+		fetchMethod  resource.ClientIdentifier
+		metadata     string
+		contentText  []byte
+	)
+	err = stmt.QueryRowContext(s.dbh.Ctx, key, version).Scan(
+		&canonicalUrl,
+		&fetchEpoch,
+		&fetchMethod,
+		&metadata,
+		&contentText,
+	)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil, ErrVersionNotFound
+	default:
+		return nil, err
+	}
+	page := &resource.WebPage{}
+	if err = json.Unmarshal([]byte(metadata), page); err != nil {
+		return nil, err
+	}
+	page.CanonicalURL, _ = nurl.Parse(canonicalUrl)
+	fetchTime := time.Unix(fetchEpoch, 0).UTC()
+	page.FetchTime = &fetchTime
+	page.FetchMethod = fetchMethod
+	if page.ContentText, err = database.DecompressText(contentText); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Versions are compared using a checksum of the title and content text.
+// The checksum is kept to 63 bits so that it can be stored as a signed
+// integer.
+func contentChecksum(page *resource.WebPage) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(page.Title))
+	h.Write([]byte{0})
+	h.Write([]byte(page.ContentText))
+	return int64(h.Sum64() & math.MaxInt64)
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	nurl "net/url"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/resource"
+)
+
+// Saves a version of the test page with the passed content, fetched
+// at the passed time.
+func saveVersionOf(t *testing.T, s *URLDataStore, content string, fetchTime time.Time) *resource.WebPage {
+	page := getWebPage(t)
+	page.ContentText = content
+	page.FetchTime = &fetchTime
+	if _, err := s.Save(page); err != nil {
+		t.Fatalf("Error storing page: %v", err)
+	}
+	return page
+}
+
+func TestHistoryDisabledByDefault(t *testing.T) {
+	s := getURLDataStore(t)
+	if s.HistoryEnabled() {
+		t.Fatal("Expected history to be disabled by default")
+	}
+	page := saveVersionOf(t, s, "version 1", time.Now().Add(-time.Minute))
+	saveVersionOf(t, s, "version 2", time.Now())
+	if _, err := s.Versions(page.CanonicalURL); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("Expected ErrResourceNotFound with history disabled, got %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	s := getURLDataStore(t)
+	s = NewURLDataStore(s.dbh, WithHistory(3, 0))
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	var page *resource.WebPage
+	for i, content := range []string{"one", "two", "two", "three", "four"} {
+		page = saveVersionOf(t, s, content, base.Add(time.Duration(i)*time.Minute))
+	}
+	// the requested url maps to the canonical url, so either can be used
+	for _, url := range []*nurl.URL{page.CanonicalURL, page.RequestedURL} {
+		versions, err := s.Versions(url)
+		if err != nil {
+			t.Fatalf("Error listing versions for %s: %v", url, err)
+		}
+		// "two" was saved twice but is only recorded once, and "one" has been
+		// trimmed, since only 3 versions are kept
+		if len(versions) != 3 {
+			t.Fatalf("Expected 3 versions for %s, got %d", url, len(versions))
+		}
+		expected := []time.Time{base.Add(4 * time.Minute), base.Add(3 * time.Minute), base.Add(time.Minute)}
+		for i, v := range versions {
+			if !v.FetchTime.Equal(expected[i]) || v.Version != expected[i].Unix() {
+				t.Errorf("Expected version %d to be fetched at %v, got %v (%d)", i, expected[i], v.FetchTime, v.Version)
+			}
+			if v.Title != page.Title {
+				t.Errorf("Expected title %q, got %q", page.Title, v.Title)
+			}
+		}
+	}
+
+	v, err := s.Version(page.CanonicalURL, base.Add(time.Minute).Unix())
+	if err != nil {
+		t.Fatalf("Error fetching version: %v", err)
+	}
+	if v.ContentText != "two" {
+		t.Errorf("Expected content %q, got %q", "two", v.ContentText)
+	}
+	if v.CanonicalURL.String() != page.CanonicalURL.String() {
+		t.Errorf("Expected url %s, got %s", page.CanonicalURL, v.CanonicalURL)
+	}
+	if _, err := s.Version(page.CanonicalURL, base.Unix()); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected ErrVersionNotFound for a trimmed version, got %v", err)
+	}
+
+	// deleting the page deletes its history
+	if _, err := s.Delete(page.CanonicalURL); err != nil {
+		t.Fatalf("Error deleting page: %v", err)
+	}
+	if _, err := s.Versions(page.CanonicalURL); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("Expected ErrResourceNotFound after delete, got %v", err)
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	s := getURLDataStore(t)
+	s = NewURLDataStore(s.dbh, WithHistory(0, 24*time.Hour))
+	now := time.Now().UTC().Truncate(time.Second)
+	saveVersionOf(t, s, "old", now.Add(-48*time.Hour))
+	saveVersionOf(t, s, "older", now.Add(-36*time.Hour))
+	page := saveVersionOf(t, s, "new", now.Add(-time.Hour))
+	pruned, err := s.PruneHistory()
+	if err != nil {
+		t.Fatalf("Error pruning history: %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("Expected 2 versions pruned, got %d", pruned)
+	}
+	versions, err := s.Versions(page.CanonicalURL)
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if len(versions) != 1 || versions[0].Version != now.Add(-time.Hour).Unix() {
+		t.Errorf("Expected only the newest version to remain, got %v", versions)
+	}
+}
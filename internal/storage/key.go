@@ -3,6 +3,7 @@ package storage
 
 import (
 	"fmt"
+	"hash"
 	"hash/fnv"
 	"regexp"
 )
@@ -42,6 +43,20 @@ const (
 // [Bits 62-56] A 7 bit checksum based on the domain name
 // [Bits 55-0] A 56 bit hash of the URL (reduced from a 64 bit fnv1a hash)
 func Key(url URLWithHostname) uint64 {
+	return keyWithHash(url, fnv.New64a())
+}
+
+// SecondaryKey is used in place of Key for a url whose Key is already taken by
+// a different url. It has the same layout as Key, but the 56 bit hash is reduced
+// from a 64 bit fnv1 hash, which differs from fnv1a in the order of its operations.
+func SecondaryKey(url URLWithHostname) uint64 {
+	return keyWithHash(url, fnv.New64())
+}
+
+// The key schemes, in the order they're tried when storing and looking up urls.
+var keySchemes = []func(URLWithHostname) uint64{Key, SecondaryKey}
+
+func keyWithHash(url URLWithHostname, h hash.Hash64) uint64 {
 	dbytes := []byte(url.Hostname())
 	var sum uint8
 	for _, b := range dbytes {
@@ -49,9 +64,8 @@ func Key(url URLWithHostname) uint64 {
 	}
 	seg := (uint64(sum) << 56) & CHECKSUM_MASK
 
-	h := fnv.New64a()
 	h.Write([]byte(url.String()))
-	hash := uint64(h.Sum64())
-	hash = (hash >> 56) ^ (hash & MASK_56)
-	return seg | hash
+	hashed := h.Sum64()
+	hashed = (hashed >> 56) ^ (hashed & MASK_56)
+	return seg | hashed
 }
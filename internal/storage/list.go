@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/resource"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+	// Matches a hostname and its subdomains with a range of hostname keys.
+	// Takes two args, see hostnameArgs.
+	qHostnameMatch = `u.hostname_key >= ? AND u.hostname_key < ?`
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Defines the criteria for listing stored pages. Zero values are ignored,
+// so the zero ListQuery lists all of the stored pages. An Unspecified
+// FetchMethod matches pages fetched with any method.
+//
+// Hostname matches the hostname and any of its subdomains, so "nytimes.com"
+// will also match pages from "www.nytimes.com".
+//
+// Cursor is the NextCursor from a previous ListResult, and is used to get the
+// next page of results.
+type ListQuery struct {
+	Hostname       string
+	FetchedSince   time.Time
+	FetchedUntil   time.Time
+	PublishedSince time.Time
+	PublishedUntil time.Time
+	FetchMethod    resource.ClientIdentifier
+	StatusCode     int
+	Cursor         string
+	Limit          int
+}
+
+// Pages are returned most recently fetched first, without their content text.
+// NextCursor is empty when there are no more results.
+type ListResult struct {
+	Pages      []*resource.WebPage `json:"pages"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+type Lister interface {
+	List(ListQuery) (*ListResult, error)
+}
+
+// List the stored pages matching the query. Expired pages are never returned.
+func (s *URLDataStore) List(q ListQuery) (*ListResult, error) {
+	switch {
+	case q.Limit <= 0:
+		q.Limit = DefaultListLimit
+	case q.Limit > MaxListLimit:
+		q.Limit = MaxListLimit
+	}
+	query, args, err := listSQL(q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.dbh.DB.QueryContext(s.dbh.Ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := &ListResult{Pages: make([]*resource.WebPage, 0, q.Limit)}
+	var id uint64
+	for rows.Next() {
+		// one more row than the limit was requested, so if we get it there's
+		// another page of results
+		if len(result.Pages) == q.Limit {
+			last := result.Pages[len(result.Pages)-1]
+			result.NextCursor = encodeCursor(last.FetchTime.Unix(), id)
+			break
+		}
+		page, _, err := loadPage(rows, &id)
+		if err != nil {
+			return nil, err
+		}
+		result.Pages = append(result.Pages, page)
+	}
+	return result, rows.Err()
+}
+
+// Walk calls fn with each of the stored pages matching the query, in the
+// order they'd be listed, and including their content text. The query's
+// Limit sets how many pages are loaded at a time. Walking stops at the first
+// error returned by fn, and that error is returned.
+func (s *URLDataStore) Walk(q ListQuery, fn func(*resource.WebPage) error) error {
+	for {
+		listed, err := s.List(q)
+		if err != nil {
+			return err
+		}
+		for _, lp := range listed.Pages {
+			// listed pages don't include their content text
+			page, err := s.Fetch(lp.CanonicalURL)
+			switch {
+			case errors.Is(err, ErrResourceNotFound):
+				// expired since it was listed
+				continue
+			case err != nil:
+				return err
+			}
+			if err = fn(page); err != nil {
+				return err
+			}
+		}
+		if listed.NextCursor == "" {
+			return nil
+		}
+		q.Cursor = listed.NextCursor
+	}
+}
+
+func listSQL(q ListQuery) (string, []any, error) {
+	var (
+		sb   strings.Builder
+		args []any
+	)
+	sb.WriteString(`SELECT u.url, u.parsed_url, u.fetch_time, u.expires, u.metadata, '', u.fetch_method, u.id FROM urls u WHERE u.expires > ?`)
+	args = append(args, time.Now().Unix())
+	if q.Hostname != "" {
+		sb.WriteString(` AND ` + qHostnameMatch)
+		args = append(args, hostnameArgs(q.Hostname)...)
+	}
+	if !q.FetchedSince.IsZero() {
+		sb.WriteString(` AND u.fetch_time >= ?`)
+		args = append(args, q.FetchedSince.Unix())
+	}
+	if !q.FetchedUntil.IsZero() {
+		sb.WriteString(` AND u.fetch_time < ?`)
+		args = append(args, q.FetchedUntil.Unix())
+	}
+	if !q.PublishedSince.IsZero() {
+		sb.WriteString(` AND u.published >= ?`)
+		args = append(args, q.PublishedSince.Unix())
+	}
+	if !q.PublishedUntil.IsZero() {
+		sb.WriteString(` AND u.published < ?`)
+		args = append(args, q.PublishedUntil.Unix())
+	}
+	if q.FetchMethod != resource.Unspecified {
+		sb.WriteString(` AND u.fetch_method = ?`)
+		args = append(args, int(q.FetchMethod))
+	}
+	if q.StatusCode != 0 {
+		sb.WriteString(` AND u.status_code = ?`)
+		args = append(args, q.StatusCode)
+	}
+	if q.Cursor != "" {
+		fetchTime, id, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		sb.WriteString(` AND (u.fetch_time < ? OR (u.fetch_time = ? AND u.id < ?))`)
+		args = append(args, fetchTime, fetchTime, id)
+	}
+	sb.WriteString(` ORDER BY u.fetch_time DESC, u.id DESC LIMIT ?`)
+	args = append(args, q.Limit+1)
+	return sb.String(), args, nil
+}
+
+// Returns the args for qHostnameMatch.
+func hostnameArgs(hostname string) []any {
+	lower, upper := database.HostnameKeyRange(hostname)
+	return []any{lower, upper}
+}
+
+// Cursors point at the last page returned in a list, by fetch time and key.
+func encodeCursor(fetchTime int64, id uint64) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d.%d", fetchTime, id))
+}
+
+func decodeCursor(cursor string) (int64, uint64, error) {
+	var (
+		fetchTime int64
+		id        uint64
+	)
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, errors.Join(ErrInvalidCursor, err)
+	}
+	if _, err := fmt.Sscanf(string(data), "%d.%d", &fetchTime, &id); err != nil {
+		return 0, 0, errors.Join(ErrInvalidCursor, err)
+	}
+	return fetchTime, id, nil
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	nurl "net/url"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/resource"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	t.Parallel()
+	cursor := encodeCursor(1715000000, 1<<62+7)
+	fetchTime, id, err := decodeCursor(cursor)
+	if err != nil {
+		t.Fatalf("Error decoding cursor: %v", err)
+	}
+	if fetchTime != 1715000000 || id != 1<<62+7 {
+		t.Errorf("Expected 1715000000, %d, got %d, %d", uint64(1<<62+7), fetchTime, id)
+	}
+	for _, bad := range []string{"!!!", "Zm9v", encodeCursor(1, 2)[:3]} {
+		if _, _, err := decodeCursor(bad); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestHostnameArgs(t *testing.T) {
+	t.Parallel()
+	args := hostnameArgs("My_Site.com")
+	lower, upper := args[0].(string), args[1].(string)
+	tests := []struct {
+		hostname string
+		match    bool
+	}{
+		{"my_site.com", true},
+		{"www.my_site.com", true},
+		{"a.b.my_site.com", true},
+		{"x-my_site.com", false},
+		{"amy_site.com", false},
+		{"my_site.com.au", false},
+		{"site.com", false},
+	}
+	for _, test := range tests {
+		key := database.HostnameKey(test.hostname)
+		if match := key >= lower && key < upper; match != test.match {
+			t.Errorf("[%s] Expected match %t for key %q in [%q, %q)", test.hostname, test.match, key, lower, upper)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	s := getURLDataStore(t)
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	hosts := []string{"nytimes.com", "www.nytimes.com", "example.com"}
+	for i := 0; i < 9; i++ {
+		page := getWebPage(t)
+		page.CanonicalURL, _ = nurl.Parse(fmt.Sprintf("https://%s/page/%d", hosts[i%3], i))
+		page.RequestedURL = page.CanonicalURL
+		page.Hostname = hosts[i%3]
+		fetchTime := base.Add(time.Duration(i) * time.Minute)
+		page.FetchTime = &fetchTime
+		published := time.Date(2024, 1, i+1, 0, 0, 0, 0, time.UTC)
+		page.Date = &published
+		page.StatusCode = 200
+		page.FetchMethod = resource.DefaultClient
+		if i == 8 {
+			page.FetchMethod = resource.HeadlessChromium
+			page.StatusCode = 203
+		}
+		if _, err := s.Save(page); err != nil {
+			t.Fatalf("Error storing page: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		query       ListQuery
+		expectCount int
+		expectFirst string
+	}{
+		{"all", ListQuery{}, 9, "https://example.com/page/8"},
+		{"hostname and subdomains", ListQuery{Hostname: "NYTimes.com"}, 6, "https://www.nytimes.com/page/7"},
+		{"subdomain only", ListQuery{Hostname: "www.nytimes.com"}, 3, "https://www.nytimes.com/page/7"},
+		{"no partial hostname match", ListQuery{Hostname: "times.com"}, 0, ""},
+		{"fetched since", ListQuery{FetchedSince: base.Add(6 * time.Minute)}, 3, "https://example.com/page/8"},
+		{"fetched until", ListQuery{FetchedUntil: base.Add(2 * time.Minute)}, 2, "https://www.nytimes.com/page/1"},
+		{"published range", ListQuery{
+			PublishedSince: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			PublishedUntil: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC),
+		}, 2, "https://example.com/page/2"},
+		{"fetch method", ListQuery{FetchMethod: resource.HeadlessChromium}, 1, "https://example.com/page/8"},
+		{"status code", ListQuery{StatusCode: 200}, 8, "https://www.nytimes.com/page/7"},
+	}
+	for _, test := range tests {
+		result, err := s.List(test.query)
+		if err != nil {
+			t.Errorf("[%s] Error listing pages: %v", test.name, err)
+			continue
+		}
+		if len(result.Pages) != test.expectCount {
+			t.Errorf("[%s] Expected %d pages, got %d", test.name, test.expectCount, len(result.Pages))
+			continue
+		}
+		if result.NextCursor != "" {
+			t.Errorf("[%s] Expected no next cursor, got %q", test.name, result.NextCursor)
+		}
+		if test.expectCount == 0 {
+			continue
+		}
+		if result.Pages[0].CanonicalURL.String() != test.expectFirst {
+			t.Errorf("[%s] Expected first page %s, got %s", test.name, test.expectFirst, result.Pages[0].CanonicalURL)
+		}
+		for _, p := range result.Pages {
+			if p.ContentText != "" {
+				t.Errorf("[%s] Expected no content text, got %q", test.name, p.ContentText)
+			}
+		}
+	}
+
+	// paging through everything with the cursor returns all of the pages, in order
+	var (
+		seen   []string
+		cursor string
+		calls  int
+	)
+	for {
+		result, err := s.List(ListQuery{Limit: 4, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("Error listing pages: %v", err)
+		}
+		calls++
+		for _, p := range result.Pages {
+			seen = append(seen, p.CanonicalURL.String())
+		}
+		if cursor = result.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls to page through results, got %d", calls)
+	}
+	if len(seen) != 9 {
+		t.Fatalf("Expected 9 pages across all calls, got %d", len(seen))
+	}
+	for i, url := range seen {
+		expected := fmt.Sprintf("https://%s/page/%d", hosts[(8-i)%3], 8-i)
+		if url != expected {
+			t.Errorf("Expected page %d to be %s, got %s", i, expected, url)
+		}
+	}
+
+	if _, err := s.List(ListQuery{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a bad cursor, got %v", err)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	s := getURLDataStore(t)
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		page := getWebPage(t)
+		page.CanonicalURL, _ = nurl.Parse(fmt.Sprintf("https://example.com/page/%d", i))
+		page.RequestedURL = page.CanonicalURL
+		page.ContentText = fmt.Sprintf("content %d", i)
+		fetchTime := base.Add(time.Duration(i) * time.Minute)
+		page.FetchTime = &fetchTime
+		if _, err := s.Save(page); err != nil {
+			t.Fatalf("Error storing page: %v", err)
+		}
+	}
+	walked := make([]string, 0)
+	// a small limit, so that walking has to go through several lists
+	err := s.Walk(ListQuery{Limit: 2}, func(page *resource.WebPage) error {
+		walked = append(walked, page.ContentText)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error walking pages: %v", err)
+	}
+	expected := []string{"content 4", "content 3", "content 2", "content 1", "content 0"}
+	if fmt.Sprint(walked) != fmt.Sprint(expected) {
+		t.Errorf("Expected to walk %v, got %v", expected, walked)
+	}
+
+	stop := errors.New("stop")
+	count := 0
+	err = s.Walk(ListQuery{Limit: 2}, func(page *resource.WebPage) error {
+		count++
+		return stop
+	})
+	if !errors.Is(err, stop) || count != 1 {
+		t.Errorf("Expected walking to stop with the returned error after 1 page, got %v after %d", err, count)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build postgres
+
+package storage
+
+import (
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/postgres"
+)
+
+func getTestDatabaseEngine() database.Engine {
+	return postgres.MustNew(
+		postgres.NetAddress("127.0.0.1:5432"),
+		postgres.Username("postgres"),
+		postgres.WithMaxConnections(1),
+		postgres.Database("scrape_test"),
+		postgres.WithSSLMode(postgres.SSLDisable),
+	)
+}
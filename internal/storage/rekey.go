@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	nurl "net/url"
+	"time"
+
+	"github.com/efixler/scrape/resource"
+)
+
+const (
+	qRekeyBatch   = `SELECT id, url, parsed_url FROM urls WHERE id > ? ORDER BY id LIMIT ?`
+	qSetRequested = `UPDATE urls SET parsed_url = ? WHERE id = ?`
+	// Versions that the page at the new key already has are left behind. The
+	// subquery is wrapped so that MySQL can read the table it's updating.
+	qMoveHistory = `UPDATE url_history SET id = ? WHERE id = ? AND fetch_time NOT IN
+		(SELECT fetch_time FROM (SELECT fetch_time FROM url_history WHERE id = ?) AS kept)`
+	rekeyBatchSize  = 100
+	rekeyLogMessage = "storage: can't re-key page"
+)
+
+// A table of responses that are keyed by the url they're for, which are moved
+// to the keys of their normalized urls along with their pages. Rows have the
+// time they were made, so that the newer one is kept when two urls are
+// normalized the same way. Renders have a row for each format.
+type responseTable struct {
+	list   string // the url, time and format of the rows for a key
+	time   string // the time of the row for a key and format
+	move   string // set the key and url of the row for a key and format
+	remove string // remove the row for a key and format
+	format bool
+}
+
+var responseTables = []responseTable{
+	{
+		list:   `SELECT url, fetch_time FROM url_archive WHERE id = ?`,
+		time:   `SELECT fetch_time FROM url_archive WHERE id = ?`,
+		move:   `UPDATE url_archive SET id = ?, url = ? WHERE id = ?`,
+		remove: `DELETE FROM url_archive WHERE id = ?`,
+	},
+	{
+		list:   `SELECT url, render_time, format FROM url_renders WHERE id = ?`,
+		time:   `SELECT render_time FROM url_renders WHERE id = ? AND format = ?`,
+		move:   `UPDATE url_renders SET id = ?, url = ? WHERE id = ? AND format = ?`,
+		remove: `DELETE FROM url_renders WHERE id = ? AND format = ?`,
+		format: true,
+	},
+}
+
+type storedResponse struct {
+	url    string
+	time   int64
+	format string
+}
+
+// The outcome of re-keying stored pages. Moved pages were stored under the
+// key of their normalized url, merged pages were dropped in favor of a page
+// already stored for their normalized url, and remapped pages only had the
+// url they were requested with mapped to them again.
+type RekeySummary struct {
+	Examined int `json:"examined"`
+	Moved    int `json:"moved"`
+	Merged   int `json:"merged"`
+	Remapped int `json:"remapped"`
+	Skipped  int `json:"skipped"`
+}
+
+type storedKey struct {
+	id        uint64
+	canonical string
+	requested string
+}
+
+// Rekey stores pages under the keys of their normalized urls, after the url
+// normalization rules have changed. normalize is applied to a copy of each
+// page's canonical and requested urls; resource.CleanURL normalizes with the
+// current rules. The urls that were mapped to a page, and its old key, are
+// mapped to it under its new key, so that they still find it.
+//
+// When a page's normalized url is already stored, the newer of the two pages
+// is kept, along with the history of both. The archived responses and renders
+// of a page's urls are moved to the keys of their normalized urls too. Expired
+// pages, and pages whose new keys are taken by other urls, are skipped.
+// Pages are re-keyed one at a time, so this can be safely interrupted and run
+// again.
+func (s *URLDataStore) Rekey(normalize func(*nurl.URL) *nurl.URL) (*RekeySummary, error) {
+	summary := &RekeySummary{}
+	// Pages can be moved to keys that haven't been read yet; they're already done.
+	moved := make(map[uint64]bool)
+	var lastID any = -1
+	for {
+		batch, err := s.rekeyBatch(lastID)
+		if err != nil {
+			return summary, err
+		}
+		if len(batch) == 0 {
+			return summary, nil
+		}
+		for _, sk := range batch {
+			if moved[sk.id] {
+				continue
+			}
+			summary.Examined++
+			key, err := s.rekey(sk, normalize, summary)
+			if err != nil {
+				return summary, err
+			}
+			if key != sk.id {
+				moved[key] = true
+			}
+		}
+		lastID = batch[len(batch)-1].id
+	}
+}
+
+func (s *URLDataStore) rekeyBatch(afterID any) ([]storedKey, error) {
+	rows, err := s.dbh.DB.QueryContext(s.dbh.Ctx, qRekeyBatch, afterID, rekeyBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	batch := make([]storedKey, 0, rekeyBatchSize)
+	for rows.Next() {
+		var sk storedKey
+		if err = rows.Scan(&sk.id, &sk.canonical, &sk.requested); err != nil {
+			return nil, err
+		}
+		batch = append(batch, sk)
+	}
+	return batch, rows.Err()
+}
+
+// Re-key one page, and return the key it's stored under afterwards.
+func (s *URLDataStore) rekey(
+	sk storedKey,
+	normalize func(*nurl.URL) *nurl.URL,
+	summary *RekeySummary,
+) (uint64, error) {
+	canonical, err := nurl.Parse(sk.canonical)
+	if err != nil {
+		slog.Warn(rekeyLogMessage, "id", sk.id, "url", sk.canonical, "err", err)
+		summary.Skipped++
+		return sk.id, nil
+	}
+	requested, err := nurl.Parse(sk.requested)
+	if err != nil {
+		requested = canonical
+	}
+	newCanonical := normalize(copyURL(canonical))
+	newRequested := normalize(copyURL(requested))
+	switch {
+	case newCanonical.String() != sk.canonical:
+		return s.movePage(sk, newCanonical, newRequested, normalize, summary)
+	case newRequested.String() != sk.requested:
+		if err = s.storeIdMap(newRequested, sk.id); errors.Is(err, ErrKeyCollision) {
+			slog.Warn(rekeyLogMessage, "id", sk.id, "url", newRequested, "err", err)
+			summary.Skipped++
+			return sk.id, nil
+		} else if err != nil {
+			return sk.id, err
+		}
+		if _, err = s.dbh.DB.ExecContext(s.dbh.Ctx, qSetRequested, newRequested.String(), sk.id); err != nil {
+			return sk.id, err
+		}
+		summary.Remapped++
+	}
+	return sk.id, nil
+}
+
+// Store the page at sk under the key for its new canonical url, merging it with
+// the page that's already stored there if there is one.
+func (s *URLDataStore) movePage(
+	sk storedKey,
+	canonical, requested *nurl.URL,
+	normalize func(*nurl.URL) *nurl.URL,
+	summary *RekeySummary,
+) (uint64, error) {
+	old, exptime, err := s.loadStored(sk.id)
+	if err != nil {
+		return sk.id, err
+	}
+	if old == nil || time.Now().After(exptime) {
+		summary.Skipped++
+		return sk.id, nil
+	}
+	lookup, err := s.storedURLsStmt()
+	if err != nil {
+		return sk.id, err
+	}
+	key, replaces, err := s.storageKey(lookup, canonical)
+	if errors.Is(err, ErrKeyCollision) {
+		slog.Warn(rekeyLogMessage, "id", sk.id, "url", canonical, "err", err)
+		summary.Skipped++
+		return sk.id, nil
+	} else if err != nil {
+		return sk.id, err
+	}
+	// storageKey only returns a key with an unexpired page on it when the page is
+	// for the same url.
+	var page *resource.WebPage
+	if !replaces {
+		if page, exptime, err = s.loadStored(key); err != nil {
+			return sk.id, err
+		}
+		replaces = page != nil && time.Now().After(exptime)
+		if replaces {
+			page = nil
+		}
+	}
+	aliases, err := s.rekeyAliases(sk.id)
+	if err != nil {
+		return sk.id, err
+	}
+	old.CanonicalURL = canonical
+	old.RequestedURL = requested
+	if replaces {
+		if _, err = s.deleteKey(key); err != nil {
+			return sk.id, err
+		}
+	}
+	if _, err = s.dbh.DB.ExecContext(s.dbh.Ctx, qMoveHistory, key, sk.id, key); err != nil {
+		return sk.id, err
+	}
+	// When the page is merged, keep the one that was fetched last.
+	if page == nil || old.FetchTime.After(*page.FetchTime) {
+		if _, err = s.Save(old); err != nil {
+			return sk.id, err
+		}
+	}
+	if page != nil {
+		summary.Merged++
+	} else {
+		summary.Moved++
+	}
+	// The old urls are mapped to the new key before the old key is deleted, so
+	// that deleting it doesn't remove the responses for them.
+	if err = s.saveAliases(key, aliases); err != nil {
+		return key, err
+	}
+	for _, alias := range aliases {
+		if err = s.moveResponses(alias, normalize); err != nil {
+			return key, err
+		}
+	}
+	_, err = s.deleteKey(sk.id)
+	return key, err
+}
+
+// Move the archived responses and renders at key to the keys of their
+// normalized urls. When there's already a response at the new key, the
+// newer of the two is kept.
+func (s *URLDataStore) moveResponses(key uint64, normalize func(*nurl.URL) *nurl.URL) error {
+	for _, table := range responseTables {
+		responses, err := s.storedResponses(table, key)
+		if err != nil {
+			return err
+		}
+		for _, r := range responses {
+			url, err := nurl.Parse(r.url)
+			if err != nil {
+				continue
+			}
+			url = normalize(url)
+			newKey := Key(url)
+			if url.String() == r.url || newKey == key {
+				continue
+			}
+			from, to := []any{key}, []any{newKey}
+			if table.format {
+				from, to = append(from, r.format), append(to, r.format)
+			}
+			var existing int64
+			err = s.dbh.DB.QueryRowContext(s.dbh.Ctx, table.time, to...).Scan(&existing)
+			switch {
+			case err == sql.ErrNoRows:
+			case err != nil:
+				return err
+			case existing >= r.time:
+				if _, err = s.dbh.DB.ExecContext(s.dbh.Ctx, table.remove, from...); err != nil {
+					return err
+				}
+				continue
+			default:
+				if _, err = s.dbh.DB.ExecContext(s.dbh.Ctx, table.remove, to...); err != nil {
+					return err
+				}
+			}
+			args := append([]any{newKey, url.String()}, from...)
+			if _, err = s.dbh.DB.ExecContext(s.dbh.Ctx, table.move, args...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *URLDataStore) storedResponses(table responseTable, key uint64) ([]storedResponse, error) {
+	rows, err := s.dbh.DB.QueryContext(s.dbh.Ctx, table.list, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	responses := make([]storedResponse, 0, 1)
+	for rows.Next() {
+		var r storedResponse
+		dest := []any{&r.url, &r.time}
+		if table.format {
+			dest = append(dest, &r.format)
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		responses = append(responses, r)
+	}
+	return responses, rows.Err()
+}
+
+// The stored page at key, or nil if there isn't one, with its expiry time.
+func (s *URLDataStore) loadStored(key uint64) (*resource.WebPage, time.Time, error) {
+	page, exptime, err := loadPage(s.dbh.DB.QueryRowContext(s.dbh.Ctx, qFetchOne, key))
+	if err == sql.ErrNoRows {
+		return nil, exptime, nil
+	}
+	return page, exptime, err
+}
+
+// The keys that are mapped to the page at key, and key itself, so that the
+// page's old urls can be mapped to it after it moves.
+func (s *URLDataStore) rekeyAliases(key uint64) ([]uint64, error) {
+	stmt, err := s.aliasesStmt()
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := s.aliasesOf(stmt, key)
+	if err != nil {
+		return nil, err
+	}
+	return append(aliases, key), nil
+}
+
+func copyURL(url *nurl.URL) *nurl.URL {
+	c := *url
+	if url.User != nil {
+		u := *url.User
+		c.User = &u
+	}
+	return &c
+}
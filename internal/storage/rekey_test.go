@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"errors"
+	"net/http"
+	nurl "net/url"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/resource"
+)
+
+func TestRekey(t *testing.T) {
+	s := getURLDataStore(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	earlier := now.Add(-time.Hour)
+	pages := []struct {
+		canonical string
+		requested string
+		fetched   time.Time
+		content   string
+	}{
+		{"https://Example.com/moved/?fbclid=abc", "https://Example.com/moved/?fbclid=abc", now, "moved"},
+		{"https://example.com/remapped", "https://example.com/remapped?utm_source=feed", now, "remapped"},
+		{"https://example.com/merged/", "https://example.com/merged/", earlier, "older"},
+		{"https://example.com/merged", "https://example.com/merged", now, "newer"},
+		{"https://example.com/kept", "https://example.com/kept", now, "kept"},
+	}
+	for _, p := range pages {
+		page := getWebPage(t)
+		page.CanonicalURL, _ = nurl.Parse(p.canonical)
+		page.RequestedURL, _ = nurl.Parse(p.requested)
+		fetched := p.fetched
+		page.FetchTime = &fetched
+		page.TTL = 24 * time.Hour
+		page.ContentText = p.content
+		if _, err := s.Save(page); err != nil {
+			t.Fatalf("Error saving %s: %v", p.canonical, err)
+		}
+	}
+	alias, _ := nurl.Parse("https://short.example/moved")
+	moved, _ := nurl.Parse(pages[0].canonical)
+	if err := s.storeIdMap(alias, Key(moved)); err != nil {
+		t.Fatalf("Error mapping alias: %v", err)
+	}
+
+	normalizer := resource.URLNormalizer{TrackingParams: resource.DefaultTrackingParams, NormalizePaths: true}
+	summary, err := s.Rekey(normalizer.Normalize)
+	if err != nil {
+		t.Fatalf("Error re-keying: %v", err)
+	}
+	expect := RekeySummary{Examined: 5, Moved: 1, Merged: 1, Remapped: 1}
+	if *summary != expect {
+		t.Errorf("Expected summary %+v, got %+v", expect, *summary)
+	}
+
+	tests := []struct {
+		url             string
+		expectCanonical string
+		expectContent   string
+	}{
+		{"https://example.com/moved", "https://example.com/moved", "moved"},
+		{pages[0].canonical, "https://example.com/moved", "moved"},
+		{alias.String(), "https://example.com/moved", "moved"},
+		{"https://example.com/remapped", "https://example.com/remapped", "remapped"},
+		{"https://example.com/merged", "https://example.com/merged", "newer"},
+		{pages[2].canonical, "https://example.com/merged", "newer"},
+		{"https://example.com/kept", "https://example.com/kept", "kept"},
+	}
+	for _, test := range tests {
+		url, _ := nurl.Parse(test.url)
+		page, err := s.Fetch(url)
+		if err != nil {
+			t.Errorf("[%s] Error fetching: %v", test.url, err)
+			continue
+		}
+		if page.CanonicalURL.String() != test.expectCanonical || page.ContentText != test.expectContent {
+			t.Errorf(
+				"[%s] Expected %s with %q, got %s with %q",
+				test.url, test.expectCanonical, test.expectContent, page.CanonicalURL, page.ContentText,
+			)
+		}
+	}
+	var rows int
+	if err := s.dbh.DB.QueryRowContext(s.dbh.Ctx, `SELECT COUNT(*) FROM urls`).Scan(&rows); err != nil || rows != 4 {
+		t.Errorf("Expected 4 stored pages, got %d (%v)", rows, err)
+	}
+
+	// Once urls are normalized, there's nothing left to do.
+	summary, err = s.Rekey(normalizer.Normalize)
+	if err != nil {
+		t.Fatalf("Error re-keying again: %v", err)
+	}
+	expect = RekeySummary{Examined: 4}
+	if *summary != expect {
+		t.Errorf("Expected summary %+v the second time, got %+v", expect, *summary)
+	}
+}
+
+func TestRekeyMovesResponsesAndHistory(t *testing.T) {
+	s := NewURLDataStore(getURLDataStore(t).dbh, WithHistory(10, 0))
+	archive := NewArchiveStore(s.dbh, 0)
+	renders := NewRenderStore(s.dbh, 0)
+	now := time.Now().UTC().Truncate(time.Second)
+	save := func(u string, fetched time.Time, content string) *nurl.URL {
+		page := getWebPage(t)
+		page.CanonicalURL, _ = nurl.Parse(u)
+		page.RequestedURL, _ = nurl.Parse(u)
+		page.FetchTime = &fetched
+		page.TTL = 24 * time.Hour
+		page.ContentText = content
+		if _, err := s.Save(page); err != nil {
+			t.Fatalf("Error saving %s: %v", u, err)
+		}
+		if err := archive.Archive(page.RequestedURL, http.Header{}, []byte(content)); err != nil {
+			t.Fatalf("Error archiving %s: %v", u, err)
+		}
+		_, err := s.dbh.DB.Exec("UPDATE url_archive SET fetch_time = ? WHERE id = ?", fetched.Unix(), Key(page.RequestedURL))
+		if err != nil {
+			t.Fatalf("Error setting archive time for %s: %v", u, err)
+		}
+		return page.RequestedURL
+	}
+	// a page that moves, with two versions, an archived response and a render
+	moved := save("https://example.com/moved/?fbclid=abc", now.Add(-time.Hour), "moved 1")
+	save(moved.String(), now, "moved 2")
+	if _, err := renders.Save(moved, fetch.Screenshot, []byte("screenshot")); err != nil {
+		t.Fatalf("Error saving render: %v", err)
+	}
+	// a page that's merged into an older page for its normalized url, keeping
+	// the history of both
+	merged := save("https://example.com/merged/?fbclid=abc", now.Add(-30*time.Minute), "newer 1")
+	save(merged.String(), now, "newer 2")
+	save("https://example.com/merged", now.Add(-time.Hour), "older")
+
+	normalizer := resource.URLNormalizer{TrackingParams: resource.DefaultTrackingParams, NormalizePaths: true}
+	summary, err := s.Rekey(normalizer.Normalize)
+	if err != nil {
+		t.Fatalf("Error re-keying: %v", err)
+	}
+	if expect := (RekeySummary{Examined: 3, Moved: 1, Merged: 1}); *summary != expect {
+		t.Errorf("Expected summary %+v, got %+v", expect, *summary)
+	}
+
+	tests := []struct {
+		url            string
+		expectArchive  string
+		expectVersions int
+	}{
+		{"https://example.com/moved", "moved 2", 2},
+		{"https://example.com/merged", "newer 2", 3},
+	}
+	for _, test := range tests {
+		url, _ := nurl.Parse(test.url)
+		if archived, err := archive.Load(url); err != nil || string(archived.Body) != test.expectArchive {
+			t.Errorf("[%s] Expected archived response %q, got %v", test.url, test.expectArchive, err)
+		}
+		if versions, err := s.Versions(url); err != nil || len(versions) != test.expectVersions {
+			t.Errorf("[%s] Expected %d versions, got %d (%v)", test.url, test.expectVersions, len(versions), err)
+		}
+	}
+	url, _ := nurl.Parse("https://example.com/moved")
+	if render, err := renders.Load(url, fetch.Screenshot); err != nil || string(render.Body) != "screenshot" {
+		t.Errorf("Expected the render to move, got %v", err)
+	}
+	for _, old := range []*nurl.URL{moved, merged} {
+		if _, err := archive.Load(old); !errors.Is(err, ErrResourceNotFound) {
+			t.Errorf("[%s] Expected no archived response under the old url, got %v", old, err)
+		}
+	}
+	var rows int
+	if err := s.dbh.DB.QueryRow(`SELECT COUNT(*) FROM url_archive`).Scan(&rows); err != nil || rows != 2 {
+		t.Errorf("Expected 2 archived responses, got %d (%v)", rows, err)
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	nurl "net/url"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/fetch"
+)
+
+const (
+	qFetchRender = `SELECT url, render_time, expires, body FROM url_renders WHERE id = ? AND format = ?`
+	qPruneRender = `DELETE FROM url_renders WHERE expires > 0 AND expires < ?`
+	// Renders of a page, under its own key or the keys of the urls that
+	// were requested for it.
+	qDeleteRenders = `DELETE FROM url_renders WHERE id = ? OR id IN (SELECT requested_id FROM id_map WHERE canonical_id = ?)`
+)
+
+var urlRendersColumns = []string{"id", "format", "url", "render_time", "expires", "body"}
+
+// A screenshot or PDF of a page.
+type Render struct {
+	URL        *nurl.URL
+	Format     fetch.RenderFormat
+	RenderTime time.Time
+	Body       []byte
+}
+
+// RenderStore keeps the most recent render of each format for a URL.
+// Renders are keyed by the requested URL, and are kept for the store's TTL.
+type RenderStore struct {
+	dbh *database.DBHandle
+	ttl time.Duration
+}
+
+// Make a render store. Renders expire after the ttl, and are removed by Prune,
+// which should be run periodically. If the ttl is zero renders don't expire.
+func NewRenderStore(dbh *database.DBHandle, ttl time.Duration) *RenderStore {
+	return &RenderStore{dbh: dbh, ttl: max(ttl, 0)}
+}
+
+// Save a render of a URL, replacing any render of the same format previously
+// saved for it.
+func (rs *RenderStore) Save(url *nurl.URL, format fetch.RenderFormat, body []byte) (*Render, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	var expires int64
+	if rs.ttl > 0 {
+		expires = now.Add(rs.ttl).Unix()
+	}
+	stmt, err := rs.dbh.Statement(saveRender, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, rs.dbh.Engine.Dialect().Upsert("url_renders", []string{"id", "format"}, urlRendersColumns...))
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = stmt.ExecContext(rs.dbh.Ctx, Key(url), string(format), url.String(), now.Unix(), expires, body)
+	if err != nil {
+		return nil, err
+	}
+	return &Render{URL: url, Format: format, RenderTime: now, Body: body}, nil
+}
+
+// Load the render of a URL. The URL must be the one that was requested when the
+// render was saved. Returns ErrResourceNotFound if there's no unexpired render
+// of the format for the URL.
+func (rs *RenderStore) Load(url *nurl.URL, format fetch.RenderFormat) (*Render, error) {
+	stmt, err := rs.dbh.Statement(fetchRender, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, qFetchRender)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var (
+		renderedUrl string
+		renderEpoch int64
+		expires     int64
+		body        []byte
+	)
+	err = stmt.QueryRowContext(rs.dbh.Ctx, Key(url), string(format)).Scan(&renderedUrl, &renderEpoch, &expires, &body)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil, ErrResourceNotFound
+	default:
+		return nil, err
+	}
+	// An expired render, or another url's render under the same key
+	if (expires > 0 && expires < time.Now().Unix()) || renderedUrl != url.String() {
+		return nil, ErrResourceNotFound
+	}
+	render := &Render{
+		Format:     format,
+		RenderTime: time.Unix(renderEpoch, 0).UTC(),
+		Body:       body,
+	}
+	if render.URL, err = nurl.Parse(renderedUrl); err != nil {
+		return nil, err
+	}
+	return render, nil
+}
+
+// Remove expired renders. Returns the number of renders removed.
+func (rs *RenderStore) Prune() (int64, error) {
+	result, err := rs.dbh.DB.ExecContext(rs.dbh.Ctx, qPruneRender, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
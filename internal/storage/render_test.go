@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	nurl "net/url"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/fetch"
+)
+
+func getRenderStore(t *testing.T, ttl time.Duration) *RenderStore {
+	return NewRenderStore(getURLDataStore(t).dbh, ttl)
+}
+
+func TestRenderStore(t *testing.T) {
+	rs := getRenderStore(t, time.Hour)
+	url, _ := nurl.Parse("https://example.com/rendered")
+	if _, err := rs.Load(url, fetch.Screenshot); !errors.Is(err, ErrResourceNotFound) {
+		t.Fatalf("Expected ErrResourceNotFound before saving, got %v", err)
+	}
+	screenshots := [][]byte{[]byte("\x89PNG first"), []byte("\x89PNG second")}
+	for _, body := range screenshots {
+		if _, err := rs.Save(url, fetch.Screenshot, body); err != nil {
+			t.Fatalf("Error saving render: %v", err)
+		}
+	}
+	if _, err := rs.Save(url, fetch.PDF, []byte("%PDF")); err != nil {
+		t.Fatalf("Error saving pdf: %v", err)
+	}
+	render, err := rs.Load(url, fetch.Screenshot)
+	if err != nil {
+		t.Fatalf("Error loading render: %v", err)
+	}
+	if !bytes.Equal(render.Body, screenshots[1]) {
+		t.Errorf("Expected the latest screenshot, got %q", render.Body)
+	}
+	if render.URL.String() != url.String() || render.Format != fetch.Screenshot {
+		t.Errorf("Expected a screenshot of %s, got a %s of %s", url, render.Format, render.URL)
+	}
+	if time.Since(render.RenderTime) > time.Minute {
+		t.Errorf("Expected a recent render time, got %v", render.RenderTime)
+	}
+	if render, err = rs.Load(url, fetch.PDF); err != nil || string(render.Body) != "%PDF" {
+		t.Errorf("Expected the pdf, got %v, %v", render, err)
+	}
+	other, _ := nurl.Parse("https://example.com/other")
+	if _, err := rs.Load(other, fetch.Screenshot); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("Expected ErrResourceNotFound for another url, got %v", err)
+	}
+}
+
+func TestPruneRenders(t *testing.T) {
+	rs := getRenderStore(t, time.Hour)
+	old, _ := nurl.Parse("https://example.com/old")
+	newer, _ := nurl.Parse("https://example.com/new")
+	for _, url := range []*nurl.URL{old, newer} {
+		if _, err := rs.Save(url, fetch.Screenshot, []byte(url.String())); err != nil {
+			t.Fatalf("Error saving %s: %v", url, err)
+		}
+	}
+	_, err := rs.dbh.DB.Exec("UPDATE url_renders SET expires = ? WHERE id = ?", time.Now().Add(-time.Minute).Unix(), Key(old))
+	if err != nil {
+		t.Fatalf("Error expiring render: %v", err)
+	}
+	if _, err := rs.Load(old, fetch.Screenshot); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("Expected an expired render not to load, got %v", err)
+	}
+	pruned, err := rs.Prune()
+	if err != nil {
+		t.Fatalf("Error pruning renders: %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("Expected 1 render pruned, got %d", pruned)
+	}
+	if _, err := rs.Load(newer, fetch.Screenshot); err != nil {
+		t.Errorf("Expected unexpired render to remain, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+//go:build !mysql && !postgres
+
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/sqlite"
+)
+
+// In-memory databases don't have a journal, so their transactions can't be
+// rolled back; these tests use a database file.
+func getFileURLDataStore(t *testing.T, options ...option) *URLDataStore {
+	db := database.New(sqlite.MustNew(sqlite.File(filepath.Join(t.TempDir(), "scrape.db"))))
+	if err := db.Open(context.Background()); err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return NewURLDataStore(db, options...)
+}
+
+// Saves a changed version of the test page while table can't be written, and
+// checks that Save fails without changing the stored page. The table is renamed
+// after a first save has prepared the statements that write it, so that the
+// write fails in Save's transaction.
+func assertSaveRollsBack(t *testing.T, s *URLDataStore, table string) {
+	t.Helper()
+	page := getWebPage(t)
+	page.ContentText = "first version"
+	if _, err := s.Save(page); err != nil {
+		t.Fatalf("Error storing page: %v", err)
+	}
+	rename := func(from, to string) {
+		if _, err := s.dbh.DB.ExecContext(s.dbh.Ctx, `ALTER TABLE `+from+` RENAME TO `+to); err != nil {
+			t.Fatalf("Error renaming %s: %v", from, err)
+		}
+	}
+	rename(table, table+"_moved")
+	changed := getWebPage(t)
+	changed.ContentText = "second version"
+	_, err := s.Save(changed)
+	rename(table+"_moved", table)
+	if err == nil {
+		t.Fatalf("Expected an error saving without %s", table)
+	}
+	stored, err := s.Fetch(page.CanonicalURL)
+	if err != nil {
+		t.Fatalf("Error fetching page: %v", err)
+	}
+	if stored.ContentText != page.ContentText {
+		t.Errorf("Expected the failed save to be rolled back, got content %q", stored.ContentText)
+	}
+}
+
+func TestSaveRollsBackWhenIndexFails(t *testing.T) {
+	s := getFileURLDataStore(t)
+	if !s.SearchEnabled() {
+		t.Skip("full text search not available (build with -tags sqlite_fts5)")
+	}
+	assertSaveRollsBack(t, s, "urls_fts")
+}
+
+func TestSaveRollsBackWhenHistoryFails(t *testing.T) {
+	s := getFileURLDataStore(t, WithHistory(3, 0))
+	assertSaveRollsBack(t, s, "url_history")
+}
+
+func TestSaveRollsBackWhenMappingFails(t *testing.T) {
+	s := getFileURLDataStore(t)
+	assertSaveRollsBack(t, s, "id_map")
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/resource"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	snippetWidth       = 240
+)
+
+var (
+	ErrSearchUnavailable = errors.New("full text search is not available for this database")
+	ErrEmptySearchQuery  = errors.New("search query must contain at least one term")
+	ErrInvalidDate       = errors.New("dates must be RFC3339 or YYYY-MM-DD")
+)
+
+// Parse a date bound for a query, as an RFC3339 timestamp or a YYYY-MM-DD
+// date. An empty value returns the zero time, which leaves the bound unset.
+func ParseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, ErrInvalidDate
+}
+
+// Defines the criteria for a full text search of stored content.
+// Since and Until are bounds on the fetch time of the results, and are
+// ignored when zero.
+type SearchQuery struct {
+	Query    string
+	Hostname string
+	Since    time.Time
+	Until    time.Time
+	Offset   int
+	Limit    int
+}
+
+// A single search hit. The Page is the stored metadata for the hit, without
+// its content text; the Snippet is an excerpt of the content text around
+// the first matched term.
+type SearchResult struct {
+	Snippet string            `json:"snippet,omitempty"`
+	Page    *resource.WebPage `json:"page"`
+}
+
+type Searcher interface {
+	Search(SearchQuery) ([]SearchResult, error)
+}
+
+// SearchEnabled reports whether the underlying database engine maintains
+// a full text index of stored content.
+func (s *URLDataStore) SearchEnabled() bool {
+	return s.fullText() != nil
+}
+
+// The SQL for the engine's full text index, or nil when search isn't enabled.
+func (s *URLDataStore) fullText() database.FullTextDialect {
+	fts, ok := s.dbh.Engine.(database.FullTextSearchable)
+	if !ok || !fts.FullTextSearch() {
+		return nil
+	}
+	return s.dbh.Engine.Dialect().FullText()
+}
+
+// The statement that adds or updates a page's entry in the full text index,
+// or nil when search isn't enabled. It's prepared before Save's transaction
+// is started, like the statements from clearKeyStmts.
+func (s *URLDataStore) indexStmt() (*sql.Stmt, error) {
+	ft := s.fullText()
+	if ft == nil {
+		return nil, nil
+	}
+	return s.dbh.Statement(saveSearch, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, ft.Index())
+	})
+}
+
+// Add or update the full text index entry for a stored page in tx, with the
+// statement from indexStmt, so the index is written with the page. This is a
+// no-op when search isn't enabled. Deletes are propagated by the database.
+func (s *URLDataStore) index(tx *sql.Tx, stmt *sql.Stmt, key uint64, page *resource.WebPage) error {
+	if stmt == nil {
+		return nil
+	}
+	_, err := tx.StmtContext(s.dbh.Ctx, stmt).ExecContext(s.dbh.Ctx, key, page.Title, page.Description, page.ContentText)
+	return err
+}
+
+// Search the stored content for pages matching all of the terms in the query,
+// with the best matches first. Expired pages are never returned.
+func (s *URLDataStore) Search(q SearchQuery) ([]SearchResult, error) {
+	ft := s.fullText()
+	if ft == nil {
+		return nil, ErrSearchUnavailable
+	}
+	terms := searchTerms(q.Query)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearchQuery
+	}
+	switch {
+	case q.Limit <= 0:
+		q.Limit = DefaultSearchLimit
+	case q.Limit > MaxSearchLimit:
+		q.Limit = MaxSearchLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	query, args := searchSQL(ft, q, terms)
+	rows, err := s.dbh.DB.QueryContext(s.dbh.Ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := make([]SearchResult, 0, q.Limit)
+	for rows.Next() {
+		page, _, err := loadPage(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{
+			Snippet: snippet(page.ContentText, terms, snippetWidth),
+			Page:    page,
+		})
+		page.ContentText = ""
+	}
+	return results, rows.Err()
+}
+
+func searchSQL(ft database.FullTextDialect, q SearchQuery, terms []string) (string, []any) {
+	var sb strings.Builder
+	sb.WriteString(`SELECT u.url, u.parsed_url, u.fetch_time, u.expires, u.metadata, u.content_text, u.fetch_method FROM urls_fts f `)
+	match, args := ft.Match(terms)
+	sb.WriteString(`JOIN urls u ON u.id = f.` + ft.Key() + ` WHERE ` + match)
+	sb.WriteString(` AND u.expires > ?`)
+	args = append(args, time.Now().Unix())
+	if q.Hostname != "" {
+		sb.WriteString(` AND ` + qHostnameMatch)
+		args = append(args, hostnameArgs(q.Hostname)...)
+	}
+	if !q.Since.IsZero() {
+		sb.WriteString(` AND u.fetch_time >= ?`)
+		args = append(args, q.Since.Unix())
+	}
+	if !q.Until.IsZero() {
+		sb.WriteString(` AND u.fetch_time < ?`)
+		args = append(args, q.Until.Unix())
+	}
+	rank, rankArgs := ft.Rank(terms)
+	sb.WriteString(` ORDER BY ` + rank)
+	args = append(args, rankArgs...)
+	sb.WriteString(` LIMIT ? OFFSET ?`)
+	args = append(args, q.Limit, q.Offset)
+	return sb.String(), args
+}
+
+// Split a free text query into search terms. Anything that isn't a letter
+// or a digit is treated as a separator, so query syntax from the underlying
+// engines can't be injected.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Returns an excerpt of text of about width characters, around the first
+// occurrence of any of the terms. If no terms are found, the excerpt is taken
+// from the start of the text.
+func snippet(text string, terms []string, width int) string {
+	if text == "" {
+		return ""
+	}
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	runes := []rune(text)
+	start := 0
+	if len(quoted) > 0 {
+		re := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+		if loc := re.FindStringIndex(text); loc != nil {
+			start = len([]rune(text[:loc[0]])) - width/3
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + width
+	if end > len(runes) {
+		end = len(runes)
+		if start = end - width; start < 0 {
+			start = 0
+		}
+	}
+	// trim to word boundaries
+	for start > 0 && start < end && !unicode.IsSpace(runes[start-1]) {
+		start++
+	}
+	for end < len(runes) && end > start && !unicode.IsSpace(runes[end]) {
+		end--
+	}
+	excerpt := strings.TrimSpace(string(runes[start:end]))
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(runes) {
+		excerpt += "…"
+	}
+	return excerpt
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"errors"
+	nurl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/database"
+)
+
+func TestSearchTerms(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"empty", "", []string{}},
+		{"single", "fowler", []string{"fowler"}},
+		{"case folded", "Martin FOWLER", []string{"martin", "fowler"}},
+		{"punctuation", `"martin" -fowler AND:refactoring*`, []string{"martin", "fowler", "and", "refactoring"}},
+		{"unicode", "café über", []string{"café", "über"}},
+	}
+	for _, test := range tests {
+		terms := searchTerms(test.query)
+		if strings.Join(terms, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("[%s] expected %v, got %v", test.name, test.expected, terms)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	t.Parallel()
+	long := strings.Repeat("lorem ipsum ", 50) + "the needle is here " + strings.Repeat("dolor sit ", 50)
+	tests := []struct {
+		name          string
+		text          string
+		terms         []string
+		width         int
+		contains      string
+		leadingElide  bool
+		trailingElide bool
+	}{
+		{"empty", "", []string{"foo"}, 20, "", false, false},
+		{"short", "the needle is here", []string{"needle"}, 40, "the needle is here", false, false},
+		{"no match", long, []string{"haystack"}, 40, "lorem ipsum", false, true},
+		{"match in the middle", long, []string{"NEEDLE"}, 60, "needle is here", true, true},
+	}
+	for _, test := range tests {
+		s := snippet(test.text, test.terms, test.width)
+		if !strings.Contains(s, test.contains) {
+			t.Errorf("[%s] expected snippet to contain %q, got %q", test.name, test.contains, s)
+		}
+		if strings.HasPrefix(s, "…") != test.leadingElide {
+			t.Errorf("[%s] leading ellipsis: expected %v, got %q", test.name, test.leadingElide, s)
+		}
+		if strings.HasSuffix(s, "…") != test.trailingElide {
+			t.Errorf("[%s] trailing ellipsis: expected %v, got %q", test.name, test.trailingElide, s)
+		}
+		if len([]rune(s)) > test.width+2 {
+			t.Errorf("[%s] snippet too long (%d): %q", test.name, len([]rune(s)), s)
+		}
+	}
+}
+
+// An engine that reports a full text index, with a dialect that doesn't have one.
+type noFullTextEngine struct {
+	database.Engine
+}
+
+func (e noFullTextEngine) Dialect() database.Dialect {
+	return database.BaseDialect{}
+}
+
+func (e noFullTextEngine) FullTextSearch() bool {
+	return true
+}
+
+func TestSearchUnavailableWithoutFullTextDialect(t *testing.T) {
+	t.Parallel()
+	s := NewURLDataStore(database.New(noFullTextEngine{getTestDatabaseEngine()}))
+	if s.SearchEnabled() {
+		t.Error("Expected search to be disabled")
+	}
+	if _, err := s.Search(SearchQuery{Query: "fowler"}); !errors.Is(err, ErrSearchUnavailable) {
+		t.Errorf("Expected ErrSearchUnavailable, got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := getURLDataStore(t)
+	if !s.SearchEnabled() {
+		t.Skip("full text search not available (build with -tags sqlite_fts5)")
+	}
+	page := getWebPage(t)
+	page.ContentText = "Martin Fowler writes about refactoring and software architecture."
+	if _, err := s.Save(page); err != nil {
+		t.Fatalf("Error storing page: %v", err)
+	}
+	other := getWebPage(t)
+	other.CanonicalURL, _ = nurl.Parse("https://example.com/other")
+	other.RequestedURL = other.CanonicalURL
+	other.Hostname = "example.com"
+	other.Title = "Something else"
+	other.ContentText = "Nothing to see here, just some software."
+	if _, err := s.Save(other); err != nil {
+		t.Fatalf("Error storing page: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		query     SearchQuery
+		expectErr error
+		expectURL []string
+	}{
+		{"empty query", SearchQuery{Query: " -- "}, ErrEmptySearchQuery, nil},
+		{"one hit", SearchQuery{Query: "refactoring"}, nil, []string{page.CanonicalURL.String()}},
+		{"stemmed", SearchQuery{Query: "refactored"}, nil, []string{page.CanonicalURL.String()}},
+		{"all terms required", SearchQuery{Query: "refactoring nothing"}, nil, []string{}},
+		{"title match", SearchQuery{Query: "something"}, nil, []string{other.CanonicalURL.String()}},
+		{"two hits", SearchQuery{Query: "software"}, nil, []string{page.CanonicalURL.String(), other.CanonicalURL.String()}},
+		{"hostname", SearchQuery{Query: "software", Hostname: "Example.com"}, nil, []string{other.CanonicalURL.String()}},
+		{"until", SearchQuery{Query: "software", Until: time.Now().Add(-time.Hour)}, nil, []string{}},
+		{"since", SearchQuery{Query: "software", Since: time.Now().Add(-time.Hour), Limit: 1}, nil, []string{""}},
+	}
+	for _, test := range tests {
+		results, err := s.Search(test.query)
+		if !errors.Is(err, test.expectErr) {
+			t.Errorf("[%s] expected error %v, got %v", test.name, test.expectErr, err)
+			continue
+		}
+		if len(results) != len(test.expectURL) {
+			t.Errorf("[%s] expected %d results, got %d", test.name, len(test.expectURL), len(results))
+			continue
+		}
+		for _, r := range results {
+			if r.Page.ContentText != "" {
+				t.Errorf("[%s] expected no content text in results, got %q", test.name, r.Page.ContentText)
+			}
+			if len(test.expectURL) == 1 && test.expectURL[0] != "" && r.Page.CanonicalURL.String() != test.expectURL[0] {
+				t.Errorf("[%s] expected %s, got %s", test.name, test.expectURL[0], r.Page.CanonicalURL)
+			}
+		}
+	}
+
+	// deleted pages are removed from the index
+	if _, err := s.Delete(page.CanonicalURL); err != nil {
+		t.Fatalf("Error deleting page: %v", err)
+	}
+	results, err := s.Search(SearchQuery{Query: "refactoring"})
+	if err != nil {
+		t.Fatalf("Error searching after delete: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results after delete, got %d", len(results))
+	}
+}
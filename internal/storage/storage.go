@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log/slog"
 	nurl "net/url"
+	"strings"
 	"time"
 
 	"github.com/efixler/scrape/database"
@@ -23,29 +24,61 @@ const (
 	lookupId
 	fetchOne
 	delete
+	saveSearch
+	latestChecksum
+	saveVersion
+	versionCutoff
+	trimVersions
+	listVersions
+	fetchVersion
+	deleteHistory
+	saveArchive
+	fetchArchive
+	listAliases
+	deleteAliases
+	lookupStoredURLs
+	saveRender
+	fetchRender
+	deleteArchives
+	deleteRenders
 )
 
 const (
-	qSave     = `REPLACE INTO urls (id, url, parsed_url, fetch_time, expires, metadata, content_text, fetch_method) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
-	qSaveId   = `REPLACE INTO id_map (requested_id, canonical_id) VALUES (?, ?)`
 	qLookupId = `SELECT canonical_id FROM id_map WHERE requested_id = ?`
+	qAliases  = `SELECT requested_id FROM id_map WHERE canonical_id = ? ORDER BY requested_id`
 	qFetchOne = `SELECT url, parsed_url, fetch_time, expires, metadata, content_text, fetch_method FROM urls WHERE id = ?`
 	qDelete   = `DELETE FROM urls WHERE id = ?`
-	qClear    = `DELETE FROM urls; DELETE FROM id_map;`
+	// Mappings to the deleted page, from the urls that were requested for it
+	qDeleteAliases = `DELETE FROM id_map WHERE canonical_id = ?`
+	qClear         = `DELETE FROM urls; DELETE FROM id_map; DELETE FROM url_history; DELETE FROM url_archive; DELETE FROM url_renders;`
 	// qClearId  = `DELETE FROM id_map where canonical_id = ?`
 )
 
+// Upserts are generated by the database engine's dialect, from these columns.
+var (
+	urlsColumns  = []string{"id", "url", "parsed_url", "fetch_time", "expires", "metadata", "content_text", "fetch_method", "hostname", "published", "status_code", "content_size", "hostname_key"}
+	idMapColumns = []string{"requested_id", "canonical_id"}
+)
+
 var (
 	ErrResourceNotFound = errors.New("resource not found in data store")
 	ErrMappingNotFound  = errors.New("id mapping not found")
 )
 
+type option func(*URLDataStore)
+
 type URLDataStore struct {
-	dbh *database.DBHandle
+	dbh             *database.DBHandle
+	historyVersions int
+	historyMaxAge   time.Duration
 }
 
-func NewURLDataStore(dbh *database.DBHandle) *URLDataStore {
-	return &URLDataStore{dbh: dbh}
+func NewURLDataStore(dbh *database.DBHandle, options ...option) *URLDataStore {
+	s := &URLDataStore{dbh: dbh}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
 }
 
 func (s *URLDataStore) Database() *database.DBHandle {
@@ -56,7 +89,12 @@ func (s *URLDataStore) Database() *database.DBHandle {
 // Save() will use the canonical url of the passed resource both for the key
 // and for the url field in the stored data. It will also store an id map entry
 // for the requested URL, back to the canonical URL. This mapping will also be stored in
-// cases where the two urls are the same.
+// cases where the two urls are the same. The urls the page was redirected from
+// and to, and the AMP or mobile url it was resolved from, are mapped to it as well.
+// If the canonical url's key is already taken by an unexpired page for a
+// different url, the page is stored under a secondary key; ErrKeyCollision
+// is returned if all of the url's keys are taken. An expired page for a
+// different url is replaced, and everything kept for it is removed.
 // Returns a key for the stored URL (which you actually can't
 // use for anything, so this interface may change)
 func (s *URLDataStore) Save(uptr *resource.WebPage) (uint64, error) {
@@ -68,7 +106,6 @@ func (s *URLDataStore) Save(uptr *resource.WebPage) (uint64, error) {
 		uptr.FetchTime = &now
 	}
 	expireTime, _ := uptr.ExpireTime()
-	key := Key(uptr.CanonicalURL)
 	// We need the copy here becauase uptr might be getting returned
 	// to a client concurrently and the skipMap can be applied inadvertently
 	// in both places
@@ -84,24 +121,81 @@ func (s *URLDataStore) Save(uptr *resource.WebPage) (uint64, error) {
 	if err != nil {
 		return 0, err
 	}
+	var published any
+	if (uptr.Date != nil) && !uptr.Date.IsZero() {
+		published = uptr.Date.Unix()
+	}
+	content, err := database.CompressText(uptr.ContentText)
+	if err != nil {
+		return 0, err
+	}
+	hostname := pageHostname(uptr)
+	var hostnameKey any
+	if hostname != "" {
+		hostnameKey = database.HostnameKey(hostname)
+	}
 	values := []any{
-		key,
+		nil, // the key, which is found in the transaction
 		uptr.CanonicalURL.String(),
 		uptr.RequestedURL.String(),
 		uptr.FetchTime.Unix(),
 		expireTime.Unix(),
 		string(metadata),
-		uptr.ContentText,
+		content,
 		int(uptr.FetchMethod),
+		hostname,
+		published,
+		uptr.StatusCode,
+		len(uptr.ContentText),
+		hostnameKey,
 	}
 
 	stmt, err := s.dbh.Statement(save, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
-		return db.PrepareContext(ctx, qSave)
+		return db.PrepareContext(ctx, s.dbh.Engine.Dialect().Upsert("urls", []string{"id"}, urlsColumns...))
 	})
 	if err != nil {
 		return 0, err
 	}
-	result, err := stmt.ExecContext(s.dbh.Ctx, values...)
+	mapping, err := s.mappingStmts()
+	if err != nil {
+		return 0, err
+	}
+	clearStmts, err := s.clearKeyStmts()
+	if err != nil {
+		return 0, err
+	}
+	indexStmt, err := s.indexStmt()
+	if err != nil {
+		return 0, err
+	}
+	versions, err := s.versionStmts()
+	if err != nil {
+		return 0, err
+	}
+	// The key is found and written in one transaction, so that a page saved
+	// concurrently for another url with the same key can't be overwritten.
+	// The page's search index entry, its version, and the mappings to it from
+	// its requested and redirected urls are written in the same transaction,
+	// so that none of them are stored if any of them fail.
+	tx, err := s.dbh.DB.BeginTx(s.dbh.Ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	mapping = mapping.in(s.dbh.Ctx, tx)
+	key, replaces, err := s.storageKey(mapping.storedURLs, uptr.CanonicalURL)
+	if err != nil {
+		return 0, err
+	}
+	if replaces {
+		// The expired page's history, archived responses and renders, and
+		// the urls that were mapped to it, aren't this page's.
+		if err = s.clearKey(tx, clearStmts, key); err != nil {
+			return 0, err
+		}
+	}
+	values[0] = key
+	result, err := tx.StmtContext(s.dbh.Ctx, stmt).ExecContext(s.dbh.Ctx, values...)
 	if err != nil {
 		return 0, err
 	}
@@ -112,24 +206,176 @@ func (s *URLDataStore) Save(uptr *resource.WebPage) (uint64, error) {
 	if (rows == 0) || (rows > 2) {
 		return 0, fmt.Errorf("expected 1 row affected, got %d", rows)
 	}
-	err = s.storeIdMap(uptr.RequestedURL, key)
-	if err != nil {
+	if err = s.index(tx, indexStmt, key, uptr); err != nil {
+		return 0, err
+	}
+	if err = s.recordVersion(tx, versions, key, uptr, string(metadata)); err != nil {
+		return 0, err
+	}
+	if err = s.mapURL(mapping, uptr.RequestedURL, key); err != nil {
+		return 0, err
+	}
+	if err = s.storeRedirectAliases(mapping, uptr, key); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
 		return 0, err
 	}
 	return key, nil
 }
 
+// Map the urls the page was redirected from, the url it was redirected to, and
+// the AMP or mobile url it was resolved from, to the stored page, so that
+// requests for any of them find it, with the statements from mappingStmts. Urls
+// that can't be mapped because their keys are taken are skipped.
+func (s URLDataStore) storeRedirectAliases(ms *mappingStmts, page *resource.WebPage, canonicalID uint64) error {
+	urls := make([]string, 0, len(page.Redirects)+1)
+	for _, r := range page.Redirects {
+		urls = append(urls, r.URL)
+	}
+	if page.FinalURL != "" {
+		urls = append(urls, page.FinalURL)
+	}
+	if page.VariantURL != "" {
+		urls = append(urls, page.VariantURL)
+	}
+	for _, u := range urls {
+		alias, err := nurl.Parse(u)
+		if err != nil || u == page.RequestedURL.String() || u == page.CanonicalURL.String() {
+			continue
+		}
+		err = s.mapURL(ms, alias, canonicalID)
+		switch {
+		case errors.Is(err, ErrKeyCollision):
+			slog.Debug("Can't map redirect to stored page", "url", u, "canonical", page.CanonicalURL)
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+// The statements that find the key to map a requested url from, and write the
+// mapping. They're prepared before Save's transaction is started, like the
+// statements from clearKeyStmts, and bound to it with in.
+type mappingStmts struct {
+	lookupId   *sql.Stmt
+	storedURLs *sql.Stmt
+	saveId     *sql.Stmt
+}
+
+func (s URLDataStore) mappingStmts() (*mappingStmts, error) {
+	var (
+		ms  mappingStmts
+		err error
+	)
+	if ms.lookupId, err = s.lookupIdStmt(); err != nil {
+		return nil, err
+	}
+	if ms.storedURLs, err = s.storedURLsStmt(); err != nil {
+		return nil, err
+	}
+	if ms.saveId, err = s.saveIdStmt(); err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+// Returns the statements bound to tx.
+func (ms *mappingStmts) in(ctx context.Context, tx *sql.Tx) *mappingStmts {
+	return &mappingStmts{
+		lookupId:   tx.StmtContext(ctx, ms.lookupId),
+		storedURLs: tx.StmtContext(ctx, ms.storedURLs),
+		saveId:     tx.StmtContext(ctx, ms.saveId),
+	}
+}
+
+func (s URLDataStore) saveIdStmt() (*sql.Stmt, error) {
+	return s.dbh.Statement(saveId, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, s.dbh.Engine.Dialect().Upsert("id_map", []string{"requested_id"}, idMapColumns...))
+	})
+}
+
 func (s URLDataStore) storeIdMap(requested *nurl.URL, canonicalID uint64) error {
-	stmt, err := s.dbh.Statement(saveId, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
-		return db.PrepareContext(ctx, qSaveId)
+	ms, err := s.mappingStmts()
+	if err != nil {
+		return err
+	}
+	return s.mapURL(ms, requested, canonicalID)
+}
+
+// Like storeIdMap, with the statements from mappingStmts.
+func (s URLDataStore) mapURL(ms *mappingStmts, requested *nurl.URL, canonicalID uint64) error {
+	requestedID, err := s.mappingKey(ms, requested, canonicalID)
+	if err != nil {
+		return err
+	}
+	_, err = ms.saveId.ExecContext(s.dbh.Ctx, requestedID, canonicalID)
+	return err
+}
+
+// Aliases returns the keys of the requested urls that map to the stored page
+// for a canonical url, including the canonical url's own key if it's mapped.
+// Use SaveAliases to restore the mappings, for instance in another database.
+func (s URLDataStore) Aliases(canonical *nurl.URL) ([]uint64, error) {
+	stmt, err := s.aliasesStmt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.resolveKey(canonical)
+	switch err {
+	case nil:
+	case ErrResourceNotFound:
+		return []uint64{}, nil
+	default:
+		return nil, err
+	}
+	return s.aliasesOf(stmt, key)
+}
+
+func (s URLDataStore) aliasesOf(stmt *sql.Stmt, key uint64) ([]uint64, error) {
+	rows, err := stmt.QueryContext(s.dbh.Ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := make([]uint64, 0, 1)
+	for rows.Next() {
+		var key uint64
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s URLDataStore) aliasesStmt() (*sql.Stmt, error) {
+	return s.dbh.Statement(listAliases, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, qAliases)
 	})
+}
+
+// SaveAliases maps the passed keys, as returned by Aliases, to the stored
+// page for a canonical url.
+func (s URLDataStore) SaveAliases(canonical *nurl.URL, keys []uint64) error {
+	canonicalID, err := s.resolveKey(canonical)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.ExecContext(s.dbh.Ctx, Key(requested), canonicalID)
+	return s.saveAliases(canonicalID, keys)
+}
+
+func (s URLDataStore) saveAliases(canonicalID uint64, keys []uint64) error {
+	stmt, err := s.saveIdStmt()
 	if err != nil {
 		return err
 	}
+	for _, key := range keys {
+		if _, err = stmt.ExecContext(s.dbh.Ctx, key, canonicalID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -140,55 +386,84 @@ func (s URLDataStore) storeIdMap(requested *nurl.URL, canonicalID uint64) error
 // being different than the requested URL.
 //
 // In that case, the canonical version of the content will be returned, if we have it.
+// Pages stored for a different url with the same key are never returned.
 func (s URLDataStore) Fetch(url *nurl.URL) (*resource.WebPage, error) {
-	requested_key := Key(url)
-	key, err := s.lookupId(requested_key)
-	switch err {
-	case ErrMappingNotFound:
-		slog.Debug("sqlite: No mapped key for resource, trying direct key", "url", url.String(), "requested_key", requested_key, "canonical_key", key)
-		key = requested_key
-	case nil:
-		// we have a key
-		slog.Debug("sqlite: Found mapped key for resource", "url", url.String(), "requested_key", requested_key, "canonical_key", key)
-	default:
-		return nil, err
-	}
 	stmt, err := s.dbh.Statement(fetchOne, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
 		return db.PrepareContext(ctx, qFetchOne)
 	})
 	if err != nil {
 		return nil, err
 	}
-	rows, err := stmt.QueryContext(s.dbh.Ctx, key)
+	var (
+		page    *resource.WebPage
+		exptime time.Time
+	)
+	_, err = s.resolve(url, func(key uint64) (*storedURLs, error) {
+		var loadErr error
+		page, exptime, loadErr = loadPage(stmt.QueryRowContext(s.dbh.Ctx, key))
+		switch loadErr {
+		case nil:
+			return &storedURLs{
+				canonical: page.CanonicalURL.String(),
+				requested: page.RequestedURL.String(),
+				expires:   exptime,
+			}, nil
+		case sql.ErrNoRows:
+			page = nil
+			return nil, nil
+		default:
+			return nil, loadErr
+		}
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	if !rows.Next() {
+	if page == nil || time.Now().After(exptime) {
 		return nil, ErrResourceNotFound
 	}
-	// parsed_url, fetch_time, expires, metadata, content_text
+	return page, nil
+}
+
+// Get the key for the canonical version of a URL, using the id map if
+// there's an entry for it, or the key of the URL itself if not. Keys where
+// a different url is stored are skipped, so the key may be a secondary key.
+// Returns ErrResourceNotFound if all of the url's keys are taken by other urls.
+func (s URLDataStore) resolveKey(url *nurl.URL) (uint64, error) {
+	return s.resolve(url, s.storedURLs)
+}
+
+// rowScanner is implemented by *sql.Rows and *sql.Row
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// loadPage builds a WebPage from a row with the columns:
+// url, parsed_url, fetch_time, expires, metadata, content_text, fetch_method
+// (in that order). Any additional columns in the row are scanned into extra.
+// The expiry time for the record is returned along with the page.
+func loadPage(row rowScanner, extra ...any) (*resource.WebPage, time.Time, error) {
 	var (
 		canonicalUrl string
 		parsedUrl    string
 		fetchEpoch   int64
 		expiryEpoch  int64
 		metadata     string
-		contentText  string
+		contentText  []byte
 		fetchMethod  resource.ClientIdentifier
 	)
-	err = rows.Scan(&canonicalUrl, &parsedUrl, &fetchEpoch, &expiryEpoch, &metadata, &contentText, &fetchMethod)
+	dest := append(
+		[]any{&canonicalUrl, &parsedUrl, &fetchEpoch, &expiryEpoch, &metadata, &contentText, &fetchMethod},
+		extra...,
+	)
+	err := row.Scan(dest...)
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 	exptime := time.Unix(expiryEpoch, 0)
-	if time.Now().After(exptime) {
-		return nil, ErrResourceNotFound
-	}
 	page := &resource.WebPage{}
 	err = json.Unmarshal([]byte(metadata), page)
 	if err != nil {
-		return nil, err
+		return nil, exptime, err
 	}
 
 	canonical, _ := nurl.Parse(canonicalUrl)
@@ -200,19 +475,43 @@ func (s URLDataStore) Fetch(url *nurl.URL) (*resource.WebPage, error) {
 	// Calculate actual TTL from now to expiry
 	ttl := exptime.Sub(fetchTime)
 	page.TTL = ttl
-	page.ContentText = contentText
+	if page.ContentText, err = database.DecompressText(contentText); err != nil {
+		return nil, exptime, err
+	}
 	page.FetchMethod = fetchMethod
-	return page, nil
+	return page, exptime, nil
+}
+
+// The hostname that's stored for a page: the hostname reported by the page,
+// or the hostname of its canonical URL if that's missing.
+func pageHostname(page *resource.WebPage) string {
+	if page.Hostname != "" {
+		return strings.ToLower(page.Hostname)
+	}
+	if page.CanonicalURL != nil {
+		return strings.ToLower(page.CanonicalURL.Hostname())
+	}
+	return ""
 }
 
 // Will search url_ids to see if there's a parent entry for this url.
 func (s *URLDataStore) lookupId(requested_id uint64) (uint64, error) {
-	stmt, err := s.dbh.Statement(lookupId, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
-		return db.PrepareContext(ctx, qLookupId)
-	})
+	stmt, err := s.lookupIdStmt()
 	if err != nil {
 		return 0, err
 	}
+	return s.mappedId(stmt, requested_id)
+}
+
+func (s URLDataStore) lookupIdStmt() (*sql.Stmt, error) {
+	return s.dbh.Statement(lookupId, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, qLookupId)
+	})
+}
+
+// Like lookupId, with a statement from lookupIdStmt, which can be bound to a
+// transaction.
+func (s URLDataStore) mappedId(stmt *sql.Stmt, requested_id uint64) (uint64, error) {
 	rows, err := stmt.QueryContext(s.dbh.Ctx, requested_id)
 	if err != nil {
 		return 0, err
@@ -229,19 +528,42 @@ func (s *URLDataStore) lookupId(requested_id uint64) (uint64, error) {
 	return lookupId, nil
 }
 
-// Delete will only delete a url that matches the canonical URL.
-// TODO: Evaluate desired behavior here
-// TODO: Not accounting for lookup keys
+// Delete the stored page for a url, which can be the page's canonical url or any
+// url that was requested and mapped to it. The page, its history, and the mappings
+// to it from all of its requested urls are deleted in one transaction. Returns false
+// if no page was stored for the url; any mappings left for it are still deleted.
 // NB: TTL management is handled by maintenance routines
 func (s *URLDataStore) Delete(url *nurl.URL) (bool, error) {
-	key := Key(url)
+	key, err := s.resolveKey(url)
+	switch err {
+	case nil:
+	case ErrResourceNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+	return s.deleteKey(key)
+}
+
+// Delete the page stored at key, with its history, its archived responses
+// and renders, and the mappings to it.
+func (s *URLDataStore) deleteKey(key uint64) (bool, error) {
 	stmt, err := s.dbh.Statement(delete, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
 		return db.PrepareContext(ctx, qDelete)
 	})
 	if err != nil {
 		return false, err
 	}
-	result, err := stmt.ExecContext(s.dbh.Ctx, key)
+	clearStmts, err := s.clearKeyStmts()
+	if err != nil {
+		return false, err
+	}
+	tx, err := s.dbh.DB.BeginTx(s.dbh.Ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	result, err := tx.StmtContext(s.dbh.Ctx, stmt).ExecContext(s.dbh.Ctx, key)
 	if err != nil {
 		return false, err
 	}
@@ -249,14 +571,62 @@ func (s *URLDataStore) Delete(url *nurl.URL) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	switch rows {
-	case 0:
-		return false, nil
-	case 1:
-		return true, nil
-	default:
+	if rows > 1 {
 		return false, fmt.Errorf("expected 0 or 1 row affected, got %d", rows)
 	}
+	if err = s.clearKey(tx, clearStmts, key); err != nil {
+		return false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// The queries that remove everything kept for a page other than the page
+// itself: its archived responses and renders, the mappings to it, and its
+// history. Archived responses and renders are removed before the mappings,
+// since they're found through them. Queries with two params take the key twice.
+var clearKeyQueries = []struct {
+	idx    stmtIndex
+	query  string
+	params int
+}{
+	{deleteArchives, qDeleteArchives, 2},
+	{deleteRenders, qDeleteRenders, 2},
+	{deleteAliases, qDeleteAliases, 1},
+	{deleteHistory, qDeleteHistory, 1},
+}
+
+// Statements need a connection to be prepared, so they're prepared before a
+// transaction is started; in-memory databases only have one connection.
+func (s *URLDataStore) clearKeyStmts() ([]*sql.Stmt, error) {
+	stmts := make([]*sql.Stmt, 0, len(clearKeyQueries))
+	for _, q := range clearKeyQueries {
+		stmt, err := s.dbh.Statement(q.idx, func(ctx context.Context, db *sql.DB) (*sql.Stmt, error) {
+			return db.PrepareContext(ctx, q.query)
+		})
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+// Remove everything kept for the page at key other than the page itself,
+// with the statements from clearKeyStmts.
+func (s *URLDataStore) clearKey(tx *sql.Tx, stmts []*sql.Stmt, key uint64) error {
+	for i, stmt := range stmts {
+		args := []any{key}
+		if clearKeyQueries[i].params == 2 {
+			args = append(args, key)
+		}
+		if _, err := tx.StmtContext(s.dbh.Ctx, stmt).ExecContext(s.dbh.Ctx, args...); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Clear will delete all url content from the database
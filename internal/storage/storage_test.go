@@ -158,19 +158,18 @@ func TestStore(t *testing.T) {
 	if lid, err := s.lookupId(Key(url)); lid != canonicalId {
 		t.Errorf("Expected lookup id %d, got %d (err: %s)", canonicalId, lid, err)
 	}
-	// NB: Delete only works for canonical URLs
+	// Deleting by the requested url deletes the canonical record
 	ok, err := s.Delete(url)
 	if err != nil {
-		t.Errorf("Unexpected error deleting non-canonical record: %v", err)
-	}
-	if ok {
-		t.Errorf("Delete returned true, deleted non-canonical record (url: %s)", url)
+		t.Errorf("Error deleting record by requested url: %v", err)
+	} else if !ok {
+		t.Errorf("Delete returned false, didn't delete record (url: %s)", url)
 	}
 	ok, err = s.Delete(stored.CanonicalURL)
 	if err != nil {
-		t.Errorf("Error deleting record: %v", err)
-	} else if !ok {
-		t.Errorf("Delete returned false, didn't delete record (url: %s)", url)
+		t.Errorf("Error deleting deleted record: %v", err)
+	} else if ok {
+		t.Errorf("Delete returned true for a deleted record (url: %s)", stored.CanonicalURL)
 	}
 }
 
@@ -235,6 +234,67 @@ func TestCanonicalSelfLookupExists(t *testing.T) {
 	}
 }
 
+func TestAliases(t *testing.T) {
+	s := getURLDataStore(t)
+	page := getWebPage(t)
+	if _, err := s.Save(page); err != nil {
+		t.Fatalf("Error storing page: %v", err)
+	}
+	aliases, err := s.Aliases(page.CanonicalURL)
+	if err != nil {
+		t.Fatalf("Error listing aliases: %v", err)
+	}
+	if !slices.Equal(aliases, []uint64{Key(page.RequestedURL)}) {
+		t.Errorf("Expected the requested url's key as the only alias, got %v", aliases)
+	}
+
+	other, _ := nurl.Parse("https://martinfowler.com/about")
+	if err = s.SaveAliases(page.CanonicalURL, []uint64{Key(other)}); err != nil {
+		t.Fatalf("Error saving aliases: %v", err)
+	}
+	fetched, err := s.Fetch(other)
+	if err != nil {
+		t.Fatalf("Error fetching page by alias: %v", err)
+	}
+	if fetched.CanonicalURL.String() != page.CanonicalURL.String() {
+		t.Errorf("Expected alias to map to %s, got %s", page.CanonicalURL, fetched.CanonicalURL)
+	}
+	if aliases, _ = s.Aliases(page.CanonicalURL); len(aliases) != 2 {
+		t.Errorf("Expected 2 aliases, got %v", aliases)
+	}
+}
+
+func TestRedirectAliases(t *testing.T) {
+	s := getURLDataStore(t)
+	page := getWebPage(t)
+	page.Redirects = []resource.Redirect{
+		{URL: page.RequestedURL.String(), StatusCode: 301},
+		{URL: "https://mf.example/short", StatusCode: 302},
+	}
+	page.FinalURL = "https://martinfowler.com/?utm_source=short"
+	page.VariantURL = "https://m.martinfowler.com/"
+	if _, err := s.Save(page); err != nil {
+		t.Fatalf("Error storing page: %v", err)
+	}
+	for _, u := range []string{"https://mf.example/short", page.FinalURL, page.VariantURL} {
+		url, _ := nurl.Parse(u)
+		fetched, err := s.Fetch(url)
+		if err != nil {
+			t.Errorf("Error fetching page by redirect url %s: %v", u, err)
+			continue
+		}
+		if fetched.CanonicalURL.String() != page.CanonicalURL.String() {
+			t.Errorf("Expected %s to map to %s, got %s", u, page.CanonicalURL, fetched.CanonicalURL)
+		}
+		if len(fetched.Redirects) != 2 || fetched.Redirects[1].StatusCode != 302 || fetched.FinalURL != page.FinalURL {
+			t.Errorf("Expected the redirects to be stored, got %+v, %s", fetched.Redirects, fetched.FinalURL)
+		}
+	}
+	if aliases, _ := s.Aliases(page.CanonicalURL); len(aliases) != 4 {
+		t.Errorf("Expected 4 aliases, got %v", aliases)
+	}
+}
+
 func TestClear(t *testing.T) {
 	s := getURLDataStore(t)
 	res := getWebPage(t)
@@ -274,3 +334,43 @@ func TestDelete(t *testing.T) {
 		t.Errorf("Delete returned false, didn't delete record (url: %s)", res.CanonicalURL)
 	}
 }
+
+func TestDeleteRemovesAliases(t *testing.T) {
+	tests := []struct {
+		name     string
+		deleteBy func(page *resource.WebPage, alias *nurl.URL) *nurl.URL
+	}{
+		{"canonical", func(page *resource.WebPage, _ *nurl.URL) *nurl.URL { return page.CanonicalURL }},
+		{"requested", func(page *resource.WebPage, _ *nurl.URL) *nurl.URL { return page.RequestedURL }},
+		{"alias", func(_ *resource.WebPage, alias *nurl.URL) *nurl.URL { return alias }},
+	}
+	for _, test := range tests {
+		s := getURLDataStore(t)
+		page := getWebPage(t)
+		if _, err := s.Save(page); err != nil {
+			t.Fatalf("[%s] Error storing data: %v", test.name, err)
+		}
+		alias, _ := nurl.Parse(page.CanonicalURL.String() + "?from=feed")
+		if err := s.SaveAliases(page.CanonicalURL, []uint64{Key(alias)}); err != nil {
+			t.Fatalf("[%s] Error saving alias: %v", test.name, err)
+		}
+		ok, err := s.Delete(test.deleteBy(page, alias))
+		if err != nil {
+			t.Fatalf("[%s] Error deleting: %v", test.name, err)
+		}
+		if !ok {
+			t.Errorf("[%s] Delete returned false, didn't delete record", test.name)
+		}
+		for _, url := range []*nurl.URL{page.CanonicalURL, page.RequestedURL, alias} {
+			if _, err := s.Fetch(url); err != ErrResourceNotFound {
+				t.Errorf("[%s] Expected %s to be deleted, got %v", test.name, url, err)
+			}
+			if _, err := s.lookupId(Key(url)); err != ErrMappingNotFound {
+				t.Errorf("[%s] Expected mapping for %s to be deleted, got %v", test.name, url, err)
+			}
+		}
+		if aliases, _ := s.Aliases(page.CanonicalURL); len(aliases) != 0 {
+			t.Errorf("[%s] Expected no aliases after delete, got %v", test.name, aliases)
+		}
+	}
+}
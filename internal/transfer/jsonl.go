@@ -0,0 +1,96 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+// A stored page, as exported to JSON lines. TTL is a Go duration string,
+// and together with the page's fetch time it sets when the page expires.
+// Aliases are the keys of the requested urls that map to the page.
+type PageRecord struct {
+	Page    *resource.WebPage `json:"page"`
+	TTL     string            `json:"ttl"`
+	Aliases []uint64          `json:"aliases,omitempty"`
+}
+
+// Write the stored pages matching the query to w as JSON lines, one
+// PageRecord per line. Returns the number of pages written.
+func (e *Exporter) JSONL(w io.Writer, q storage.ListQuery) (int, error) {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	count := 0
+	err := e.store.Walk(q, func(page *resource.WebPage) error {
+		aliases, err := e.store.Aliases(page.CanonicalURL)
+		if err != nil {
+			return err
+		}
+		err = encoder.Encode(&PageRecord{
+			Page:    page,
+			TTL:     page.TTL.String(),
+			Aliases: aliases,
+		})
+		if err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// Import the pages in a JSON lines file written by Exporter.JSONL. Pages
+// keep their fetch time and expiry, and pages that have already expired are
+// skipped. An error is only returned if the file can't be read; pages that
+// fail to import are logged and counted in the summary.
+func (i *Importer) JSONL(r io.Reader) (*ImportSummary, error) {
+	decoder := json.NewDecoder(r)
+	summary := &ImportSummary{}
+	for line := 1; ; line++ {
+		var rec PageRecord
+		if err := decoder.Decode(&rec); err == io.EOF {
+			return summary, nil
+		} else if err != nil {
+			return summary, fmt.Errorf("error reading record %d: %w", line, err)
+		}
+		err := i.saveRecord(&rec)
+		switch {
+		case errors.Is(err, errFiltered):
+			summary.Skipped++
+		case err != nil:
+			slog.Warn("Error importing record", "record", line, "err", err)
+			summary.Failed++
+		default:
+			summary.Imported++
+		}
+	}
+}
+
+func (i *Importer) saveRecord(rec *PageRecord) error {
+	page := rec.Page
+	if page == nil || page.CanonicalURL == nil || page.FetchTime == nil {
+		return errors.New("record has no page url or fetch time")
+	}
+	if page.RequestedURL == nil {
+		page.RequestedURL = page.CanonicalURL
+	}
+	ttl, err := time.ParseDuration(rec.TTL)
+	if err != nil {
+		return err
+	}
+	page.TTL = ttl
+	if expires, _ := page.ExpireTime(); ttl <= 0 || expires.Before(time.Now()) || !i.matches(page) {
+		return errFiltered
+	}
+	if _, err = i.store.Save(page); err != nil {
+		return err
+	}
+	return i.store.SaveAliases(page.CanonicalURL, rec.Aliases)
+}
@@ -0,0 +1,120 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/json"
+	nurl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+func TestJSONLRoundTrip(t *testing.T) {
+	store, _ := openStore(t)
+	page := savePage(t, store, "https://example.com/page", "Page Title", "Page content")
+	alias, _ := nurl.Parse("https://example.com/alias")
+	if err := store.SaveAliases(page.CanonicalURL, []uint64{storage.Key(alias)}); err != nil {
+		t.Fatalf("Error saving alias: %v", err)
+	}
+	stored, err := store.Fetch(page.CanonicalURL)
+	if err != nil {
+		t.Fatalf("Error fetching page: %v", err)
+	}
+	expires, _ := stored.ExpireTime()
+
+	var buf bytes.Buffer
+	count, err := NewExporter(store, nil).JSONL(&buf, storage.ListQuery{})
+	if err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 page exported, got %d", count)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 1 {
+		t.Errorf("Expected 1 line, got %d", lines)
+	}
+
+	imported, _ := openStore(t)
+	summary, err := NewImporter(imported, time.Minute).JSONL(&buf)
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if *summary != (ImportSummary{Imported: 1}) {
+		t.Errorf("Expected 1 page imported, got %+v", summary)
+	}
+	for _, url := range []*nurl.URL{page.CanonicalURL, alias} {
+		got, err := imported.Fetch(url)
+		if err != nil {
+			t.Fatalf("Error fetching imported page %s: %v", url, err)
+		}
+		if got.Title != page.Title || got.ContentText != page.ContentText || got.FetchMethod != page.FetchMethod {
+			t.Errorf("Expected %+v for %s, got %+v", page, url, got)
+		}
+		if !got.FetchTime.Equal(*page.FetchTime) {
+			t.Errorf("Expected fetch time %v for %s, got %v", page.FetchTime, url, got.FetchTime)
+		}
+		// the original expiry is kept, rather than the importer's ttl
+		if gotExpires, _ := got.ExpireTime(); !gotExpires.Equal(expires) {
+			t.Errorf("Expected %s to expire at %v, got %v", url, expires, gotExpires)
+		}
+	}
+}
+
+func TestJSONLImportFilters(t *testing.T) {
+	record := func(url string, fetchTime time.Time, ttl time.Duration) string {
+		u, _ := nurl.Parse(url)
+		line, _ := json.Marshal(&PageRecord{
+			Page: &resource.WebPage{
+				RequestedURL: u,
+				CanonicalURL: u,
+				FetchTime:    &fetchTime,
+				Hostname:     u.Hostname(),
+				Title:        url,
+			},
+			TTL: ttl.String(),
+		})
+		return string(line) + "\n"
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	input := record("https://example.com/recent", now.Add(-time.Hour), 24*time.Hour) +
+		record("https://www.example.com/subdomain", now.Add(-time.Hour), 24*time.Hour) +
+		record("https://example.com/old", now.Add(-72*time.Hour), 96*time.Hour) +
+		record("https://example.com/expired", now.Add(-2*time.Hour), time.Hour) +
+		record("https://example.org/other", now.Add(-time.Hour), 24*time.Hour)
+
+	store, _ := openStore(t)
+	importer := NewImporter(store, 0, WithFilter(storage.ListQuery{
+		Hostname:     "example.com",
+		FetchedSince: now.Add(-24 * time.Hour),
+	}))
+	summary, err := importer.JSONL(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if *summary != (ImportSummary{Imported: 2, Skipped: 3}) {
+		t.Errorf("Expected 2 pages imported and 3 skipped, got %+v", summary)
+	}
+	for _, test := range []struct {
+		url         string
+		expectFound bool
+	}{
+		{"https://example.com/recent", true},
+		{"https://www.example.com/subdomain", true},
+		{"https://example.com/old", false},
+		{"https://example.com/expired", false},
+		{"https://example.org/other", false},
+	} {
+		u, _ := nurl.Parse(test.url)
+		_, err := store.Fetch(u)
+		if found := err == nil; found != test.expectFound {
+			t.Errorf("Expected found=%v for %s, got error %v", test.expectFound, test.url, err)
+		}
+	}
+
+	if _, err := importer.JSONL(strings.NewReader("{not json")); err == nil {
+		t.Error("Expected an error reading invalid JSON")
+	}
+}
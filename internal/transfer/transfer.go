@@ -0,0 +1,84 @@
+// Export and import of stored pages, for moving content between
+// deployments and seeding the store from other archives.
+package transfer
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/resource"
+)
+
+var errFiltered = errors.New("page doesn't match the import filter")
+
+// Counts of the pages handled by an import. Skipped pages are responses
+// that can't be extracted, like errors and non-HTML content, and pages
+// that don't match the importer's filter or have already expired.
+type ImportSummary struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+	Failed   int `json:"failed"`
+}
+
+type option func(*Importer)
+
+// Only import pages matching the Hostname, FetchedSince and FetchedUntil
+// criteria of the query. Other query fields are ignored.
+func WithFilter(q storage.ListQuery) option {
+	return func(i *Importer) {
+		i.filter = q
+	}
+}
+
+// Normalize the urls of pages imported from WARC files with n, instead of
+// resource.DefaultURLNormalizer, so that they're stored under the same keys
+// as the pages that are fetched.
+func WithURLNormalizer(n *resource.URLNormalizer) option {
+	return func(i *Importer) {
+		i.normalizer = n
+	}
+}
+
+type Importer struct {
+	store      *storage.URLDataStore
+	ttl        time.Duration
+	filter     storage.ListQuery
+	normalizer *resource.URLNormalizer
+}
+
+// Make an importer that saves pages to store. Pages imported from WARC
+// files keep their fetch time, but expire ttl after they're imported.
+// Pages imported from JSON lines keep their original expiry.
+func NewImporter(store *storage.URLDataStore, ttl time.Duration, options ...option) *Importer {
+	if ttl <= 0 {
+		ttl = resource.DefaultTTL
+	}
+	i := &Importer{store: store, ttl: ttl}
+	for _, opt := range options {
+		opt(i)
+	}
+	return i
+}
+
+func (i *Importer) matches(page *resource.WebPage) bool {
+	f := i.filter
+	if !f.FetchedSince.IsZero() && page.FetchTime.Before(f.FetchedSince) {
+		return false
+	}
+	if !f.FetchedUntil.IsZero() && !page.FetchTime.Before(f.FetchedUntil) {
+		return false
+	}
+	if f.Hostname != "" {
+		want := strings.ToLower(f.Hostname)
+		host := strings.ToLower(page.Hostname)
+		if host == "" {
+			host = strings.ToLower(page.CanonicalURL.Hostname())
+		}
+		if host != want && !strings.HasSuffix(host, "."+want) {
+			return false
+		}
+	}
+	return true
+}
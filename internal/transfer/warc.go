@@ -0,0 +1,237 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	nurl "net/url"
+	"time"
+
+	"github.com/efixler/scrape/fetch"
+	"github.com/efixler/scrape/internal"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/internal/warc"
+	"github.com/efixler/scrape/resource"
+)
+
+const metadataType = "application/json"
+
+type Exporter struct {
+	store   *storage.URLDataStore
+	archive *storage.ArchiveStore
+}
+
+// Make an exporter for the pages in store. archive may be nil, in which
+// case raw responses aren't exported.
+func NewExporter(store *storage.URLDataStore, archive *storage.ArchiveStore) *Exporter {
+	return &Exporter{store: store, archive: archive}
+}
+
+// Write the stored pages matching the query to w as WARC records. Each page
+// is written as a metadata record holding the page's JSON, preceded by a
+// response record with the page's raw response, if it was archived.
+// Returns the number of pages written.
+func (e *Exporter) WARC(w io.Writer, q storage.ListQuery, compress bool) (int, error) {
+	ww := warc.NewWriter(w, compress)
+	info := warc.NewRecord(warc.WarcInfo)
+	info.ContentType = warc.WarcFieldsType
+	info.Block = []byte("software: scrape\r\nformat: WARC File Format 1.1\r\n")
+	if err := ww.Write(info); err != nil {
+		return 0, err
+	}
+	count := 0
+	err := e.store.Walk(q, func(page *resource.WebPage) error {
+		var concurrentTo string
+		if e.archive != nil {
+			archived, err := e.archive.Load(page.RequestedURL)
+			switch {
+			case err == nil:
+				rec, err := responseRecord(archived)
+				if err != nil {
+					return err
+				}
+				if err = ww.Write(rec); err != nil {
+					return err
+				}
+				concurrentTo = rec.ID
+			case !errors.Is(err, storage.ErrResourceNotFound):
+				return err
+			}
+		}
+		metadata, err := page.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		rec := warc.NewRecord(warc.Metadata)
+		rec.TargetURI = page.CanonicalURL.String()
+		rec.Date = *page.FetchTime
+		rec.ConcurrentTo = concurrentTo
+		rec.ContentType = metadataType
+		rec.Block = metadata
+		if err = ww.Write(rec); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// Make a response record from an archived response. The archived body
+// has already been decoded, so the headers describing its encoding on
+// the wire are replaced with the body's actual length.
+func responseRecord(archived *storage.Archived) (*warc.Record, error) {
+	header := archived.Header.Clone()
+	for _, h := range []string{"Content-Length", "Content-Encoding", "Transfer-Encoding"} {
+		header.Del(h)
+	}
+	resp := &http.Response{
+		StatusCode:    http.StatusOK,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(archived.Body)),
+		ContentLength: int64(len(archived.Body)),
+	}
+	var block bytes.Buffer
+	if err := resp.Write(&block); err != nil {
+		return nil, err
+	}
+	rec := warc.NewRecord(warc.Response)
+	rec.TargetURI = archived.URL.String()
+	rec.Date = archived.FetchTime
+	rec.ContentType = warc.HTTPResponseType
+	rec.Block = block.Bytes()
+	return rec, nil
+}
+
+// Import the pages in a WARC file. Response records are run through the
+// extractor and saved. Metadata records written by Exporter.WARC are saved
+// as is, unless they accompany a response record. Other records are ignored.
+// An error is only returned if the WARC file can't be read; pages that fail
+// to import are logged and counted in the summary.
+func (i *Importer) WARC(r io.Reader) (*ImportSummary, error) {
+	wr, err := warc.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	summary := &ImportSummary{}
+	responses := make(map[string]bool)
+	for {
+		rec, err := wr.Next()
+		if err == io.EOF {
+			return summary, nil
+		} else if err != nil {
+			return summary, err
+		}
+		var page *resource.WebPage
+		switch {
+		case rec.Type == warc.Response && isHTTPResponse(rec.ContentType):
+			responses[rec.ID] = true
+			page, err = i.extractRecord(rec)
+		case rec.Type == warc.Metadata && rec.ContentType == metadataType:
+			if responses[rec.ConcurrentTo] {
+				continue
+			}
+			page, err = metadataPage(rec)
+		default:
+			continue
+		}
+		switch {
+		case errors.Is(err, errNotExtractable):
+			summary.Skipped++
+			continue
+		case err == nil:
+			err = i.save(page, rec.Date)
+		}
+		if errors.Is(err, errFiltered) {
+			summary.Skipped++
+			continue
+		} else if err != nil {
+			slog.Warn("Error importing WARC record", "url", rec.TargetURI, "id", rec.ID, "err", err)
+			summary.Failed++
+			continue
+		}
+		summary.Imported++
+	}
+}
+
+// Save a page from a WARC record, which expires the importer's ttl from
+// now, regardless of when it was fetched.
+func (i *Importer) save(page *resource.WebPage, fetchTime time.Time) error {
+	if page.FetchTime == nil || page.FetchTime.IsZero() {
+		if fetchTime.IsZero() {
+			fetchTime = time.Now().UTC()
+		}
+		page.FetchTime = &fetchTime
+	}
+	if !i.matches(page) {
+		return errFiltered
+	}
+	page.TTL = time.Since(*page.FetchTime) + i.ttl
+	_, err := i.store.Save(page)
+	return err
+}
+
+var errNotExtractable = errors.New("response can't be extracted")
+
+func isHTTPResponse(contentType string) bool {
+	mtype, params, err := mime.ParseMediaType(contentType)
+	return err == nil && mtype == "application/http" && params["msgtype"] == "response"
+}
+
+// Run a response record through the extractor.
+func (i *Importer) extractRecord(rec *warc.Record) (*resource.WebPage, error) {
+	url, err := nurl.Parse(rec.TargetURI)
+	if err != nil || !url.IsAbs() {
+		return nil, errors.Join(errNotExtractable, err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rec.Block)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errNotExtractable
+	}
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = zr
+		resp.Header.Del("Content-Encoding")
+	}
+	page, err := internal.ExtractResponse(i.normalizer.Normalize(url), resp, resource.Unspecified)
+	if errors.Is(err, fetch.ErrUnsupportedContentType) {
+		return nil, errors.Join(errNotExtractable, err)
+	} else if err != nil {
+		return nil, err
+	}
+	// the page was fetched when the response was recorded, not now
+	if !rec.Date.IsZero() {
+		fetchTime := rec.Date
+		page.FetchTime = &fetchTime
+	}
+	return page, nil
+}
+
+// Load a page from a metadata record written by Exporter.WARC.
+func metadataPage(rec *warc.Record) (*resource.WebPage, error) {
+	page := &resource.WebPage{}
+	if err := page.UnmarshalJSON(rec.Block); err != nil {
+		return nil, err
+	}
+	if page.CanonicalURL == nil {
+		return nil, errors.Join(errNotExtractable, errors.New("no url in metadata"))
+	}
+	if page.RequestedURL == nil {
+		page.RequestedURL = page.CanonicalURL
+	}
+	return page, nil
+}
@@ -0,0 +1,141 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	nurl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/efixler/scrape/database"
+	"github.com/efixler/scrape/database/sqlite"
+	"github.com/efixler/scrape/internal/storage"
+	"github.com/efixler/scrape/internal/warc"
+	"github.com/efixler/scrape/resource"
+)
+
+func openStore(t *testing.T) (*storage.URLDataStore, *storage.ArchiveStore) {
+	dbh := database.New(sqlite.MustNew(sqlite.InMemoryDB()))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := dbh.Open(ctx); err != nil {
+		t.Fatalf("Could not open database: %v", err)
+	}
+	return storage.NewURLDataStore(dbh), storage.NewArchiveStore(dbh, 0)
+}
+
+func savePage(t *testing.T, store *storage.URLDataStore, url string, title string, content string) *resource.WebPage {
+	u, _ := nurl.Parse(url)
+	fetchTime := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	page := &resource.WebPage{
+		RequestedURL: u,
+		CanonicalURL: u,
+		FetchTime:    &fetchTime,
+		FetchMethod:  resource.DefaultClient,
+		StatusCode:   200,
+		Title:        title,
+		ContentText:  content,
+	}
+	if _, err := store.Save(page); err != nil {
+		t.Fatalf("Error saving page: %v", err)
+	}
+	return page
+}
+
+func TestWARCRoundTrip(t *testing.T) {
+	store, archive := openStore(t)
+	archivedPage := savePage(t, store, "https://example.com/archived", "Stored Title", "Stored content")
+	html := "<html><head><title>Archived Title</title></head><body><p>Archived content</p></body></html>"
+	header := http.Header{"Content-Type": {"text/html"}, "Content-Encoding": {"gzip"}}
+	if err := archive.Archive(archivedPage.RequestedURL, header, []byte(html)); err != nil {
+		t.Fatalf("Error archiving: %v", err)
+	}
+	plainPage := savePage(t, store, "https://example.com/plain", "Plain Title", "Plain content")
+
+	var buf bytes.Buffer
+	count, err := NewExporter(store, archive).WARC(&buf, storage.ListQuery{}, true)
+	if err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 pages exported, got %d", count)
+	}
+
+	imported, _ := openStore(t)
+	summary, err := NewImporter(imported, time.Hour).WARC(&buf)
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if *summary != (ImportSummary{Imported: 2}) {
+		t.Errorf("Expected 2 pages imported, got %+v", summary)
+	}
+	archived, _ := archive.Load(archivedPage.RequestedURL)
+	for _, test := range []struct {
+		url             *nurl.URL
+		expectTitle     string
+		expectContent   string
+		expectFetchTime time.Time
+	}{
+		// the archived page is extracted from its response, and was fetched
+		// when the response was archived
+		{archivedPage.CanonicalURL, "Archived Title", "Archived content", archived.FetchTime},
+		{plainPage.CanonicalURL, "Plain Title", "Plain content", *plainPage.FetchTime},
+	} {
+		page, err := imported.Fetch(test.url)
+		if err != nil {
+			t.Fatalf("Error fetching imported page %s: %v", test.url, err)
+		}
+		if page.Title != test.expectTitle || !strings.Contains(page.ContentText, test.expectContent) {
+			t.Errorf("Expected %q / %q for %s, got %q / %q", test.expectTitle, test.expectContent, test.url, page.Title, page.ContentText)
+		}
+		if !page.FetchTime.Equal(test.expectFetchTime) {
+			t.Errorf("Expected fetch time %v for %s, got %v", test.expectFetchTime, test.url, page.FetchTime)
+		}
+		if expires := page.FetchTime.Add(page.TTL); time.Until(expires) < 59*time.Minute {
+			t.Errorf("Expected %s to expire an hour after import, expires %v", test.url, expires)
+		}
+	}
+}
+
+func TestImportSkipsUnextractableResponses(t *testing.T) {
+	httpResponse := func(status string, contentType string, body string) string {
+		return fmt.Sprintf("HTTP/1.1 %s\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n%s", status, contentType, len(body), body)
+	}
+	var buf bytes.Buffer
+	w := warc.NewWriter(&buf, false)
+	records := []struct {
+		recordType string
+		block      string
+	}{
+		{warc.Request, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"},
+		{warc.Response, httpResponse("404 Not Found", "text/html", "Not Found")},
+		{warc.Response, httpResponse("200 OK", "image/png", "PNG")},
+		{warc.Response, httpResponse("200 OK", "text/html", "<html><head><title>OK</title></head><body>OK</body></html>")},
+	}
+	for i, r := range records {
+		rec := warc.NewRecord(r.recordType)
+		rec.TargetURI = "https://example.com/" + string(rune('a'+i))
+		if r.recordType == warc.Response {
+			rec.ContentType = warc.HTTPResponseType
+		}
+		rec.Block = []byte(r.block)
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, _ := openStore(t)
+	summary, err := NewImporter(store, 0).WARC(&buf)
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if *summary != (ImportSummary{Imported: 1, Skipped: 2}) {
+		t.Errorf("Expected 1 page imported and 2 skipped, got %+v", summary)
+	}
+	url, _ := nurl.Parse("https://example.com/d")
+	if _, err := store.Fetch(url); err != nil {
+		t.Errorf("Expected imported page to be stored, got %v", err)
+	}
+}
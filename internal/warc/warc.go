@@ -0,0 +1,203 @@
+// Reading and writing of WARC (Web ARChive) files, as defined by
+// ISO 28500. Both plain and gzip compressed files can be read, and
+// compressed files are written with each record in its own gzip member,
+// as is conventional.
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Version = "WARC/1.1"
+
+	WarcInfo = "warcinfo"
+	Response = "response"
+	Resource = "resource"
+	Request  = "request"
+	Metadata = "metadata"
+	Revisit  = "revisit"
+
+	HTTPResponseType = "application/http; msgtype=response"
+	WarcFieldsType   = "application/warc-fields"
+
+	// Records with larger blocks than this aren't read.
+	MaxBlockSize = 64 * 1024 * 1024
+)
+
+var (
+	ErrInvalidRecord  = errors.New("invalid WARC record")
+	ErrRecordTooLarge = errors.New("WARC record is too large")
+)
+
+// A WARC record. The named fields are the most commonly used WARC
+// header fields; they're written ahead of any other fields in Header,
+// and are filled in from the header when a record is read. Header
+// keys are canonicalized, so use Header.Get to read other fields.
+type Record struct {
+	Type         string
+	ID           string
+	Date         time.Time
+	TargetURI    string
+	ContentType  string
+	ConcurrentTo string
+	Header       textproto.MIMEHeader
+	Block        []byte
+}
+
+// Make a record with a new record id, dated now.
+func NewRecord(recordType string) *Record {
+	return &Record{
+		Type:   recordType,
+		ID:     NewRecordID(),
+		Date:   time.Now().UTC().Truncate(time.Second),
+		Header: make(textproto.MIMEHeader),
+	}
+}
+
+// Generate a new, random record id, as a urn:uuid.
+func NewRecordID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+type Writer struct {
+	w        io.Writer
+	compress bool
+}
+
+// Make a writer for WARC records. When compress is set, each record is
+// written as a separate gzip member.
+func NewWriter(w io.Writer, compress bool) *Writer {
+	return &Writer{w: w, compress: compress}
+}
+
+func (w *Writer) Write(rec *Record) error {
+	if rec.Type == "" || rec.ID == "" {
+		return fmt.Errorf("%w: type and id are required", ErrInvalidRecord)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(Version + "\r\n")
+	writeField := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+		}
+	}
+	writeField("WARC-Type", rec.Type)
+	writeField("WARC-Record-ID", rec.ID)
+	writeField("WARC-Date", rec.Date.UTC().Format(time.RFC3339))
+	writeField("WARC-Target-URI", rec.TargetURI)
+	writeField("WARC-Concurrent-To", rec.ConcurrentTo)
+	writeField("Content-Type", rec.ContentType)
+	for name, values := range rec.Header {
+		if isNamedField(name) {
+			continue
+		}
+		for _, v := range values {
+			writeField(name, v)
+		}
+	}
+	writeField("Content-Length", strconv.Itoa(len(rec.Block)))
+	buf.WriteString("\r\n")
+	buf.Write(rec.Block)
+	buf.WriteString("\r\n\r\n")
+
+	if !w.compress {
+		_, err := w.w.Write(buf.Bytes())
+		return err
+	}
+	zw := gzip.NewWriter(w.w)
+	if _, err := zw.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+type Reader struct {
+	r  *bufio.Reader
+	tp *textproto.Reader
+}
+
+// Make a reader for WARC records. Gzip compressed input is detected
+// and decompressed.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(zr)
+	}
+	return &Reader{r: br, tp: textproto.NewReader(br)}, nil
+}
+
+// Read the next record. Returns io.EOF when there are no more records.
+func (r *Reader) Next() (*Record, error) {
+	var line string
+	var err error
+	// skip the blank lines that end the previous record
+	for line == "" {
+		if line, err = r.tp.ReadLine(); err != nil {
+			return nil, err
+		}
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, fmt.Errorf("%w: expected a WARC version line, got %q", ErrInvalidRecord, line)
+	}
+	header, err := r.tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, errors.Join(ErrInvalidRecord, err)
+	}
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("%w: bad Content-Length %q", ErrInvalidRecord, header.Get("Content-Length"))
+	}
+	if length > MaxBlockSize {
+		return nil, fmt.Errorf("%w: Content-Length %d is more than %d", ErrRecordTooLarge, length, MaxBlockSize)
+	}
+	rec := &Record{
+		Type:         header.Get("WARC-Type"),
+		ID:           header.Get("WARC-Record-ID"),
+		TargetURI:    strings.Trim(header.Get("WARC-Target-URI"), "<>"),
+		ContentType:  header.Get("Content-Type"),
+		ConcurrentTo: header.Get("WARC-Concurrent-To"),
+		Header:       header,
+	}
+	if date := header.Get("WARC-Date"); date != "" {
+		if rec.Date, err = time.Parse(time.RFC3339Nano, date); err != nil {
+			return nil, errors.Join(ErrInvalidRecord, err)
+		}
+	}
+	// The block grows as it's read, so a Content-Length that's longer than
+	// the input doesn't allocate space for data that isn't there.
+	if rec.Block, err = io.ReadAll(io.LimitReader(r.r, length)); err != nil {
+		return nil, errors.Join(ErrInvalidRecord, err)
+	}
+	if int64(len(rec.Block)) < length {
+		return nil, fmt.Errorf("%w: block is shorter than its Content-Length %d", ErrInvalidRecord, length)
+	}
+	return rec, nil
+}
+
+func isNamedField(name string) bool {
+	switch textproto.CanonicalMIMEHeaderKey(name) {
+	case "Warc-Type", "Warc-Record-Id", "Warc-Date", "Warc-Target-Uri",
+		"Warc-Concurrent-To", "Content-Type", "Content-Length":
+		return true
+	}
+	return false
+}
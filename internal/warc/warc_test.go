@@ -0,0 +1,126 @@
+package warc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	response := NewRecord(Response)
+	response.TargetURI = "https://example.com/page"
+	response.ContentType = HTTPResponseType
+	response.Block = []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<html>hello</html>")
+	metadata := NewRecord(Metadata)
+	metadata.TargetURI = response.TargetURI
+	metadata.ConcurrentTo = response.ID
+	metadata.ContentType = "application/json"
+	metadata.Header.Set("WARC-Block-Digest", "sha1:abc")
+	metadata.Block = []byte(`{"title":"hello"}`)
+
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, compress)
+		for _, rec := range []*Record{response, metadata} {
+			if err := w.Write(rec); err != nil {
+				t.Fatalf("[compress=%v] Error writing record: %v", compress, err)
+			}
+		}
+		r, err := NewReader(&buf)
+		if err != nil {
+			t.Fatalf("[compress=%v] Error making reader: %v", compress, err)
+		}
+		for _, expected := range []*Record{response, metadata} {
+			rec, err := r.Next()
+			if err != nil {
+				t.Fatalf("[compress=%v] Error reading record: %v", compress, err)
+			}
+			if rec.Type != expected.Type || rec.ID != expected.ID || rec.TargetURI != expected.TargetURI ||
+				rec.ContentType != expected.ContentType || rec.ConcurrentTo != expected.ConcurrentTo ||
+				!rec.Date.Equal(expected.Date) || !bytes.Equal(rec.Block, expected.Block) {
+				t.Errorf("[compress=%v] Expected %+v, got %+v", compress, expected, rec)
+			}
+		}
+		if rec, err := r.Next(); err != io.EOF {
+			t.Errorf("[compress=%v] Expected io.EOF, got %v, %v", compress, rec, err)
+		}
+		if metadata.Header.Get("WARC-Block-Digest") != "sha1:abc" {
+			t.Errorf("[compress=%v] Expected extra header fields to be kept", compress)
+		}
+	}
+}
+
+func TestReadWARC10(t *testing.T) {
+	t.Parallel()
+	input := "WARC/1.0\r\n" +
+		"WARC-Type: response\r\n" +
+		"WARC-Date: 2024-03-01T12:30:45Z\r\n" +
+		"WARC-Record-ID: <urn:uuid:12345678-1234-1234-1234-123456789012>\r\n" +
+		"WARC-Target-URI: <https://example.com/>\r\n" +
+		"Content-Type: application/http; msgtype=response\r\n" +
+		"Content-Length: 5\r\n" +
+		"\r\n" +
+		"hello\r\n\r\n"
+	r, err := NewReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatalf("Error reading record: %v", err)
+	}
+	if rec.TargetURI != "https://example.com/" {
+		t.Errorf("Expected target uri without brackets, got %q", rec.TargetURI)
+	}
+	if !rec.Date.Equal(time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC)) {
+		t.Errorf("Unexpected date %v", rec.Date)
+	}
+	if string(rec.Block) != "hello" {
+		t.Errorf("Expected block %q, got %q", "hello", rec.Block)
+	}
+}
+
+func TestInvalidRecords(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		input     string
+		expectErr error
+	}{
+		{"not warc", "HTTP/1.1 200 OK\r\n\r\n", ErrInvalidRecord},
+		{"no length", "WARC/1.1\r\nWARC-Type: response\r\n\r\n", ErrInvalidRecord},
+		{"short block", "WARC/1.1\r\nWARC-Type: response\r\nContent-Length: 10\r\n\r\nhello", ErrInvalidRecord},
+		{"negative length", "WARC/1.1\r\nWARC-Type: response\r\nContent-Length: -5\r\n\r\nhello", ErrInvalidRecord},
+		{"bogus length", "WARC/1.1\r\nWARC-Type: response\r\nContent-Length: 1099511627776\r\n\r\nhello", ErrRecordTooLarge},
+		{"short block under limit", "WARC/1.1\r\nWARC-Type: response\r\nContent-Length: 60000000\r\n\r\nhello", ErrInvalidRecord},
+	}
+	for _, test := range tests {
+		r, err := NewReader(strings.NewReader(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Next(); !errors.Is(err, test.expectErr) {
+			t.Errorf("[%s] Expected %v, got %v", test.name, test.expectErr, err)
+		}
+	}
+	if err := NewWriter(io.Discard, false).Write(&Record{}); !errors.Is(err, ErrInvalidRecord) {
+		t.Errorf("Expected ErrInvalidRecord writing a record without a type, got %v", err)
+	}
+}
+
+func TestNewRecordID(t *testing.T) {
+	t.Parallel()
+	pattern := regexp.MustCompile(`^<urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}>$`)
+	id := NewRecordID()
+	if !pattern.MatchString(id) {
+		t.Errorf("Unexpected record id format %q", id)
+	}
+	if id == NewRecordID() {
+		t.Error("Expected record ids to be unique")
+	}
+}
@@ -13,12 +13,16 @@ const (
 	Unspecified ClientIdentifier = iota
 	DefaultClient
 	HeadlessChromium
+	// Fetched with the headless browser after the direct client couldn't
+	// extract the page's content.
+	HeadlessFallback
 )
 
 var fetchClientNames = map[ClientIdentifier]string{
 	Unspecified:      "unspecified",
 	DefaultClient:    "direct",
 	HeadlessChromium: "chromium-headless",
+	HeadlessFallback: "chromium-headless-fallback",
 }
 
 var ErrNoSuchFetchMethod = errors.New("no such fetch client identifier")
@@ -22,9 +22,14 @@ func TestFetchMethodString(t *testing.T) {
 			f:    HeadlessChromium,
 			want: "chromium-headless",
 		},
+		{
+			name: "Headless fallback",
+			f:    HeadlessFallback,
+			want: "chromium-headless-fallback",
+		},
 		{
 			name: "Unknown",
-			f:    3,
+			f:    99,
 			want: "Unknown",
 		},
 	}
@@ -47,6 +52,7 @@ func TestUnmarshal(t *testing.T) {
 		{input: "unspecified", expectedValue: Unspecified},
 		{input: "direct", expectedValue: DefaultClient},
 		{input: "chromium-headless", expectedValue: HeadlessChromium},
+		{input: "chromium-headless-fallback", expectedValue: HeadlessFallback},
 		{input: "1", expectError: true},
 	}
 	c := &container{}
@@ -72,6 +78,7 @@ func TestMarshal(t *testing.T) {
 		{input: 0, expectedValue: fetchClientNames[Unspecified]},
 		{input: 1, expectedValue: fetchClientNames[DefaultClient]},
 		{input: 2, expectedValue: fetchClientNames[HeadlessChromium]},
+		{input: 3, expectedValue: fetchClientNames[HeadlessFallback]},
 		{input: -1, expectError: true},
 	}
 	for _, test := range tests {
@@ -1,32 +1,216 @@
 package resource
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	nurl "net/url"
+	"slices"
+	"strings"
 )
 
-// var StripParamsMatch = regexp.MustCompile(`^(utm_\w+)`)
+const MaxURLRuleParams = 100
 
-var illegalParams = []string{
-	"utm_source",
-	"utm_medium",
-	"utm_campaign",
-	"utm_term",
-	"utm_content",
-	"utm_brand",
+var ErrInvalidURLRules = errors.New("invalid url rules")
+
+// Query params that are removed from every url by default: campaign and click
+// tracking params that don't change the page. Names ending in '*' are prefixes.
+var DefaultTrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"gbraid",
+	"wbraid",
+	"msclkid",
+	"yclid",
+	"twclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_gl",
+	"_hsenc",
+	"_hsmi",
+	"mkt_tok",
+	"oly_anon_id",
+	"oly_enc_id",
+	"vero_id",
 }
 
-// CleanURL removes utm_ parameters from the URL
-func CleanURL(url *nurl.URL) *nurl.URL {
+// The rules that CleanURL, and nil URLNormalizers, normalize urls with. Paths are
+// left as they are, since a trailing slash can make a different page.
+var DefaultURLNormalizer = URLNormalizer{
+	TrackingParams: DefaultTrackingParams,
+}
+
+// Per-domain rules for normalizing urls, which are applied along with the global ones.
+type URLRules struct {
+	// Query params to remove, in addition to the global tracking params.
+	// Names ending in '*' are prefixes.
+	StripParams []string `json:"strip_params,omitempty"`
+	// Query params that matter for the domain's pages. When set, all other
+	// params are removed. Names ending in '*' are prefixes.
+	KeepParams []string `json:"keep_params,omitempty"`
+	// Leave the paths of the domain's urls as they are, instead of removing
+	// trailing slashes.
+	KeepPath bool `json:"keep_path,omitempty"`
+}
+
+func (r *URLRules) UnmarshalJSON(b []byte) error {
+	type alias URLRules
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode((*alias)(r)); err != nil {
+		return err
+	}
+	return r.Validate()
+}
+
+func (r URLRules) Validate() error {
+	for _, params := range [][]string{r.StripParams, r.KeepParams} {
+		if len(params) > MaxURLRuleParams {
+			return fmt.Errorf("%w: at most %d params", ErrInvalidURLRules, MaxURLRuleParams)
+		}
+		for _, p := range params {
+			if p == "" || p == "*" || strings.ContainsAny(p, "=&#? ") {
+				return fmt.Errorf("%w: invalid param name %q", ErrInvalidURLRules, p)
+			}
+		}
+	}
+	return nil
+}
+
+// Normalizes urls, so that the different forms of a page's url are stored and
+// cached as the same url. Hosts are always lower cased, without default ports,
+// fragments are removed, and query params are sorted by name. Params keep their
+// original encoding, so that the url that's fetched has the same params.
+type URLNormalizer struct {
+	// Query params to remove from every url. Names ending in '*' are prefixes.
+	TrackingParams []string
+	// Remove trailing slashes from paths. AMP paths are left as they are, for
+	// variant resolution to map to their desktop pages.
+	NormalizePaths bool
+	// Gets the rules for a url's host, if there are any.
+	DomainRules func(hostname string) (*URLRules, error)
+}
+
+// Normalize url in place, and return it. A nil normalizer uses DefaultURLNormalizer.
+func (n *URLNormalizer) Normalize(url *nurl.URL) *nurl.URL {
 	if url == nil {
 		return nil
 	}
-	v := url.Query()
-	for _, p := range illegalParams {
-		v.Del(p)
+	if n == nil {
+		n = &DefaultURLNormalizer
 	}
-	slog.Debug("CleanURL", "url", url.String(), "rawQuery", url.RawQuery, "to", v.Encode())
-	url.RawQuery = v.Encode()
+	url.Host = normalizeHost(url.Scheme, url.Host)
+	rules := &URLRules{}
+	if n.DomainRules != nil && url.Host != "" {
+		switch r, err := n.DomainRules(url.Hostname()); {
+		case err != nil:
+			slog.Warn("Error loading url rules", "hostname", url.Hostname(), "err", err)
+		case r != nil:
+			rules = r
+		}
+	}
+	if n.NormalizePaths && !rules.KeepPath {
+		url.Path = normalizePath(url.Path)
+		url.RawPath = normalizePath(url.RawPath)
+	}
+	url.RawQuery = normalizeQuery(url.RawQuery, func(name, value string) bool {
+		switch {
+		case matchParam(name, n.TrackingParams), matchParam(name, rules.StripParams):
+		case len(rules.KeepParams) > 0 && !matchParam(name, rules.KeepParams):
+		default:
+			return false
+		}
+		return true
+	})
+	url.ForceQuery = false
 	url.Fragment = ""
+	url.RawFragment = ""
 	return url
 }
+
+// CleanURL normalizes url in place with DefaultURLNormalizer, and returns it: tracking
+// params are removed, along with the fragment, and the host and params are put in
+// canonical form. Use a URLNormalizer to apply other rules.
+func CleanURL(url *nurl.URL) *nurl.URL {
+	return DefaultURLNormalizer.Normalize(url)
+}
+
+func normalizeHost(scheme, host string) string {
+	host = strings.ToLower(host)
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return strings.TrimSuffix(host, ".")
+	}
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	hostname = strings.TrimSuffix(hostname, ".")
+	if strings.Contains(hostname, ":") {
+		hostname = "[" + hostname + "]"
+	}
+	if port == "" {
+		return hostname
+	}
+	return hostname + ":" + port
+}
+
+func normalizePath(path string) string {
+	return strings.TrimRight(path, "/")
+}
+
+// Removes the params from rawQuery that remove returns true for, and sorts the
+// rest by name. The params that are kept aren't decoded and encoded again, which
+// would change params that aren't encoded the usual way, and drop those that
+// can't be parsed, like params separated with ';'.
+func normalizeQuery(rawQuery string, remove func(name, value string) bool) string {
+	type param struct {
+		name, raw string
+	}
+	params := make([]param, 0)
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(raw, "=")
+		if unescaped, err := nurl.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := nurl.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		if !remove(name, value) {
+			params = append(params, param{name, raw})
+		}
+	}
+	slices.SortStableFunc(params, func(a, b param) int { return strings.Compare(a.name, b.name) })
+	var sb strings.Builder
+	for i, p := range params {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(p.raw)
+	}
+	return sb.String()
+}
+
+func matchParam(name string, params []string) bool {
+	name = strings.ToLower(name)
+	for _, p := range params {
+		p = strings.ToLower(p)
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+	return false
+}
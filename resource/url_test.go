@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"encoding/json"
 	nurl "net/url"
 	"testing"
 )
@@ -21,6 +22,16 @@ func TestCleanURL(t *testing.T) {
 		{"https://example.com?utm_source=foo&utm_medium=bar&utm_campaign=baz&utm_term=quux&utm_content=xyzzy&foo=bar", "https://example.com?foo=bar"},
 		{"https://example.com?utm_source=foo&utm_medium=bar&utm_campaign=baz&utm_term=quux&utm_content=xyzzy&foo=bar&baz=quux", "https://example.com?baz=quux&foo=bar"},
 		{"https://example.com?utm_source=foo&utm_medium=bar&utm_campaign=baz&utm_term=quux&utm_content=xyzzy&foo=bar&baz=quux#fragment", "https://example.com?baz=quux&foo=bar"},
+		{"https://example.com/a?utm_id=1&fbclid=x&gclid=y&mc_cid=z&id=2", "https://example.com/a?id=2"},
+		{"https://example.com/a?b=2&a=1", "https://example.com/a?a=1&b=2"},
+		{"https://Example.COM:443/Path/", "https://example.com/Path/"},
+		{"http://example.com:80/", "http://example.com/"},
+		{"http://example.com:8080/a", "http://example.com:8080/a"},
+		{"https://example.com./a", "https://example.com/a"},
+		{"https://example.com/news/story/amp/", "https://example.com/news/story/amp/"},
+		{"https://example.com/a?q=a+b&x=%7e&utm_source=foo", "https://example.com/a?q=a+b&x=%7e"},
+		{"https://example.com/a?b=1;c=2&a=0", "https://example.com/a?a=0&b=1;c=2"},
+		{"https://example.com/a?id=2&id=1&&utm_id=3", "https://example.com/a?id=2&id=1"},
 	}
 	for _, test := range tests {
 		url, _ := nurl.Parse(test.url)
@@ -30,3 +41,73 @@ func TestCleanURL(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizePaths(t *testing.T) {
+	n := &URLNormalizer{TrackingParams: DefaultTrackingParams, NormalizePaths: true}
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://Example.COM:443/Path/", "https://example.com/Path"},
+		{"http://example.com:80/", "http://example.com"},
+		{"https://example.com/news/story/amp/", "https://example.com/news/story/amp"},
+		{"https://example.com/news/story?amp&id=1", "https://example.com/news/story?amp&id=1"},
+		{"https://example.com/news/example/", "https://example.com/news/example"},
+		{"https://example.com/a%2Fb/", "https://example.com/a%2Fb"},
+	}
+	for _, test := range tests {
+		url, _ := nurl.Parse(test.url)
+		if normalized := n.Normalize(url).String(); normalized != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, normalized)
+		}
+	}
+}
+
+func TestURLNormalizerDomainRules(t *testing.T) {
+	rules := map[string]*URLRules{
+		"example.com": {StripParams: []string{"ref", "src_*"}},
+		"example.org": {KeepParams: []string{"id", "page"}, KeepPath: true},
+	}
+	n := &URLNormalizer{
+		TrackingParams: DefaultTrackingParams,
+		NormalizePaths: true,
+		DomainRules: func(hostname string) (*URLRules, error) {
+			return rules[hostname], nil
+		},
+	}
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://example.com/a/?ref=home&src_list=1&id=2", "https://example.com/a?id=2"},
+		{"https://example.org/a/amp/?ref=home&id=2&page=3&utm_source=x", "https://example.org/a/amp/?id=2&page=3"},
+		{"https://example.net/a/?ref=home", "https://example.net/a?ref=home"},
+	}
+	for _, test := range tests {
+		url, _ := nurl.Parse(test.url)
+		if normalized := n.Normalize(url).String(); normalized != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, normalized)
+		}
+	}
+}
+
+func TestURLRulesJSON(t *testing.T) {
+	tests := []struct {
+		json      string
+		expectErr bool
+	}{
+		{`{"strip_params":["ref"],"keep_params":["id","p*"],"keep_path":true}`, false},
+		{`{}`, false},
+		{`{"strip_params":[""]}`, true},
+		{`{"keep_params":["*"]}`, true},
+		{`{"strip_params":["a=b"]}`, true},
+		{`{"strip":["ref"]}`, true},
+	}
+	for _, test := range tests {
+		var r URLRules
+		err := json.Unmarshal([]byte(test.json), &r)
+		if (err != nil) != test.expectErr {
+			t.Errorf("[%s] Expected error %t, got %v", test.json, test.expectErr, err)
+		}
+	}
+}
@@ -4,7 +4,9 @@ package resource
 import (
 	"encoding/json"
 	"errors"
+	"maps"
 	nurl "net/url"
+	"slices"
 	"time"
 )
 
@@ -38,6 +40,9 @@ type WebPage struct { // The page that was requested by the caller
 	RequestedURL *nurl.URL        `json:"-"` // The page that was actually fetched
 	CanonicalURL *nurl.URL        `json:"-"`
 	OriginalURL  string           `json:"original_url,omitempty"` // The canonical URL of the page
+	FinalURL     string           `json:"final_url,omitempty"`    // The URL of the response, after redirects
+	Redirects    []Redirect       `json:"redirects,omitempty"`    // Redirects followed to get the response
+	VariantURL   string           `json:"variant_url,omitempty"`  // AMP or mobile URL the desktop page was resolved from
 	TTL          time.Duration    `json:"-"`                      // Time to live for the resource
 	FetchTime    *time.Time       `json:"fetch_time,omitempty"`   // When the returned source was fetched
 	FetchMethod  ClientIdentifier `json:"fetch_method,omitempty"` // Method used to fetch the page
@@ -61,6 +66,13 @@ type WebPage struct { // The page that was requested by the caller
 	skipMap      map[skippable]bool
 }
 
+// A redirect that was followed when fetching a page: the URL that redirected,
+// and the redirect's status code.
+type Redirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+}
+
 func (r WebPage) ExpireTime() (time.Time, error) {
 	if r.TTL == 0 {
 		return time.Time{}, ErrNoTTL
@@ -73,6 +85,43 @@ func (r WebPage) ExpireTime() (time.Time, error) {
 	return t.Add(r.TTL), nil
 }
 
+// Clone returns a deep copy of the page, which shares no urls, slices, maps
+// or times with the original, so that either one can be changed without
+// changing the other.
+func (r WebPage) Clone() *WebPage {
+	c := r
+	c.RequestedURL = cloneURL(r.RequestedURL)
+	c.CanonicalURL = cloneURL(r.CanonicalURL)
+	c.Redirects = slices.Clone(r.Redirects)
+	c.FetchTime = cloneTime(r.FetchTime)
+	c.Date = cloneTime(r.Date)
+	c.Authors = slices.Clone(r.Authors)
+	c.Categories = slices.Clone(r.Categories)
+	c.Tags = slices.Clone(r.Tags)
+	c.skipMap = maps.Clone(r.skipMap)
+	return &c
+}
+
+func cloneURL(u *nurl.URL) *nurl.URL {
+	if u == nil {
+		return nil
+	}
+	c := *u
+	if u.User != nil {
+		user := *u.User
+		c.User = &user
+	}
+	return &c
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
 func (r *WebPage) ClearSkipWhenMarshaling() {
 	r.skipMap = nil
 }